@@ -2,10 +2,20 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"os"
+	"strconv"
+	"time"
 
 	fiberApi "github.com/fsmiamoto/zcart/cart_service/internal/adapters/fiber_api"
+	"github.com/fsmiamoto/zcart/cart_service/internal/devices"
+	"github.com/fsmiamoto/zcart/cart_service/internal/fiscal"
 	"github.com/fsmiamoto/zcart/cart_service/internal/migrations"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments/pix"
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
 
 	"github.com/rs/zerolog"
@@ -23,30 +33,257 @@ const (
 var (
 	logger  = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	devMode = false
+
+	migrateTo = flag.Int("migrate-to", -1, "migrate the database to the given version and exit")
+	dryRun    = flag.Bool("dry-run", false, "only print the migrations that would run and exit")
+	fixtures  = flag.Bool("fixtures", false, "load the demo catalog after migrating")
 )
 
 func main() {
+	flag.Parse()
+
 	if os.Getenv("DEV_MODE") == "true" {
 		logger.Info().Msgf("Running in Dev Mode")
 		devMode = true
-		os.Remove(DBFILE)
+		if !*dryRun {
+			os.Remove(DBFILE)
+		}
 	}
 
-	db, err := sql.Open("sqlite3", DBFILE)
+	db, err := sql.Open("sqlite3", dataSource())
 	fatalIfErr(err)
 
-	if devMode {
-		fatalIfErr(migrations.Apply(db))
+	migrator, err := migrations.New(db)
+	fatalIfErr(err)
+	migrator.DryRun = *dryRun
+
+	target := migrator.Latest()
+	if *migrateTo >= 0 {
+		target = *migrateTo
 	}
 
-	cartRepo := sqlite.NewCartRepository(db)
-	productRepo := sqlite.NewProductRepository(db)
+	steps, err := migrator.Migrate(target)
+	for _, step := range steps {
+		if *dryRun {
+			logger.Info().Msgf("would apply migration %s", step)
+		} else {
+			logger.Info().Msgf("applied migration %s", step)
+		}
+	}
+	fatalIfErr(err)
 
-	api := fiberApi.New(logger, cartRepo, productRepo)
+	if (devMode || *fixtures) && !*dryRun {
+		fatalIfErr(migrations.LoadFixtures(db))
+	}
+
+	if *dryRun || *migrateTo >= 0 {
+		return
+	}
+
+	promotions := sqlite.NewPromotionRepository(db)
+	taxRules := sqlite.NewTaxRuleRepository(db)
+	calculator := pricing.NewCalculator(promotions, taxRules)
+	reservationTTL := stockReservationTTL()
+
+	api := fiberApi.New(logger, fiberApi.Repositories{
+		Carts:      sqlite.NewCartRepository(db, reservationTTL),
+		Products:   sqlite.NewProductRepository(db),
+		Orders:     sqlite.NewOrderRepository(db, calculator),
+		CartEvents: sqlite.NewCartEventRepository(db, cartEventRetention()),
+		Promotions: promotions,
+		Coupons:    sqlite.NewCouponRepository(db),
+		Payments:   sqlite.NewPaymentRepository(db),
+		Fiscal:     sqlite.NewFiscalRepository(db),
+		TaxRules:   taxRules,
+		Stock:      sqlite.NewStockRepository(db),
+		Pending:    sqlite.NewPendingItemRepository(db, reservationTTL),
+		Weights:    sqlite.NewCartWeightRepository(db),
+		Devices:    sqlite.NewDeviceRepository(db),
+		Heartbeats: sqlite.NewHeartbeatRepository(db),
+	}, calculator, fiberApi.Gateways{
+		Cards: payments.NewFakeGateway(),
+		Pix:   pix.NewGateway(pixConfig(), pixPSP()),
+	}, models.Store{
+		Name:    getenv("STORE_NAME", "ZCART"),
+		Address: os.Getenv("STORE_ADDRESS"),
+		TaxID:   os.Getenv("STORE_TAX_ID"),
+	}, fiscalInvoicer(), recognition.NewVerifier(recognitionConfig()), devices.NewMonitor(healthConfig()))
 
 	fatalIfErr(api.Listen(PORT))
 }
 
+// dataSource opens the database read only on a dry run, which plans every migration
+// on an empty database in memory when there is no database file yet.
+func dataSource() string {
+	if !*dryRun {
+		return DBFILE
+	}
+	if _, err := os.Stat(DBFILE); os.IsNotExist(err) {
+		return ":memory:"
+	}
+	return "file:" + DBFILE + "?mode=ro"
+}
+
+// stockReservationTTL is how long the units in a cart stay reserved after the cart last changed.
+func stockReservationTTL() time.Duration {
+	ttl, err := time.ParseDuration(getenv("STOCK_RESERVATION_TTL", "15m"))
+	fatalIfErr(err)
+	return ttl
+}
+
+// cartEventRetention is how long the events of a cart are kept for clients to replay after the cart was finished.
+func cartEventRetention() time.Duration {
+	retention, err := time.ParseDuration(getenv("CART_EVENT_RETENTION", "168h"))
+	fatalIfErr(err)
+	return retention
+}
+
+// recognitionConfig sets how the detections and weight readings of every cart device are checked.
+func recognitionConfig() recognition.Config {
+	minConfidence, err := strconv.ParseFloat(getenv("DETECTION_MIN_CONFIDENCE", strconv.FormatFloat(recognition.DefaultMinConfidence, 'f', -1, 64)), 64)
+	fatalIfErr(err)
+	pendingItemTTL, err := time.ParseDuration(getenv("PENDING_ITEM_TTL", recognition.DefaultPendingItemTTL.String()))
+	fatalIfErr(err)
+	mismatchTolerance, err := strconv.ParseFloat(getenv("WEIGHT_MISMATCH_TOLERANCE", strconv.Itoa(recognition.DefaultWeightMismatchTolerance)), 64)
+	fatalIfErr(err)
+	mismatchPeriod, err := time.ParseDuration(getenv("WEIGHT_MISMATCH_PERIOD", recognition.DefaultWeightMismatchPeriod.String()))
+	fatalIfErr(err)
+
+	return recognition.Config{
+		MinConfidence:           minConfidence,
+		PendingItemTTL:          pendingItemTTL,
+		WeightMismatchTolerance: mismatchTolerance,
+		WeightMismatchPeriod:    mismatchPeriod,
+	}
+}
+
+// healthConfig sets when the devices are taken offline or degraded, and for how long their heartbeats are kept.
+func healthConfig() devices.HealthConfig {
+	offlineAfter, err := time.ParseDuration(getenv("DEVICE_OFFLINE_AFTER", devices.DefaultOfflineAfter.String()))
+	fatalIfErr(err)
+	retention, err := time.ParseDuration(getenv("HEARTBEAT_RETENTION", devices.DefaultHeartbeatRetention.String()))
+	fatalIfErr(err)
+	minCameraFPS, err := strconv.ParseFloat(getenv("DEVICE_MIN_CAMERA_FPS", strconv.Itoa(devices.DefaultMinCameraFPS)), 64)
+	fatalIfErr(err)
+	maxDetectorLatency, err := strconv.ParseFloat(getenv("DEVICE_MAX_DETECTOR_LATENCY_MS", strconv.Itoa(devices.DefaultMaxDetectorLatencyMs)), 64)
+	fatalIfErr(err)
+	minBattery, err := strconv.ParseFloat(getenv("DEVICE_MIN_BATTERY_PERCENT", strconv.Itoa(devices.DefaultMinBatteryPercent)), 64)
+	fatalIfErr(err)
+
+	return devices.HealthConfig{
+		OfflineAfter:         offlineAfter,
+		HeartbeatRetention:   retention,
+		MinCameraFPS:         minCameraFPS,
+		MaxDetectorLatencyMs: maxDetectorLatency,
+		MinBatteryPercent:    minBattery,
+	}
+}
+
+// pixConfig refuses the calls to the webhook when no secret is set, so no one but the PSP
+// confirms payments. In dev mode, where they are confirmed by hand, a demo secret is used.
+func pixConfig() pix.Config {
+	secret := os.Getenv("PIX_WEBHOOK_SECRET")
+	if devMode {
+		secret = getenv("PIX_WEBHOOK_SECRET", "dev-webhook-secret")
+	} else if secret == "" {
+		logger.Warn().Msg("PIX_WEBHOOK_SECRET is not set, PIX payments will not be confirmed")
+	}
+
+	expiry, err := time.ParseDuration(getenv("PIX_CHARGE_EXPIRY", pix.DefaultChargeExpiry.String()))
+	fatalIfErr(err)
+
+	return pix.Config{
+		Key:           getenv("PIX_KEY", "pix@zcart.com.br"),
+		MerchantName:  getenv("PIX_MERCHANT_NAME", "ZCART"),
+		MerchantCity:  getenv("PIX_MERCHANT_CITY", "SAO PAULO"),
+		WebhookSecret: secret,
+		ChargeExpiry:  expiry,
+	}
+}
+
+// pixPSP is only set in dev mode, where dynamic codes are issued by a stub.
+// Otherwise the codes are static, for the key of the store.
+func pixPSP() pix.PSP {
+	if devMode {
+		return pix.NewStubPSP()
+	}
+	return nil
+}
+
+// fiscalInvoicer issues NFC-e when a certificate is configured. In dev mode they are
+// issued for a demo store, signed with a throwaway certificate and authorized by a stub.
+func fiscalInvoicer() *fiscal.Invoicer {
+	path := os.Getenv("FISCAL_CERTIFICATE")
+	if path == "" && !devMode {
+		logger.Info().Msg("FISCAL_CERTIFICATE is not set, NFC-e will not be issued")
+		return nil
+	}
+
+	// Demo values are only used in dev mode, a missing setting failing otherwise
+	setting := func(key string, demo string) string {
+		if devMode {
+			return getenv(key, demo)
+		}
+		return os.Getenv(key)
+	}
+
+	series, err := strconv.ParseUint(getenv("FISCAL_SERIES", "1"), 10, 32)
+	fatalIfErr(err)
+	environment, err := strconv.Atoi(getenv("FISCAL_ENVIRONMENT", strconv.Itoa(int(fiscal.Homologation))))
+	fatalIfErr(err)
+
+	config := fiscal.Config{
+		Issuer: fiscal.Issuer{
+			CNPJ:      setting("FISCAL_CNPJ", "11222333000181"),
+			IE:        setting("FISCAL_IE", "111111111111"),
+			Name:      setting("FISCAL_NAME", "ZCART COMERCIO LTDA"),
+			TradeName: getenv("FISCAL_TRADE_NAME", getenv("STORE_NAME", "ZCART")),
+			Street:    setting("FISCAL_STREET", "Av. Paulista"),
+			Number:    setting("FISCAL_NUMBER", "1000"),
+			District:  setting("FISCAL_DISTRICT", "Bela Vista"),
+			CityCode:  setting("FISCAL_CITY_CODE", "3550308"),
+			City:      setting("FISCAL_CITY", "Sao Paulo"),
+			UF:        setting("FISCAL_UF", "SP"),
+			CEP:       setting("FISCAL_CEP", "01310100"),
+		},
+		Series:      uint(series),
+		Environment: fiscal.Environment(environment),
+		CSCID:       setting("FISCAL_CSC_ID", "000001"),
+		CSC:         setting("FISCAL_CSC", "0123456789"),
+		QRCodeURL:   setting("FISCAL_QRCODE_URL", "https://www.homologacao.nfce.fazenda.sp.gov.br/NFCeConsultaPublica/Paginas/ConsultaQRCode.aspx"),
+		ConsultURL:  setting("FISCAL_CONSULT_URL", "https://www.homologacao.nfce.fazenda.sp.gov.br/NFCeConsultaPublica"),
+	}
+
+	var certificate *fiscal.Certificate
+	if path != "" {
+		certificate, err = fiscal.LoadCertificate(path, os.Getenv("FISCAL_CERTIFICATE_PASSWORD"))
+	} else {
+		certificate, err = fiscal.SelfSignedCertificate(config.Issuer.Name + ":" + config.Issuer.CNPJ)
+	}
+	fatalIfErr(err)
+
+	invoicer, err := fiscal.NewInvoicer(config, certificate, fiscalSEFAZ())
+	fatalIfErr(err)
+
+	return invoicer
+}
+
+// fiscalSEFAZ is only set in dev mode, where documents are authorized by a stub.
+// Otherwise they are kept signed until there is a SEFAZ to send them to.
+func fiscalSEFAZ() fiscal.SEFAZ {
+	if devMode {
+		return fiscal.NewStubSEFAZ()
+	}
+	return nil
+}
+
+func getenv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func fatalIfErr(err error) {
 	if err != nil {
 		logger.Fatal().Err(err).Msg("")
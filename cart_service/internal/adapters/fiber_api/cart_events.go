@@ -0,0 +1,145 @@
+package fiber_api
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/events"
+	"github.com/gofiber/fiber/v2"
+)
+
+// cartEventsPruneInterval is how often the events of the carts finished past their retention are deleted.
+const cartEventsPruneInterval = time.Hour
+
+// cartEventStream is the event source shared by the websocket and SSE endpoints.
+// It yields the events a resuming client missed, a snapshot of the cart and then
+// live ones, skipping anything published while those were being loaded that was already sent.
+type cartEventStream struct {
+	sub *events.Subscription[CartEventWebsocketNotification]
+	// backlog ends with a cart_snapshot event
+	backlog      []CartEventWebsocketNotification
+	lastSequence uint64
+	cartVersion  uint64
+}
+
+// openCartEventStream subscribes to the cart, loads the events published after since
+// when resume is set and the current state of the cart. Callers must close the stream.
+func (h *Handler) openCartEventStream(cartId string, since uint64, resume bool) (*cartEventStream, error) {
+	// Each stream gets its own subscription, so other clients
+	// of the same cart are not affected when this one goes away.
+	// Subscribing before loading the backlog means nothing published in between is lost.
+	stream := &cartEventStream{
+		sub:          h.broker.Subscribe(cartId),
+		lastSequence: since,
+	}
+
+	if resume {
+		backlog, err := h.eventsSince(cartId, since)
+		if err != nil {
+			h.broker.Unsubscribe(stream.sub)
+			return nil, err
+		}
+
+		stream.backlog = backlog
+		if len(backlog) > 0 {
+			stream.lastSequence = backlog[len(backlog)-1].Sequence
+		}
+
+		h.logger.Printf("replaying %d events of cart %s since %d", len(backlog), cartId, since)
+	}
+
+	cart, err := h.pricedCart(cartId)
+	if err != nil {
+		h.broker.Unsubscribe(stream.sub)
+		return nil, err
+	}
+
+	// The snapshot goes last so clients applying events in order end up with the current cart
+	stream.backlog = append(stream.backlog, CartEventWebsocketNotification{
+		Event: CartSnapshotEvent,
+		Cart:  cart,
+	})
+	stream.cartVersion = cart.Version
+
+	return stream, nil
+}
+
+func (h *Handler) closeCartEventStream(stream *cartEventStream) {
+	h.broker.Unsubscribe(stream.sub)
+}
+
+func (s *cartEventStream) Events() <-chan CartEventWebsocketNotification {
+	return s.sub.Events()
+}
+
+// accept reports whether a live notification should be forwarded to the client.
+func (s *cartEventStream) accept(notification CartEventWebsocketNotification) bool {
+	if notification.Cart != nil && notification.Cart.Version < s.cartVersion {
+		// Already reflected in the snapshot
+		return false
+	}
+	if notification.Sequence == 0 {
+		// Not persisted, so it can't have been replayed
+		return true
+	}
+	if notification.Sequence <= s.lastSequence {
+		return false
+	}
+	s.lastSequence = notification.Sequence
+	return true
+}
+
+// eventsSince loads the persisted notifications of the cart published after the since cursor.
+func (h *Handler) eventsSince(cartId string, since uint64) ([]CartEventWebsocketNotification, error) {
+	stored, err := h.eventRepo.ListSince(cartId, since, maxReplayedEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]CartEventWebsocketNotification, 0, len(stored))
+	for _, event := range stored {
+		var notification CartEventWebsocketNotification
+		if err := json.Unmarshal(event.Payload, &notification); err != nil {
+			return nil, err
+		}
+		notification.Sequence = event.Sequence
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// parseCursor reads a resume cursor, an empty value meaning the client is not resuming.
+func parseCursor(value string) (since uint64, resume bool, err error) {
+	if value == "" {
+		return 0, false, nil
+	}
+
+	since, err = strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, errors.New("since must be a non-negative integer")
+	}
+
+	return since, true, nil
+}
+
+func (h *Handler) EventMetrics(ctx *fiber.Ctx) error {
+	return ctx.JSON(h.broker.Metrics())
+}
+
+// pruneCartEvents deletes the events of the carts finished past their retention, every interval.
+func (h *Handler) pruneCartEvents(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pruned, err := h.eventRepo.PruneEvents(time.Now().UTC())
+		if err != nil {
+			h.logger.Err(err).Msg("failed to prune cart events")
+		} else if pruned > 0 {
+			h.logger.Info().Msgf("Pruned %d cart events", pruned)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package fiber_api
+
+import (
+	"fmt"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/ids"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) CreateCart(ctx *fiber.Ctx) error {
+	var request CreateCartRequest
+
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			return newError(fiber.StatusBadRequest, err)
+		}
+	}
+
+	if request.ID == "" {
+		request.ID = ids.New()
+	}
+
+	cart, err := h.cartRepo.CreateCart(request.ID)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("CreateCart: %s", cart.ID)
+
+	return ctx.Status(fiber.StatusCreated).JSON(cart)
+}
+
+func (h *Handler) UpdateCartStatus(ctx *fiber.Ctx) error {
+	var request UpdateCartStatusRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	id := ctx.Params("id")
+
+	cart, err := h.cartRepo.GetCart(id)
+	if err != nil {
+		return err
+	}
+
+	if cart.Status != request.Status {
+		if !cart.Status.CanTransitionTo(request.Status) {
+			return fmt.Errorf("%w: %s to %s", repository.ErrInvalidCartTransition, cart.Status, request.Status)
+		}
+
+		if err := h.cartRepo.UpdateCartStatus(id, cart.Status, request.Status); err != nil {
+			return err
+		}
+
+		h.logger.Info().Msgf("UpdateCartStatus: %s from %s to %s", id, cart.Status, request.Status)
+
+		if request.Status != models.CartOpen && request.Status != models.CartCheckingOut {
+			h.releaseDevice(id)
+		}
+	}
+
+	updated, err := h.pricedCart(id)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(updated)
+}
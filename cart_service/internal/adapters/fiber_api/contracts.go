@@ -2,13 +2,26 @@ package fiber_api
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
 )
 
 var (
 	ErrInvalidId    = errors.New("invalid cart id")
-	ErrCartNotFound = errors.New("cart not found")
+	ErrCartNotFound = repository.ErrCartNotFound
+	// ErrCartChanged means the cart total moved between authorizing the payment and placing the order
+	ErrCartChanged = errors.New("cart changed during checkout, retry with a new idempotency key")
+	// ErrIdempotencyKeyReused means the key was already used to check out another cart
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for another cart")
+	// ErrOrderNotCompleted means the payment of the order was not captured, so it has no receipt or NFC-e
+	ErrOrderNotCompleted = errors.New("order is not completed")
 )
 
 type UpdateProductsRequestAction string
@@ -37,17 +50,460 @@ func (u *UpdateProductsRequest) Validate() error {
 	return nil
 }
 
+type CreateCartRequest struct {
+	ID string `json:"id"`
+}
+
+type UpdateCartStatusRequest struct {
+	Status models.CartStatus `json:"status"`
+}
+
+func (u *UpdateCartStatusRequest) Validate() error {
+	if u.Status == "" {
+		return errors.New("missing status")
+	}
+	if !u.Status.Valid() {
+		return fmt.Errorf("unknown status %q", u.Status)
+	}
+	return nil
+}
+
+const (
+	defaultProductsPageSize = 20
+	maxProductsPageSize     = 100
+)
+
+// ProductRequest takes the price either as a money object or,
+// as older clients send it, a decimal amount in the default currency.
+type ProductRequest struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description *string      `json:"description"`
+	Price       *money.Money `json:"price"`
+	ImageURL    *string      `json:"image_url"`
+	// TaxClass defaults to models.DefaultTaxClass
+	TaxClass string `json:"tax_class"`
+	// Fiscal defaults to a national product sold without ICMS credit
+	Fiscal *models.ProductFiscal `json:"fiscal"`
+	// Recognition defaults to a product the cart can't recognize
+	Recognition *models.ProductRecognition `json:"recognition"`
+}
+
+func (p *ProductRequest) Validate() error {
+	if p.ID == "" {
+		return errors.New("missing product id")
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("missing name")
+	}
+	if p.Price == nil {
+		return errors.New("missing price")
+	}
+	if p.Price.IsNegative() {
+		return errors.New("price must not be negative")
+	}
+	if err := validateCurrency("price", *p.Price); err != nil {
+		return err
+	}
+	if p.TaxClass != "" && !validTaxClass(p.TaxClass) {
+		return errTaxClass
+	}
+	if err := validateProductRecognition(p.Recognition); err != nil {
+		return err
+	}
+	return validateProductFiscal(p.Fiscal)
+}
+
+func (p *ProductRequest) ToProduct() models.Product {
+	taxClass := p.TaxClass
+	if taxClass == "" {
+		taxClass = models.DefaultTaxClass
+	}
+
+	return models.Product{
+		ID:          p.ID,
+		Name:        strings.TrimSpace(p.Name),
+		Description: p.Description,
+		Price:       *p.Price,
+		ImageURL:    p.ImageURL,
+		TaxClass:    taxClass,
+		Fiscal:      withFiscalDefaults(p.Fiscal),
+		Recognition: withRecognitionDefaults(p.Recognition),
+	}
+}
+
+// PatchProductRequest only carries the fields that should change,
+// the fiscal and recognition data being replaced as a whole.
+type PatchProductRequest struct {
+	Name        *string                    `json:"name"`
+	Description *string                    `json:"description"`
+	Price       *money.Money               `json:"price"`
+	ImageURL    *string                    `json:"image_url"`
+	TaxClass    *string                    `json:"tax_class"`
+	Fiscal      *models.ProductFiscal      `json:"fiscal"`
+	Recognition *models.ProductRecognition `json:"recognition"`
+}
+
+func (p *PatchProductRequest) Validate() error {
+	if p.Name != nil && strings.TrimSpace(*p.Name) == "" {
+		return errors.New("name must not be empty")
+	}
+	if p.Price != nil && p.Price.IsNegative() {
+		return errors.New("price must not be negative")
+	}
+	if p.Price != nil {
+		if err := validateCurrency("price", *p.Price); err != nil {
+			return err
+		}
+	}
+	if p.TaxClass != nil && !validTaxClass(*p.TaxClass) {
+		return errTaxClass
+	}
+	if err := validateProductRecognition(p.Recognition); err != nil {
+		return err
+	}
+	return validateProductFiscal(p.Fiscal)
+}
+
+func (p *PatchProductRequest) Apply(product *models.Product) {
+	if p.Name != nil {
+		product.Name = strings.TrimSpace(*p.Name)
+	}
+	if p.Description != nil {
+		product.Description = p.Description
+	}
+	if p.Price != nil {
+		product.Price = *p.Price
+	}
+	if p.ImageURL != nil {
+		product.ImageURL = p.ImageURL
+	}
+	if p.TaxClass != nil {
+		product.TaxClass = *p.TaxClass
+	}
+	if p.Fiscal != nil {
+		product.Fiscal = withFiscalDefaults(p.Fiscal)
+	}
+	if p.Recognition != nil {
+		product.Recognition = withRecognitionDefaults(p.Recognition)
+	}
+}
+
+// validateCurrency only lets in amounts in a supported currency, as the carts can't mix currencies.
+// Amounts without one take the default currency.
+func validateCurrency(field string, amount money.Money) error {
+	if amount.Currency != "" && !amount.Currency.Supported() {
+		return fmt.Errorf("%s must be in %s, not %q", field, money.DefaultCurrency, amount.Currency)
+	}
+	return nil
+}
+
+// csosns are the ICMS situations the NFC-e can be issued with, stores in the Simples Nacional
+// not passing on ICMS credit to consumers.
+var csosns = map[string]bool{"102": true, "103": true, "300": true, "400": true, "500": true}
+
+// validateProductFiscal allows the NCM to be left empty, products without one can't be invoiced though.
+func validateProductFiscal(fiscal *models.ProductFiscal) error {
+	if fiscal == nil {
+		return nil
+	}
+	if fiscal.NCM != "" && !isDigits(fiscal.NCM, 8) {
+		return errors.New("ncm must have 8 digits")
+	}
+	if fiscal.CEST != "" && !isDigits(fiscal.CEST, 7) {
+		return errors.New("cest must have 7 digits")
+	}
+	if fiscal.CFOP != "" && (!isDigits(fiscal.CFOP, 4) || fiscal.CFOP[0] != '5') {
+		return errors.New("cfop must have 4 digits, starting with 5 for sales within the state")
+	}
+	if fiscal.Origin > 8 {
+		return errors.New("origin must be between 0 and 8")
+	}
+	if fiscal.CSOSN != "" && !csosns[fiscal.CSOSN] {
+		return fmt.Errorf("csosn %q is not supported", fiscal.CSOSN)
+	}
+	if len(fiscal.Unit) > 6 {
+		return errors.New("unit must have at most 6 characters")
+	}
+	return nil
+}
+
+func withFiscalDefaults(fiscal *models.ProductFiscal) *models.ProductFiscal {
+	defaults := models.DefaultProductFiscal()
+	if fiscal == nil {
+		return defaults
+	}
+
+	withDefaults := *fiscal
+	if withDefaults.CFOP == "" {
+		withDefaults.CFOP = defaults.CFOP
+	}
+	if withDefaults.CSOSN == "" {
+		withDefaults.CSOSN = defaults.CSOSN
+	}
+	if withDefaults.Unit = strings.ToUpper(strings.TrimSpace(withDefaults.Unit)); withDefaults.Unit == "" {
+		withDefaults.Unit = defaults.Unit
+	}
+	return &withDefaults
+}
+
+func validateProductRecognition(recognition *models.ProductRecognition) error {
+	if recognition == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(recognition.Labels))
+	for _, label := range recognition.Labels {
+		if !validLabel(label) {
+			return fmt.Errorf("label %q must have up to 64 lowercase letters, digits, - or _", label)
+		}
+		if seen[label] {
+			return fmt.Errorf("label %q is repeated", label)
+		}
+		seen[label] = true
+	}
+
+	if recognition.WeightGrams != nil && *recognition.WeightGrams == 0 {
+		return errors.New("weight_grams must be positive")
+	}
+	if recognition.WeightTolerance > 100 {
+		return errors.New("weight_tolerance must be at most 100 percent")
+	}
+	return nil
+}
+
+// withRecognitionDefaults gives products without a tolerance the default one.
+func withRecognitionDefaults(recognition *models.ProductRecognition) *models.ProductRecognition {
+	withDefaults := models.ProductRecognition{WeightTolerance: models.DefaultWeightTolerance}
+	if recognition != nil {
+		withDefaults = *recognition
+	}
+
+	withDefaults.Labels = append(make([]string, 0, len(withDefaults.Labels)), withDefaults.Labels...)
+	sort.Strings(withDefaults.Labels)
+	if withDefaults.WeightTolerance == 0 {
+		withDefaults.WeightTolerance = models.DefaultWeightTolerance
+	}
+	return &withDefaults
+}
+
+// validLabel accepts the labels the detector outputs, such as coke_soda.
+func validLabel(label string) bool {
+	if label == "" || len(label) > 64 {
+		return false
+	}
+	for _, r := range label {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// RecognitionEntry tells the recognizer which product a detector label stands for and how much a unit of it weighs.
+type RecognitionEntry struct {
+	Label           string `json:"label"`
+	ProductID       string `json:"product_id"`
+	Name            string `json:"name"`
+	WeightGrams     *uint  `json:"weight_grams"`
+	WeightTolerance uint   `json:"weight_tolerance"`
+}
+
+type ListProductsResponse struct {
+	Products []models.Product `json:"products"`
+	Total    int              `json:"total"`
+	Limit    int              `json:"limit"`
+	Offset   int              `json:"offset"`
+}
+
+// DetectionsRequest carries what a cart device saw change in the cart, along with the scale readings around the change.
+type DetectionsRequest struct {
+	Detections []recognition.Detection `json:"detections"`
+	recognition.Weighing
+}
+
+func (d *DetectionsRequest) Validate() error {
+	if len(d.Detections) == 0 {
+		return errors.New("missing detections")
+	}
+
+	labels := make(map[string]bool, len(d.Detections))
+	for _, detection := range d.Detections {
+		if detection.Label == "" {
+			return errors.New("missing detection label")
+		}
+		if labels[detection.Label] {
+			return fmt.Errorf("label %q is detected more than once", detection.Label)
+		}
+		labels[detection.Label] = true
+
+		if detection.Count == 0 {
+			return fmt.Errorf("missing count of %q", detection.Label)
+		}
+		if detection.Confidence < 0 || detection.Confidence > 1 {
+			return fmt.Errorf("confidence of %q must be between 0 and 1", detection.Label)
+		}
+	}
+
+	return nil
+}
+
+// DetectionsResponse tells the device what was made of each detection and how the cart ended up.
+type DetectionsResponse struct {
+	*recognition.Result
+	// Pending holds the items created for the detections the shopper is asked about
+	Pending []*models.PendingItem `json:"pending"`
+	Cart    *CartResponse         `json:"cart"`
+}
+
+// WeightReadingRequest is a reading of the scale of a cart, in grams.
+type WeightReadingRequest struct {
+	Grams *float64 `json:"grams"`
+}
+
+func (w *WeightReadingRequest) Validate() error {
+	if w.Grams == nil {
+		return errors.New("missing grams")
+	}
+	return nil
+}
+
+const (
+	DeviceIDHeader     = "X-Device-ID"
+	DeviceSecretHeader = "X-Device-Secret"
+)
+
+type RegisterDeviceRequest struct {
+	ID string `json:"id"`
+	// Name defaults to the id
+	Name string `json:"name"`
+}
+
+func (r *RegisterDeviceRequest) Validate() error {
+	if strings.TrimSpace(r.ID) == "" {
+		return errors.New("missing id")
+	}
+	return nil
+}
+
+// RegisterDeviceResponse is the only response to carry the secret of the device.
+type RegisterDeviceResponse struct {
+	*models.Device
+	Secret string `json:"secret"`
+}
+
+// BindCartRequest starts the shopping session of a device on a new cart when no cart is given.
+type BindCartRequest struct {
+	CartID string `json:"cart_id"`
+}
+
+// HeartbeatRequest is the status of the components of the device sending it, leaving out the
+// ones it could not read.
+type HeartbeatRequest struct {
+	CameraFPS         *float64 `json:"camera_fps"`
+	DetectorLatencyMs *float64 `json:"detector_latency_ms"`
+	ScaleGrams        *float64 `json:"scale_grams"`
+	BatteryPercent    *float64 `json:"battery_percent"`
+}
+
+func (h *HeartbeatRequest) Validate() error {
+	if h.CameraFPS != nil && *h.CameraFPS < 0 {
+		return errors.New("camera_fps must not be negative")
+	}
+	if h.DetectorLatencyMs != nil && *h.DetectorLatencyMs < 0 {
+		return errors.New("detector_latency_ms must not be negative")
+	}
+	if h.BatteryPercent != nil && (*h.BatteryPercent < 0 || *h.BatteryPercent > 100) {
+		return errors.New("battery_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// DeviceStatus is a device along with what is wrong with it as of its last heartbeat.
+type DeviceStatus struct {
+	*models.Device
+	Problems      []string          `json:"problems"`
+	LastHeartbeat *models.Heartbeat `json:"last_heartbeat"`
+}
+
+// FleetResponse counts the devices by health.
+type FleetResponse struct {
+	Online   int             `json:"online"`
+	Degraded int             `json:"degraded"`
+	Offline  int             `json:"offline"`
+	Devices  []*DeviceStatus `json:"devices"`
+}
+
+// PendingItemResponse is a pending item along with the cart after it was resolved.
+type PendingItemResponse struct {
+	PendingItem *models.PendingItem `json:"pending_item"`
+	Cart        *CartResponse       `json:"cart"`
+}
+
 // WebSocket
+const maxReplayedEvents = 1000
+
 const (
 	ProductAddedEvent   = "product_added"
 	ProductRemovedEvent = "product_removed"
+	CouponAppliedEvent  = "coupon_applied"
+	CouponRemovedEvent  = "coupon_removed"
+	// PendingItemAddedEvent asks the shopper to confirm or reject a change the cart device was not sure about
+	PendingItemAddedEvent = "pending_item_added"
+	// PendingItemResolvedEvent tells the item was confirmed, rejected or expired, a confirmed one being
+	// followed by the change to the cart
+	PendingItemResolvedEvent = "pending_item_resolved"
+	// WeightMismatchEvent raises an anomaly as the scale of the cart kept disagreeing with its lines
+	WeightMismatchEvent = "weight_mismatch"
+	// WeightReconciledEvent resolves the anomaly as the scale agrees with the lines again
+	WeightReconciledEvent = "weight_reconciled"
+	// DeviceOnlineEvent, DeviceDegradedEvent and DeviceOfflineEvent tell the staff, and the clients of the
+	// cart the device is bound to, that a device changed health
+	DeviceOnlineEvent   = "device_online"
+	DeviceDegradedEvent = "device_degraded"
+	DeviceOfflineEvent  = "device_offline"
+	// CartSnapshotEvent carries only the current cart, sent on connect and when the cart is replaced as a whole
+	CartSnapshotEvent = "cart_snapshot"
 )
 
 type CartEvent string
 
 type CartEventWebsocketNotification struct {
+	// CartProduct holds the requested change, its Quantity being the delta
 	CartProduct *models.CartProduct `json:"cart_product"`
 	Event       CartEvent           `json:"event"`
+	// Sequence is 0 when the event was not persisted
+	Sequence uint64 `json:"sequence"`
+	// LineQuantity is the quantity of the product in the cart after the change
+	LineQuantity uint `json:"line_quantity"`
+	// Cart is the whole cart after the change
+	Cart *CartResponse `json:"cart"`
+	// CouponCode is set for coupon events
+	CouponCode string `json:"coupon_code,omitempty"`
+	// PendingItem is set for pending item events
+	PendingItem *models.PendingItem `json:"pending_item,omitempty"`
+	// Anomaly is set for weight events
+	Anomaly *models.CartAnomaly `json:"anomaly,omitempty"`
+	// Device is set for device events
+	Device *DeviceStatus `json:"device,omitempty"`
+}
+
+// CartResponse is a cart along with the totals computed by the server.
+type CartResponse struct {
+	*models.Cart
+	*models.CartTotals
 }
 
 func updateProductsActionToCartEvent(action UpdateProductsRequestAction) CartEvent {
@@ -56,3 +512,350 @@ func updateProductsActionToCartEvent(action UpdateProductsRequestAction) CartEve
 	}
 	return ProductAddedEvent
 }
+
+// PromotionRequest creates a promotion, the id being generated when missing.
+type PromotionRequest struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Kind        models.PromotionKind `json:"kind"`
+	ProductID   string               `json:"product_id"`
+	Percent     uint                 `json:"percent"`
+	Amount      money.Money          `json:"amount"`
+	BuyQuantity uint                 `json:"buy_quantity"`
+	GetQuantity uint                 `json:"get_quantity"`
+	StartsAt    *time.Time           `json:"starts_at"`
+	EndsAt      *time.Time           `json:"ends_at"`
+}
+
+func (p *PromotionRequest) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("missing name")
+	}
+	if p.ProductID == "" {
+		return errors.New("missing product id")
+	}
+	if !p.Kind.Valid() {
+		return fmt.Errorf("invalid kind %q", p.Kind)
+	}
+
+	switch p.Kind {
+	case models.PromotionPercentage:
+		if p.Percent == 0 || p.Percent > 100 {
+			return errors.New("percent must be between 1 and 100")
+		}
+	case models.PromotionFixed:
+		if p.Amount.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+	case models.PromotionBuyXGetY:
+		if p.BuyQuantity == 0 || p.GetQuantity == 0 {
+			return errors.New("buy_quantity and get_quantity must be positive")
+		}
+	case models.PromotionMultiBuy:
+		if p.BuyQuantity < 2 {
+			return errors.New("buy_quantity must be at least 2")
+		}
+		if p.Amount.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+	}
+
+	if err := validateCurrency("amount", p.Amount); err != nil {
+		return err
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	return nil
+}
+
+func (p *PromotionRequest) ToPromotion() *models.Promotion {
+	if p.Amount.Currency == "" {
+		p.Amount.Currency = money.DefaultCurrency
+	}
+
+	return &models.Promotion{
+		ID:          p.ID,
+		Name:        strings.TrimSpace(p.Name),
+		Kind:        p.Kind,
+		ProductID:   p.ProductID,
+		Percent:     p.Percent,
+		Amount:      p.Amount,
+		BuyQuantity: p.BuyQuantity,
+		GetQuantity: p.GetQuantity,
+		StartsAt:    p.StartsAt,
+		EndsAt:      p.EndsAt,
+	}
+}
+
+// TaxRuleRequest creates a tax rule, the id being generated when missing. Taxes are inclusive unless told otherwise.
+type TaxRuleRequest struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	TaxClass  string         `json:"tax_class"`
+	Tax       models.TaxKind `json:"tax"`
+	Rate      uint           `json:"rate"`
+	Inclusive *bool          `json:"inclusive"`
+}
+
+func (r *TaxRuleRequest) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("missing name")
+	}
+	if !validTaxClass(r.TaxClass) {
+		return errTaxClass
+	}
+	if !r.Tax.Valid() {
+		return fmt.Errorf("invalid tax %q", r.Tax)
+	}
+	if r.Rate == 0 || r.Rate > 10000 {
+		return errors.New("rate must be between 1 and 10000 basis points")
+	}
+	return nil
+}
+
+func (r *TaxRuleRequest) ToTaxRule() *models.TaxRule {
+	inclusive := r.Inclusive == nil || *r.Inclusive
+
+	return &models.TaxRule{
+		ID:        r.ID,
+		Name:      strings.TrimSpace(r.Name),
+		TaxClass:  r.TaxClass,
+		Tax:       r.Tax,
+		Rate:      r.Rate,
+		Inclusive: inclusive,
+	}
+}
+
+var errTaxClass = errors.New("tax class must have up to 32 lowercase letters, digits, - or _")
+
+func validTaxClass(class string) bool {
+	if class == "" || len(class) > 32 {
+		return false
+	}
+	for _, r := range class {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// TrackStockRequest starts tracking the stock of a product, with no units on hand until some are received.
+type TrackStockRequest struct {
+	LowStockThreshold *uint `json:"low_stock_threshold"`
+}
+
+func (t *TrackStockRequest) Validate() error {
+	if t.LowStockThreshold == nil {
+		return errors.New("missing low_stock_threshold")
+	}
+	return nil
+}
+
+// StockAdjustmentRequest adds units to the stock of a product when delta is positive and removes them when negative.
+type StockAdjustmentRequest struct {
+	Delta  int                `json:"delta"`
+	Reason models.StockReason `json:"reason"`
+	Note   *string            `json:"note"`
+}
+
+func (s *StockAdjustmentRequest) Validate() error {
+	if s.Reason == models.StockSale {
+		return errors.New("sales are only recorded by placing orders")
+	}
+	if !s.Reason.Valid() {
+		return fmt.Errorf("invalid reason %q", s.Reason)
+	}
+	if s.Delta == 0 {
+		return errors.New("missing delta")
+	}
+
+	switch s.Reason {
+	case models.StockReceived, models.StockReturned:
+		if s.Delta < 0 {
+			return fmt.Errorf("delta must be positive for %s units", s.Reason)
+		}
+	case models.StockShrinkage, models.StockDamaged:
+		if s.Delta > 0 {
+			return fmt.Errorf("delta must be negative for %s", s.Reason)
+		}
+	}
+
+	return nil
+}
+
+func (s *StockAdjustmentRequest) ToStockAdjustment(productId string) *models.StockAdjustment {
+	var note *string
+	if s.Note != nil && strings.TrimSpace(*s.Note) != "" {
+		trimmed := strings.TrimSpace(*s.Note)
+		note = &trimmed
+	}
+
+	return &models.StockAdjustment{
+		ProductID: productId,
+		Delta:     s.Delta,
+		Reason:    s.Reason,
+		Note:      note,
+	}
+}
+
+type StockAdjustmentResponse struct {
+	Adjustment *models.StockAdjustment `json:"adjustment"`
+	Stock      *models.StockLevel      `json:"stock"`
+}
+
+// CouponRequest creates a coupon, codes being case insensitive.
+type CouponRequest struct {
+	Code                 string            `json:"code"`
+	Description          string            `json:"description"`
+	Kind                 models.CouponKind `json:"kind"`
+	Percent              uint              `json:"percent"`
+	Amount               money.Money       `json:"amount"`
+	MinimumBasket        money.Money       `json:"minimum_basket"`
+	MaxUses              uint              `json:"max_uses"`
+	SingleUsePerCustomer bool              `json:"single_use_per_customer"`
+	ExpiresAt            *time.Time        `json:"expires_at"`
+}
+
+func (c *CouponRequest) Validate() error {
+	if normalizeCouponCode(c.Code) == "" {
+		return errors.New("missing code")
+	}
+	if !c.Kind.Valid() {
+		return fmt.Errorf("invalid kind %q", c.Kind)
+	}
+
+	switch c.Kind {
+	case models.CouponPercentage:
+		if c.Percent == 0 || c.Percent > 100 {
+			return errors.New("percent must be between 1 and 100")
+		}
+	case models.CouponFixed:
+		if c.Amount.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+	}
+
+	if c.MinimumBasket.IsNegative() {
+		return errors.New("minimum_basket must not be negative")
+	}
+
+	if err := validateCurrency("amount", c.Amount); err != nil {
+		return err
+	}
+	if err := validateCurrency("minimum_basket", c.MinimumBasket); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *CouponRequest) ToCoupon() *models.Coupon {
+	if c.Amount.Currency == "" {
+		c.Amount.Currency = money.DefaultCurrency
+	}
+	if c.MinimumBasket.Currency == "" {
+		c.MinimumBasket.Currency = c.Amount.Currency
+	}
+
+	return &models.Coupon{
+		Code:                 normalizeCouponCode(c.Code),
+		Description:          strings.TrimSpace(c.Description),
+		Kind:                 c.Kind,
+		Percent:              c.Percent,
+		Amount:               c.Amount,
+		MinimumBasket:        c.MinimumBasket,
+		MaxUses:              c.MaxUses,
+		SingleUsePerCustomer: c.SingleUsePerCustomer,
+		ExpiresAt:            c.ExpiresAt,
+	}
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code"`
+	// CustomerID is required by coupons that can only be used once per customer
+	CustomerID *string `json:"customer_id"`
+}
+
+func (a *ApplyCouponRequest) Validate() error {
+	if normalizeCouponCode(a.Code) == "" {
+		return errors.New("missing code")
+	}
+	if a.CustomerID != nil && strings.TrimSpace(*a.CustomerID) == "" {
+		return errors.New("customer_id must not be empty")
+	}
+	return nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type CheckoutRequest struct {
+	PaymentMethod string `json:"payment_method"`
+	// IdempotencyKey may also be sent in the Idempotency-Key header
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+func (c *CheckoutRequest) Validate() error {
+	if strings.TrimSpace(c.PaymentMethod) == "" {
+		return errors.New("missing payment method")
+	}
+	return nil
+}
+
+// CheckoutResponse has no order yet when it carries a PIX charge for the customer to pay.
+type CheckoutResponse struct {
+	*models.Order
+	Payment    *models.PaymentIntent `json:"payment"`
+	Pix        *PixChargeResponse    `json:"pix,omitempty"`
+	ReceiptURL string                `json:"receipt_url,omitempty"`
+}
+
+func newCheckoutResponse(order *models.Order, payment *models.PaymentIntent) CheckoutResponse {
+	return CheckoutResponse{
+		Order:      order,
+		Payment:    payment,
+		ReceiptURL: fmt.Sprintf("/orders/%s/receipt", order.ID),
+	}
+}
+
+type PixChargeResponse struct {
+	TxID string `json:"txid"`
+	// BRCode is the copy and paste code
+	BRCode string `json:"brcode"`
+	// QRCode is a data URI of the PNG image
+	QRCode string `json:"qr_code"`
+}
+
+const PixWebhookSecretHeader = "X-Webhook-Secret"
+
+// PixWebhookRequest is sent by the PSP when PIX payments are received, in the format of the BCB API.
+type PixWebhookRequest struct {
+	Pix []PixReceived `json:"pix"`
+}
+
+type PixReceived struct {
+	EndToEndID string `json:"endToEndId"`
+	TxID       string `json:"txid"`
+	// Amount is a decimal string in reais
+	Amount string    `json:"valor"`
+	Time   time.Time `json:"horario"`
+}
+
+func (p *PixWebhookRequest) Validate() error {
+	for _, received := range p.Pix {
+		if received.TxID == "" {
+			return errors.New("missing txid")
+		}
+		if received.Amount == "" {
+			return errors.New("missing valor")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package fiber_api
+
+import (
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) ListCoupons(ctx *fiber.Ctx) error {
+	coupons, err := h.couponRepo.ListCoupons()
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(coupons)
+}
+
+func (h *Handler) GetCoupon(ctx *fiber.Ctx) error {
+	coupon, err := h.couponRepo.GetCoupon(normalizeCouponCode(ctx.Params("code")))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(coupon)
+}
+
+func (h *Handler) CreateCoupon(ctx *fiber.Ctx) error {
+	var request CouponRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	coupon := request.ToCoupon()
+	if err := h.couponRepo.CreateCoupon(coupon); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("CreateCoupon: %s (%s)", coupon.Code, coupon.Kind)
+
+	return ctx.Status(fiber.StatusCreated).JSON(coupon)
+}
+
+// ApplyCoupon checks the coupon against the cart as it is now. The checks are
+// repeated at checkout, where a coupon that no longer applies is left out.
+func (h *Handler) ApplyCoupon(ctx *fiber.Ctx) error {
+	var request ApplyCouponRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	cartId := ctx.Params("cart_id")
+
+	coupon, err := h.couponRepo.GetCoupon(normalizeCouponCode(request.Code))
+	if err != nil {
+		return err
+	}
+
+	cart, err := h.pricedCart(cartId)
+	if err != nil {
+		return err
+	}
+
+	if coupon.SingleUsePerCustomer {
+		if request.CustomerID == nil {
+			return newError(fiber.StatusUnprocessableEntity, pricing.ErrCouponNeedsCustomer)
+		}
+
+		used, err := h.couponRepo.UsedByCustomer(coupon.Code, *request.CustomerID)
+		if err != nil {
+			return err
+		}
+		if used {
+			return newError(fiber.StatusUnprocessableEntity, pricing.ErrCouponAlreadyUsed)
+		}
+	}
+
+	if err := pricing.CheckCoupon(coupon, time.Now(), cart.Total); err != nil {
+		return newError(fiber.StatusUnprocessableEntity, err)
+	}
+
+	if err := h.couponRepo.ApplyToCart(cartId, coupon.Code, request.CustomerID); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("ApplyCoupon: %s to cart %s", coupon.Code, cartId)
+
+	return h.respondWithCouponEvent(ctx, cartId, coupon.Code, CouponAppliedEvent)
+}
+
+func (h *Handler) RemoveCoupon(ctx *fiber.Ctx) error {
+	cartId := ctx.Params("cart_id")
+	code := normalizeCouponCode(ctx.Params("code"))
+
+	if err := h.couponRepo.RemoveFromCart(cartId, code); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("RemoveCoupon: %s from cart %s", code, cartId)
+
+	return h.respondWithCouponEvent(ctx, cartId, code, CouponRemovedEvent)
+}
+
+// respondWithCouponEvent reprices the cart, letting subscribers know about the new totals.
+func (h *Handler) respondWithCouponEvent(ctx *fiber.Ctx, cartId string, code string, event CartEvent) error {
+	cart, err := h.pricedCart(cartId)
+	if err != nil {
+		return err
+	}
+
+	h.publish(cartId, CartEventWebsocketNotification{
+		Event:      event,
+		Cart:       cart,
+		CouponCode: code,
+	})
+
+	return ctx.JSON(cart)
+}
@@ -0,0 +1,140 @@
+package fiber_api
+
+import (
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PostDetections applies to the cart the changes seen by its device that the catalog and the scale agree with.
+// The shopper is asked about the detections of known products that were not accepted, which wait as pending
+// items. Either way the device learns what was made of each detection from their verdicts.
+func (h *Handler) PostDetections(ctx *fiber.Ctx) error {
+	var request DetectionsRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	cartId := ctx.Params("cart_id")
+
+	current, err := h.cartRepo.GetCart(cartId)
+	if err != nil {
+		return err
+	}
+	if current.Status != models.CartOpen {
+		return repository.ErrCartNotOpen
+	}
+
+	catalog, err := h.labelCatalog(request.Detections)
+	if err != nil {
+		return err
+	}
+
+	result := h.verifier.Verify(request.Detections, request.Weighing, catalog)
+
+	for _, decision := range result.Decisions {
+		h.logger.Info().Msgf(
+			"PostDetections: cart %s, %d of %q (%.2f) is %s, expected %.0fg and measured %.0fg",
+			cartId, decision.Count, decision.Label, decision.Confidence, decision.Verdict, result.ExpectedGrams, result.MeasuredGrams,
+		)
+	}
+
+	for _, decision := range result.Decisions {
+		if !decision.Accepted() {
+			continue
+		}
+		if err := h.applyDetection(cartId, decision, catalog[decision.Label]); err != nil {
+			return err
+		}
+	}
+
+	pending, err := h.holdPendingItems(cartId, result)
+	if err != nil {
+		return err
+	}
+
+	cart, err := h.pricedCart(cartId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(DetectionsResponse{Result: result, Pending: pending, Cart: cart})
+}
+
+// holdPendingItems saves the detections the shopper is asked about and lets the clients of the cart know.
+func (h *Handler) holdPendingItems(cartId string, result *recognition.Result) ([]*models.PendingItem, error) {
+	pending := make([]*models.PendingItem, 0)
+
+	for _, item := range h.verifier.PendingItems(cartId, result, time.Now().UTC()) {
+		if err := h.pendingRepo.CreatePendingItem(item); err != nil {
+			return nil, err
+		}
+
+		// Loads the name of the product along with the item
+		item, err := h.pendingRepo.GetPendingItem(cartId, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, item)
+
+		h.logger.Info().Msgf("PostDetections: pending item %s for %d of product %s in cart %s (%s)", item.ID, item.Quantity, item.ProductID, cartId, item.Reason)
+
+		h.publish(cartId, CartEventWebsocketNotification{
+			Event:       PendingItemAddedEvent,
+			PendingItem: item,
+		})
+	}
+
+	return pending, nil
+}
+
+// labelCatalog finds the products recognized by the labels of the detections, leaving the unknown labels out.
+func (h *Handler) labelCatalog(detections []recognition.Detection) (map[string]*models.Product, error) {
+	catalog := make(map[string]*models.Product, len(detections))
+
+	for _, detection := range detections {
+		products, err := h.productRepo.ListProducts(repository.ProductFilter{Label: detection.Label, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(products) > 0 {
+			catalog[detection.Label] = &products[0]
+		}
+	}
+
+	return catalog, nil
+}
+
+// applyDetection changes the quantity of the product by the detected count, just like a request to update the cart.
+func (h *Handler) applyDetection(cartId string, decision *recognition.Decision, product *models.Product) error {
+	action, quantity := AddProductAction, decision.Count
+	if quantity < 0 {
+		action, quantity = RemoveProductAction, -quantity
+	}
+
+	if err := h.processAction(cartId, product.ID, uint(quantity), action); err != nil {
+		return err
+	}
+
+	cart, err := h.pricedCart(cartId)
+	if err != nil {
+		return err
+	}
+
+	h.notify(&models.CartProduct{
+		CartID:    cartId,
+		ProductID: product.ID,
+		Quantity:  uint(quantity),
+		Product:   *product,
+	}, cart, action)
+
+	return nil
+}
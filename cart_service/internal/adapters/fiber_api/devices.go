@@ -0,0 +1,158 @@
+package fiber_api
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/devices"
+	"github.com/fsmiamoto/zcart/cart_service/internal/ids"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+var errInvalidDeviceCredentials = errors.New("invalid device credentials")
+
+func (h *Handler) ListDevices(ctx *fiber.Ctx) error {
+	devices, err := h.deviceRepo.ListDevices()
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(devices)
+}
+
+// RegisterDevice registers a physical cart, handing out the secret it authenticates with.
+func (h *Handler) RegisterDevice(ctx *fiber.Ctx) error {
+	var request RegisterDeviceRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	secret, hash := devices.NewSecret()
+
+	device := &models.Device{
+		ID:         strings.TrimSpace(request.ID),
+		Name:       strings.TrimSpace(request.Name),
+		SecretHash: hash,
+		Health:     models.DeviceOffline,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if device.Name == "" {
+		device.Name = device.ID
+	}
+
+	if err := h.deviceRepo.CreateDevice(device); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("RegisterDevice: %s", device.ID)
+
+	return ctx.Status(fiber.StatusCreated).JSON(RegisterDeviceResponse{Device: device, Secret: secret})
+}
+
+func (h *Handler) GetDevice(ctx *fiber.Ctx) error {
+	device, err := h.deviceRepo.GetDevice(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(device)
+}
+
+// BindDeviceCart starts a shopping session on the device, either on the given cart or on a new one.
+func (h *Handler) BindDeviceCart(ctx *fiber.Ctx) error {
+	var request BindCartRequest
+
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			return newError(fiber.StatusBadRequest, err)
+		}
+	}
+
+	deviceId := ctx.Params("id")
+
+	// Fail before creating a cart for nothing
+	if _, err := h.deviceRepo.GetDevice(deviceId); err != nil {
+		return err
+	}
+
+	if request.CartID == "" {
+		cart, err := h.cartRepo.CreateCart(ids.New())
+		if err != nil {
+			return err
+		}
+		request.CartID = cart.ID
+	}
+
+	device, err := h.deviceRepo.BindCart(deviceId, request.CartID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("BindDeviceCart: device %s to cart %s", device.ID, request.CartID)
+
+	return ctx.JSON(device)
+}
+
+func (h *Handler) UnbindDeviceCart(ctx *fiber.Ctx) error {
+	device, err := h.deviceRepo.UnbindCart(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("UnbindDeviceCart: device %s", device.ID)
+
+	return ctx.JSON(device)
+}
+
+// DeviceCart tells the device calling it which cart to operate on.
+func (h *Handler) DeviceCart(ctx *fiber.Ctx) error {
+	device, err := h.authenticateDevice(ctx)
+	if err != nil {
+		return err
+	}
+
+	if device.CartID == nil {
+		return repository.ErrDeviceNotBound
+	}
+
+	cart, err := h.pricedCart(*device.CartID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(cart)
+}
+
+// authenticateDevice loads the device identified by the headers of the request, as long as they carry its secret.
+func (h *Handler) authenticateDevice(ctx *fiber.Ctx) (*models.Device, error) {
+	device, err := h.deviceRepo.GetDevice(ctx.Get(DeviceIDHeader))
+	if err != nil && !errors.Is(err, repository.ErrDeviceNotFound) {
+		return nil, err
+	}
+
+	if !devices.Authenticate(device, ctx.Get(DeviceSecretHeader)) {
+		return nil, newError(fiber.StatusUnauthorized, errInvalidDeviceCredentials)
+	}
+
+	return device, nil
+}
+
+// releaseDevice ends the shopping session of the device bound to the cart, which waits for the next shopper.
+func (h *Handler) releaseDevice(cartId string) {
+	released, err := h.deviceRepo.ReleaseCart(cartId)
+	if err != nil {
+		h.logger.Err(err).Msgf("failed to release the device of cart %s", cartId)
+		return
+	}
+	if released {
+		h.logger.Info().Msgf("Released the device of cart %s", cartId)
+	}
+}
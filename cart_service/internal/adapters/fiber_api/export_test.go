@@ -0,0 +1,17 @@
+package fiber_api
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// App exposes the routes of the handler to the tests, without listening on a port.
+func (h *Handler) App() *fiber.App {
+	return h.app
+}
+
+// ExpirePixCharges expires the PIX charges as the ticker would at now.
+func (h *Handler) ExpirePixCharges(now time.Time) {
+	h.expirePixChargesAt(now)
+}
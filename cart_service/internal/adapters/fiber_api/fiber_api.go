@@ -1,12 +1,24 @@
 package fiber_api
 
 import (
+	"encoding/json"
 	"errors"
+	"sync"
 
+	"github.com/fsmiamoto/zcart/cart_service/internal/devices"
+	"github.com/fsmiamoto/zcart/cart_service/internal/events"
+	"github.com/fsmiamoto/zcart/cart_service/internal/fiscal"
+	"github.com/fsmiamoto/zcart/cart_service/internal/ids"
 	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments/pix"
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/websocket/v2"
 	"github.com/rs/zerolog"
 )
@@ -15,21 +27,95 @@ import (
 // Refactor into the appropriate Application/Domain services
 
 type Handler struct {
-	app         *fiber.App
-	logger      zerolog.Logger
-	channels    map[string]chan CartEventWebsocketNotification
-	cartRepo    repository.CartRepository
-	productRepo repository.ProductRepository
+	app           *fiber.App
+	logger        zerolog.Logger
+	broker        *events.Broker[CartEventWebsocketNotification]
+	publishMu     sync.Mutex
+	cartRepo      repository.CartRepository
+	productRepo   repository.ProductRepository
+	orderRepo     repository.OrderRepository
+	eventRepo     repository.CartEventRepository
+	promotionRepo repository.PromotionRepository
+	couponRepo    repository.CouponRepository
+	paymentRepo   repository.PaymentRepository
+	fiscalRepo    repository.FiscalRepository
+	taxRuleRepo   repository.TaxRuleRepository
+	stockRepo     repository.StockRepository
+	pendingRepo   repository.PendingItemRepository
+	weightRepo    repository.CartWeightRepository
+	deviceRepo    repository.DeviceRepository
+	heartbeatRepo repository.HeartbeatRepository
+	calculator    *pricing.Calculator
+	gateway       payments.PaymentGateway
+	pix           *pix.Gateway
+	store         models.Store
+	invoicer      *fiscal.Invoicer
+	verifier      *recognition.Verifier
+	monitor       *devices.Monitor
 }
 
-func New(logger zerolog.Logger, cartRepo repository.CartRepository, productRepo repository.ProductRepository) *Handler {
+type Repositories struct {
+	Carts      repository.CartRepository
+	Products   repository.ProductRepository
+	Orders     repository.OrderRepository
+	CartEvents repository.CartEventRepository
+	Promotions repository.PromotionRepository
+	Coupons    repository.CouponRepository
+	Payments   repository.PaymentRepository
+	Fiscal     repository.FiscalRepository
+	TaxRules   repository.TaxRuleRepository
+	Stock      repository.StockRepository
+	Pending    repository.PendingItemRepository
+	Weights    repository.CartWeightRepository
+	Devices    repository.DeviceRepository
+	Heartbeats repository.HeartbeatRepository
+}
+
+// Gateways take the payments, Pix being optional.
+type Gateways struct {
+	Cards payments.PaymentGateway
+	Pix   *pix.Gateway
+}
+
+// New builds the API, issuing NFC-e for the orders placed only when given an invoicer.
+// The detections of the cart devices are checked by the verifier and their heartbeats by the monitor.
+func New(
+	logger zerolog.Logger, repos Repositories, calculator *pricing.Calculator, gateways Gateways,
+	store models.Store, invoicer *fiscal.Invoicer, verifier *recognition.Verifier, monitor *devices.Monitor,
+) *Handler {
+	broker := events.NewBroker[CartEventWebsocketNotification](events.Options{
+		BufferSize: events.DefaultBufferSize,
+		Policy:     events.DropOldest,
+	})
+
 	handler := &Handler{
-		app:         fiber.New(),
-		logger:      logger,
-		channels:    make(map[string]chan CartEventWebsocketNotification),
-		cartRepo:    cartRepo,
-		productRepo: productRepo,
+		app:           fiber.New(fiber.Config{ErrorHandler: errorHandler}),
+		logger:        logger,
+		broker:        broker,
+		cartRepo:      repos.Carts,
+		productRepo:   repos.Products,
+		orderRepo:     repos.Orders,
+		eventRepo:     repos.CartEvents,
+		promotionRepo: repos.Promotions,
+		couponRepo:    repos.Coupons,
+		paymentRepo:   repos.Payments,
+		fiscalRepo:    repos.Fiscal,
+		taxRuleRepo:   repos.TaxRules,
+		stockRepo:     repos.Stock,
+		pendingRepo:   repos.Pending,
+		weightRepo:    repos.Weights,
+		deviceRepo:    repos.Devices,
+		heartbeatRepo: repos.Heartbeats,
+		calculator:    calculator,
+		gateway:       gateways.Cards,
+		pix:           gateways.Pix,
+		store:         store,
+		invoicer:      invoicer,
+		verifier:      verifier,
+		monitor:       monitor,
 	}
+	// A bug in one request must not take the service down with it
+	handler.app.Use(recover.New())
 	handler.app.Use(cors.New())
 	handler.RegisterEndpoints()
 
@@ -37,20 +123,177 @@ func New(logger zerolog.Logger, cartRepo repository.CartRepository, productRepo
 }
 
 func (h *Handler) Listen(addr string) error {
+	go h.expirePendingItems(pendingItemsExpiryInterval)
+	go h.monitorFleet(fleetMonitorInterval)
+	go h.pruneCartEvents(cartEventsPruneInterval)
+	if h.pix != nil {
+		go h.expirePixCharges(pixChargesExpiryInterval)
+	}
 	return h.app.Listen(addr)
 }
 
 func (h *Handler) RegisterEndpoints() {
 	h.app.Get("/cart/:id/ws", h.WebsocketHandler, websocket.New(h.WebsocketManager))
+	h.app.Get("/cart/:id/events", h.ServerSentEvents)
 	h.app.Get("/cart/:id", h.GetCart)
+	h.app.Post("/carts", h.CreateCart)
+	h.app.Get("/carts/:id", h.GetCart)
+	h.app.Patch("/carts/:id", h.UpdateCartStatus)
+	h.app.Get("/carts/:id/orders", h.ListCartOrders)
+
+	h.app.Get("/orders/:id", h.GetOrder)
+	h.app.Get("/orders/:id/payments", h.ListOrderPayments)
+	h.app.Get("/orders/:id/receipt", h.GetOrderReceipt)
+	h.app.Get("/orders/:id/nfce", h.GetOrderNFCe)
+	h.app.Post("/orders/:id/nfce", h.IssueOrderNFCe)
+	h.app.Post("/webhooks/pix", h.PixWebhook)
 	h.app.Post("/cart/:cart_id/products", h.UpdateProducts)
+	h.app.Post("/cart/:cart_id/detections", h.PostDetections)
+	h.app.Get("/cart/:cart_id/pending", h.ListPendingItems)
+	h.app.Post("/cart/:cart_id/pending/:pending_id/confirm", h.ConfirmPendingItem)
+	h.app.Post("/cart/:cart_id/pending/:pending_id/reject", h.RejectPendingItem)
+	h.app.Get("/cart/:cart_id/weight", h.GetCartWeight)
+	h.app.Post("/cart/:cart_id/weight", h.PostCartWeight)
 	h.app.Post("/cart/:cart_id/checkout", h.Checkout)
+	h.app.Post("/cart/:cart_id/coupons", h.ApplyCoupon)
+	h.app.Delete("/cart/:cart_id/coupons/:code", h.RemoveCoupon)
+
+	h.app.Get("/metrics/events", h.EventMetrics)
+
+	h.app.Get("/anomalies", h.ListAnomalies)
+	h.app.Get("/staff/events", h.StaffEvents)
+
+	// Registered before /devices/:id so that "me" is not taken for an id
+	h.app.Get("/devices/me/cart", h.DeviceCart)
+	h.app.Post("/devices/me/heartbeat", h.PostHeartbeat)
+	h.app.Get("/devices", h.ListDevices)
+	h.app.Post("/devices", h.RegisterDevice)
+	h.app.Get("/devices/:id", h.GetDevice)
+	h.app.Put("/devices/:id/cart", h.BindDeviceCart)
+	h.app.Delete("/devices/:id/cart", h.UnbindDeviceCart)
+	h.app.Get("/devices/:id/heartbeats", h.ListHeartbeats)
+	h.app.Get("/fleet", h.Fleet)
+
+	h.app.Get("/products", h.ListProducts)
+	h.app.Post("/products", h.CreateProduct)
+	h.app.Get("/products/:id", h.GetProduct)
+	h.app.Put("/products/:id", h.ReplaceProduct)
+	h.app.Patch("/products/:id", h.PatchProduct)
+	h.app.Delete("/products/:id", h.DeleteProduct)
+	h.app.Get("/catalog/recognition", h.RecognitionCatalog)
+
+	h.app.Get("/promotions", h.ListPromotions)
+	h.app.Post("/promotions", h.CreatePromotion)
+	h.app.Get("/promotions/:id", h.GetPromotion)
+	h.app.Delete("/promotions/:id", h.DeletePromotion)
+
+	h.app.Get("/tax-rules", h.ListTaxRules)
+	h.app.Post("/tax-rules", h.CreateTaxRule)
+	h.app.Get("/tax-rules/:id", h.GetTaxRule)
+	h.app.Delete("/tax-rules/:id", h.DeleteTaxRule)
+
+	h.app.Get("/stock", h.ListStock)
+	h.app.Get("/stock/alerts", h.ListStockAlerts)
+	h.app.Get("/stock/:product_id", h.GetStock)
+	h.app.Put("/stock/:product_id", h.TrackStock)
+	h.app.Get("/stock/:product_id/adjustments", h.ListStockAdjustments)
+	h.app.Post("/stock/:product_id/adjustments", h.AdjustStock)
+
+	h.app.Get("/coupons", h.ListCoupons)
+	h.app.Post("/coupons", h.CreateCoupon)
+	h.app.Get("/coupons/:code", h.GetCoupon)
+}
+
+// pricedCart loads the cart and prices it, so every endpoint and event reports the same amounts.
+func (h *Handler) pricedCart(cartId string) (*CartResponse, error) {
+	cart, err := h.cartRepo.GetCart(cartId)
+	if err != nil {
+		return nil, err
+	}
+
+	if cart.Products == nil {
+		cart.Products = make([]*models.CartProduct, 0)
+	}
+
+	cartCoupons, err := h.couponRepo.ListCartCoupons(cartId)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := h.calculator.Price(cart.Products, models.CouponsOf(cartCoupons))
+	if err != nil {
+		return nil, err
+	}
+
+	return &CartResponse{Cart: cart, CartTotals: totals}, nil
 }
 
 func newError(status int, err error) error {
 	return fiber.NewError(status, err.Error())
 }
 
+// errorHandler translates domain errors returned by the handlers
+// into the matching HTTP status before delegating to fiber.
+func errorHandler(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrCartNotFound),
+		errors.Is(err, repository.ErrProductNotFound),
+		errors.Is(err, repository.ErrOrderNotFound),
+		errors.Is(err, repository.ErrPromotionNotFound),
+		errors.Is(err, repository.ErrCouponNotFound),
+		errors.Is(err, repository.ErrCouponNotApplied),
+		errors.Is(err, repository.ErrPaymentNotFound),
+		errors.Is(err, repository.ErrFiscalDocumentNotFound),
+		errors.Is(err, repository.ErrTaxRuleNotFound),
+		errors.Is(err, repository.ErrStockNotFound),
+		errors.Is(err, repository.ErrPendingItemNotFound),
+		errors.Is(err, repository.ErrCartWeightNotFound),
+		errors.Is(err, repository.ErrDeviceNotFound),
+		errors.Is(err, repository.ErrDeviceNotBound):
+		err = newError(fiber.StatusNotFound, err)
+	case errors.Is(err, repository.ErrCartAlreadyExists),
+		errors.Is(err, repository.ErrCartNotOpen),
+		errors.Is(err, repository.ErrCartNotCheckingOut),
+		errors.Is(err, repository.ErrOrderNotPending),
+		errors.Is(err, repository.ErrInvalidCartTransition),
+		errors.Is(err, repository.ErrProductAlreadyExists),
+		errors.Is(err, repository.ErrLabelTaken),
+		errors.Is(err, repository.ErrPromotionAlreadyExists),
+		errors.Is(err, repository.ErrCouponAlreadyExists),
+		errors.Is(err, repository.ErrCouponAlreadyApplied),
+		errors.Is(err, repository.ErrPaymentAlreadyExists),
+		errors.Is(err, repository.ErrFiscalDocumentAlreadyExists),
+		errors.Is(err, repository.ErrTaxRuleAlreadyExists),
+		errors.Is(err, repository.ErrPendingItemResolved),
+		errors.Is(err, repository.ErrDeviceAlreadyExists),
+		errors.Is(err, repository.ErrCartAlreadyBound),
+		errors.Is(err, ErrCartChanged),
+		errors.Is(err, ErrOrderNotCompleted):
+		err = newError(fiber.StatusConflict, err)
+	case errors.Is(err, repository.ErrCartEmpty),
+		errors.Is(err, pricing.ErrCouponExpired),
+		errors.Is(err, pricing.ErrCouponExhausted),
+		errors.Is(err, pricing.ErrCouponMinimumBasket),
+		errors.Is(err, pricing.ErrCouponAlreadyUsed),
+		errors.Is(err, pricing.ErrCouponNeedsCustomer),
+		errors.Is(err, repository.ErrInsufficientStock),
+		errors.Is(err, money.ErrCurrencyMismatch),
+		errors.Is(err, fiscal.ErrProductFiscalData):
+		err = newError(fiber.StatusUnprocessableEntity, err)
+	case errors.Is(err, payments.ErrDeclined):
+		err = newError(fiber.StatusPaymentRequired, err)
+	case errors.Is(err, payments.ErrUnavailable),
+		errors.Is(err, fiscal.ErrSEFAZUnavailable):
+		err = newError(fiber.StatusBadGateway, err)
+	}
+
+	return fiber.DefaultErrorHandler(ctx, err)
+}
+
+// Checkout places the order once its payment is authorized, capturing the payment right
+// before the order is saved. Retrying with the same idempotency key resumes the same
+// payment instead of charging the customer again. The cart is checking out, and can't be
+// changed, from the start of the payment until it is paid or the payment fails.
 func (h *Handler) Checkout(ctx *fiber.Ctx) error {
 	cartId := ctx.Params("cart_id")
 
@@ -58,13 +301,79 @@ func (h *Handler) Checkout(ctx *fiber.Ctx) error {
 		return newError(fiber.StatusBadRequest, errors.New("missing cart id"))
 	}
 
+	var request CheckoutRequest
+
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			return newError(fiber.StatusBadRequest, err)
+		}
+	}
+
+	if key := ctx.Get(IdempotencyKeyHeader); key != "" {
+		request.IdempotencyKey = key
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	// Without a key the client can't retry, so the attempt gets its own
+	if request.IdempotencyKey == "" {
+		request.IdempotencyKey = ids.New()
+	}
+
 	h.logger.Info().Msgf("Checkout: %s", cartId)
 
-	if err := h.cartRepo.EmptyCart(cartId); err != nil {
+	payment, err := h.startPayment(cartId, request)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if payment.Status == models.PaymentCaptured {
+		order, err := h.orderRepo.GetOrder(payment.OrderID)
+		if err != nil {
+			return err
+		}
+		return ctx.JSON(newCheckoutResponse(order, payment))
+	}
+
+	// PIX payments are made by the customer after checkout, the order being placed once the PSP confirms it
+	if payment.Gateway == pix.Method && payment.Status == models.PaymentPending {
+		return h.chargeWithPix(ctx, payment)
+	}
+
+	order, err := h.payForOrder(payment)
+	if err != nil {
+		return err
+	}
+
+	h.orderPlaced(order, payment)
+
+	return ctx.Status(fiber.StatusCreated).JSON(newCheckoutResponse(order, payment))
+}
+
+// orderPlaced issues the NFC-e of the order and lets the clients of the cart know it was emptied by
+// the order. The order stands even if its NFC-e fails, it can be issued again later.
+func (h *Handler) orderPlaced(order *models.Order, payment *models.PaymentIntent) {
+	h.logger.Info().Msgf("Checkout: cart %s created order %s with total %s %s", order.CartID, order.ID, order.Total.Currency, order.Total)
+
+	if h.invoicer != nil {
+		if _, err := h.issueNFCe(order, payment); err != nil {
+			h.logger.Err(err).Msgf("failed to issue the NFC-e of order %s", order.ID)
+		}
+	}
+
+	// The next shopper starts over on a new cart
+	h.releaseDevice(order.CartID)
+
+	if cart, err := h.pricedCart(order.CartID); err == nil {
+		h.publish(order.CartID, CartEventWebsocketNotification{
+			Event: CartSnapshotEvent,
+			Cart:  cart,
+		})
+	} else {
+		h.logger.Err(err).Msgf("failed to load cart %s after checkout", order.CartID)
+	}
 }
 
 func (h *Handler) UpdateProducts(ctx *fiber.Ctx) error {
@@ -97,7 +406,12 @@ func (h *Handler) UpdateProducts(ctx *fiber.Ctx) error {
 		Product:   product,
 	}
 
-	h.notify(cp, request.Action)
+	cart, err := h.pricedCart(cartId)
+	if err != nil {
+		return err
+	}
+
+	h.notify(cp, cart, request.Action)
 
 	return nil
 }
@@ -110,17 +424,13 @@ func (h *Handler) GetCart(ctx *fiber.Ctx) error {
 
 	h.logger.Printf("GetCart: %s", id)
 
-	cart, err := h.cartRepo.GetCart(id)
+	cart, err := h.pricedCart(id)
 	if err != nil {
 		return err
 	}
 
 	h.logger.Printf("Cart length: %d", len(cart.Products))
 
-	if cart.Products == nil {
-		cart.Products = make([]*models.CartProduct, 0)
-	}
-
 	return ctx.JSON(cart)
 }
 
@@ -136,7 +446,8 @@ func (h *Handler) processAction(cartId string, productId string, quantity uint,
 	return h.cartRepo.UpdateProductQuantity(cartId, productId, delta)
 }
 
-func (h *Handler) notify(cartProduct *models.CartProduct, action UpdateProductsRequestAction) {
+// notify publishes a change to the cart along with its state after the change.
+func (h *Handler) notify(cartProduct *models.CartProduct, cart *CartResponse, action UpdateProductsRequestAction) {
 	if cartProduct == nil {
 		return
 	}
@@ -144,14 +455,34 @@ func (h *Handler) notify(cartProduct *models.CartProduct, action UpdateProductsR
 	notification := CartEventWebsocketNotification{
 		Event:       updateProductsActionToCartEvent(action),
 		CartProduct: cartProduct,
+		Cart:        cart,
+	}
+	if line := cart.Line(cartProduct.ProductID); line != nil {
+		notification.LineQuantity = line.Quantity
 	}
 
-	select {
-	case h.channels[cartProduct.CartID] <- notification:
-		// success
-		h.logger.Printf("notified cart %s", cartProduct.CartID)
-	default:
-		// blocked
-		h.logger.Printf("ignoring notification for cart %s: channel full", cartProduct.CartID)
+	h.publish(cartProduct.CartID, notification)
+}
+
+// publish persists the notification with the next sequence number of the cart,
+// so reconnecting clients can replay it, and then fans it out to live subscribers.
+func (h *Handler) publish(cartId string, notification CartEventWebsocketNotification) {
+	// Keeps live subscribers receiving events in sequence order
+	h.publishMu.Lock()
+	defer h.publishMu.Unlock()
+
+	payload, err := json.Marshal(notification)
+	if err == nil {
+		notification.Sequence, err = h.eventRepo.Append(cartId, string(notification.Event), payload)
+	}
+	if err != nil {
+		h.logger.Err(err).Msgf("failed to persist event for cart %s", cartId)
+	}
+
+	delivered, dropped := h.broker.Publish(cartId, notification)
+
+	h.logger.Printf("notified %d subscribers of cart %s", delivered, cartId)
+	if dropped > 0 {
+		h.logger.Printf("dropped notification for %d subscribers of cart %s: buffer full", dropped, cartId)
 	}
 }
@@ -0,0 +1,100 @@
+package fiber_api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrFiscalDisabled = errors.New("fiscal documents are not enabled")
+
+// GetOrderNFCe returns the XML of the NFC-e of the order, along with its protocol once authorized.
+func (h *Handler) GetOrderNFCe(ctx *fiber.Ctx) error {
+	document, err := h.fiscalRepo.GetDocument(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s-nfce.xml"`, document.AccessKey))
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+
+	return ctx.Send(document.XML)
+}
+
+// IssueOrderNFCe issues the NFC-e of an order that has none, as when issuing it after checkout
+// failed, or sends a document the SEFAZ could not be reached for again.
+func (h *Handler) IssueOrderNFCe(ctx *fiber.Ctx) error {
+	if h.invoicer == nil {
+		return newError(fiber.StatusServiceUnavailable, ErrFiscalDisabled)
+	}
+
+	order, err := h.orderRepo.GetOrder(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	if order.Status != models.OrderCompleted {
+		return ErrOrderNotCompleted
+	}
+
+	document, err := h.fiscalRepo.GetDocument(order.ID)
+	switch {
+	case errors.Is(err, repository.ErrFiscalDocumentNotFound):
+		var payment *models.PaymentIntent
+		if payment, err = h.capturedPayment(order.ID); err == nil {
+			document, err = h.issueNFCe(order, payment)
+		}
+	case err == nil && document.Status == models.FiscalSigned:
+		err = h.authorizeNFCe(document)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(document)
+}
+
+// issueNFCe numbers, signs and stores the NFC-e of the order before having it authorized.
+// A document the SEFAZ could not be reached for is kept signed, to be sent again.
+func (h *Handler) issueNFCe(order *models.Order, payment *models.PaymentIntent) (*models.FiscalDocument, error) {
+	products := make(map[string]*models.ProductFiscal, len(order.Lines))
+	for _, line := range order.Lines {
+		product, err := h.productRepo.GetProduct(line.ProductID)
+		if errors.Is(err, repository.ErrProductNotFound) {
+			// Deleted since, the invoicer tells it has no fiscal data
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		products[line.ProductID] = product.Fiscal
+	}
+
+	document, err := h.fiscalRepo.CreateDocument(order.ID, h.invoicer.Series(), func(number uint) (*models.FiscalDocument, error) {
+		return h.invoicer.Issue(order, products, payment, number)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Info().Msgf("NFC-e: issued %s for order %s", document.AccessKey, order.ID)
+
+	return document, h.authorizeNFCe(document)
+}
+
+func (h *Handler) authorizeNFCe(document *models.FiscalDocument) error {
+	if err := h.invoicer.Authorize(document); err != nil {
+		return err
+	}
+
+	if err := h.fiscalRepo.UpdateDocument(document); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("NFC-e: %s %s: %s", document.AccessKey, document.Status, *document.StatusMessage)
+
+	return nil
+}
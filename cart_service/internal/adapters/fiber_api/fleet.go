@@ -0,0 +1,206 @@
+package fiber_api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// fleetMonitorInterval is how often the devices that stopped sending heartbeats are taken offline.
+	fleetMonitorInterval = 5 * time.Second
+
+	defaultHeartbeatsPageSize = 60
+	maxHeartbeatsPageSize     = 1000
+)
+
+var deviceHealthEvents = map[models.DeviceHealth]CartEvent{
+	models.DeviceOnline:   DeviceOnlineEvent,
+	models.DeviceDegraded: DeviceDegradedEvent,
+	models.DeviceOffline:  DeviceOfflineEvent,
+}
+
+// PostHeartbeat records the status of the components of the device calling it.
+func (h *Handler) PostHeartbeat(ctx *fiber.Ctx) error {
+	device, err := h.authenticateDevice(ctx)
+	if err != nil {
+		return err
+	}
+
+	var request HeartbeatRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	now := time.Now().UTC()
+	heartbeat := &models.Heartbeat{
+		DeviceID:          device.ID,
+		CameraFPS:         request.CameraFPS,
+		DetectorLatencyMs: request.DetectorLatencyMs,
+		ScaleGrams:        request.ScaleGrams,
+		BatteryPercent:    request.BatteryPercent,
+		ReceivedAt:        now,
+	}
+
+	if err := h.heartbeatRepo.CreateHeartbeat(heartbeat); err != nil {
+		return err
+	}
+
+	status, err := h.checkDevice(device, heartbeat, now)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(status)
+}
+
+func (h *Handler) ListHeartbeats(ctx *fiber.Ctx) error {
+	deviceId := ctx.Params("id")
+
+	limit := defaultHeartbeatsPageSize
+	if v := ctx.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return newError(fiber.StatusBadRequest, errors.New("limit must be a positive integer"))
+		}
+		limit = n
+	}
+	if limit > maxHeartbeatsPageSize {
+		limit = maxHeartbeatsPageSize
+	}
+
+	// Make unknown devices a 404 instead of an empty list
+	if _, err := h.deviceRepo.GetDevice(deviceId); err != nil {
+		return err
+	}
+
+	heartbeats, err := h.heartbeatRepo.ListHeartbeats(deviceId, limit)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(heartbeats)
+}
+
+// Fleet reports the health of every device as of now. It only reads, the changes of health
+// being saved and announced by the heartbeats and the monitor of the fleet.
+func (h *Handler) Fleet(ctx *fiber.Ctx) error {
+	devices, err := h.deviceRepo.ListDevices()
+	if err != nil {
+		return err
+	}
+
+	heartbeats, err := h.heartbeatRepo.LastHeartbeats()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	fleet := FleetResponse{Devices: make([]*DeviceStatus, 0, len(devices))}
+
+	for _, device := range devices {
+		last := heartbeats[device.ID]
+		health, problems := h.monitor.Health(last, now)
+
+		switch health {
+		case models.DeviceOnline:
+			fleet.Online++
+		case models.DeviceDegraded:
+			fleet.Degraded++
+		default:
+			fleet.Offline++
+		}
+
+		// Ahead of the monitor, which saves it along with since when
+		device.Health = health
+		fleet.Devices = append(fleet.Devices, &DeviceStatus{Device: device, Problems: problems, LastHeartbeat: last})
+	}
+
+	return ctx.JSON(fleet)
+}
+
+// checkDevice saves the health of the device as of its last heartbeat, letting the staff know when it changed.
+// When someone else changed it first, they are the one letting the staff know.
+func (h *Handler) checkDevice(device *models.Device, last *models.Heartbeat, now time.Time) (*DeviceStatus, error) {
+	health, problems := h.monitor.Health(last, now)
+	status := &DeviceStatus{Device: device, Problems: problems, LastHeartbeat: last}
+
+	if health == device.Health {
+		return status, nil
+	}
+
+	err := h.deviceRepo.UpdateHealth(device.ID, device.Health, health, now)
+	if errors.Is(err, repository.ErrDeviceHealthChanged) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Info().Msgf("Device %s went from %s to %s %v", device.ID, device.Health, health, problems)
+
+	device.Health, device.HealthSince = health, &now
+
+	h.publishDeviceHealth(status)
+
+	return status, nil
+}
+
+// publishDeviceHealth lets the staff know the health of a device changed, along with the clients of the
+// cart it is bound to. Those can replay the event, the staff list the fleet to catch up instead.
+func (h *Handler) publishDeviceHealth(status *DeviceStatus) {
+	notification := CartEventWebsocketNotification{
+		Event:  deviceHealthEvents[status.Health],
+		Device: status,
+	}
+
+	if status.CartID != nil {
+		h.publish(*status.CartID, notification)
+	}
+	h.broker.Publish(staffTopic, notification)
+}
+
+// monitorFleet takes offline the devices that stopped sending heartbeats and drops
+// the heartbeats past their retention, every interval.
+func (h *Handler) monitorFleet(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now().UTC()
+
+		devices, err := h.deviceRepo.ListDevices()
+		if err != nil {
+			h.logger.Err(err).Msg("failed to list devices")
+			continue
+		}
+
+		heartbeats, err := h.heartbeatRepo.LastHeartbeats()
+		if err != nil {
+			h.logger.Err(err).Msg("failed to load the last heartbeats")
+			continue
+		}
+
+		for _, device := range devices {
+			if _, err := h.checkDevice(device, heartbeats[device.ID], now); err != nil {
+				h.logger.Err(err).Msgf("failed to check device %s", device.ID)
+			}
+		}
+
+		pruned, err := h.heartbeatRepo.PruneHeartbeats(h.monitor.RetainedSince(now))
+		if err != nil {
+			h.logger.Err(err).Msg("failed to prune heartbeats")
+		} else if pruned > 0 {
+			h.logger.Info().Msgf("Pruned %d heartbeats", pruned)
+		}
+	}
+}
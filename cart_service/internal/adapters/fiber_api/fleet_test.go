@@ -0,0 +1,65 @@
+package fiber_api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	fiberApi "github.com/fsmiamoto/zcart/cart_service/internal/adapters/fiber_api"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFleet(t *testing.T) {
+	t.Run("Counts a device that stopped sending heartbeats as offline", func(t *testing.T) {
+		setup := newAPISetup(t)
+		now := time.Now().UTC()
+
+		require.NoError(t, setup.heartbeats.CreateHeartbeat(&models.Heartbeat{DeviceID: "cart-01", ReceivedAt: now.Add(-time.Minute)}))
+		require.NoError(t, setup.devices.UpdateHealth("cart-01", models.DeviceOffline, models.DeviceOnline, now.Add(-time.Minute)))
+
+		response, err := setup.app.Test(httptest.NewRequest(http.MethodGet, "/fleet", nil), -1)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, fiber.StatusOK, response.StatusCode)
+
+		var fleet fiberApi.FleetResponse
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&fleet))
+
+		assert.Equal(t, 0, fleet.Online)
+		assert.Equal(t, 1, fleet.Offline)
+		require.Len(t, fleet.Devices, 1)
+		assert.Equal(t, models.DeviceOffline, fleet.Devices[0].Health)
+		assert.Contains(t, fleet.Devices[0].Problems[0], "no heartbeat")
+
+		// Taking it offline is left to the monitor
+		device, err := setup.devices.GetDevice("cart-01")
+		require.NoError(t, err)
+		assert.Equal(t, models.DeviceOnline, device.Health)
+	})
+
+	t.Run("Records the change of health for the clients of the cart", func(t *testing.T) {
+		setup := newAPISetup(t)
+
+		request := httptest.NewRequest(http.MethodPost, "/devices/me/heartbeat",
+			strings.NewReader(`{"camera_fps":15,"detector_latency_ms":80,"scale_grams":1200,"battery_percent":90}`))
+		request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		request.Header.Set(fiberApi.DeviceIDHeader, "cart-01")
+		request.Header.Set(fiberApi.DeviceSecretHeader, "demo-secret")
+		require.Equal(t, fiber.StatusOK, setup.send(t, request))
+
+		device, err := setup.devices.GetDevice("cart-01")
+		require.NoError(t, err)
+		assert.Equal(t, models.DeviceOnline, device.Health)
+
+		events, err := setup.events.ListSince("2", 0, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, string(fiberApi.DeviceOnlineEvent), events[0].Event)
+	})
+}
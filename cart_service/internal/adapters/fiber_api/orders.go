@@ -0,0 +1,84 @@
+package fiber_api
+
+import (
+	"fmt"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/receipts"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) GetOrder(ctx *fiber.Ctx) error {
+	order, err := h.orderRepo.GetOrder(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(order)
+}
+
+func (h *Handler) ListCartOrders(ctx *fiber.Ctx) error {
+	cartId := ctx.Params("id")
+
+	// Make unknown carts a 404 instead of an empty list
+	if _, err := h.cartRepo.GetCart(cartId); err != nil {
+		return err
+	}
+
+	orders, err := h.orderRepo.ListCartOrders(cartId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(orders)
+}
+
+func (h *Handler) GetOrderReceipt(ctx *fiber.Ctx) error {
+	format := receipts.Format(ctx.Query("format", string(receipts.HTML)))
+	if !format.Valid() {
+		return newError(fiber.StatusBadRequest, fmt.Errorf("format must be one of %s, %s or %s", receipts.HTML, receipts.PDF, receipts.Text))
+	}
+
+	order, err := h.orderRepo.GetOrder(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	if order.Status != models.OrderCompleted {
+		return ErrOrderNotCompleted
+	}
+
+	payment, err := h.capturedPayment(order.ID)
+	if err != nil {
+		return err
+	}
+
+	body, err := receipts.New(h.store, order, payment).Render(format)
+	if err != nil {
+		return err
+	}
+
+	if format == receipts.PDF {
+		ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="receipt-%s.pdf"`, order.ID))
+	}
+	ctx.Set(fiber.HeaderContentType, format.ContentType())
+
+	return ctx.Send(body)
+}
+
+// capturedPayment is the payment the order was placed with. Orders placed before payments were taken have none.
+func (h *Handler) capturedPayment(orderId string) (*models.PaymentIntent, error) {
+	intents, err := h.paymentRepo.ListOrderPayments(orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *models.PaymentIntent
+	for _, intent := range intents {
+		if intent.Status == models.PaymentCaptured {
+			payment = intent
+		}
+	}
+
+	return payment, nil
+}
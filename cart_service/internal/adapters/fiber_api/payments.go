@@ -0,0 +1,428 @@
+package fiber_api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/ids"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments/pix"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// pixChargesExpiryInterval is how often the payments of the PIX charges not paid in time are given up.
+const pixChargesExpiryInterval = time.Minute
+
+// ErrPixChargeExpired is why the payment of a PIX charge not paid in time was given up
+var ErrPixChargeExpired = errors.New("PIX charge expired")
+
+func (h *Handler) ListOrderPayments(ctx *fiber.Ctx) error {
+	orderId := ctx.Params("id")
+
+	if _, err := h.orderRepo.GetOrder(orderId); err != nil {
+		return err
+	}
+
+	intents, err := h.paymentRepo.ListOrderPayments(orderId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(intents)
+}
+
+// startPayment returns the payment of the checkout identified by the idempotency key,
+// creating it for the current total of the cart on the first attempt.
+func (h *Handler) startPayment(cartId string, request CheckoutRequest) (*models.PaymentIntent, error) {
+	payment, err := h.paymentRepo.GetPaymentByKey(request.IdempotencyKey)
+	if err == nil {
+		if payment.CartID != cartId {
+			return nil, newError(fiber.StatusUnprocessableEntity, ErrIdempotencyKeyReused)
+		}
+		return payment, nil
+	}
+	if !errors.Is(err, repository.ErrPaymentNotFound) {
+		return nil, err
+	}
+
+	cart, err := h.cartRepo.GetCart(cartId)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cart.Status {
+	case models.CartOpen:
+		if len(cart.Products) == 0 {
+			return nil, repository.ErrCartEmpty
+		}
+		// Changes to the cart are rejected from now on, so the order is placed for the amount paid
+		if err := h.cartRepo.UpdateCartStatus(cartId, models.CartOpen, models.CartCheckingOut); err != nil {
+			return nil, err
+		}
+		h.logger.Info().Msgf("Checkout: cart %s is checking out", cartId)
+	case models.CartCheckingOut:
+		// Checked out before, with another key or after the shopper asked to
+	default:
+		return nil, repository.ErrCartNotOpen
+	}
+
+	priced, err := h.pricedCart(cartId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(priced.Products) == 0 {
+		h.reopenCart(cartId)
+		return nil, repository.ErrCartEmpty
+	}
+
+	gateway := h.gateway
+	if request.PaymentMethod == pix.Method {
+		if h.pix == nil {
+			return nil, newError(fiber.StatusBadRequest, errors.New("PIX payments are not available"))
+		}
+		gateway = h.pix
+	}
+
+	payment = &models.PaymentIntent{
+		ID:             ids.New(),
+		OrderID:        ids.New(),
+		CartID:         cartId,
+		IdempotencyKey: request.IdempotencyKey,
+		Amount:         priced.Total,
+		Status:         models.PaymentPending,
+		Method:         request.PaymentMethod,
+		Gateway:        gateway.Name(),
+	}
+
+	if err := h.paymentRepo.CreatePayment(payment); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// payForOrder takes the payment from wherever a previous attempt left it to a placed order.
+func (h *Handler) payForOrder(payment *models.PaymentIntent) (*models.Order, error) {
+	switch payment.Status {
+	case models.PaymentFailed:
+		return nil, newError(fiber.StatusPaymentRequired, paymentFailure(payment))
+	case models.PaymentVoided, models.PaymentRefunded:
+		return nil, newError(fiber.StatusConflict, paymentFailure(payment))
+	case models.PaymentPending:
+		if err := h.authorizePayment(payment); err != nil {
+			return nil, err
+		}
+	}
+
+	return h.placeOrder(payment)
+}
+
+func (h *Handler) authorizePayment(payment *models.PaymentIntent) error {
+	authorization, err := h.gateway.Authorize(payments.AuthorizeRequest{
+		Amount: payment.Amount,
+		Method: payment.Method,
+		// The gateway remembers the payment, so authorizing it again after a crash is safe
+		IdempotencyKey: payment.ID,
+		Description:    fmt.Sprintf("zcart order %s", payment.OrderID),
+	})
+	if errors.Is(err, payments.ErrDeclined) {
+		return h.failPayment(payment, models.PaymentFailed, err)
+	}
+	if err != nil {
+		// The payment stays pending, for the client to retry
+		return err
+	}
+
+	h.logger.Info().Msgf("Checkout: authorized payment %s as %s", payment.ID, authorization.TransactionID)
+
+	payment.Status = models.PaymentAuthorized
+	payment.TransactionID = &authorization.TransactionID
+	return h.paymentRepo.UpdatePayment(payment)
+}
+
+// placeOrder creates the order of an authorized payment as pending, captures the payment once the order
+// is saved and completes it. The capture is left out of the transaction, so a slow gateway doesn't hold
+// the database, and a retry finds the pending order to capture and complete it again.
+func (h *Handler) placeOrder(payment *models.PaymentIntent) (*models.Order, error) {
+	gateway := h.gatewayOf(payment)
+
+	order, err := h.orderRepo.GetOrder(payment.OrderID)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		order, err = h.orderRepo.CreateFromCart(payment.OrderID, payment.CartID, func(order *models.Order) error {
+			if order.Total != payment.Amount {
+				return ErrCartChanged
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, h.cancelPayment(payment, gateway, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch order.Status {
+	case models.OrderCancelled:
+		return nil, newError(fiber.StatusConflict, paymentFailure(payment))
+	case models.OrderPending:
+		if err := gateway.Capture(*payment.TransactionID, payment.Amount); err != nil {
+			if errors.Is(err, payments.ErrUnavailable) {
+				// Still authorized with the order pending, the client may retry
+				return nil, err
+			}
+			return nil, h.cancelOrder(order, payment, gateway, err)
+		}
+
+		if err := h.orderRepo.CompleteOrder(order.ID); err != nil {
+			// Captured, a retry captures it again, which the gateway ignores, and completes the order
+			return nil, err
+		}
+		order.Status = models.OrderCompleted
+	}
+
+	payment.Status = models.PaymentCaptured
+	payment.FailureReason = nil
+	if err := h.paymentRepo.UpdatePayment(payment); err != nil {
+		// The order was placed, a retry finds it and records the capture
+		h.logger.Err(err).Msgf("failed to record capture of payment %s", payment.ID)
+	}
+
+	return order, nil
+}
+
+// cancelOrder gives the lines of an order whose payment could not be captured back to the cart,
+// and the money back to the customer. It returns the cause.
+func (h *Handler) cancelOrder(order *models.Order, payment *models.PaymentIntent, gateway payments.PaymentGateway, cause error) error {
+	if err := h.orderRepo.CancelOrder(order.ID); err != nil {
+		// Still pending, a retry tries to capture it again
+		h.logger.Err(err).Msgf("failed to cancel order %s", order.ID)
+		return cause
+	}
+
+	h.logger.Info().Msgf("Checkout: cancelled order %s, payment %s not captured: %s", order.ID, payment.ID, cause)
+
+	return h.cancelPayment(payment, gateway, cause)
+}
+
+// cancelPayment gives the money back after the order of the payment could not be placed. The payment
+// is only recorded as voided once the gateway did it, it stays authorized, along with its cart checking
+// out, for someone to refund by hand otherwise. It returns the cause.
+func (h *Handler) cancelPayment(payment *models.PaymentIntent, gateway payments.PaymentGateway, cause error) error {
+	if err := gateway.Void(*payment.TransactionID); err != nil {
+		return h.needsRefund(payment, cause, err)
+	}
+
+	return h.failPayment(payment, models.PaymentVoided, cause)
+}
+
+// needsRefund records that the money of the payment could not be given back, returning the cause.
+func (h *Handler) needsRefund(payment *models.PaymentIntent, cause error, err error) error {
+	h.logger.Err(err).Msgf("failed to give back payment %s, it must be refunded by hand", payment.ID)
+
+	reason := fmt.Sprintf("%s, refund by hand: %s", cause, err)
+	payment.FailureReason = &reason
+
+	if err := h.paymentRepo.UpdatePayment(payment); err != nil {
+		h.logger.Err(err).Msgf("failed to record failure of payment %s", payment.ID)
+	}
+
+	return cause
+}
+
+func (h *Handler) gatewayOf(payment *models.PaymentIntent) payments.PaymentGateway {
+	if h.pix != nil && payment.Gateway == h.pix.Name() {
+		return h.pix
+	}
+	return h.gateway
+}
+
+// chargeWithPix issues the BR Code of a pending PIX payment, again when the checkout is retried.
+func (h *Handler) chargeWithPix(ctx *fiber.Ctx, payment *models.PaymentIntent) error {
+	charge, err := h.pix.Charge(payment.ID, payment.Amount)
+	if err != nil {
+		return err
+	}
+
+	if payment.TransactionID == nil || *payment.TransactionID != charge.TxID {
+		payment.TransactionID = &charge.TxID
+		if err := h.paymentRepo.UpdatePayment(payment); err != nil {
+			return err
+		}
+	}
+
+	h.logger.Info().Msgf("Checkout: PIX charge %s for payment %s", charge.TxID, payment.ID)
+
+	return ctx.Status(fiber.StatusAccepted).JSON(CheckoutResponse{
+		Payment: payment,
+		Pix: &PixChargeResponse{
+			TxID:   charge.TxID,
+			BRCode: charge.BRCode,
+			QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(charge.QRCode),
+		},
+	})
+}
+
+// PixWebhook places the orders of the PIX payments the PSP received. Payments it
+// does not know about or with the wrong amount are left for someone to look into.
+func (h *Handler) PixWebhook(ctx *fiber.Ctx) error {
+	if h.pix == nil {
+		return fiber.ErrNotFound
+	}
+
+	if !h.pix.Authenticate(ctx.Get(PixWebhookSecretHeader)) {
+		return newError(fiber.StatusUnauthorized, errors.New("invalid webhook secret"))
+	}
+
+	var request PixWebhookRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	for _, received := range request.Pix {
+		// Failing makes the PSP call again later
+		if err := h.confirmPixPayment(received); err != nil {
+			return err
+		}
+	}
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func (h *Handler) confirmPixPayment(received PixReceived) error {
+	payment, err := h.paymentRepo.GetPaymentByTransaction(h.pix.Name(), received.TxID)
+	if errors.Is(err, repository.ErrPaymentNotFound) {
+		h.logger.Warn().Msgf("PixWebhook: unknown txid %s (%s)", received.TxID, received.EndToEndID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	amount, err := money.Parse(received.Amount, money.BRL)
+	if err != nil || amount != payment.Amount {
+		h.logger.Warn().Msgf("PixWebhook: payment %s received %q instead of %s", payment.ID, received.Amount, payment.Amount)
+		return nil
+	}
+
+	switch payment.Status {
+	case models.PaymentPending:
+		payment.Status = models.PaymentAuthorized
+		if err := h.paymentRepo.UpdatePayment(payment); err != nil {
+			return err
+		}
+	case models.PaymentAuthorized:
+		if payment.FailureReason != nil {
+			h.logger.Warn().Msgf("PixWebhook: payment %s must be refunded by hand: %s", payment.ID, *payment.FailureReason)
+			return nil
+		}
+		// Confirmed before, placing the order failed
+	default:
+		if payment.Status == models.PaymentVoided && payment.FailureReason != nil && *payment.FailureReason == ErrPixChargeExpired.Error() {
+			h.refundExpiredPix(payment)
+			return nil
+		}
+		h.logger.Info().Msgf("PixWebhook: payment %s already %s", payment.ID, payment.Status)
+		return nil
+	}
+
+	h.logger.Info().Msgf("PixWebhook: received payment %s (%s)", payment.ID, received.EndToEndID)
+
+	order, err := h.placeOrder(payment)
+	if err != nil {
+		if payment.Status != models.PaymentAuthorized || payment.FailureReason != nil {
+			// The money went back to the customer, or is left for someone to refund by hand,
+			// there is nothing left to retry
+			return nil
+		}
+		return err
+	}
+
+	h.orderPlaced(order, payment)
+
+	return nil
+}
+
+// refundExpiredPix gives back a PIX paid after its charge expired, the cart having been reopened since.
+func (h *Handler) refundExpiredPix(payment *models.PaymentIntent) {
+	if err := h.pix.Refund(*payment.TransactionID, payment.Amount); err != nil {
+		_ = h.needsRefund(payment, ErrPixChargeExpired, err)
+		return
+	}
+
+	payment.Status = models.PaymentRefunded
+	if err := h.paymentRepo.UpdatePayment(payment); err != nil {
+		h.logger.Err(err).Msgf("failed to record refund of payment %s", payment.ID)
+	}
+
+	h.logger.Info().Msgf("PixWebhook: refunded payment %s, paid after its charge expired", payment.ID)
+}
+
+// expirePixCharges gives up the payments of the PIX charges not paid in time, every interval.
+func (h *Handler) expirePixCharges(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.expirePixChargesAt(time.Now().UTC())
+	}
+}
+
+// expirePixChargesAt voids the PIX payments still pending once their charge expired, so the
+// shopper may change the cart or pay another way.
+func (h *Handler) expirePixChargesAt(now time.Time) {
+	expired, err := h.paymentRepo.ExpirePendingPayments(h.pix.Name(), h.pix.ExpiredBefore(now), ErrPixChargeExpired.Error())
+	if err != nil {
+		h.logger.Err(err).Msg("failed to expire PIX charges")
+		return
+	}
+
+	for _, payment := range expired {
+		h.logger.Info().Msgf("Checkout: PIX charge of payment %s expired", payment.ID)
+		h.reopenCart(payment.CartID)
+	}
+}
+
+// failPayment records why the payment can't go on, returning the cause.
+func (h *Handler) failPayment(payment *models.PaymentIntent, status models.PaymentStatus, cause error) error {
+	reason := cause.Error()
+	payment.Status = status
+	payment.FailureReason = &reason
+
+	if err := h.paymentRepo.UpdatePayment(payment); err != nil {
+		h.logger.Err(err).Msgf("failed to record failure of payment %s", payment.ID)
+	}
+
+	h.logger.Info().Msgf("Checkout: payment %s %s: %s", payment.ID, status, reason)
+
+	// The shopper may change the cart and check out again
+	h.reopenCart(payment.CartID)
+
+	return cause
+}
+
+// reopenCart lets the shopper change a cart whose checkout did not go through.
+func (h *Handler) reopenCart(cartId string) {
+	err := h.cartRepo.UpdateCartStatus(cartId, models.CartCheckingOut, models.CartOpen)
+	if err != nil && !errors.Is(err, repository.ErrInvalidCartTransition) {
+		h.logger.Err(err).Msgf("failed to reopen cart %s", cartId)
+	}
+}
+
+func paymentFailure(payment *models.PaymentIntent) error {
+	if payment.FailureReason == nil {
+		return fmt.Errorf("payment %s", payment.Status)
+	}
+	return errors.New(*payment.FailureReason)
+}
@@ -0,0 +1,359 @@
+package fiber_api_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	fiberApi "github.com/fsmiamoto/zcart/cart_service/internal/adapters/fiber_api"
+	"github.com/fsmiamoto/zcart/cart_service/internal/devices"
+	"github.com/fsmiamoto/zcart/cart_service/internal/migrations"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments/pix"
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// recordingGateway remembers the calls made to it, its captures failing with captureErr
+// and its voids with voidErr when set.
+type recordingGateway struct {
+	*payments.FakeGateway
+	calls      []string
+	captureErr error
+	voidErr    error
+}
+
+func (g *recordingGateway) Capture(transactionId string, amount money.Money) error {
+	g.calls = append(g.calls, "capture")
+	if g.captureErr != nil {
+		return g.captureErr
+	}
+	return g.FakeGateway.Capture(transactionId, amount)
+}
+
+func (g *recordingGateway) Void(transactionId string) error {
+	g.calls = append(g.calls, "void")
+	if g.voidErr != nil {
+		return g.voidErr
+	}
+	return g.FakeGateway.Void(transactionId)
+}
+
+// failingOrders saves the orders in the database, failing to create them with createErr
+// and to complete them with completeErr when set.
+type failingOrders struct {
+	repository.OrderRepository
+	createErr   error
+	completeErr error
+}
+
+func (f *failingOrders) CreateFromCart(orderId string, cartId string, check func(*models.Order) error) (*models.Order, error) {
+	return f.OrderRepository.CreateFromCart(orderId, cartId, func(order *models.Order) error {
+		if f.createErr != nil {
+			return f.createErr
+		}
+		return check(order)
+	})
+}
+
+func (f *failingOrders) CompleteOrder(orderId string) error {
+	if f.completeErr != nil {
+		return f.completeErr
+	}
+	return f.OrderRepository.CompleteOrder(orderId)
+}
+
+type apiSetup struct {
+	handler    *fiberApi.Handler
+	app        *fiber.App
+	gateway    *recordingGateway
+	orders     *failingOrders
+	carts      repository.CartRepository
+	coupons    repository.CouponRepository
+	payments   repository.PaymentRepository
+	devices    repository.DeviceRepository
+	heartbeats repository.HeartbeatRepository
+	events     repository.CartEventRepository
+}
+
+const webhookSecret = "s3cr3t"
+
+// newAPISetup serves the API from a database with the demo fixtures, where cart 1 has products and
+// device cart-01, with the secret demo-secret, is bound to cart 2. PIX codes are static, there being no PSP to give the money back,
+// and expire after 15 minutes.
+// Devices are offline as soon as they miss a heartbeat, and degraded by a slow detector only.
+func newAPISetup(t *testing.T) *apiSetup {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "zcart.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	require.NoError(t, migrations.LoadFixtures(db))
+
+	promotions := sqlite.NewPromotionRepository(db)
+	taxRules := sqlite.NewTaxRuleRepository(db)
+	calculator := pricing.NewCalculator(promotions, taxRules)
+
+	setup := &apiSetup{
+		gateway:    &recordingGateway{FakeGateway: payments.NewFakeGateway()},
+		orders:     &failingOrders{OrderRepository: sqlite.NewOrderRepository(db, calculator)},
+		carts:      sqlite.NewCartRepository(db, time.Minute),
+		coupons:    sqlite.NewCouponRepository(db),
+		payments:   sqlite.NewPaymentRepository(db),
+		devices:    sqlite.NewDeviceRepository(db),
+		heartbeats: sqlite.NewHeartbeatRepository(db),
+		events:     sqlite.NewCartEventRepository(db, time.Hour),
+	}
+
+	handler := fiberApi.New(zerolog.Nop(), fiberApi.Repositories{
+		Carts:      setup.carts,
+		Products:   sqlite.NewProductRepository(db),
+		Orders:     setup.orders,
+		CartEvents: setup.events,
+		Promotions: promotions,
+		Coupons:    setup.coupons,
+		Payments:   setup.payments,
+		Fiscal:     sqlite.NewFiscalRepository(db),
+		TaxRules:   taxRules,
+		Stock:      sqlite.NewStockRepository(db),
+		Pending:    sqlite.NewPendingItemRepository(db, time.Minute),
+		Weights:    sqlite.NewCartWeightRepository(db),
+		Devices:    setup.devices,
+		Heartbeats: setup.heartbeats,
+	}, calculator, fiberApi.Gateways{
+		Cards: setup.gateway,
+		Pix:   pix.NewGateway(pix.Config{Key: "pix@zcart.com.br", MerchantName: "ZCART", MerchantCity: "SAO PAULO", WebhookSecret: webhookSecret, ChargeExpiry: 15 * time.Minute}, nil),
+	}, models.Store{Name: "ZCART"}, nil,
+		recognition.NewVerifier(recognition.Config{}), devices.NewMonitor(devices.HealthConfig{MaxDetectorLatencyMs: devices.DefaultMaxDetectorLatencyMs}))
+
+	setup.handler, setup.app = handler, handler.App()
+	return setup
+}
+
+func (s *apiSetup) checkout(t *testing.T, cartId string, key string) int {
+	return s.checkoutWith(t, cartId, key, "card")
+}
+
+func (s *apiSetup) checkoutWith(t *testing.T, cartId string, key string, method string) int {
+	request := httptest.NewRequest(http.MethodPost, "/cart/"+cartId+"/checkout", strings.NewReader(`{"payment_method":"`+method+`"}`))
+	request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	request.Header.Set(fiberApi.IdempotencyKeyHeader, key)
+
+	return s.send(t, request)
+}
+
+// receivePix calls the webhook as the PSP would once the payment was received.
+func (s *apiSetup) receivePix(t *testing.T, payment *models.PaymentIntent) int {
+	body, err := json.Marshal(fiberApi.PixWebhookRequest{Pix: []fiberApi.PixReceived{{
+		EndToEndID: "E00000000202210181200abcdef12345",
+		TxID:       *payment.TransactionID,
+		Amount:     payment.Amount.String(),
+		Time:       time.Now(),
+	}}})
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/webhooks/pix", strings.NewReader(string(body)))
+	request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	request.Header.Set(fiberApi.PixWebhookSecretHeader, webhookSecret)
+
+	return s.send(t, request)
+}
+
+func (s *apiSetup) send(t *testing.T, request *http.Request) int {
+	response, err := s.app.Test(request, -1)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	return response.StatusCode
+}
+
+func (s *apiSetup) payment(t *testing.T, key string) *models.PaymentIntent {
+	payment, err := s.payments.GetPaymentByKey(key)
+	require.NoError(t, err)
+	return payment
+}
+
+func (s *apiSetup) cartStatus(t *testing.T, cartId string) models.CartStatus {
+	cart, err := s.carts.GetCart(cartId)
+	require.NoError(t, err)
+	return cart.Status
+}
+
+func (s *apiSetup) order(t *testing.T, key string) *models.Order {
+	order, err := s.orders.GetOrder(s.payment(t, key).OrderID)
+	require.NoError(t, err)
+	return order
+}
+
+func TestCheckout(t *testing.T) {
+	t.Run("Captures the payment of the order placed", func(t *testing.T) {
+		setup := newAPISetup(t)
+
+		assert.Equal(t, fiber.StatusCreated, setup.checkout(t, "1", "k1"))
+		assert.Equal(t, []string{"capture"}, setup.gateway.calls)
+		assert.Equal(t, models.PaymentCaptured, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.OrderCompleted, setup.order(t, "k1").Status)
+		assert.Equal(t, models.CartPaid, setup.cartStatus(t, "1"))
+	})
+
+	t.Run("Voids the payment when the order can't be created", func(t *testing.T) {
+		setup := newAPISetup(t)
+		setup.orders.createErr = pricing.ErrCouponExhausted
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, setup.checkout(t, "1", "k1"))
+		assert.Equal(t, []string{"void"}, setup.gateway.calls)
+		assert.Equal(t, models.PaymentVoided, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.CartOpen, setup.cartStatus(t, "1"))
+	})
+
+	t.Run("Leaves out a coupon the customer used on another cart", func(t *testing.T) {
+		setup := newAPISetup(t)
+		customerId := "c1"
+
+		require.NoError(t, setup.carts.UpdateProductQuantity("2", "1", 5))
+		require.NoError(t, setup.coupons.ApplyToCart("1", "WELCOME10", &customerId))
+		require.NoError(t, setup.coupons.ApplyToCart("2", "WELCOME10", &customerId))
+
+		assert.Equal(t, fiber.StatusCreated, setup.checkout(t, "2", "k1"))
+		assert.Len(t, setup.order(t, "k1").Coupons, 1)
+
+		assert.Equal(t, fiber.StatusCreated, setup.checkout(t, "1", "k2"))
+		order := setup.order(t, "k2")
+		assert.Empty(t, order.Coupons)
+		assert.Equal(t, setup.payment(t, "k2").Amount, order.Total)
+		assert.Equal(t, models.CartPaid, setup.cartStatus(t, "1"))
+	})
+
+	t.Run("Cancels the order when the payment can't be captured", func(t *testing.T) {
+		setup := newAPISetup(t)
+		setup.gateway.captureErr = payments.ErrInvalidState
+
+		cart, err := setup.carts.GetCart("1")
+		require.NoError(t, err)
+
+		assert.Equal(t, fiber.StatusInternalServerError, setup.checkout(t, "1", "k1"))
+		assert.Equal(t, []string{"capture", "void"}, setup.gateway.calls)
+		assert.Equal(t, models.PaymentVoided, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.OrderCancelled, setup.order(t, "k1").Status)
+
+		reopened, err := setup.carts.GetCart("1")
+		require.NoError(t, err)
+		assert.Equal(t, models.CartOpen, reopened.Status)
+		assert.Len(t, reopened.Products, len(cart.Products), "lines back in the cart")
+	})
+
+	t.Run("Completes the order of a captured payment on retry", func(t *testing.T) {
+		setup := newAPISetup(t)
+		setup.orders.completeErr = errors.New("disk I/O error")
+
+		assert.Equal(t, fiber.StatusInternalServerError, setup.checkout(t, "1", "k1"))
+		assert.Equal(t, models.PaymentAuthorized, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.OrderPending, setup.order(t, "k1").Status)
+		assert.Equal(t, models.CartCheckingOut, setup.cartStatus(t, "1"))
+
+		setup.orders.completeErr = nil
+		assert.Equal(t, fiber.StatusCreated, setup.checkout(t, "1", "k1"))
+		assert.Equal(t, []string{"capture", "capture"}, setup.gateway.calls)
+		assert.Equal(t, models.PaymentCaptured, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.OrderCompleted, setup.order(t, "k1").Status)
+		assert.Equal(t, models.CartPaid, setup.cartStatus(t, "1"))
+	})
+
+	t.Run("Keeps the payment authorized when the void fails", func(t *testing.T) {
+		setup := newAPISetup(t)
+		setup.gateway.captureErr = payments.ErrInvalidState
+		setup.gateway.voidErr = payments.ErrInvalidState
+
+		assert.Equal(t, fiber.StatusInternalServerError, setup.checkout(t, "1", "k1"))
+		assert.Equal(t, []string{"capture", "void"}, setup.gateway.calls)
+
+		payment := setup.payment(t, "k1")
+		assert.Equal(t, models.PaymentAuthorized, payment.Status)
+		require.NotNil(t, payment.FailureReason)
+		assert.Contains(t, *payment.FailureReason, "refund by hand")
+		assert.Equal(t, models.CartCheckingOut, setup.cartStatus(t, "1"))
+
+		// The order was cancelled, retrying doesn't place it again
+		setup.gateway.captureErr = nil
+		assert.Equal(t, fiber.StatusConflict, setup.checkout(t, "1", "k1"))
+		assert.Equal(t, models.PaymentAuthorized, setup.payment(t, "k1").Status)
+	})
+}
+
+func TestPixWebhook(t *testing.T) {
+	t.Run("Places the order of the payment received", func(t *testing.T) {
+		setup := newAPISetup(t)
+
+		assert.Equal(t, fiber.StatusAccepted, setup.checkoutWith(t, "1", "k1", pix.Method))
+		assert.Equal(t, models.CartCheckingOut, setup.cartStatus(t, "1"), "frozen while the charge is pending")
+
+		assert.Equal(t, fiber.StatusOK, setup.receivePix(t, setup.payment(t, "k1")))
+		assert.Equal(t, models.PaymentCaptured, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.CartPaid, setup.cartStatus(t, "1"))
+	})
+
+	t.Run("Keeps the payment for a refund by hand when the order fails", func(t *testing.T) {
+		setup := newAPISetup(t)
+		setup.orders.createErr = pricing.ErrCouponExhausted
+
+		assert.Equal(t, fiber.StatusAccepted, setup.checkoutWith(t, "1", "k1", pix.Method))
+		assert.Equal(t, fiber.StatusOK, setup.receivePix(t, setup.payment(t, "k1")))
+
+		payment := setup.payment(t, "k1")
+		assert.Equal(t, models.PaymentAuthorized, payment.Status)
+		require.NotNil(t, payment.FailureReason)
+		assert.Contains(t, *payment.FailureReason, "refund by hand")
+		assert.Equal(t, models.CartCheckingOut, setup.cartStatus(t, "1"))
+
+		// Calls the PSP makes again do not place the order
+		setup.orders.createErr = nil
+		assert.Equal(t, fiber.StatusOK, setup.receivePix(t, payment))
+		assert.Equal(t, models.PaymentAuthorized, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.CartCheckingOut, setup.cartStatus(t, "1"))
+	})
+	t.Run("Gives up the payment of a charge not paid in time", func(t *testing.T) {
+		setup := newAPISetup(t)
+		now := time.Now().UTC()
+
+		assert.Equal(t, fiber.StatusAccepted, setup.checkoutWith(t, "1", "k1", pix.Method))
+
+		setup.handler.ExpirePixCharges(now)
+		assert.Equal(t, models.PaymentPending, setup.payment(t, "k1").Status)
+		assert.Equal(t, models.CartCheckingOut, setup.cartStatus(t, "1"))
+
+		setup.handler.ExpirePixCharges(now.Add(time.Hour))
+		payment := setup.payment(t, "k1")
+		assert.Equal(t, models.PaymentVoided, payment.Status)
+		assert.Equal(t, models.CartOpen, setup.cartStatus(t, "1"))
+
+		// The shopper pays by card instead
+		assert.Equal(t, fiber.StatusCreated, setup.checkout(t, "1", "k2"))
+		assert.Equal(t, models.CartPaid, setup.cartStatus(t, "1"))
+
+		// Paying the charge late does not place another order, the money has to go back
+		assert.Equal(t, fiber.StatusOK, setup.receivePix(t, payment))
+		payment = setup.payment(t, "k1")
+		assert.Equal(t, models.PaymentVoided, payment.Status)
+		require.NotNil(t, payment.FailureReason)
+		assert.Contains(t, *payment.FailureReason, "refund by hand")
+	})
+}
@@ -0,0 +1,119 @@
+package fiber_api
+
+import (
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// pendingItemsExpiryInterval is how often the pending items the shopper did not answer are expired.
+const pendingItemsExpiryInterval = 5 * time.Second
+
+func (h *Handler) ListPendingItems(ctx *fiber.Ctx) error {
+	cartId := ctx.Params("cart_id")
+
+	// Make unknown carts a 404 instead of an empty list
+	if _, err := h.cartRepo.GetCart(cartId); err != nil {
+		return err
+	}
+
+	items, err := h.pendingRepo.ListPendingItems(cartId)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(items)
+}
+
+// ConfirmPendingItem applies the change the shopper confirmed to the cart.
+func (h *Handler) ConfirmPendingItem(ctx *fiber.Ctx) error {
+	cartId := ctx.Params("cart_id")
+
+	item, err := h.pendingRepo.ConfirmPendingItem(cartId, ctx.Params("pending_id"))
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("ConfirmPendingItem: %s, %d of product %s to cart %s", item.ID, item.Quantity, item.ProductID, cartId)
+
+	cart, err := h.pendingItemResolved(item)
+	if err != nil {
+		return err
+	}
+
+	product, err := h.productRepo.GetProduct(item.ProductID)
+	if err != nil {
+		return err
+	}
+
+	action, quantity := AddProductAction, item.Quantity
+	if quantity < 0 {
+		action, quantity = RemoveProductAction, -quantity
+	}
+
+	h.notify(&models.CartProduct{
+		CartID:    cartId,
+		ProductID: item.ProductID,
+		Quantity:  uint(quantity),
+		Product:   product,
+	}, cart, action)
+
+	return ctx.JSON(PendingItemResponse{PendingItem: item, Cart: cart})
+}
+
+// RejectPendingItem drops the change the shopper rejected, leaving the cart untouched.
+func (h *Handler) RejectPendingItem(ctx *fiber.Ctx) error {
+	cartId := ctx.Params("cart_id")
+
+	item, err := h.pendingRepo.RejectPendingItem(cartId, ctx.Params("pending_id"))
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("RejectPendingItem: %s, %d of product %s to cart %s", item.ID, item.Quantity, item.ProductID, cartId)
+
+	cart, err := h.pendingItemResolved(item)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(PendingItemResponse{PendingItem: item, Cart: cart})
+}
+
+// pendingItemResolved lets the clients of the cart know the item is no longer waiting for the shopper.
+func (h *Handler) pendingItemResolved(item *models.PendingItem) (*CartResponse, error) {
+	cart, err := h.pricedCart(item.CartID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.publish(item.CartID, CartEventWebsocketNotification{
+		Event:       PendingItemResolvedEvent,
+		Cart:        cart,
+		PendingItem: item,
+	})
+
+	return cart, nil
+}
+
+// expirePendingItems expires the pending items the shopper did not answer in time, every interval.
+func (h *Handler) expirePendingItems(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		items, err := h.pendingRepo.ExpirePendingItems(time.Now().UTC())
+		if err != nil {
+			h.logger.Err(err).Msg("failed to expire pending items")
+			continue
+		}
+
+		for _, item := range items {
+			h.logger.Info().Msgf("Pending item %s of cart %s expired", item.ID, item.CartID)
+			if _, err := h.pendingItemResolved(item); err != nil {
+				h.logger.Err(err).Msgf("failed to notify expired pending item %s", item.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package fiber_api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) ListProducts(ctx *fiber.Ctx) error {
+	filter, err := parseProductFilter(ctx)
+	if err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	products, err := h.productRepo.ListProducts(filter)
+	if err != nil {
+		return err
+	}
+
+	total, err := h.productRepo.CountProducts(filter)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(ListProductsResponse{
+		Products: products,
+		Total:    total,
+		Limit:    filter.Limit,
+		Offset:   filter.Offset,
+	})
+}
+
+func (h *Handler) GetProduct(ctx *fiber.Ctx) error {
+	product, err := h.productRepo.GetProduct(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(product)
+}
+
+func (h *Handler) CreateProduct(ctx *fiber.Ctx) error {
+	var request ProductRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	product := request.ToProduct()
+	if err := h.productRepo.CreateProduct(product); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("CreateProduct: %s", product.ID)
+
+	return ctx.Status(fiber.StatusCreated).JSON(product)
+}
+
+func (h *Handler) ReplaceProduct(ctx *fiber.Ctx) error {
+	var request ProductRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	id := ctx.Params("id")
+	if request.ID != "" && request.ID != id {
+		return newError(fiber.StatusBadRequest, errors.New("product id does not match the url"))
+	}
+	request.ID = id
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	product := request.ToProduct()
+	if err := h.productRepo.UpdateProduct(product); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("ReplaceProduct: %s", product.ID)
+
+	return ctx.JSON(product)
+}
+
+func (h *Handler) PatchProduct(ctx *fiber.Ctx) error {
+	var request PatchProductRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	product, err := h.productRepo.GetProduct(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	request.Apply(&product)
+
+	if err := h.productRepo.UpdateProduct(product); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("PatchProduct: %s", product.ID)
+
+	return ctx.JSON(product)
+}
+
+func (h *Handler) DeleteProduct(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+
+	if err := h.productRepo.DeleteProduct(id); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("DeleteProduct: %s", id)
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// RecognitionCatalog lists every detector label with the product it stands for, for the recognizer of the carts.
+func (h *Handler) RecognitionCatalog(ctx *fiber.Ctx) error {
+	products, err := h.productRepo.ListProducts(repository.ProductFilter{Labelled: true})
+	if err != nil {
+		return err
+	}
+
+	entries := make([]RecognitionEntry, 0, len(products))
+	for _, product := range products {
+		for _, label := range product.Recognition.Labels {
+			entries = append(entries, RecognitionEntry{
+				Label:           label,
+				ProductID:       product.ID,
+				Name:            product.Name,
+				WeightGrams:     product.Recognition.WeightGrams,
+				WeightTolerance: product.Recognition.WeightTolerance,
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Label < entries[j].Label
+	})
+
+	return ctx.JSON(entries)
+}
+
+func parseProductFilter(ctx *fiber.Ctx) (repository.ProductFilter, error) {
+	filter := repository.ProductFilter{
+		Name:  ctx.Query("name"),
+		Label: ctx.Query("label"),
+		Limit: defaultProductsPageSize,
+	}
+
+	var err error
+
+	if filter.MinPrice, err = optionalMoneyQuery(ctx, "min_price"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = optionalMoneyQuery(ctx, "max_price"); err != nil {
+		return filter, err
+	}
+
+	if v := ctx.Query("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+			return filter, errors.New("limit must be a positive integer")
+		}
+		if filter.Limit > maxProductsPageSize {
+			filter.Limit = maxProductsPageSize
+		}
+	}
+
+	if v := ctx.Query("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			return filter, errors.New("offset must be a non-negative integer")
+		}
+	}
+
+	return filter, nil
+}
+
+func optionalMoneyQuery(ctx *fiber.Ctx, key string) (*money.Money, error) {
+	v := ctx.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+
+	m, err := money.Parse(v, money.DefaultCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an amount such as 5.99", key)
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,65 @@
+package fiber_api
+
+import (
+	"github.com/fsmiamoto/zcart/cart_service/internal/ids"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) ListPromotions(ctx *fiber.Ctx) error {
+	promotions, err := h.promotionRepo.ListPromotions()
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(promotions)
+}
+
+func (h *Handler) GetPromotion(ctx *fiber.Ctx) error {
+	promotion, err := h.promotionRepo.GetPromotion(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(promotion)
+}
+
+func (h *Handler) CreatePromotion(ctx *fiber.Ctx) error {
+	var request PromotionRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if _, err := h.productRepo.GetProduct(request.ProductID); err != nil {
+		return err
+	}
+
+	promotion := request.ToPromotion()
+	if promotion.ID == "" {
+		promotion.ID = ids.New()
+	}
+
+	if err := h.promotionRepo.CreatePromotion(promotion); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("CreatePromotion: %s (%s) on product %s", promotion.ID, promotion.Kind, promotion.ProductID)
+
+	return ctx.Status(fiber.StatusCreated).JSON(promotion)
+}
+
+func (h *Handler) DeletePromotion(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+
+	if err := h.promotionRepo.DeletePromotion(id); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("DeletePromotion: %s", id)
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
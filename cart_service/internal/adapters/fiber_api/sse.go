@@ -0,0 +1,156 @@
+package fiber_api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	sseKeepAliveInterval = 15 * time.Second
+	sseRetryInterval     = time.Second
+)
+
+// staffTopic carries the events the staff should know about from every cart.
+// Cart ids are never blank, so it does not clash with the topic of a cart.
+const staffTopic = ""
+
+// ServerSentEvents streams the same notifications as the websocket endpoint
+// as text/event-stream, for clients behind proxies that break websocket upgrades.
+// Resuming works through the standard Last-Event-ID header or the since query param.
+func (h *Handler) ServerSentEvents(ctx *fiber.Ctx) error {
+	cartId := ctx.Params("id")
+
+	cursor := ctx.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = ctx.Query("since")
+	}
+
+	since, resume, err := parseCursor(cursor)
+	if err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	stream, err := h.openCartEventStream(cartId, since, resume)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Printf("sse connection for cart %s", cartId)
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	// Keeps nginx from buffering the stream
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.logger.Printf("closing sse connection for cart %s", cartId)
+		defer h.closeCartEventStream(stream)
+
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetryInterval.Milliseconds())
+
+		for _, notification := range stream.backlog {
+			if err := writeServerSentEvent(w, notification); err != nil {
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(sseKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case notification, ok := <-stream.Events():
+				if !ok {
+					return
+				}
+				if !stream.accept(notification) {
+					continue
+				}
+				if err := writeServerSentEvent(w, notification); err != nil {
+					h.logger.Err(err).Msgf("failed to write sse event")
+					return
+				}
+			case <-keepAlive.C:
+				// Comments are ignored by clients but let us notice dead connections
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+
+			// Flushing fails once the client has gone away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// StaffEvents streams the anomalies raised and resolved in every cart, and the changes of health
+// of the devices, as text/event-stream. Nothing is replayed, the open anomalies being listed by
+// the anomalies endpoint and the health of the devices by the fleet endpoint instead.
+func (h *Handler) StaffEvents(ctx *fiber.Ctx) error {
+	sub := h.broker.Subscribe(staffTopic)
+
+	h.logger.Printf("sse connection for staff")
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.logger.Printf("closing sse connection for staff")
+		defer h.broker.Unsubscribe(sub)
+
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetryInterval.Milliseconds())
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(sseKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case notification, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if err := writeServerSentEvent(w, notification); err != nil {
+					h.logger.Err(err).Msgf("failed to write sse event")
+					return
+				}
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+func writeServerSentEvent(w *bufio.Writer, notification CartEventWebsocketNotification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	if notification.Sequence != 0 {
+		fmt.Fprintf(w, "id: %d\n", notification.Sequence)
+	}
+
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
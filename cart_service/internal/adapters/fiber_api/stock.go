@@ -0,0 +1,105 @@
+package fiber_api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) ListStock(ctx *fiber.Ctx) error {
+	lowOnly := false
+	if v := ctx.Query("low"); v != "" {
+		var err error
+		if lowOnly, err = strconv.ParseBool(v); err != nil {
+			return newError(fiber.StatusBadRequest, errors.New("low must be true or false"))
+		}
+	}
+
+	levels, err := h.stockRepo.ListStock(lowOnly)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(levels)
+}
+
+func (h *Handler) GetStock(ctx *fiber.Ctx) error {
+	level, err := h.stockRepo.GetStock(ctx.Params("product_id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(level)
+}
+
+func (h *Handler) TrackStock(ctx *fiber.Ctx) error {
+	var request TrackStockRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	productId := ctx.Params("product_id")
+
+	level, err := h.stockRepo.TrackStock(productId, *request.LowStockThreshold)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("TrackStock: %s with low stock threshold %d", productId, level.LowStockThreshold)
+
+	return ctx.JSON(level)
+}
+
+func (h *Handler) ListStockAdjustments(ctx *fiber.Ctx) error {
+	adjustments, err := h.stockRepo.ListAdjustments(ctx.Params("product_id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(adjustments)
+}
+
+func (h *Handler) AdjustStock(ctx *fiber.Ctx) error {
+	var request StockAdjustmentRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	adjustment := request.ToStockAdjustment(ctx.Params("product_id"))
+
+	level, err := h.stockRepo.AdjustStock(adjustment)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("AdjustStock: %s by %d (%s), %d on hand", adjustment.ProductID, adjustment.Delta, adjustment.Reason, level.OnHand)
+	if level.Low {
+		h.logger.Warn().Msgf("Low stock: %s has %d units on hand, threshold is %d", level.ProductID, level.OnHand, level.LowStockThreshold)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(StockAdjustmentResponse{
+		Adjustment: adjustment,
+		Stock:      level,
+	})
+}
+
+// ListStockAlerts returns the products that dropped to their low stock threshold and were not restocked since.
+func (h *Handler) ListStockAlerts(ctx *fiber.Ctx) error {
+	alerts, err := h.stockRepo.ListAlerts()
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(alerts)
+}
@@ -0,0 +1,61 @@
+package fiber_api
+
+import (
+	"github.com/fsmiamoto/zcart/cart_service/internal/ids"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) ListTaxRules(ctx *fiber.Ctx) error {
+	rules, err := h.taxRuleRepo.ListTaxRules()
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(rules)
+}
+
+func (h *Handler) GetTaxRule(ctx *fiber.Ctx) error {
+	rule, err := h.taxRuleRepo.GetTaxRule(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(rule)
+}
+
+func (h *Handler) CreateTaxRule(ctx *fiber.Ctx) error {
+	var request TaxRuleRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	rule := request.ToTaxRule()
+	if rule.ID == "" {
+		rule.ID = ids.New()
+	}
+
+	if err := h.taxRuleRepo.CreateTaxRule(rule); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("CreateTaxRule: %s (%s at %d bp) on tax class %s", rule.ID, rule.Tax, rule.Rate, rule.TaxClass)
+
+	return ctx.Status(fiber.StatusCreated).JSON(rule)
+}
+
+func (h *Handler) DeleteTaxRule(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+
+	if err := h.taxRuleRepo.DeleteTaxRule(id); err != nil {
+		return err
+	}
+
+	h.logger.Info().Msgf("DeleteTaxRule: %s", id)
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
@@ -1,8 +1,6 @@
 package fiber_api
 
 import (
-	// "encoding/json"
-
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
@@ -12,44 +10,64 @@ func (h *Handler) WebsocketHandler(ctx *fiber.Ctx) error {
 		return fiber.ErrUpgradeRequired
 	}
 
+	if _, _, err := parseCursor(ctx.Query("since")); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
 	cartId := ctx.Params("id")
+	if _, err := h.cartRepo.GetCart(cartId); err != nil {
+		return err
+	}
+
 	h.logger.Printf("websocket connection for cart %s", cartId)
-	h.setupUpdateChannel(cartId)
 	return ctx.Next()
 }
 
-func (h *Handler) setupUpdateChannel(cartId string) {
-	if _, found := h.channels[cartId]; !found {
-		h.channels[cartId] = make(chan CartEventWebsocketNotification)
-	}
-}
-
 func (h *Handler) WebsocketManager(c *websocket.Conn) {
 	cartId := c.Params("id")
 
 	h.logger.Printf("creating new websocket connection")
 	defer h.logger.Printf("closing websocket connection")
 
-	type websocketMessage struct {
-		messageType int
-		payload     []byte
+	since, resume, _ := parseCursor(c.Query("since"))
+
+	stream, err := h.openCartEventStream(cartId, since, resume)
+	if err != nil {
+		h.logger.Err(err).Msgf("failed to open event stream of cart %s", cartId)
+		return
 	}
+	defer h.closeCartEventStream(stream)
 
-	var (
+	for _, notification := range stream.backlog {
+		if err := c.WriteJSON(notification); err != nil {
+			h.logger.Err(err).Msgf("failed to write message")
+			return
+		}
+	}
+
+	type websocketMessage struct {
 		messageType int
 		payload     []byte
-		err         error
-	)
+	}
 
 	readerChannel := make(chan websocketMessage)
+	readerDone := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
 
 	reader := func(ch chan<- websocketMessage) {
+		defer close(readerDone)
 		for {
-			if messageType, payload, err = c.ReadMessage(); err != nil {
+			messageType, payload, err := c.ReadMessage()
+			if err != nil {
 				h.logger.Printf("error: %s", err)
 				return
 			}
-			ch <- websocketMessage{messageType, payload}
+			select {
+			case ch <- websocketMessage{messageType, payload}:
+			case <-stop:
+				return
+			}
 		}
 	}
 
@@ -65,29 +83,36 @@ func (h *Handler) WebsocketManager(c *websocket.Conn) {
 
 	go reader(readerChannel)
 
-	defer func() {
-		delete(h.channels, cartId)
-	}()
-
 	for {
 		select {
 		case msg := <-readerChannel:
 			h.logger.Printf("payload: %s", string(msg.payload))
-			if err = c.WriteMessage(messageType, payload); err != nil {
+			if err := c.WriteMessage(msg.messageType, msg.payload); err != nil {
 				h.logger.Err(err).Msgf("failed to write message")
 				return
 			}
-		case action := <-h.channels[cartId]:
-			h.logger.Printf("update for cart %s", action.CartProduct.CartID)
+		case action, ok := <-stream.Events():
+			if !ok {
+				return
+			}
+
+			if !stream.accept(action) {
+				continue
+			}
+
+			h.logger.Printf("update for cart %s", cartId)
 
 			if err := c.WriteJSON(action); err != nil {
 				h.logger.Err(err).Msgf("failed to write message")
 				return
 			}
 
-			h.logger.Info().Msgf("notified clients of cart %s", action.CartProduct.CartID)
+			h.logger.Info().Msgf("notified clients of cart %s", cartId)
 		case <-closeChannel:
 			return
+		case <-readerDone:
+			// Connection dropped without a close frame
+			return
 		}
 	}
 }
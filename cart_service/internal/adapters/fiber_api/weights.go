@@ -0,0 +1,131 @@
+package fiber_api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) GetCartWeight(ctx *fiber.Ctx) error {
+	weight, err := h.weightRepo.GetCartWeight(ctx.Params("cart_id"))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(weight)
+}
+
+// PostCartWeight reconciles a reading of the scale of the cart with what its lines weigh, raising a weight
+// mismatch anomaly when they keep disagreeing. Only the carts being shopped are reconciled.
+func (h *Handler) PostCartWeight(ctx *fiber.Ctx) error {
+	var request WeightReadingRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return newError(fiber.StatusBadRequest, err)
+	}
+
+	cartId := ctx.Params("cart_id")
+
+	cart, err := h.cartRepo.GetCart(cartId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	current := &models.CartWeight{
+		CartID:        cartId,
+		MeasuredGrams: *request.Grams,
+		MeasuredAt:    now,
+	}
+
+	if cart.Status == models.CartOpen || cart.Status == models.CartCheckingOut {
+		if current.ExpectedGrams, current.Reconciled, err = h.weightRepo.ExpectedWeight(cartId); err != nil {
+			return err
+		}
+	}
+
+	previous, err := h.weightRepo.GetCartWeight(cartId)
+	if err != nil && !errors.Is(err, repository.ErrCartWeightNotFound) {
+		return err
+	}
+
+	open, err := h.weightRepo.GetOpenAnomaly(cartId, models.AnomalyWeightMismatch)
+	if err != nil && !errors.Is(err, repository.ErrAnomalyNotFound) {
+		return err
+	}
+
+	action := h.verifier.ReconcileWeight(previous, current, open != nil)
+
+	if err := h.weightRepo.SaveCartWeight(current); err != nil {
+		return err
+	}
+
+	switch action {
+	case recognition.RaiseAnomaly:
+		anomaly := &models.CartAnomaly{
+			CartID:        cartId,
+			Kind:          models.AnomalyWeightMismatch,
+			ExpectedGrams: current.ExpectedGrams,
+			MeasuredGrams: current.MeasuredGrams,
+			Since:         *current.MismatchSince,
+			CreatedAt:     now,
+		}
+		if err := h.weightRepo.CreateAnomaly(anomaly); err != nil {
+			return err
+		}
+
+		h.logger.Warn().Msgf(
+			"Weight mismatch: cart %s weighs %.0fg but its lines %.0fg since %s",
+			cartId, current.MeasuredGrams, current.ExpectedGrams, anomaly.Since.Format(time.RFC3339),
+		)
+		h.publishAnomaly(WeightMismatchEvent, anomaly)
+	case recognition.ResolveAnomaly:
+		open.ResolvedAt = &now
+		if err := h.weightRepo.ResolveAnomaly(open); err != nil {
+			return err
+		}
+
+		h.logger.Info().Msgf("Weight reconciled: cart %s weighs %.0fg as expected", cartId, current.MeasuredGrams)
+		h.publishAnomaly(WeightReconciledEvent, open)
+	}
+
+	return ctx.JSON(current)
+}
+
+// ListAnomalies returns the anomalies of every cart, newest first.
+func (h *Handler) ListAnomalies(ctx *fiber.Ctx) error {
+	openOnly := false
+	if v := ctx.Query("open"); v != "" {
+		var err error
+		if openOnly, err = strconv.ParseBool(v); err != nil {
+			return newError(fiber.StatusBadRequest, errors.New("open must be true or false"))
+		}
+	}
+
+	anomalies, err := h.weightRepo.ListAnomalies(openOnly)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(anomalies)
+}
+
+// publishAnomaly lets both the clients of the cart and the staff know about the anomaly.
+func (h *Handler) publishAnomaly(event CartEvent, anomaly *models.CartAnomaly) {
+	notification := CartEventWebsocketNotification{
+		Event:   event,
+		Anomaly: anomaly,
+	}
+
+	h.publish(anomaly.CartID, notification)
+	h.broker.Publish(staffTopic, notification)
+}
@@ -0,0 +1,41 @@
+// Package devices handles the credentials and the health of the physical carts, whose
+// recognizer and display operate on the cart they are bound to.
+package devices
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+)
+
+// secretSize is the number of random bytes of a secret
+const secretSize = 32
+
+// NewSecret returns a random secret for a device along with the hash to be stored in its place.
+// The secret itself is only handed out once, when the device is registered.
+func NewSecret() (secret string, hash string) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return secret, HashSecret(secret)
+}
+
+// HashSecret hashes a secret for storage. Being random and long, secrets need no salt nor a slow hash.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate tells whether the secret is the one of the device.
+func Authenticate(device *models.Device, secret string) bool {
+	if device == nil || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(device.SecretHash)) == 1
+}
@@ -0,0 +1,31 @@
+package devices_test
+
+import (
+	"testing"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/devices"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSecret(t *testing.T) {
+	secret, hash := devices.NewSecret()
+
+	assert.Len(t, secret, 43)
+	assert.Equal(t, devices.HashSecret(secret), hash)
+	assert.NotEqual(t, secret, hash)
+
+	other, _ := devices.NewSecret()
+	assert.NotEqual(t, secret, other)
+}
+
+func TestAuthenticate(t *testing.T) {
+	secret, hash := devices.NewSecret()
+	device := &models.Device{ID: "cart-01", SecretHash: hash}
+
+	assert.True(t, devices.Authenticate(device, secret))
+	assert.False(t, devices.Authenticate(device, secret+"x"))
+	assert.False(t, devices.Authenticate(device, hash))
+	assert.False(t, devices.Authenticate(device, ""))
+	assert.False(t, devices.Authenticate(nil, secret))
+}
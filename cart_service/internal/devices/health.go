@@ -0,0 +1,85 @@
+package devices
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+)
+
+const (
+	// DefaultOfflineAfter is how long a device may go without a heartbeat before it is offline.
+	DefaultOfflineAfter = 30 * time.Second
+	// DefaultHeartbeatRetention is how long the heartbeats are kept.
+	DefaultHeartbeatRetention = 24 * time.Hour
+	// DefaultMinCameraFPS is the frame rate below which detections are missed.
+	DefaultMinCameraFPS = 5
+	// DefaultMaxDetectorLatencyMs is how long the detector may take for a frame.
+	DefaultMaxDetectorLatencyMs = 500
+	// DefaultMinBatteryPercent is the charge below which the cart should be taken to be charged.
+	DefaultMinBatteryPercent = 20
+)
+
+type HealthConfig struct {
+	OfflineAfter         time.Duration
+	HeartbeatRetention   time.Duration
+	MinCameraFPS         float64
+	MaxDetectorLatencyMs float64
+	MinBatteryPercent    float64
+}
+
+// Monitor tells how the devices are doing from their heartbeats, so the whole fleet is judged by the same rules.
+type Monitor struct {
+	config HealthConfig
+}
+
+func NewMonitor(config HealthConfig) *Monitor {
+	return &Monitor{config}
+}
+
+// Health tells how a device is doing from its last heartbeat, nil if it never sent one,
+// along with what is wrong with it.
+func (m *Monitor) Health(last *models.Heartbeat, now time.Time) (models.DeviceHealth, []string) {
+	if last == nil {
+		return models.DeviceOffline, []string{"never sent a heartbeat"}
+	}
+
+	if silent := now.Sub(last.ReceivedAt); silent > m.config.OfflineAfter {
+		return models.DeviceOffline, []string{fmt.Sprintf("no heartbeat for %s", silent.Round(time.Second))}
+	}
+
+	problems := make([]string, 0)
+
+	switch {
+	case last.CameraFPS == nil:
+		problems = append(problems, "camera is not reporting")
+	case *last.CameraFPS < m.config.MinCameraFPS:
+		problems = append(problems, fmt.Sprintf("camera at %.1f fps", *last.CameraFPS))
+	}
+
+	switch {
+	case last.DetectorLatencyMs == nil:
+		problems = append(problems, "detector is not reporting")
+	case *last.DetectorLatencyMs > m.config.MaxDetectorLatencyMs:
+		problems = append(problems, fmt.Sprintf("detector takes %.0fms a frame", *last.DetectorLatencyMs))
+	}
+
+	if last.ScaleGrams == nil {
+		problems = append(problems, "scale is not reporting")
+	}
+
+	if last.BatteryPercent != nil && *last.BatteryPercent < m.config.MinBatteryPercent {
+		problems = append(problems, fmt.Sprintf("battery at %.0f%%", *last.BatteryPercent))
+	}
+
+	if len(problems) > 0 {
+		return models.DeviceDegraded, problems
+	}
+
+	return models.DeviceOnline, problems
+}
+
+// RetainedSince is when the oldest heartbeat still kept was received.
+func (m *Monitor) RetainedSince(now time.Time) time.Time {
+	return now.Add(-m.config.HeartbeatRetention)
+}
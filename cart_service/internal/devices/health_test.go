@@ -0,0 +1,108 @@
+package devices_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/devices"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func value(v float64) *float64 {
+	return &v
+}
+
+func TestHealth(t *testing.T) {
+	monitor := devices.NewMonitor(devices.HealthConfig{
+		OfflineAfter:         30 * time.Second,
+		HeartbeatRetention:   time.Hour,
+		MinCameraFPS:         5,
+		MaxDetectorLatencyMs: 500,
+		MinBatteryPercent:    20,
+	})
+
+	now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	healthy := func() *models.Heartbeat {
+		return &models.Heartbeat{
+			DeviceID:          "cart-01",
+			CameraFPS:         value(12),
+			DetectorLatencyMs: value(80),
+			ScaleGrams:        value(387),
+			BatteryPercent:    value(76),
+			ReceivedAt:        now.Add(-5 * time.Second),
+		}
+	}
+
+	t.Run("Online", func(t *testing.T) {
+		health, problems := monitor.Health(healthy(), now)
+		assert.Equal(t, models.DeviceOnline, health)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("Online without battery", func(t *testing.T) {
+		heartbeat := healthy()
+		heartbeat.BatteryPercent = nil
+
+		health, _ := monitor.Health(heartbeat, now)
+		assert.Equal(t, models.DeviceOnline, health)
+	})
+
+	t.Run("Offline without heartbeats", func(t *testing.T) {
+		health, problems := monitor.Health(nil, now)
+		assert.Equal(t, models.DeviceOffline, health)
+		assert.Equal(t, []string{"never sent a heartbeat"}, problems)
+	})
+
+	t.Run("Offline when heartbeats stop", func(t *testing.T) {
+		heartbeat := healthy()
+		heartbeat.ReceivedAt = now.Add(-45 * time.Second)
+
+		health, problems := monitor.Health(heartbeat, now)
+		assert.Equal(t, models.DeviceOffline, health)
+		assert.Equal(t, []string{"no heartbeat for 45s"}, problems)
+	})
+
+	t.Run("Still online right at the limit", func(t *testing.T) {
+		heartbeat := healthy()
+		heartbeat.ReceivedAt = now.Add(-30 * time.Second)
+
+		health, _ := monitor.Health(heartbeat, now)
+		assert.Equal(t, models.DeviceOnline, health)
+	})
+
+	t.Run("Degraded", func(t *testing.T) {
+		heartbeat := healthy()
+		heartbeat.CameraFPS = value(2)
+		heartbeat.DetectorLatencyMs = value(900)
+		heartbeat.ScaleGrams = nil
+		heartbeat.BatteryPercent = value(12)
+
+		health, problems := monitor.Health(heartbeat, now)
+		assert.Equal(t, models.DeviceDegraded, health)
+		assert.Equal(t, []string{
+			"camera at 2.0 fps",
+			"detector takes 900ms a frame",
+			"scale is not reporting",
+			"battery at 12%",
+		}, problems)
+	})
+
+	t.Run("Degraded without camera", func(t *testing.T) {
+		heartbeat := healthy()
+		heartbeat.CameraFPS = nil
+		heartbeat.DetectorLatencyMs = nil
+
+		health, problems := monitor.Health(heartbeat, now)
+		assert.Equal(t, models.DeviceDegraded, health)
+		assert.Equal(t, []string{"camera is not reporting", "detector is not reporting"}, problems)
+	})
+}
+
+func TestRetainedSince(t *testing.T) {
+	monitor := devices.NewMonitor(devices.HealthConfig{HeartbeatRetention: time.Hour})
+
+	now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+	assert.Equal(t, now.Add(-time.Hour), monitor.RetainedSince(now))
+}
@@ -0,0 +1,193 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy decides what happens when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// DropNewest discards the event being published for that subscriber.
+	DropNewest Policy = iota
+	// DropOldest evicts the oldest buffered event to make room for the new one.
+	DropOldest
+	// Block waits up to Options.BlockTimeout for room, then drops the event.
+	Block
+)
+
+const DefaultBufferSize = 16
+
+type Options struct {
+	BufferSize   int
+	Policy       Policy
+	BlockTimeout time.Duration
+}
+
+type Metrics struct {
+	Published   uint64 `json:"published"`
+	Delivered   uint64 `json:"delivered"`
+	Dropped     uint64 `json:"dropped"`
+	Subscribers int    `json:"subscribers"`
+	Topics      int    `json:"topics"`
+}
+
+// Broker is a publish/subscribe hub where every subscriber of a topic
+// gets its own bounded buffer and receives every event published to it.
+// It is safe for concurrent use.
+type Broker[T any] struct {
+	opts Options
+
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription[T]]struct{}
+
+	published uint64
+	delivered uint64
+	dropped   uint64
+}
+
+type Subscription[T any] struct {
+	topic   string
+	events  chan T
+	dropped uint64
+	closed  bool
+}
+
+// Events is closed once the subscription is cancelled.
+func (s *Subscription[T]) Events() <-chan T {
+	return s.events
+}
+
+func (s *Subscription[T]) Topic() string {
+	return s.topic
+}
+
+// Dropped returns how many events this subscriber missed because its buffer was full.
+func (s *Subscription[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func NewBroker[T any](opts Options) *Broker[T] {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	return &Broker[T]{
+		opts:   opts,
+		topics: make(map[string]map[*Subscription[T]]struct{}),
+	}
+}
+
+func (b *Broker[T]) Subscribe(topic string) *Subscription[T] {
+	s := &Subscription[T]{
+		topic:  topic,
+		events: make(chan T, b.opts.BufferSize),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*Subscription[T]]struct{})
+	}
+	b.topics[topic][s] = struct{}{}
+
+	return s
+}
+
+// Unsubscribe removes the subscription and closes its channel. It is safe to call more than once.
+func (b *Broker[T]) Unsubscribe(s *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	delete(b.topics[s.topic], s)
+	if len(b.topics[s.topic]) == 0 {
+		delete(b.topics, s.topic)
+	}
+
+	close(s.events)
+}
+
+// Publish delivers event to every current subscriber of topic and
+// returns how many received it and how many had it dropped.
+func (b *Broker[T]) Publish(topic string, event T) (delivered int, dropped int) {
+	// Holding the read lock keeps Unsubscribe from closing a channel we are sending on
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	atomic.AddUint64(&b.published, 1)
+
+	for s := range b.topics[topic] {
+		if b.deliver(s, event) {
+			delivered++
+		} else {
+			dropped++
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+
+	atomic.AddUint64(&b.delivered, uint64(delivered))
+	atomic.AddUint64(&b.dropped, uint64(dropped))
+
+	return delivered, dropped
+}
+
+func (b *Broker[T]) deliver(s *Subscription[T], event T) bool {
+	select {
+	case s.events <- event:
+		return true
+	default:
+	}
+
+	switch b.opts.Policy {
+	case DropOldest:
+		// The subscriber may drain the buffer concurrently, so both steps are non-blocking
+		select {
+		case <-s.events:
+			atomic.AddUint64(&s.dropped, 1)
+			atomic.AddUint64(&b.dropped, 1)
+		default:
+		}
+		select {
+		case s.events <- event:
+			return true
+		default:
+			return false
+		}
+	case Block:
+		timer := time.NewTimer(b.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case s.events <- event:
+			return true
+		case <-timer.C:
+			return false
+		}
+	default:
+		return false
+	}
+}
+
+func (b *Broker[T]) Metrics() Metrics {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	subscribers := 0
+	for _, subs := range b.topics {
+		subscribers += len(subs)
+	}
+
+	return Metrics{
+		Published:   atomic.LoadUint64(&b.published),
+		Delivered:   atomic.LoadUint64(&b.delivered),
+		Dropped:     atomic.LoadUint64(&b.dropped),
+		Subscribers: subscribers,
+		Topics:      len(b.topics),
+	}
+}
@@ -0,0 +1,187 @@
+package events_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(ch <-chan int) []int {
+	var got []int
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, v)
+		default:
+			return got
+		}
+	}
+}
+
+func TestBroker(t *testing.T) {
+	t.Run("Fan out to every subscriber of a topic", func(t *testing.T) {
+		broker := events.NewBroker[int](events.Options{})
+
+		a := broker.Subscribe("cart-1")
+		b := broker.Subscribe("cart-1")
+		other := broker.Subscribe("cart-2")
+
+		delivered, dropped := broker.Publish("cart-1", 42)
+		assert.Equal(t, 2, delivered)
+		assert.Equal(t, 0, dropped)
+
+		assert.Equal(t, []int{42}, drain(a.Events()))
+		assert.Equal(t, []int{42}, drain(b.Events()))
+		assert.Empty(t, drain(other.Events()))
+	})
+
+	t.Run("Unsubscribing one does not affect the others", func(t *testing.T) {
+		broker := events.NewBroker[int](events.Options{})
+
+		a := broker.Subscribe("cart-1")
+		b := broker.Subscribe("cart-1")
+
+		broker.Unsubscribe(a)
+		broker.Unsubscribe(a)
+
+		_, ok := <-a.Events()
+		assert.False(t, ok, "channel should be closed")
+
+		delivered, _ := broker.Publish("cart-1", 7)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, []int{7}, drain(b.Events()))
+
+		broker.Unsubscribe(b)
+		assert.Equal(t, events.Metrics{Published: 1, Delivered: 1}, broker.Metrics())
+	})
+
+	t.Run("Publishing without subscribers", func(t *testing.T) {
+		broker := events.NewBroker[int](events.Options{})
+
+		delivered, dropped := broker.Publish("nobody", 1)
+		assert.Zero(t, delivered)
+		assert.Zero(t, dropped)
+	})
+
+	t.Run("Policies", func(t *testing.T) {
+		t.Run("DropNewest", func(t *testing.T) {
+			broker := events.NewBroker[int](events.Options{BufferSize: 2, Policy: events.DropNewest})
+			sub := broker.Subscribe("cart")
+
+			for i := 1; i <= 4; i++ {
+				broker.Publish("cart", i)
+			}
+
+			assert.Equal(t, []int{1, 2}, drain(sub.Events()))
+			assert.Equal(t, uint64(2), sub.Dropped())
+			assert.Equal(t, uint64(2), broker.Metrics().Dropped)
+		})
+
+		t.Run("DropOldest", func(t *testing.T) {
+			broker := events.NewBroker[int](events.Options{BufferSize: 2, Policy: events.DropOldest})
+			sub := broker.Subscribe("cart")
+
+			for i := 1; i <= 4; i++ {
+				delivered, _ := broker.Publish("cart", i)
+				assert.Equal(t, 1, delivered)
+			}
+
+			assert.Equal(t, []int{3, 4}, drain(sub.Events()))
+			assert.Equal(t, uint64(2), sub.Dropped())
+		})
+
+		t.Run("Block waits for the subscriber", func(t *testing.T) {
+			broker := events.NewBroker[int](events.Options{BufferSize: 1, Policy: events.Block, BlockTimeout: time.Second})
+			sub := broker.Subscribe("cart")
+
+			broker.Publish("cart", 1)
+
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				<-sub.Events()
+			}()
+
+			delivered, dropped := broker.Publish("cart", 2)
+			assert.Equal(t, 1, delivered)
+			assert.Equal(t, 0, dropped)
+			assert.Equal(t, []int{2}, drain(sub.Events()))
+		})
+
+		t.Run("Block gives up after the timeout", func(t *testing.T) {
+			broker := events.NewBroker[int](events.Options{BufferSize: 1, Policy: events.Block, BlockTimeout: time.Millisecond})
+			sub := broker.Subscribe("cart")
+
+			broker.Publish("cart", 1)
+			delivered, dropped := broker.Publish("cart", 2)
+
+			assert.Equal(t, 0, delivered)
+			assert.Equal(t, 1, dropped)
+			assert.Equal(t, uint64(1), sub.Dropped())
+		})
+	})
+
+	t.Run("Concurrent publish, subscribe and unsubscribe", func(t *testing.T) {
+		const (
+			topics     = 4
+			publishers = 8
+			perWorker  = 200
+		)
+
+		broker := events.NewBroker[int](events.Options{BufferSize: 8, Policy: events.DropOldest})
+
+		var wg sync.WaitGroup
+
+		for p := 0; p < publishers; p++ {
+			wg.Add(1)
+			go func(p int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					broker.Publish(fmt.Sprintf("cart-%d", (p+i)%topics), i)
+				}
+			}(p)
+		}
+
+		for c := 0; c < topics*2; c++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				for i := 0; i < perWorker/10; i++ {
+					sub := broker.Subscribe(fmt.Sprintf("cart-%d", c%topics))
+					for j := 0; j < 5; j++ {
+						select {
+						case <-sub.Events():
+						default:
+						}
+					}
+					broker.Unsubscribe(sub)
+				}
+			}(c)
+		}
+
+		// A long-lived reader that keeps consuming until its subscription is closed
+		long := broker.Subscribe("cart-0")
+		readerDone := make(chan struct{})
+		go func() {
+			defer close(readerDone)
+			for range long.Events() {
+			}
+		}()
+
+		wg.Wait()
+		broker.Unsubscribe(long)
+		<-readerDone
+
+		metrics := broker.Metrics()
+		require.Equal(t, uint64(publishers*perWorker), metrics.Published)
+		assert.Zero(t, metrics.Subscribers)
+		assert.Zero(t, metrics.Topics)
+	})
+}
@@ -0,0 +1,58 @@
+package fiscal
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// Model 65 is the NFC-e, model 55 being the NF-e between businesses
+	Model = 65
+	// normalEmission is the tpEmis of documents authorized online
+	normalEmission = 1
+)
+
+// AccessKey is the 44 digit key identifying a fiscal document nationwide.
+type AccessKey string
+
+// NewAccessKey composes the key out of the state, month, issuer, series and number of the document.
+// Its code is the random part of the key, keeping it from being guessed.
+func NewAccessKey(ufCode string, issuedAt time.Time, cnpj string, series uint, number uint, code string) AccessKey {
+	digits := fmt.Sprintf(
+		"%s%s%s%02d%03d%09d%d%s",
+		ufCode, issuedAt.In(brasilia).Format("0601"), cnpj, Model, series, number, normalEmission, code,
+	)
+	return AccessKey(fmt.Sprintf("%s%d", digits, checkDigit(digits, 9)))
+}
+
+func (k AccessKey) Valid() bool {
+	return isDigits(string(k), 44, 44) && checkDigit(string(k[:43]), 9) == int(k[43]-'0')
+}
+
+// Code is the 8 digit cNF of the document.
+func (k AccessKey) Code() string {
+	return string(k[35:43])
+}
+
+func (k AccessKey) CheckDigit() int {
+	return int(k[43] - '0')
+}
+
+// maxDocumentCode bounds the 8 digit cNF
+var maxDocumentCode = big.NewInt(100000000)
+
+// documentCode draws the cNF of the document at random, so the key can't be derived from the order.
+// It is kept with the document as part of its key, and must not equal its number.
+func documentCode(number uint) (string, error) {
+	for {
+		code, err := rand.Int(rand.Reader, maxDocumentCode)
+		if err != nil {
+			return "", err
+		}
+		if code.Uint64() != uint64(number) {
+			return fmt.Sprintf("%08d", code.Uint64()), nil
+		}
+	}
+}
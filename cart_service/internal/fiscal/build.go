@@ -0,0 +1,252 @@
+package fiscal
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments/pix"
+)
+
+const (
+	withoutGTIN = "SEM GTIN"
+	// homologationItem must replace the name of the first item of documents with no fiscal value
+	homologationItem = "NOTA FISCAL EMITIDA EM AMBIENTE DE HOMOLOGACAO - SEM VALOR FISCAL"
+	maxItemName      = 120
+	// simplesNacional is the CRT of the issuer
+	simplesNacional = 1
+	// qrCodeVersion 2 is the QR code of documents authorized online
+	qrCodeVersion = 2
+	zero          = "0.00"
+)
+
+// The tPag of the payments.
+const (
+	paidWithCreditCard = "03"
+	paidWithPix        = "17"
+	notPaid            = "90"
+	paidWithOther      = "99"
+)
+
+// Build lays out the unsigned NFC-e of the order, numbered number in the series of the config.
+// products holds the fiscal data of the products in the order, and payment is how the order was
+// paid for, when it was.
+func Build(config Config, order *models.Order, products map[string]*models.ProductFiscal, payment *models.PaymentIntent, number uint, issuedAt time.Time) (*NFe, error) {
+	if order.Total.Currency != money.DefaultCurrency {
+		return nil, fmt.Errorf("order %s is not in %s", order.ID, money.DefaultCurrency)
+	}
+
+	code, err := documentCode(number)
+	if err != nil {
+		return nil, err
+	}
+
+	key := NewAccessKey(config.ufCode(), issuedAt, config.Issuer.CNPJ, config.Series, number, code)
+	discounts := lineDiscounts(order)
+
+	nfe := &NFe{
+		InfNFe: InfNFe{
+			Versao: Version,
+			ID:     "NFe" + string(key),
+			Ide: Ide{
+				CUF:      config.ufCode(),
+				CNF:      key.Code(),
+				NatOp:    "VENDA",
+				Mod:      Model,
+				Serie:    config.Series,
+				NNF:      number,
+				DhEmi:    issuedAt.In(brasilia).Format(time.RFC3339),
+				TpNF:     1,
+				IDDest:   1,
+				CMunFG:   config.Issuer.CityCode,
+				TpImp:    4,
+				TpEmis:   normalEmission,
+				CDV:      key.CheckDigit(),
+				TpAmb:    int(config.Environment),
+				FinNFe:   1,
+				IndFinal: 1,
+				IndPres:  1,
+				VerProc:  "zcart",
+			},
+			Emit: Emit{
+				CNPJ:  config.Issuer.CNPJ,
+				XNome: config.Issuer.Name,
+				XFant: config.Issuer.TradeName,
+				EnderEmit: Ender{
+					XLgr:    config.Issuer.Street,
+					Nro:     config.Issuer.Number,
+					XBairro: config.Issuer.District,
+					CMun:    config.Issuer.CityCode,
+					XMun:    config.Issuer.City,
+					UF:      config.Issuer.UF,
+					CEP:     config.Issuer.CEP,
+					CPais:   "1058",
+					XPais:   "BRASIL",
+				},
+				IE:  config.Issuer.IE,
+				CRT: simplesNacional,
+			},
+			Det:     make([]Det, 0, len(order.Lines)),
+			Transp:  Transp{ModFrete: 9},
+			Pag:     Pag{DetPag: []DetPag{paidWith(order, payment)}},
+			InfAdic: &InfAdic{InfCpl: "Pedido " + order.ID},
+		},
+		InfNFeSupl: InfNFeSupl{
+			QRCode:   qrCode(config, key),
+			URLChave: config.ConsultURL,
+		},
+	}
+
+	var subtotal, discount, other, taxes int64
+
+	for i, line := range order.Lines {
+		fiscal := products[line.ProductID]
+		if fiscal == nil || fiscal.NCM == "" {
+			return nil, fmt.Errorf("%w: %s", ErrProductFiscalData, line.ProductID)
+		}
+
+		name := line.Name
+		if i == 0 && config.Environment == Homologation {
+			name = homologationItem
+		}
+		if len([]rune(name)) > maxItemName {
+			name = string([]rune(name)[:maxItemName])
+		}
+
+		lineSubtotal := line.UnitPrice.Mul(int64(line.Quantity))
+		quantity := fmt.Sprintf("%d.0000", line.Quantity)
+
+		det := Det{
+			NItem: i + 1,
+			Prod: Prod{
+				CProd:    line.ProductID,
+				CEAN:     withoutGTIN,
+				XProd:    name,
+				NCM:      fiscal.NCM,
+				CEST:     fiscal.CEST,
+				CFOP:     fiscal.CFOP,
+				UCom:     fiscal.Unit,
+				QCom:     quantity,
+				VUnCom:   line.UnitPrice.String(),
+				VProd:    lineSubtotal.String(),
+				CEANTrib: withoutGTIN,
+				UTrib:    fiscal.Unit,
+				QTrib:    quantity,
+				VUnTrib:  line.UnitPrice.String(),
+				IndTot:   1,
+			},
+			Imposto: imposto(fiscal),
+		}
+		if discounts[i].Amount > 0 {
+			det.Prod.VDesc = discounts[i].String()
+		}
+		added := addedTax(line)
+		if added > 0 {
+			det.Prod.VOutro = money.Cents(added).String()
+		}
+		if line.Tax.Amount > 0 {
+			det.Imposto.VTotTrib = line.Tax.String()
+		}
+
+		nfe.InfNFe.Det = append(nfe.InfNFe.Det, det)
+		subtotal += lineSubtotal.Amount
+		discount += discounts[i].Amount
+		other += added
+		taxes += line.Tax.Amount
+	}
+
+	if total := subtotal - discount + other; total != order.Total.Amount {
+		return nil, fmt.Errorf("order %s: items add up to %s, not to its total of %s", order.ID, money.Cents(total), order.Total)
+	}
+
+	nfe.InfNFe.Total = Total{ICMSTot: ICMSTot{
+		VBC: zero, VICMS: zero, VICMSDeson: zero, VFCP: zero, VBCST: zero, VST: zero, VFCPST: zero, VFCPSTRet: zero,
+		VProd:  money.Cents(subtotal).String(),
+		VFrete: zero, VSeg: zero,
+		VDesc: money.Cents(discount).String(),
+		VII:   zero, VIPI: zero, VIPIDevol: zero, VPIS: zero, VCOFINS: zero,
+		VOutro: money.Cents(other).String(),
+		VNF:    order.Total.String(),
+	}}
+	if taxes > 0 {
+		nfe.InfNFe.Total.ICMSTot.VTotTrib = money.Cents(taxes).String()
+	}
+
+	return nfe, nil
+}
+
+// lineDiscounts adds to the promotions of every line its share of the coupons, which are taken
+// off the whole order but must be told per item. Shares are proportional to the line totals, the
+// cents left over by rounding going to the largest line.
+func lineDiscounts(order *models.Order) []money.Money {
+	discounts := make([]money.Money, len(order.Lines))
+	weights := make([]money.Money, len(order.Lines))
+	coupons := money.Cents(order.Discount.Amount)
+
+	for i, line := range order.Lines {
+		discounts[i] = money.Cents(line.Discount.Amount)
+		weights[i] = money.Cents(line.Total.Amount)
+		coupons = coupons.Sub(discounts[i])
+	}
+
+	if coupons.Amount <= 0 {
+		return discounts
+	}
+
+	for i, share := range coupons.Allocate(weights) {
+		discounts[i] = discounts[i].Add(share)
+	}
+
+	return discounts
+}
+
+// addedTax is the part of the taxes of the line charged on top of its price.
+func addedTax(line *models.OrderLine) int64 {
+	var added int64
+	for _, tax := range line.Taxes {
+		if !tax.Inclusive {
+			added += tax.Amount.Amount
+		}
+	}
+	return added
+}
+
+func imposto(fiscal *models.ProductFiscal) Imposto {
+	icms := &ICMSSN{Orig: fiscal.Origin, CSOSN: fiscal.CSOSN}
+
+	imposto := Imposto{
+		PIS:    PIS{PISOutr: PISOutr{CST: "99", VBC: zero, PPIS: "0.0000", VPIS: zero}},
+		COFINS: COFINS{COFINSOutr: COFINSOutr{CST: "99", VBC: zero, PCOFINS: "0.0000", VCOFINS: zero}},
+	}
+	if fiscal.CSOSN == "500" {
+		imposto.ICMS.ICMSSN500 = icms
+	} else {
+		imposto.ICMS.ICMSSN102 = icms
+	}
+
+	return imposto
+}
+
+func paidWith(order *models.Order, payment *models.PaymentIntent) DetPag {
+	switch {
+	case order.Total.IsZero():
+		return DetPag{TPag: notPaid, VPag: zero}
+	case payment == nil:
+		// Orders placed before payments were taken
+		return DetPag{TPag: paidWithOther, XPag: "Outros", VPag: order.Total.String()}
+	case payment.Method == pix.Method:
+		return DetPag{TPag: paidWithPix, VPag: order.Total.String(), Card: &Card{TpIntegra: 2}}
+	default:
+		return DetPag{TPag: paidWithCreditCard, VPag: order.Total.String(), Card: &Card{TpIntegra: 2}}
+	}
+}
+
+// qrCode is the URL of the QR code printed on the DANFE, hashed with the CSC so only the issuer can make it.
+func qrCode(config Config, key AccessKey) string {
+	params := fmt.Sprintf("%s|%d|%d|%s", key, qrCodeVersion, config.Environment, strings.TrimLeft(config.CSCID, "0"))
+	hash := sha1.Sum([]byte(params + config.CSC))
+	return fmt.Sprintf("%s?p=%s|%X", config.QRCodeURL, params, hash)
+}
@@ -0,0 +1,98 @@
+package fiscal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// canonicalize writes the element in data in the Canonical XML 1.0 form, without comments, which is
+// what gets digested and signed. Only default namespaces are supported, as used by fiscal documents.
+//
+// Declarations equal to the namespace of the parent are superfluous and dropped, while the root
+// keeps the one in scope. So canonicalizing an element marshaled on its own, declaring its namespace,
+// gives the same result as canonicalizing it as a subset of the whole document.
+func canonicalize(data []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var (
+		out bytes.Buffer
+		// namespaces holds the default namespace of every open element
+		namespaces = []string{""}
+	)
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != "" {
+				return nil, errors.New("c14n: prefixed names are not supported")
+			}
+
+			parent := namespaces[len(namespaces)-1]
+			namespace := parent
+			attrs := make([]xml.Attr, 0, len(t.Attr))
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					namespace = attr.Value
+				case attr.Name.Space != "":
+					return nil, errors.New("c14n: prefixed attributes are not supported")
+				default:
+					attrs = append(attrs, attr)
+				}
+			}
+			sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name.Local < attrs[j].Name.Local })
+
+			out.WriteString("<" + t.Name.Local)
+			if namespace != parent {
+				out.WriteString(` xmlns="` + attrEscaper.Replace(namespace) + `"`)
+			}
+			for _, attr := range attrs {
+				out.WriteString(" " + attr.Name.Local + `="` + attrEscaper.Replace(attr.Value) + `"`)
+			}
+			out.WriteString(">")
+
+			namespaces = append(namespaces, namespace)
+		case xml.EndElement:
+			if len(namespaces) == 1 {
+				return nil, errors.New("c14n: unexpected end element")
+			}
+			out.WriteString("</" + t.Name.Local + ">")
+			namespaces = namespaces[:len(namespaces)-1]
+		case xml.CharData:
+			// Whitespace around the root element is not part of it
+			if len(namespaces) > 1 {
+				out.WriteString(textEscaper.Replace(string(t)))
+			}
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer(
+		"&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;",
+	)
+)
+
+// canonicalXML marshals v and canonicalizes it.
+func canonicalXML(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalize(data)
+}
@@ -0,0 +1,129 @@
+// Package fiscal issues the NFC-e, the electronic fiscal document of consumer sales in Brazil.
+package fiscal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidConfig = errors.New("invalid fiscal config")
+	// ErrProductFiscalData means a product of the order can't be invoiced without its NCM
+	ErrProductFiscalData = errors.New("product is missing fiscal data")
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrSEFAZUnavailable  = errors.New("SEFAZ unavailable")
+)
+
+type Environment int
+
+const (
+	Production Environment = 1
+	// Homologation is the test environment of the SEFAZ, its documents having no fiscal value
+	Homologation Environment = 2
+)
+
+// Issuer is the store issuing the documents, as registered at the SEFAZ.
+// It is always taxed under the Simples Nacional.
+type Issuer struct {
+	CNPJ string
+	// IE is the state registration
+	IE        string
+	Name      string
+	TradeName string
+	Street    string
+	Number    string
+	District  string
+	// CityCode is the 7 digit IBGE code of the city, starting with the code of the state
+	CityCode string
+	City     string
+	// UF is the abbreviation of the state, such as SP
+	UF  string
+	CEP string
+}
+
+type Config struct {
+	Issuer      Issuer
+	Series      uint
+	Environment Environment
+	// CSCID and CSC are the security code given by the SEFAZ, hashed into the QR codes
+	CSCID string
+	CSC   string
+	// QRCodeURL and ConsultURL are where the SEFAZ of the state lets consumers look up documents
+	QRCodeURL  string
+	ConsultURL string
+}
+
+func (c Config) Validate() error {
+	issuer := c.Issuer
+
+	switch {
+	case !validCNPJ(issuer.CNPJ):
+		return fmt.Errorf("%w: cnpj %q", ErrInvalidConfig, issuer.CNPJ)
+	case !isDigits(issuer.IE, 2, 14):
+		return fmt.Errorf("%w: ie %q", ErrInvalidConfig, issuer.IE)
+	case issuer.Name == "":
+		return fmt.Errorf("%w: missing name", ErrInvalidConfig)
+	case issuer.Street == "" || issuer.Number == "" || issuer.District == "" || issuer.City == "":
+		return fmt.Errorf("%w: incomplete address", ErrInvalidConfig)
+	case !isDigits(issuer.CityCode, 7, 7):
+		return fmt.Errorf("%w: city code %q", ErrInvalidConfig, issuer.CityCode)
+	case len(issuer.UF) != 2:
+		return fmt.Errorf("%w: uf %q", ErrInvalidConfig, issuer.UF)
+	case !isDigits(issuer.CEP, 8, 8):
+		return fmt.Errorf("%w: cep %q", ErrInvalidConfig, issuer.CEP)
+	case c.Series > 999:
+		return fmt.Errorf("%w: series %d", ErrInvalidConfig, c.Series)
+	case c.Environment != Production && c.Environment != Homologation:
+		return fmt.Errorf("%w: environment %d", ErrInvalidConfig, c.Environment)
+	case c.CSCID == "" || c.CSC == "":
+		return fmt.Errorf("%w: missing csc", ErrInvalidConfig)
+	case c.QRCodeURL == "" || c.ConsultURL == "":
+		return fmt.Errorf("%w: missing consult urls", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// ufCode is the IBGE code of the state of the issuer.
+func (c Config) ufCode() string {
+	return c.Issuer.CityCode[:2]
+}
+
+// brasilia is the time zone documents are dated in, Brazil having no daylight saving time anymore.
+var brasilia = time.FixedZone("BRT", -3*60*60)
+
+func validCNPJ(cnpj string) bool {
+	if !isDigits(cnpj, 14, 14) {
+		return false
+	}
+	return checkDigit(cnpj[:12], 9) == int(cnpj[12]-'0') && checkDigit(cnpj[:13], 9) == int(cnpj[13]-'0')
+}
+
+// checkDigit is the modulo 11 digit of the number, weighted from 2 up to maxWeight from the right.
+func checkDigit(digits string, maxWeight int) int {
+	sum, weight := 0, 2
+	for i := len(digits) - 1; i >= 0; i-- {
+		sum += int(digits[i]-'0') * weight
+		if weight++; weight > maxWeight {
+			weight = 2
+		}
+	}
+
+	if rest := sum % 11; rest >= 2 {
+		return 11 - rest
+	}
+	return 0
+}
+
+func isDigits(s string, min int, max int) bool {
+	if len(s) < min || len(s) > max {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
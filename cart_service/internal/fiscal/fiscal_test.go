@@ -0,0 +1,299 @@
+package fiscal_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/fiscal"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	config = fiscal.Config{
+		Issuer: fiscal.Issuer{
+			CNPJ:     "11222333000181",
+			IE:       "111111111111",
+			Name:     "ZCART COMERCIO LTDA",
+			Street:   "Av. Paulista",
+			Number:   "1000",
+			District: "Bela Vista",
+			CityCode: "3550308",
+			City:     "Sao Paulo",
+			UF:       "SP",
+			CEP:      "01310100",
+		},
+		Series:      1,
+		Environment: fiscal.Homologation,
+		CSCID:       "000001",
+		CSC:         "0123456789",
+		QRCodeURL:   "https://www.homologacao.nfce.fazenda.sp.gov.br/qrcode",
+		ConsultURL:  "https://www.homologacao.nfce.fazenda.sp.gov.br/consulta",
+	}
+
+	issuedAt = time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	products = map[string]*models.ProductFiscal{
+		"5": {NCM: "04039000", CFOP: "5102", CSOSN: "102", Unit: "UN"},
+		"1": {NCM: "22021000", CEST: "0300700", CFOP: "5405", CSOSN: "500", Unit: "UN"},
+	}
+)
+
+// testOrder has 3 Chamyto for 25.00 and 3 Coca Cola, with 4.30 off the order by a coupon.
+func testOrder() *models.Order {
+	return &models.Order{
+		ID:        "2ee9e93cfe57ed7d0f2fa819b3908b92",
+		CartID:    "1",
+		Status:    models.OrderCompleted,
+		ItemCount: 6,
+		Lines: []*models.OrderLine{
+			{ProductID: "5", Name: "Chamyto", UnitPrice: money.Cents(1099), Quantity: 3, Discount: money.Cents(797), Total: money.Cents(2500)},
+			{ProductID: "1", Name: "Coca-Cola 2L d'água & <gás>", UnitPrice: money.Cents(599), Quantity: 3, Discount: money.Cents(0), Total: money.Cents(1797)},
+		},
+		Subtotal:  money.Cents(5094),
+		Discount:  money.Cents(797 + 430),
+		Coupons:   []*models.AppliedCoupon{{Code: "TEN", Discount: money.Cents(430)}},
+		Total:     money.Cents(3867),
+		CreatedAt: issuedAt,
+	}
+}
+
+func TestAccessKey(t *testing.T) {
+	t.Run("Check digit of the example of the manual", func(t *testing.T) {
+		assert.True(t, fiscal.AccessKey("52060433009911002506550120000007800267301615").Valid())
+		assert.False(t, fiscal.AccessKey("52060433009911002506550120000007800267301614").Valid())
+		assert.False(t, fiscal.AccessKey("5206043300991100250655012000000780026730161").Valid())
+	})
+
+	t.Run("Composition", func(t *testing.T) {
+		key := fiscal.NewAccessKey("35", issuedAt, "11222333000181", 1, 42, "12345678")
+
+		assert.Equal(t, fiscal.AccessKey("35221111222333000181650010000000421123456782"), key)
+		assert.Equal(t, "12345678", key.Code())
+		assert.Equal(t, 2, key.CheckDigit())
+	})
+}
+
+func TestConfig(t *testing.T) {
+	assert.NoError(t, config.Validate())
+
+	invalid := config
+	invalid.Issuer.CNPJ = "11222333000182"
+	assert.ErrorIs(t, invalid.Validate(), fiscal.ErrInvalidConfig)
+
+	invalid = config
+	invalid.Environment = 3
+	assert.ErrorIs(t, invalid.Validate(), fiscal.ErrInvalidConfig)
+}
+
+func TestBuild(t *testing.T) {
+	payment := &models.PaymentIntent{Method: "pix", Amount: money.Cents(3867), Status: models.PaymentCaptured}
+
+	t.Run("Items, totals and payment", func(t *testing.T) {
+		nfe, err := fiscal.Build(config, testOrder(), products, payment, 42, issuedAt)
+		require.NoError(t, err)
+
+		info := nfe.InfNFe
+		require.Len(t, info.Det, 2)
+		key := fiscal.AccessKey(strings.TrimPrefix(info.ID, "NFe"))
+		assert.True(t, key.Valid())
+		assert.True(t, strings.HasPrefix(string(key), "35221111222333000181650010000000421"), "state, month, issuer, model, series, number and emission")
+		assert.Equal(t, key.Code(), info.Ide.CNF)
+		assert.Equal(t, uint(42), info.Ide.NNF)
+		assert.Equal(t, "2022-11-02T12:04:05-03:00", info.Ide.DhEmi)
+
+		chamyto, coke := info.Det[0].Prod, info.Det[1].Prod
+		assert.Equal(t, "NOTA FISCAL EMITIDA EM AMBIENTE DE HOMOLOGACAO - SEM VALOR FISCAL", chamyto.XProd)
+		assert.Equal(t, "3.0000", chamyto.QCom)
+		assert.Equal(t, "10.99", chamyto.VUnCom)
+		assert.Equal(t, "32.97", chamyto.VProd)
+		// The promotion plus 25.00 / 42.97 of the coupon, with the cent left over by rounding
+		assert.Equal(t, "10.48", chamyto.VDesc)
+		assert.Equal(t, "1.79", coke.VDesc)
+		assert.Equal(t, "0300700", coke.CEST)
+
+		assert.NotNil(t, info.Det[0].Imposto.ICMS.ICMSSN102)
+		assert.NotNil(t, info.Det[1].Imposto.ICMS.ICMSSN500)
+
+		totals := info.Total.ICMSTot
+		assert.Equal(t, "50.94", totals.VProd)
+		assert.Equal(t, "12.27", totals.VDesc)
+		assert.Equal(t, "38.67", totals.VNF)
+
+		require.Len(t, info.Pag.DetPag, 1)
+		assert.Equal(t, "17", info.Pag.DetPag[0].TPag)
+		assert.Equal(t, "38.67", info.Pag.DetPag[0].VPag)
+
+		params := fmt.Sprintf("%s|2|2|1", key)
+		assert.Equal(t, fmt.Sprintf("%s?p=%s|%X", config.QRCodeURL, params, sha1.Sum([]byte(params+config.CSC))), nfe.InfNFeSupl.QRCode)
+	})
+
+	t.Run("Code of the key is not derived from the order", func(t *testing.T) {
+		first, err := fiscal.Build(config, testOrder(), products, payment, 42, issuedAt)
+		require.NoError(t, err)
+		second, err := fiscal.Build(config, testOrder(), products, payment, 42, issuedAt)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first.InfNFe.Ide.CNF, second.InfNFe.Ide.CNF)
+	})
+
+	t.Run("Taxes of the order", func(t *testing.T) {
+		order := testOrder()
+		order.Lines[0].Tax = money.Cents(157)
+		order.Lines[1].Tax = money.Cents(291 + 162)
+		order.Lines[1].Taxes = []*models.TaxAmount{
+			{Tax: models.TaxICMS, Rate: 1800, Inclusive: true, Base: money.Cents(1618), Amount: money.Cents(291)},
+			{Tax: models.TaxIPI, Rate: 1000, Base: money.Cents(1618), Amount: money.Cents(162)},
+		}
+		order.Total = money.Cents(3867 + 162)
+
+		nfe, err := fiscal.Build(config, order, products, payment, 42, issuedAt)
+		require.NoError(t, err)
+
+		info := nfe.InfNFe
+		assert.Equal(t, "1.57", info.Det[0].Imposto.VTotTrib)
+		assert.Empty(t, info.Det[0].Prod.VOutro)
+		assert.Equal(t, "1.62", info.Det[1].Prod.VOutro)
+		assert.Equal(t, "1.62", info.Total.ICMSTot.VOutro)
+		assert.Equal(t, "6.10", info.Total.ICMSTot.VTotTrib)
+		assert.Equal(t, "40.29", info.Total.ICMSTot.VNF)
+	})
+
+	t.Run("Error without the NCM of a product", func(t *testing.T) {
+		_, err := fiscal.Build(config, testOrder(), map[string]*models.ProductFiscal{"5": products["5"]}, payment, 42, issuedAt)
+		assert.ErrorIs(t, err, fiscal.ErrProductFiscalData)
+	})
+}
+
+func TestSign(t *testing.T) {
+	certificate, err := fiscal.SelfSignedCertificate("ZCART COMERCIO LTDA:11222333000181")
+	require.NoError(t, err)
+
+	nfe, err := fiscal.Build(config, testOrder(), products, nil, 42, issuedAt)
+	require.NoError(t, err)
+
+	signed, err := fiscal.Sign(nfe, certificate)
+	require.NoError(t, err)
+
+	t.Run("Canonical form", func(t *testing.T) {
+		assert.True(t, bytes.HasPrefix(signed, []byte(`<NFe xmlns="http://www.portalfiscal.inf.br/nfe"><infNFe Id="NFe`)))
+		assert.Contains(t, string(signed), `<xProd>Coca-Cola 2L d'água &amp; &lt;gás&gt;</xProd>`)
+		assert.Contains(t, string(signed), `<Signature xmlns="http://www.w3.org/2000/09/xmldsig#"><SignedInfo><CanonicalizationMethod`)
+		assert.Contains(t, string(signed), `<Reference URI="#`+nfe.InfNFe.ID+`">`)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		verified, err := fiscal.Verify(signed)
+		require.NoError(t, err)
+		assert.Equal(t, nfe.InfNFe.ID, verified.InfNFe.ID)
+	})
+
+	t.Run("Verify fails when the document is changed", func(t *testing.T) {
+		tampered := bytes.Replace(signed, []byte("<vNF>38.67</vNF>"), []byte("<vNF>3.86</vNF>"), 1)
+		require.NotEqual(t, signed, tampered)
+
+		_, err := fiscal.Verify(tampered)
+		assert.ErrorIs(t, err, fiscal.ErrInvalidSignature)
+	})
+}
+
+func TestLoadCertificate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ZCART COMERCIO LTDA:11222333000181"},
+		NotBefore:    issuedAt,
+		NotAfter:     issuedAt.AddDate(1, 0, 0),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	var file bytes.Buffer
+	require.NoError(t, pem.Encode(&file, &pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	require.NoError(t, pem.Encode(&file, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	path := filepath.Join(t.TempDir(), "certificate.pem")
+	require.NoError(t, os.WriteFile(path, file.Bytes(), 0600))
+
+	certificate, err := fiscal.LoadCertificate(path, "")
+	require.NoError(t, err)
+
+	nfe, err := fiscal.Build(config, testOrder(), products, nil, 1, issuedAt)
+	require.NoError(t, err)
+	signed, err := fiscal.Sign(nfe, certificate)
+	require.NoError(t, err)
+
+	_, err = fiscal.Verify(signed)
+	assert.NoError(t, err)
+
+	t.Run("Error without the key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "certificate.pem")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+		_, err := fiscal.LoadCertificate(path, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestInvoicer(t *testing.T) {
+	certificate, err := fiscal.SelfSignedCertificate("ZCART COMERCIO LTDA:11222333000181")
+	require.NoError(t, err)
+
+	t.Run("Issue and authorize", func(t *testing.T) {
+		invoicer, err := fiscal.NewInvoicer(config, certificate, fiscal.NewStubSEFAZ())
+		require.NoError(t, err)
+
+		document, err := invoicer.Issue(testOrder(), products, nil, 7)
+		require.NoError(t, err)
+		assert.Equal(t, models.FiscalSigned, document.Status)
+		assert.Equal(t, uint(7), document.Number)
+		assert.Len(t, document.AccessKey, 44)
+
+		require.NoError(t, invoicer.Authorize(document))
+		assert.Equal(t, models.FiscalAuthorized, document.Status)
+		require.NotNil(t, document.Protocol)
+		assert.Len(t, *document.Protocol, 15)
+		assert.True(t, bytes.HasPrefix(document.XML, []byte(`<nfeProc xmlns="http://www.portalfiscal.inf.br/nfe" versao="4.00"><NFe>`)))
+		assert.Contains(t, string(document.XML), "<cStat>100</cStat>")
+	})
+
+	t.Run("Rejected when the signature does not match", func(t *testing.T) {
+		invoicer, err := fiscal.NewInvoicer(config, certificate, fiscal.NewStubSEFAZ())
+		require.NoError(t, err)
+
+		document, err := invoicer.Issue(testOrder(), products, nil, 8)
+		require.NoError(t, err)
+		document.XML = bytes.Replace(document.XML, []byte("<vNF>38.67</vNF>"), []byte("<vNF>3.86</vNF>"), 1)
+
+		require.NoError(t, invoicer.Authorize(document))
+		assert.Equal(t, models.FiscalRejected, document.Status)
+		assert.True(t, strings.HasPrefix(*document.StatusMessage, "297"))
+	})
+
+	t.Run("Stays signed without a SEFAZ", func(t *testing.T) {
+		invoicer, err := fiscal.NewInvoicer(config, certificate, nil)
+		require.NoError(t, err)
+
+		document, err := invoicer.Issue(testOrder(), products, nil, 9)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, invoicer.Authorize(document), fiscal.ErrSEFAZUnavailable)
+		assert.Equal(t, models.FiscalSigned, document.Status)
+	})
+}
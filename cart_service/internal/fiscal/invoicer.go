@@ -0,0 +1,94 @@
+package fiscal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+)
+
+// Invoicer issues the NFC-e of orders with the certificate of the store and
+// has them authorized by the SEFAZ, when there is one to send them to.
+type Invoicer struct {
+	config      Config
+	certificate *Certificate
+	sefaz       SEFAZ
+	Now         func() time.Time
+}
+
+func NewInvoicer(config Config, certificate *Certificate, sefaz SEFAZ) (*Invoicer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if certificate == nil {
+		return nil, fmt.Errorf("%w: missing certificate", ErrInvalidConfig)
+	}
+
+	return &Invoicer{config: config, certificate: certificate, sefaz: sefaz, Now: time.Now}, nil
+}
+
+// Series is the series the documents are numbered in.
+func (i *Invoicer) Series() uint {
+	return i.config.Series
+}
+
+// Issue builds and signs the NFC-e of the order, numbered number.
+func (i *Invoicer) Issue(order *models.Order, products map[string]*models.ProductFiscal, payment *models.PaymentIntent, number uint) (*models.FiscalDocument, error) {
+	nfe, err := Build(i.config, order, products, payment, number, i.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := Sign(nfe, i.certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FiscalDocument{
+		OrderID:   order.ID,
+		AccessKey: nfe.InfNFe.ID[len("NFe"):],
+		Series:    i.config.Series,
+		Number:    number,
+		Status:    models.FiscalSigned,
+		XML:       signed,
+	}, nil
+}
+
+// Authorize sends a signed document to the SEFAZ, recording its answer in the document.
+// It fails with ErrSEFAZUnavailable when the document could not be sent, leaving it signed.
+func (i *Invoicer) Authorize(document *models.FiscalDocument) error {
+	if document.Status != models.FiscalSigned {
+		return fmt.Errorf("document of order %s is %s", document.OrderID, document.Status)
+	}
+	if i.sefaz == nil {
+		return fmt.Errorf("%w: no SEFAZ configured", ErrSEFAZUnavailable)
+	}
+
+	protocol, err := i.sefaz.Authorize(AccessKey(document.AccessKey), document.XML)
+	if err != nil {
+		if errors.Is(err, ErrSEFAZUnavailable) {
+			return err
+		}
+		return fmt.Errorf("%w: %s", ErrSEFAZUnavailable, err)
+	}
+
+	message := fmt.Sprintf("%d - %s", protocol.Status, protocol.Message)
+	document.StatusMessage = &message
+
+	if !protocol.Authorized() {
+		document.Status = models.FiscalRejected
+		return nil
+	}
+
+	proc, err := Proc(document.XML, protocol)
+	if err != nil {
+		return err
+	}
+
+	document.Status = models.FiscalAuthorized
+	document.Protocol = &protocol.Number
+	document.XML = proc
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package fiscal
+
+import "encoding/xml"
+
+// The layout of the NF-e 4.00, with only the groups a NFC-e of the Simples Nacional needs.
+// Fields follow the order of the schema, which the SEFAZ validates.
+
+const (
+	Namespace = "http://www.portalfiscal.inf.br/nfe"
+	Version   = "4.00"
+)
+
+type NFe struct {
+	XMLName    xml.Name   `xml:"http://www.portalfiscal.inf.br/nfe NFe"`
+	InfNFe     InfNFe     `xml:"infNFe"`
+	InfNFeSupl InfNFeSupl `xml:"infNFeSupl"`
+	Signature  *Signature `xml:"Signature"`
+}
+
+// InfNFe is the signed part of the document.
+type InfNFe struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe infNFe"`
+	Versao  string   `xml:"versao,attr"`
+	// ID is the access key prefixed with NFe
+	ID      string   `xml:"Id,attr"`
+	Ide     Ide      `xml:"ide"`
+	Emit    Emit     `xml:"emit"`
+	Det     []Det    `xml:"det"`
+	Total   Total    `xml:"total"`
+	Transp  Transp   `xml:"transp"`
+	Pag     Pag      `xml:"pag"`
+	InfAdic *InfAdic `xml:"infAdic"`
+}
+
+type Ide struct {
+	CUF         string `xml:"cUF"`
+	CNF         string `xml:"cNF"`
+	NatOp       string `xml:"natOp"`
+	Mod         int    `xml:"mod"`
+	Serie       uint   `xml:"serie"`
+	NNF         uint   `xml:"nNF"`
+	DhEmi       string `xml:"dhEmi"`
+	TpNF        int    `xml:"tpNF"`
+	IDDest      int    `xml:"idDest"`
+	CMunFG      string `xml:"cMunFG"`
+	TpImp       int    `xml:"tpImp"`
+	TpEmis      int    `xml:"tpEmis"`
+	CDV         int    `xml:"cDV"`
+	TpAmb       int    `xml:"tpAmb"`
+	FinNFe      int    `xml:"finNFe"`
+	IndFinal    int    `xml:"indFinal"`
+	IndPres     int    `xml:"indPres"`
+	IndIntermed int    `xml:"indIntermed"`
+	ProcEmi     int    `xml:"procEmi"`
+	VerProc     string `xml:"verProc"`
+}
+
+type Emit struct {
+	CNPJ      string `xml:"CNPJ"`
+	XNome     string `xml:"xNome"`
+	XFant     string `xml:"xFant,omitempty"`
+	EnderEmit Ender  `xml:"enderEmit"`
+	IE        string `xml:"IE"`
+	CRT       int    `xml:"CRT"`
+}
+
+type Ender struct {
+	XLgr    string `xml:"xLgr"`
+	Nro     string `xml:"nro"`
+	XBairro string `xml:"xBairro"`
+	CMun    string `xml:"cMun"`
+	XMun    string `xml:"xMun"`
+	UF      string `xml:"UF"`
+	CEP     string `xml:"CEP"`
+	CPais   string `xml:"cPais"`
+	XPais   string `xml:"xPais"`
+}
+
+type Det struct {
+	NItem   int     `xml:"nItem,attr"`
+	Prod    Prod    `xml:"prod"`
+	Imposto Imposto `xml:"imposto"`
+}
+
+type Prod struct {
+	CProd    string `xml:"cProd"`
+	CEAN     string `xml:"cEAN"`
+	XProd    string `xml:"xProd"`
+	NCM      string `xml:"NCM"`
+	CEST     string `xml:"CEST,omitempty"`
+	CFOP     string `xml:"CFOP"`
+	UCom     string `xml:"uCom"`
+	QCom     string `xml:"qCom"`
+	VUnCom   string `xml:"vUnCom"`
+	VProd    string `xml:"vProd"`
+	CEANTrib string `xml:"cEANTrib"`
+	UTrib    string `xml:"uTrib"`
+	QTrib    string `xml:"qTrib"`
+	VUnTrib  string `xml:"vUnTrib"`
+	VDesc    string `xml:"vDesc,omitempty"`
+	// VOutro carries the taxes charged on top of the price
+	VOutro string `xml:"vOutro,omitempty"`
+	IndTot int    `xml:"indTot"`
+}
+
+type Imposto struct {
+	// VTotTrib is the approximate amount of taxes in the price, told to consumers by law
+	VTotTrib string `xml:"vTotTrib,omitempty"`
+	ICMS     ICMS   `xml:"ICMS"`
+	PIS      PIS    `xml:"PIS"`
+	COFINS   COFINS `xml:"COFINS"`
+}
+
+// ICMS holds the one group matching the CSOSN of the product.
+type ICMS struct {
+	// ICMSSN102 is used by CSOSN 102, 103, 300 and 400, all without ICMS credit
+	ICMSSN102 *ICMSSN `xml:"ICMSSN102"`
+	// ICMSSN500 is used by products whose ICMS was charged earlier by tax substitution
+	ICMSSN500 *ICMSSN `xml:"ICMSSN500"`
+}
+
+type ICMSSN struct {
+	Orig  uint   `xml:"orig"`
+	CSOSN string `xml:"CSOSN"`
+}
+
+// PIS and COFINS are of "other operations", stores in the Simples Nacional paying them in the DAS.
+type PIS struct {
+	PISOutr PISOutr `xml:"PISOutr"`
+}
+
+type PISOutr struct {
+	CST  string `xml:"CST"`
+	VBC  string `xml:"vBC"`
+	PPIS string `xml:"pPIS"`
+	VPIS string `xml:"vPIS"`
+}
+
+type COFINS struct {
+	COFINSOutr COFINSOutr `xml:"COFINSOutr"`
+}
+
+type COFINSOutr struct {
+	CST     string `xml:"CST"`
+	VBC     string `xml:"vBC"`
+	PCOFINS string `xml:"pCOFINS"`
+	VCOFINS string `xml:"vCOFINS"`
+}
+
+type Total struct {
+	ICMSTot ICMSTot `xml:"ICMSTot"`
+}
+
+type ICMSTot struct {
+	VBC        string `xml:"vBC"`
+	VICMS      string `xml:"vICMS"`
+	VICMSDeson string `xml:"vICMSDeson"`
+	VFCP       string `xml:"vFCP"`
+	VBCST      string `xml:"vBCST"`
+	VST        string `xml:"vST"`
+	VFCPST     string `xml:"vFCPST"`
+	VFCPSTRet  string `xml:"vFCPSTRet"`
+	VProd      string `xml:"vProd"`
+	VFrete     string `xml:"vFrete"`
+	VSeg       string `xml:"vSeg"`
+	VDesc      string `xml:"vDesc"`
+	VII        string `xml:"vII"`
+	VIPI       string `xml:"vIPI"`
+	VIPIDevol  string `xml:"vIPIDevol"`
+	VPIS       string `xml:"vPIS"`
+	VCOFINS    string `xml:"vCOFINS"`
+	VOutro     string `xml:"vOutro"`
+	VNF        string `xml:"vNF"`
+	VTotTrib   string `xml:"vTotTrib,omitempty"`
+}
+
+type Transp struct {
+	ModFrete int `xml:"modFrete"`
+}
+
+type Pag struct {
+	DetPag []DetPag `xml:"detPag"`
+}
+
+type DetPag struct {
+	TPag string `xml:"tPag"`
+	XPag string `xml:"xPag,omitempty"`
+	VPag string `xml:"vPag"`
+	Card *Card  `xml:"card"`
+}
+
+type Card struct {
+	// TpIntegra 2 means the payment was not integrated with the invoicing system through a TEF
+	TpIntegra int `xml:"tpIntegra"`
+}
+
+type InfAdic struct {
+	InfCpl string `xml:"infCpl"`
+}
+
+// InfNFeSupl carries the QR code printed on the DANFE, outside of the signed part.
+type InfNFeSupl struct {
+	QRCode   string `xml:"qrCode"`
+	URLChave string `xml:"urlChave"`
+}
@@ -0,0 +1,132 @@
+package fiscal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status codes (cStat) of the SEFAZ answers.
+const (
+	StatusAuthorized = 100
+	// StatusAuthorizedLate is a document authorized after its deadline
+	StatusAuthorizedLate   = 150
+	StatusInvalidSignature = 297
+)
+
+// SEFAZ is the tax authority of the state, which authorizes the documents before they are valid.
+type SEFAZ interface {
+	// Authorize sends a signed document, NFC-e being authorized synchronously.
+	// An error means the document could not be sent, it must be sent again later.
+	Authorize(key AccessKey, signed []byte) (*Protocol, error)
+}
+
+// Protocol is the answer of the SEFAZ to a document.
+type Protocol struct {
+	Environment Environment
+	Application string
+	Key         AccessKey
+	ReceivedAt  time.Time
+	// Number is only given to authorized documents
+	Number      string
+	DigestValue string
+	Status      int
+	Message     string
+}
+
+func (p *Protocol) Authorized() bool {
+	return p.Status == StatusAuthorized || p.Status == StatusAuthorizedLate
+}
+
+// NFeProc is the document distributed once authorized, along with its protocol.
+type NFeProc struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe nfeProc"`
+	Versao  string   `xml:"versao,attr"`
+	NFe     NFe      `xml:"NFe"`
+	ProtNFe ProtNFe  `xml:"protNFe"`
+}
+
+type ProtNFe struct {
+	Versao  string  `xml:"versao,attr"`
+	InfProt InfProt `xml:"infProt"`
+}
+
+type InfProt struct {
+	TpAmb    int    `xml:"tpAmb"`
+	VerAplic string `xml:"verAplic"`
+	ChNFe    string `xml:"chNFe"`
+	DhRecbto string `xml:"dhRecbto"`
+	NProt    string `xml:"nProt,omitempty"`
+	DigVal   string `xml:"digVal,omitempty"`
+	CStat    int    `xml:"cStat"`
+	XMotivo  string `xml:"xMotivo"`
+}
+
+// Proc wraps the signed document with the protocol that authorized it.
+func Proc(signed []byte, protocol *Protocol) ([]byte, error) {
+	var nfe NFe
+	if err := xml.Unmarshal(signed, &nfe); err != nil {
+		return nil, err
+	}
+
+	return canonicalXML(&NFeProc{
+		Versao: Version,
+		NFe:    nfe,
+		ProtNFe: ProtNFe{
+			Versao: Version,
+			InfProt: InfProt{
+				TpAmb:    int(protocol.Environment),
+				VerAplic: protocol.Application,
+				ChNFe:    string(protocol.Key),
+				DhRecbto: protocol.ReceivedAt.In(brasilia).Format(time.RFC3339),
+				NProt:    protocol.Number,
+				DigVal:   protocol.DigestValue,
+				CStat:    protocol.Status,
+				XMotivo:  protocol.Message,
+			},
+		},
+	})
+}
+
+// StubSEFAZ authorizes every document with a valid signature, as the SEFAZ
+// would in homologation. It is meant for development and tests.
+type StubSEFAZ struct {
+	mu       sync.Mutex
+	sequence int64
+	Now      func() time.Time
+}
+
+func NewStubSEFAZ() *StubSEFAZ {
+	return &StubSEFAZ{Now: time.Now}
+}
+
+func (s *StubSEFAZ) Authorize(key AccessKey, signed []byte) (*Protocol, error) {
+	protocol := &Protocol{
+		Environment: Homologation,
+		Application: "zcart-stub",
+		Key:         key,
+		ReceivedAt:  s.Now(),
+	}
+
+	nfe, err := Verify(signed)
+	if err != nil || nfe.InfNFe.ID != "NFe"+string(key) {
+		protocol.Status = StatusInvalidSignature
+		protocol.Message = "Rejeicao: Assinatura difere do calculado"
+		return protocol, nil
+	}
+
+	s.mu.Lock()
+	s.sequence++
+	sequence := s.sequence
+	s.mu.Unlock()
+
+	protocol.Environment = Environment(nfe.InfNFe.Ide.TpAmb)
+	// The type of the SEFAZ, its state, the year and a sequence
+	protocol.Number = fmt.Sprintf("1%s%s%010d", nfe.InfNFe.Ide.CUF, protocol.ReceivedAt.In(brasilia).Format("06"), sequence)
+	protocol.DigestValue = nfe.Signature.SignedInfo.Reference.DigestValue
+	protocol.Status = StatusAuthorized
+	protocol.Message = "Autorizado o uso da NF-e"
+
+	return protocol, nil
+}
@@ -0,0 +1,273 @@
+package fiscal
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// The algorithms of the XML signature the SEFAZ requires.
+const (
+	c14nAlgorithm      = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	envelopedAlgorithm = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	rsaSHA1Algorithm   = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	sha1Algorithm      = "http://www.w3.org/2000/09/xmldsig#sha1"
+)
+
+type Signature struct {
+	XMLName        xml.Name   `xml:"http://www.w3.org/2000/09/xmldsig# Signature"`
+	SignedInfo     SignedInfo `xml:"SignedInfo"`
+	SignatureValue string     `xml:"SignatureValue"`
+	KeyInfo        KeyInfo    `xml:"KeyInfo"`
+}
+
+type SignedInfo struct {
+	XMLName                xml.Name  `xml:"http://www.w3.org/2000/09/xmldsig# SignedInfo"`
+	CanonicalizationMethod Algorithm `xml:"CanonicalizationMethod"`
+	SignatureMethod        Algorithm `xml:"SignatureMethod"`
+	Reference              Reference `xml:"Reference"`
+}
+
+type Algorithm struct {
+	Algorithm string `xml:"Algorithm,attr"`
+}
+
+type Reference struct {
+	URI          string      `xml:"URI,attr"`
+	Transforms   []Algorithm `xml:"Transforms>Transform"`
+	DigestMethod Algorithm   `xml:"DigestMethod"`
+	DigestValue  string      `xml:"DigestValue"`
+}
+
+type KeyInfo struct {
+	X509Certificate string `xml:"X509Data>X509Certificate"`
+}
+
+// Certificate is the ICP-Brasil certificate of the issuer, along with its private key.
+type Certificate struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+// LoadCertificate reads an A1 certificate, either a PKCS#12 file (.pfx or .p12) protected
+// by password or a PEM file holding both the certificate and its key.
+func LoadCertificate(path string, password string) (*Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*pem.Block
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pfx", ".p12":
+		if blocks, err = pkcs12.ToPEM(data, password); err != nil {
+			return nil, fmt.Errorf("certificate %s: %w", path, err)
+		}
+	default:
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			blocks = append(blocks, block)
+		}
+	}
+
+	certificate, err := certificateOf(blocks)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s: %w", path, err)
+	}
+
+	return certificate, nil
+}
+
+// certificateOf finds the key among the blocks and the certificate matching it, as files may carry the whole chain.
+func certificateOf(blocks []*pem.Block) (*Certificate, error) {
+	var (
+		key   *rsa.PrivateKey
+		certs []*x509.Certificate
+	)
+
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		case "RSA PRIVATE KEY":
+			parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			key = parsed
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			rsaKey, ok := parsed.(*rsa.PrivateKey)
+			if !ok {
+				return nil, errors.New("private key is not an RSA key")
+			}
+			key = rsaKey
+		}
+	}
+
+	if key == nil {
+		return nil, errors.New("missing private key")
+	}
+
+	for _, cert := range certs {
+		if public, ok := cert.PublicKey.(*rsa.PublicKey); ok && public.Equal(&key.PublicKey) {
+			return &Certificate{cert: cert, key: key}, nil
+		}
+	}
+
+	return nil, errors.New("missing the certificate of the private key")
+}
+
+// SelfSignedCertificate makes a throwaway certificate, for documents that are never sent to a SEFAZ.
+func SelfSignedCertificate(commonName string) (*Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificate{cert: cert, key: key}, nil
+}
+
+// Sign signs the infNFe of the document with an enveloped XML signature,
+// returning the canonical XML of the signed document.
+func Sign(nfe *NFe, certificate *Certificate) ([]byte, error) {
+	digest, err := digestOf(&nfe.InfNFe)
+	if err != nil {
+		return nil, err
+	}
+
+	signedInfo := SignedInfo{
+		CanonicalizationMethod: Algorithm{c14nAlgorithm},
+		SignatureMethod:        Algorithm{rsaSHA1Algorithm},
+		Reference: Reference{
+			URI:          "#" + nfe.InfNFe.ID,
+			Transforms:   []Algorithm{{envelopedAlgorithm}, {c14nAlgorithm}},
+			DigestMethod: Algorithm{sha1Algorithm},
+			DigestValue:  digest,
+		},
+	}
+
+	canonical, err := canonicalXML(&signedInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := sha1.Sum(canonical)
+	value, err := rsa.SignPKCS1v15(rand.Reader, certificate.key, crypto.SHA1, hashed[:])
+	if err != nil {
+		return nil, err
+	}
+
+	nfe.Signature = &Signature{
+		SignedInfo:     signedInfo,
+		SignatureValue: base64.StdEncoding.EncodeToString(value),
+		KeyInfo:        KeyInfo{X509Certificate: base64.StdEncoding.EncodeToString(certificate.cert.Raw)},
+	}
+
+	return canonicalXML(nfe)
+}
+
+// Verify checks the signature of a document against the certificate it carries.
+// It does not check whether the certificate is trusted.
+func Verify(signed []byte) (*NFe, error) {
+	var nfe NFe
+	if err := xml.Unmarshal(signed, &nfe); err != nil {
+		return nil, err
+	}
+
+	signature := nfe.Signature
+	if signature == nil {
+		return nil, fmt.Errorf("%w: document is not signed", ErrInvalidSignature)
+	}
+	if signature.SignedInfo.Reference.URI != "#"+nfe.InfNFe.ID {
+		return nil, fmt.Errorf("%w: reference to %s", ErrInvalidSignature, signature.SignedInfo.Reference.URI)
+	}
+
+	digest, err := digestOf(&nfe.InfNFe)
+	if err != nil {
+		return nil, err
+	}
+	if digest != strings.TrimSpace(signature.SignedInfo.Reference.DigestValue) {
+		return nil, fmt.Errorf("%w: digest does not match the document", ErrInvalidSignature)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature.KeyInfo.X509Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	public, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: certificate has no RSA key", ErrInvalidSignature)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature.SignatureValue))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	canonical, err := canonicalXML(&signature.SignedInfo)
+	if err != nil {
+		return nil, err
+	}
+	hashed := sha1.Sum(canonical)
+
+	if err := rsa.VerifyPKCS1v15(public, crypto.SHA1, hashed[:], value); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	return &nfe, nil
+}
+
+func digestOf(infNFe *InfNFe) (string, error) {
+	canonical, err := canonicalXML(infNFe)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha1.Sum(canonical)
+	return base64.StdEncoding.EncodeToString(digest[:]), nil
+}
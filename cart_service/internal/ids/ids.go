@@ -0,0 +1,15 @@
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// New returns a random 128-bit identifier encoded as hex.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
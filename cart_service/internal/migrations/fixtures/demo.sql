@@ -0,0 +1,47 @@
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class) VALUES ('1','Coca Cola', 599, 'https://zcart-test-images.s3.amazonaws.com/coca2l.png', '22021000', 'standard');
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class) VALUES ('2','BomBril', 199, 'https://zcart-test-images.s3.amazonaws.com/bombril.png', '73239000', 'standard');
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class) VALUES ('3','Leite Longa Vida 1L', 499, 'https://zcart-test-images.s3.amazonaws.com/leite.png', '04012010', 'food');
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class) VALUES ('4','Café', 899, 'https://zcart-test-images.s3.amazonaws.com/cafe.png', '09012100', 'food');
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class) VALUES ('5','Chamyto', 1099, 'https://zcart-test-images.s3.amazonaws.com/chamyto.png', '04039000', 'standard');
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class) VALUES ('6','Macarrão Instântaneo Nissin', 399, 'https://zcart-test-images.s3.amazonaws.com/lamen.png', '19023000', 'food');
+
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class,weight_grams) VALUES ('7','Coca Cola Soda 350ml', 399, 'https://zcart-test-images.s3.amazonaws.com/coke_soda.png', '22021000', 'standard', 387);
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class,weight_grams) VALUES ('8','Fanta Guarana Soda 350ml', 299, 'https://zcart-test-images.s3.amazonaws.com/guarana_soda.png', '22021000', 'standard', 361);
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class,weight_grams) VALUES ('9','Bic Blue Pen 4-pack', 199, 'https://zcart-test-images.s3.amazonaws.com/blue_pens.png', '96081000', 'standard', 28);
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class,weight_grams) VALUES ('10','Postit', 799, 'https://zcart-test-images.s3.amazonaws.com/post_it.png', '48201000', 'standard', 43);
+INSERT OR IGNORE INTO products (id,name,price,image_url,ncm,tax_class,weight_grams) VALUES ('11','Cart Deck', 599, 'https://zcart-test-images.s3.amazonaws.com/cart_deck.png', '95044000', 'standard', 29);
+
+INSERT OR IGNORE INTO product_labels (label,product_id) VALUES ('coke_soda','7');
+INSERT OR IGNORE INTO product_labels (label,product_id) VALUES ('guarana_soda','8');
+INSERT OR IGNORE INTO product_labels (label,product_id) VALUES ('blue_pens','9');
+INSERT OR IGNORE INTO product_labels (label,product_id) VALUES ('post_it','10');
+INSERT OR IGNORE INTO product_labels (label,product_id) VALUES ('card_deck','11');
+
+INSERT OR IGNORE INTO carts (id) VALUES ('1');
+INSERT OR IGNORE INTO carts (id) VALUES ('2');
+
+INSERT OR IGNORE INTO cart_products (cart_id,product_id,quantity) VALUES ('1','1', 10);
+INSERT OR IGNORE INTO cart_products (cart_id,product_id,quantity) VALUES ('1','2', 5);
+INSERT OR IGNORE INTO cart_products (cart_id,product_id,quantity) VALUES ('1','3', 9);
+INSERT OR IGNORE INTO cart_products (cart_id,product_id,quantity) VALUES ('1','4', 1);
+
+INSERT OR IGNORE INTO promotions (id,name,kind,product_id,buy_quantity,amount) VALUES ('chamyto-3-for-25','3 Chamyto for 25.00','multi_buy','5', 3, 2500);
+INSERT OR IGNORE INTO promotions (id,name,kind,product_id,buy_quantity,get_quantity) VALUES ('nissin-buy-2-get-1','Buy 2 Nissin, get 1 free','buy_x_get_y','6', 2, 1);
+
+INSERT OR IGNORE INTO coupons (code,description,kind,percent,minimum_basket,single_use_per_customer) VALUES ('WELCOME10','10% off your first purchase','percentage', 10, 2000, TRUE);
+INSERT OR IGNORE INTO coupons (code,description,kind,amount,max_uses) VALUES ('ZCART5','5.00 off, first 100 shoppers','fixed', 500, 100);
+
+INSERT OR IGNORE INTO tax_rules (id,name,tax_class,tax,rate,inclusive) VALUES ('icms-standard','ICMS 18%','standard','icms', 1800, TRUE);
+INSERT OR IGNORE INTO tax_rules (id,name,tax_class,tax,rate,inclusive) VALUES ('pis-standard','PIS 1.65%','standard','pis', 165, TRUE);
+INSERT OR IGNORE INTO tax_rules (id,name,tax_class,tax,rate,inclusive) VALUES ('cofins-standard','COFINS 7.6%','standard','cofins', 760, TRUE);
+INSERT OR IGNORE INTO tax_rules (id,name,tax_class,tax,rate,inclusive) VALUES ('icms-food','ICMS 7% on staple foods','food','icms', 700, TRUE);
+
+INSERT OR IGNORE INTO stock (product_id,on_hand,low_stock_threshold) VALUES ('1', 48, 12);
+INSERT OR IGNORE INTO stock (product_id,on_hand,low_stock_threshold) VALUES ('2', 20, 5);
+INSERT OR IGNORE INTO stock (product_id,on_hand,low_stock_threshold) VALUES ('3', 60, 24);
+INSERT OR IGNORE INTO stock (product_id,on_hand,low_stock_threshold) VALUES ('4', 15, 5);
+INSERT OR IGNORE INTO stock (product_id,on_hand,low_stock_threshold) VALUES ('5', 30, 6);
+INSERT OR IGNORE INTO stock (product_id,on_hand,low_stock_threshold) VALUES ('6', 40, 10);
+
+-- The demo cart authenticates with the secret demo-secret
+INSERT OR IGNORE INTO devices (id,name,secret_hash,cart_id,bound_at,created_at) VALUES ('cart-01','Demo cart', 'cd577fe2561ebff23505db0bb006300c7cdecbd46bc0e03c449afafaca2c25bf', '2', current_timestamp, current_timestamp);
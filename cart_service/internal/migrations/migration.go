@@ -2,25 +2,281 @@ package migrations
 
 import (
 	"database/sql"
-	_ "embed"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
 )
 
-//go:embed migration.sql
-var migrations string
+//go:embed sql/*.sql
+var embeddedMigrations embed.FS
 
-func Apply(db *sql.DB) error {
-	tx, err := db.Begin()
+//go:embed fixtures/demo.sql
+var demoFixtures string
+
+var (
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrMissingDownStep  = errors.New("migration has no down step")
+	ErrInvalidMigration = errors.New("invalid migration file name")
+)
+
+// Migration files are named <version>_<name>.<up|down>.sql, e.g. 0002_create_carts.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const createVersionTable = `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        applied_at DATETIME DEFAULT current_timestamp
+    );
+`
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Step is a single migration to be run in a given direction.
+type Step struct {
+	Migration Migration
+	Down      bool
+}
+
+func (s Step) String() string {
+	direction := "up"
+	if s.Down {
+		direction = "down"
+	}
+	return fmt.Sprintf("%04d_%s (%s)", s.Migration.Version, s.Migration.Name, direction)
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// DryRun makes Migrate only compute the steps that would run.
+	DryRun bool
+}
+
+// New returns a Migrator for the migrations embedded in the binary.
+func New(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(embeddedMigrations, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return NewFromFS(db, sub)
+}
+
+// NewFromFS returns a Migrator for the migration files found at the root of fsys.
+func NewFromFS(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version the database is currently at, 0 meaning no migrations.
+// It only reads the database, which has no schema_migrations table before the first migration.
+func (m *Migrator) Version() (int, error) {
+	var tables int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables); err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	if err := m.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// Up migrates the database to the latest version.
+func (m *Migrator) Up() ([]Step, error) {
+	return m.Migrate(m.Latest())
+}
+
+// Migrate moves the database up or down until it reaches target,
+// returning the steps that were (or, on DryRun, would be) applied.
+func (m *Migrator) Migrate(target int) ([]Step, error) {
+	if target != 0 && m.find(target) == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+
+	current, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := m.plan(current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.DryRun || len(steps) == 0 {
+		return steps, nil
+	}
+
+	if _, err := m.db.Exec(createVersionTable); err != nil {
+		return nil, err
+	}
+
+	for i, step := range steps {
+		if err := m.run(step); err != nil {
+			return steps[:i], fmt.Errorf("migration %s: %w", step, err)
+		}
+	}
+
+	return steps, nil
+}
+
+func (m *Migrator) plan(current, target int) ([]Step, error) {
+	var steps []Step
+
+	if target >= current {
+		for _, migration := range m.migrations {
+			if migration.Version > current && migration.Version <= target {
+				steps = append(steps, Step{Migration: migration})
+			}
+		}
+		return steps, nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version > target && migration.Version <= current {
+			if migration.Down == "" {
+				return nil, fmt.Errorf("%w: %04d_%s", ErrMissingDownStep, migration.Version, migration.Name)
+			}
+			steps = append(steps, Step{Migration: migration, Down: true})
+		}
+	}
+
+	return steps, nil
+}
+
+func (m *Migrator) run(step Step) error {
+	tx, err := m.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(migrations)
+	if err := applyStep(tx, step); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func applyStep(tx *sql.Tx, step Step) error {
+	if step.Down {
+		if _, err := tx.Exec(step.Migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, step.Migration.Version)
+		return err
+	}
+
+	if _, err := tx.Exec(step.Migration.Up); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, step.Migration.Version, step.Migration.Name)
+	return err
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// LoadFixtures inserts the demo catalog and carts. It is safe to run more than once.
+func LoadFixtures(db *sql.DB) error {
+	tx, err := db.Begin()
 	if err != nil {
-		defer func() {
-			_ = tx.Rollback()
-		}()
+		return err
+	}
+
+	if _, err := tx.Exec(demoFixtures); err != nil {
+		_ = tx.Rollback()
 		return err
 	}
 
 	return tx.Commit()
 }
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, entry.Name())
+		}
+
+		version, _ := strconv.Atoi(matches[1])
+		name, direction := matches[2], matches[3]
+
+		migration, found := byVersion[version]
+		if !found {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("%w: version %d used by %q and %q", ErrInvalidMigration, version, migration.Name, name)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: %04d_%s has no up step", ErrInvalidMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
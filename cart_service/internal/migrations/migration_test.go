@@ -0,0 +1,213 @@
+package migrations_test
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	// Every connection to :memory: gets its own database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	require.NoError(t, err)
+	return count > 0
+}
+
+var testFS = fstest.MapFS{
+	"0001_create_a.up.sql":   {Data: []byte(`CREATE TABLE a (id INTEGER);`)},
+	"0001_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)},
+	"0002_create_b.up.sql":   {Data: []byte(`CREATE TABLE b (id INTEGER);`)},
+	"0002_create_b.down.sql": {Data: []byte(`DROP TABLE b;`)},
+	"0003_create_c.up.sql":   {Data: []byte(`CREATE TABLE c (id INTEGER);`)},
+}
+
+func TestMigrator(t *testing.T) {
+	t.Run("Embedded migrations", func(t *testing.T) {
+		t.Run("Up and fixtures", func(t *testing.T) {
+			db := newDB(t)
+
+			migrator, err := migrations.New(db)
+			require.NoError(t, err)
+
+			steps, err := migrator.Up()
+			require.NoError(t, err)
+			assert.Len(t, steps, migrator.Latest())
+
+			version, err := migrator.Version()
+			assert.NoError(t, err)
+			assert.Equal(t, migrator.Latest(), version)
+
+			// Running again is a no-op
+			steps, err = migrator.Up()
+			assert.NoError(t, err)
+			assert.Empty(t, steps)
+
+			assert.NoError(t, migrations.LoadFixtures(db))
+			assert.NoError(t, migrations.LoadFixtures(db))
+
+			var products int
+			assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM products`).Scan(&products))
+			assert.Greater(t, products, 0)
+		})
+
+		t.Run("Down to zero", func(t *testing.T) {
+			db := newDB(t)
+
+			migrator, err := migrations.New(db)
+			require.NoError(t, err)
+
+			_, err = migrator.Up()
+			require.NoError(t, err)
+
+			steps, err := migrator.Migrate(0)
+			require.NoError(t, err)
+			assert.Len(t, steps, migrator.Latest())
+
+			assert.False(t, tableExists(t, db, "products"))
+			assert.False(t, tableExists(t, db, "cart_products"))
+		})
+	})
+
+	t.Run("Converting REAL prices to cents and back", func(t *testing.T) {
+		db := newDB(t)
+
+		migrator, err := migrations.New(db)
+		require.NoError(t, err)
+
+		_, err = migrator.Migrate(6)
+		require.NoError(t, err)
+
+		_, err = db.Exec(`INSERT INTO products (id, name, price) VALUES ('1', 'Coca Cola', 5.99), ('2', 'Chamyto', 10.99)`)
+		require.NoError(t, err)
+
+		_, err = migrator.Migrate(7)
+		require.NoError(t, err)
+
+		var (
+			price    int64
+			currency string
+		)
+		require.NoError(t, db.QueryRow(`SELECT price, currency FROM products WHERE id = '2'`).Scan(&price, &currency))
+		assert.Equal(t, int64(1099), price)
+		assert.Equal(t, "BRL", currency)
+
+		_, err = migrator.Migrate(6)
+		require.NoError(t, err)
+
+		var restored float64
+		require.NoError(t, db.QueryRow(`SELECT price FROM products WHERE id = '1'`).Scan(&restored))
+		assert.Equal(t, 5.99, restored)
+	})
+
+	t.Run("Migrate to a target version", func(t *testing.T) {
+		db := newDB(t)
+
+		migrator, err := migrations.NewFromFS(db, testFS)
+		require.NoError(t, err)
+		assert.Equal(t, 3, migrator.Latest())
+
+		steps, err := migrator.Migrate(2)
+		require.NoError(t, err)
+		assert.Len(t, steps, 2)
+		assert.True(t, tableExists(t, db, "b"))
+		assert.False(t, tableExists(t, db, "c"))
+
+		steps, err = migrator.Migrate(1)
+		require.NoError(t, err)
+		require.Len(t, steps, 1)
+		assert.True(t, steps[0].Down)
+		assert.False(t, tableExists(t, db, "b"))
+
+		version, err := migrator.Version()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, version)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		db := newDB(t)
+
+		migrator, err := migrations.NewFromFS(db, testFS)
+		require.NoError(t, err)
+		migrator.DryRun = true
+
+		steps, err := migrator.Up()
+		require.NoError(t, err)
+		assert.Len(t, steps, 3)
+		assert.Equal(t, "0001_create_a (up)", steps[0].String())
+
+		assert.False(t, tableExists(t, db, "a"))
+		assert.False(t, tableExists(t, db, "schema_migrations"), "nothing written")
+
+		version, err := migrator.Version()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, version)
+	})
+
+	t.Run("Error rolling back without down step", func(t *testing.T) {
+		db := newDB(t)
+
+		migrator, err := migrations.NewFromFS(db, testFS)
+		require.NoError(t, err)
+
+		_, err = migrator.Up()
+		require.NoError(t, err)
+
+		_, err = migrator.Migrate(1)
+		assert.ErrorIs(t, err, migrations.ErrMissingDownStep)
+		assert.True(t, tableExists(t, db, "c"))
+	})
+
+	t.Run("Error with unknown target", func(t *testing.T) {
+		migrator, err := migrations.NewFromFS(newDB(t), testFS)
+		require.NoError(t, err)
+
+		_, err = migrator.Migrate(42)
+		assert.ErrorIs(t, err, migrations.ErrUnknownVersion)
+	})
+
+	t.Run("Error with failing migration", func(t *testing.T) {
+		db := newDB(t)
+
+		migrator, err := migrations.NewFromFS(db, fstest.MapFS{
+			"0001_ok.up.sql":     {Data: []byte(`CREATE TABLE ok (id INTEGER);`)},
+			"0002_broken.up.sql": {Data: []byte(`CREATE TABLE broken (id INTEGER); SELECT * FROM nope;`)},
+		})
+		require.NoError(t, err)
+
+		steps, err := migrator.Up()
+		assert.Error(t, err)
+		assert.Len(t, steps, 1)
+		assert.False(t, tableExists(t, db, "broken"))
+
+		version, err := migrator.Version()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, version)
+	})
+
+	t.Run("Error with invalid file names", func(t *testing.T) {
+		_, err := migrations.NewFromFS(newDB(t), fstest.MapFS{
+			"create_a.sql": {Data: []byte(`CREATE TABLE a (id INTEGER);`)},
+		})
+		assert.ErrorIs(t, err, migrations.ErrInvalidMigration)
+
+		_, err = migrations.NewFromFS(newDB(t), fstest.MapFS{
+			"0001_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)},
+		})
+		assert.ErrorIs(t, err, migrations.ErrInvalidMigration)
+	})
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS cart_products;
+DROP TABLE IF EXISTS products;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS carts (
+    id VARCHAR(255) PRIMARY KEY,
+    status VARCHAR(32) NOT NULL DEFAULT 'open',
+    created_at DATETIME DEFAULT current_timestamp,
+    updated_at DATETIME DEFAULT current_timestamp
+);
+
+-- Carts used to exist only implicitly through cart_products
+INSERT OR IGNORE INTO carts (id) SELECT DISTINCT cart_id FROM cart_products;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_lines;
+DROP INDEX IF EXISTS orders_cart_id;
+DROP TABLE IF EXISTS orders;
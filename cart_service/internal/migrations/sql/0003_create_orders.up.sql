@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS orders (
+    id VARCHAR(255) PRIMARY KEY,
+    cart_id VARCHAR(255) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    item_count INTEGER NOT NULL,
+    subtotal REAL NOT NULL,
+    total REAL NOT NULL,
+    created_at DATETIME DEFAULT current_timestamp,
+    FOREIGN KEY (cart_id) REFERENCES carts (id)
+);
+
+CREATE INDEX IF NOT EXISTS orders_cart_id ON orders (cart_id);
+
+CREATE TABLE IF NOT EXISTS order_lines (
+    order_id VARCHAR(255),
+    product_id VARCHAR(255),
+    name VARCHAR(255) NOT NULL,
+    unit_price REAL NOT NULL,
+    quantity INTEGER NOT NULL,
+    total REAL NOT NULL,
+    PRIMARY KEY (order_id, product_id),
+    FOREIGN KEY (order_id) REFERENCES orders (id),
+    FOREIGN KEY (product_id) REFERENCES products (id)
+);
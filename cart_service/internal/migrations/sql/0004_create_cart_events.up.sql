@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS cart_events (
+    cart_id VARCHAR(255),
+    sequence INTEGER NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME DEFAULT current_timestamp,
+    PRIMARY KEY (cart_id, sequence)
+);
@@ -0,0 +1 @@
+ALTER TABLE carts ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE order_lines DROP COLUMN discount;
+ALTER TABLE orders DROP COLUMN discount;
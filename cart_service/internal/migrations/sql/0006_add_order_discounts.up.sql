@@ -0,0 +1,2 @@
+ALTER TABLE orders ADD COLUMN discount REAL NOT NULL DEFAULT 0;
+ALTER TABLE order_lines ADD COLUMN discount REAL NOT NULL DEFAULT 0;
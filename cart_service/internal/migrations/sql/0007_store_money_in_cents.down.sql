@@ -0,0 +1,34 @@
+ALTER TABLE order_lines ADD COLUMN unit_price_real REAL NOT NULL DEFAULT 0;
+ALTER TABLE order_lines ADD COLUMN discount_real REAL NOT NULL DEFAULT 0;
+ALTER TABLE order_lines ADD COLUMN total_real REAL NOT NULL DEFAULT 0;
+UPDATE order_lines SET
+    unit_price_real = unit_price / 100.0,
+    discount_real = discount / 100.0,
+    total_real = total / 100.0;
+ALTER TABLE order_lines DROP COLUMN unit_price;
+ALTER TABLE order_lines DROP COLUMN discount;
+ALTER TABLE order_lines DROP COLUMN total;
+ALTER TABLE order_lines RENAME COLUMN unit_price_real TO unit_price;
+ALTER TABLE order_lines RENAME COLUMN discount_real TO discount;
+ALTER TABLE order_lines RENAME COLUMN total_real TO total;
+
+ALTER TABLE orders DROP COLUMN currency;
+ALTER TABLE orders ADD COLUMN subtotal_real REAL NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount_real REAL NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN total_real REAL NOT NULL DEFAULT 0;
+UPDATE orders SET
+    subtotal_real = subtotal / 100.0,
+    discount_real = discount / 100.0,
+    total_real = total / 100.0;
+ALTER TABLE orders DROP COLUMN subtotal;
+ALTER TABLE orders DROP COLUMN discount;
+ALTER TABLE orders DROP COLUMN total;
+ALTER TABLE orders RENAME COLUMN subtotal_real TO subtotal;
+ALTER TABLE orders RENAME COLUMN discount_real TO discount;
+ALTER TABLE orders RENAME COLUMN total_real TO total;
+
+ALTER TABLE products DROP COLUMN currency;
+ALTER TABLE products ADD COLUMN price_real REAL NOT NULL DEFAULT 0;
+UPDATE products SET price_real = price / 100.0;
+ALTER TABLE products DROP COLUMN price;
+ALTER TABLE products RENAME COLUMN price_real TO price;
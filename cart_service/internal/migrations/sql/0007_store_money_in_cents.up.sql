@@ -0,0 +1,36 @@
+-- Prices and amounts move from REAL to INTEGER minor units, along with their currency
+
+ALTER TABLE products ADD COLUMN price_cents INTEGER NOT NULL DEFAULT 0;
+UPDATE products SET price_cents = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE products DROP COLUMN price;
+ALTER TABLE products RENAME COLUMN price_cents TO price;
+ALTER TABLE products ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'BRL';
+
+ALTER TABLE orders ADD COLUMN subtotal_cents INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount_cents INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN total_cents INTEGER NOT NULL DEFAULT 0;
+UPDATE orders SET
+    subtotal_cents = CAST(ROUND(subtotal * 100) AS INTEGER),
+    discount_cents = CAST(ROUND(discount * 100) AS INTEGER),
+    total_cents = CAST(ROUND(total * 100) AS INTEGER);
+ALTER TABLE orders DROP COLUMN subtotal;
+ALTER TABLE orders DROP COLUMN discount;
+ALTER TABLE orders DROP COLUMN total;
+ALTER TABLE orders RENAME COLUMN subtotal_cents TO subtotal;
+ALTER TABLE orders RENAME COLUMN discount_cents TO discount;
+ALTER TABLE orders RENAME COLUMN total_cents TO total;
+ALTER TABLE orders ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'BRL';
+
+ALTER TABLE order_lines ADD COLUMN unit_price_cents INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_lines ADD COLUMN discount_cents INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_lines ADD COLUMN total_cents INTEGER NOT NULL DEFAULT 0;
+UPDATE order_lines SET
+    unit_price_cents = CAST(ROUND(unit_price * 100) AS INTEGER),
+    discount_cents = CAST(ROUND(discount * 100) AS INTEGER),
+    total_cents = CAST(ROUND(total * 100) AS INTEGER);
+ALTER TABLE order_lines DROP COLUMN unit_price;
+ALTER TABLE order_lines DROP COLUMN discount;
+ALTER TABLE order_lines DROP COLUMN total;
+ALTER TABLE order_lines RENAME COLUMN unit_price_cents TO unit_price;
+ALTER TABLE order_lines RENAME COLUMN discount_cents TO discount;
+ALTER TABLE order_lines RENAME COLUMN total_cents TO total;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS promotions_product_id;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS promotions (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    product_id VARCHAR(255) NOT NULL,
+    percent INTEGER NOT NULL DEFAULT 0,
+    amount INTEGER NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT 'BRL',
+    buy_quantity INTEGER NOT NULL DEFAULT 0,
+    get_quantity INTEGER NOT NULL DEFAULT 0,
+    starts_at DATETIME,
+    ends_at DATETIME,
+    created_at DATETIME DEFAULT current_timestamp,
+    FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+CREATE INDEX IF NOT EXISTS promotions_product_id ON promotions (product_id);
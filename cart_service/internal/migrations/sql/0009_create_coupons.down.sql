@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS coupon_redemptions_customer;
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS cart_coupons;
+DROP TABLE IF EXISTS coupons;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS coupons (
+    code VARCHAR(64) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    kind VARCHAR(32) NOT NULL,
+    percent INTEGER NOT NULL DEFAULT 0,
+    amount INTEGER NOT NULL DEFAULT 0,
+    minimum_basket INTEGER NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT 'BRL',
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    uses INTEGER NOT NULL DEFAULT 0,
+    single_use_per_customer BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at DATETIME,
+    created_at DATETIME DEFAULT current_timestamp
+);
+
+-- Coupons applied to a cart, until it is checked out
+CREATE TABLE IF NOT EXISTS cart_coupons (
+    cart_id VARCHAR(255),
+    code VARCHAR(64),
+    customer_id VARCHAR(255),
+    created_at DATETIME DEFAULT current_timestamp,
+    PRIMARY KEY (cart_id, code),
+    FOREIGN KEY (cart_id) REFERENCES carts (id),
+    FOREIGN KEY (code) REFERENCES coupons (code)
+);
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    code VARCHAR(64) NOT NULL,
+    order_id VARCHAR(255) NOT NULL,
+    customer_id VARCHAR(255),
+    discount INTEGER NOT NULL,
+    created_at DATETIME DEFAULT current_timestamp,
+    PRIMARY KEY (order_id, code),
+    FOREIGN KEY (code) REFERENCES coupons (code),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+
+CREATE INDEX IF NOT EXISTS coupon_redemptions_customer ON coupon_redemptions (code, customer_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS payment_intents_order_id;
+DROP TABLE IF EXISTS payment_intents;
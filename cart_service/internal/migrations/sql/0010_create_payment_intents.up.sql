@@ -0,0 +1,19 @@
+-- order_id is not a foreign key, the order is only created once the payment is captured
+CREATE TABLE IF NOT EXISTS payment_intents (
+    id VARCHAR(255) PRIMARY KEY,
+    order_id VARCHAR(255) NOT NULL,
+    cart_id VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    amount INTEGER NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'BRL',
+    status VARCHAR(32) NOT NULL,
+    method VARCHAR(64) NOT NULL,
+    gateway VARCHAR(64) NOT NULL,
+    transaction_id VARCHAR(255),
+    failure_reason TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    FOREIGN KEY (cart_id) REFERENCES carts (id)
+);
+
+CREATE INDEX IF NOT EXISTS payment_intents_order_id ON payment_intents (order_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS payment_intents_transaction;
@@ -0,0 +1,2 @@
+-- PSP webhooks find payments by the transaction they confirm
+CREATE INDEX IF NOT EXISTS payment_intents_transaction ON payment_intents (gateway, transaction_id);
@@ -0,0 +1,6 @@
+ALTER TABLE products DROP COLUMN unit;
+ALTER TABLE products DROP COLUMN csosn;
+ALTER TABLE products DROP COLUMN origin;
+ALTER TABLE products DROP COLUMN cfop;
+ALTER TABLE products DROP COLUMN cest;
+ALTER TABLE products DROP COLUMN ncm;
@@ -0,0 +1,6 @@
+ALTER TABLE products ADD COLUMN ncm VARCHAR(8) NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN cest VARCHAR(7) NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN cfop VARCHAR(4) NOT NULL DEFAULT '5102';
+ALTER TABLE products ADD COLUMN origin INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN csosn VARCHAR(3) NOT NULL DEFAULT '102';
+ALTER TABLE products ADD COLUMN unit VARCHAR(6) NOT NULL DEFAULT 'UN';
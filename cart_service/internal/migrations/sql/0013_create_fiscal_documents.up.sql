@@ -0,0 +1,15 @@
+-- NFC-e issued for the orders, numbered in sequence within each series
+CREATE TABLE IF NOT EXISTS fiscal_documents (
+    order_id VARCHAR(255) PRIMARY KEY,
+    access_key VARCHAR(44) NOT NULL UNIQUE,
+    series INTEGER NOT NULL,
+    number INTEGER NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    xml TEXT NOT NULL,
+    protocol VARCHAR(64),
+    status_message TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (series, number),
+    FOREIGN KEY (order_id) REFERENCES orders (id)
+);
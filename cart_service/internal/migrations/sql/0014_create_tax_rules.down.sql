@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tax_rules;
+ALTER TABLE products DROP COLUMN tax_class;
@@ -0,0 +1,13 @@
+ALTER TABLE products ADD COLUMN tax_class VARCHAR(32) NOT NULL DEFAULT 'standard';
+
+-- Rates are in basis points, 1800 being 18%
+CREATE TABLE IF NOT EXISTS tax_rules (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    tax_class VARCHAR(32) NOT NULL,
+    tax VARCHAR(16) NOT NULL,
+    rate INTEGER NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at DATETIME DEFAULT current_timestamp,
+    UNIQUE (tax_class, tax)
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS order_line_taxes;
+ALTER TABLE order_lines DROP COLUMN tax;
+ALTER TABLE orders DROP COLUMN tax_added;
+ALTER TABLE orders DROP COLUMN tax;
@@ -0,0 +1,15 @@
+ALTER TABLE orders ADD COLUMN tax INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tax_added INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_lines ADD COLUMN tax INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS order_line_taxes (
+    order_id VARCHAR(255) NOT NULL,
+    product_id VARCHAR(255) NOT NULL,
+    tax VARCHAR(16) NOT NULL,
+    rate INTEGER NOT NULL,
+    inclusive BOOLEAN NOT NULL,
+    base INTEGER NOT NULL,
+    amount INTEGER NOT NULL,
+    PRIMARY KEY (order_id, product_id, tax),
+    FOREIGN KEY (order_id, product_id) REFERENCES order_lines (order_id, product_id)
+);
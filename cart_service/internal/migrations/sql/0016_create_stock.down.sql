@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS stock_alerts;
+DROP INDEX IF EXISTS stock_adjustments_product_id;
+DROP TABLE IF EXISTS stock_adjustments;
+DROP INDEX IF EXISTS stock_reservations_product_id;
+DROP TABLE IF EXISTS stock_reservations;
+DROP TABLE IF EXISTS stock;
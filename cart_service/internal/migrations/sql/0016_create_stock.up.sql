@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS stock (
+    product_id VARCHAR(255) PRIMARY KEY,
+    on_hand INTEGER NOT NULL DEFAULT 0,
+    low_stock_threshold INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT current_timestamp,
+    updated_at DATETIME DEFAULT current_timestamp,
+    FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+-- Units of tracked products sitting in carts, held until expires_at unless the cart changes again
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    cart_id VARCHAR(255) NOT NULL,
+    product_id VARCHAR(255) NOT NULL,
+    quantity INTEGER NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (cart_id, product_id),
+    FOREIGN KEY (cart_id) REFERENCES carts (id),
+    FOREIGN KEY (product_id) REFERENCES stock (product_id)
+);
+
+CREATE INDEX IF NOT EXISTS stock_reservations_product_id ON stock_reservations (product_id, expires_at);
+
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id VARCHAR(255) NOT NULL,
+    delta INTEGER NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    note TEXT,
+    order_id VARCHAR(255),
+    on_hand INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (product_id) REFERENCES stock (product_id)
+);
+
+CREATE INDEX IF NOT EXISTS stock_adjustments_product_id ON stock_adjustments (product_id);
+
+CREATE TABLE IF NOT EXISTS stock_alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id VARCHAR(255) NOT NULL,
+    on_hand INTEGER NOT NULL,
+    threshold INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    resolved_at DATETIME,
+    FOREIGN KEY (product_id) REFERENCES stock (product_id)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS product_labels_product_id;
+DROP TABLE IF EXISTS product_labels;
+ALTER TABLE products DROP COLUMN weight_tolerance;
+ALTER TABLE products DROP COLUMN weight_grams;
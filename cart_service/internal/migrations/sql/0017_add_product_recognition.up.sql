@@ -0,0 +1,11 @@
+ALTER TABLE products ADD COLUMN weight_grams INTEGER;
+ALTER TABLE products ADD COLUMN weight_tolerance INTEGER NOT NULL DEFAULT 50;
+
+-- Each label the detector outputs maps to a single product
+CREATE TABLE IF NOT EXISTS product_labels (
+    label VARCHAR(64) PRIMARY KEY,
+    product_id VARCHAR(255) NOT NULL,
+    FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+CREATE INDEX IF NOT EXISTS product_labels_product_id ON product_labels (product_id);
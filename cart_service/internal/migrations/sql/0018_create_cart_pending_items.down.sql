@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS cart_pending_items_expires_at;
+DROP INDEX IF EXISTS cart_pending_items_cart_id;
+DROP TABLE IF EXISTS cart_pending_items;
@@ -0,0 +1,19 @@
+-- Changes seen by cart devices that wait for the shopper to confirm or reject them
+CREATE TABLE IF NOT EXISTS cart_pending_items (
+    id VARCHAR(255) PRIMARY KEY,
+    cart_id VARCHAR(255) NOT NULL,
+    product_id VARCHAR(255) NOT NULL,
+    quantity INTEGER NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    label VARCHAR(64) NOT NULL,
+    confidence REAL NOT NULL,
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    resolved_at DATETIME,
+    FOREIGN KEY (cart_id) REFERENCES carts (id),
+    FOREIGN KEY (product_id) REFERENCES products (id)
+);
+
+CREATE INDEX IF NOT EXISTS cart_pending_items_cart_id ON cart_pending_items (cart_id, status);
+CREATE INDEX IF NOT EXISTS cart_pending_items_expires_at ON cart_pending_items (status, expires_at);
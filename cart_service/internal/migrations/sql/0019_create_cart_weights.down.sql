@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS cart_anomalies_cart_id;
+DROP TABLE IF EXISTS cart_anomalies;
+DROP TABLE IF EXISTS cart_weights;
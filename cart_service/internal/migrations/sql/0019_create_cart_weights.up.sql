@@ -0,0 +1,24 @@
+-- Last weight reading of each cart, reconciled against its lines
+CREATE TABLE IF NOT EXISTS cart_weights (
+    cart_id VARCHAR(255) PRIMARY KEY,
+    measured_grams REAL NOT NULL,
+    expected_grams REAL NOT NULL,
+    reconciled BOOLEAN NOT NULL,
+    mismatch_since DATETIME,
+    measured_at DATETIME NOT NULL,
+    FOREIGN KEY (cart_id) REFERENCES carts (id)
+);
+
+CREATE TABLE IF NOT EXISTS cart_anomalies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    cart_id VARCHAR(255) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    expected_grams REAL NOT NULL,
+    measured_grams REAL NOT NULL,
+    since DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    resolved_at DATETIME,
+    FOREIGN KEY (cart_id) REFERENCES carts (id)
+);
+
+CREATE INDEX IF NOT EXISTS cart_anomalies_cart_id ON cart_anomalies (cart_id, kind, resolved_at);
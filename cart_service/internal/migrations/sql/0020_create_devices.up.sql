@@ -0,0 +1,10 @@
+-- Physical carts, each bound to the cart of its current shopping session, if any
+CREATE TABLE IF NOT EXISTS devices (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    secret_hash VARCHAR(64) NOT NULL,
+    cart_id VARCHAR(255) UNIQUE,
+    bound_at DATETIME,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (cart_id) REFERENCES carts (id)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS device_heartbeats_received_at;
+DROP INDEX IF EXISTS device_heartbeats_device_id;
+DROP TABLE IF EXISTS device_heartbeats;
+ALTER TABLE devices DROP COLUMN health_since;
+ALTER TABLE devices DROP COLUMN health;
@@ -0,0 +1,18 @@
+-- Health of each device as of its last heartbeat, offline until it sends one
+ALTER TABLE devices ADD COLUMN health VARCHAR(16) NOT NULL DEFAULT 'offline';
+ALTER TABLE devices ADD COLUMN health_since DATETIME;
+
+-- Status of the components of the devices, only kept for a while
+CREATE TABLE IF NOT EXISTS device_heartbeats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    device_id VARCHAR(255) NOT NULL,
+    camera_fps REAL,
+    detector_latency_ms REAL,
+    scale_grams REAL,
+    battery_percent REAL,
+    received_at DATETIME NOT NULL,
+    FOREIGN KEY (device_id) REFERENCES devices (id)
+);
+
+CREATE INDEX IF NOT EXISTS device_heartbeats_device_id ON device_heartbeats (device_id, id);
+CREATE INDEX IF NOT EXISTS device_heartbeats_received_at ON device_heartbeats (received_at);
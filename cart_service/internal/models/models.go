@@ -1,16 +1,109 @@
 package models
 
+import (
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+type CartStatus string
+
+const (
+	CartOpen        CartStatus = "open"
+	CartCheckingOut CartStatus = "checking_out"
+	CartPaid        CartStatus = "paid"
+	CartClosed      CartStatus = "closed"
+	CartAbandoned   CartStatus = "abandoned"
+)
+
+var cartTransitions = map[CartStatus][]CartStatus{
+	CartOpen:        {CartCheckingOut, CartClosed, CartAbandoned},
+	CartCheckingOut: {CartOpen, CartPaid, CartClosed, CartAbandoned},
+	CartPaid:        {CartClosed},
+}
+
+func (s CartStatus) Valid() bool {
+	switch s {
+	case CartOpen, CartCheckingOut, CartPaid, CartClosed, CartAbandoned:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether a cart in status s may move to next.
+func (s CartStatus) CanTransitionTo(next CartStatus) bool {
+	for _, allowed := range cartTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Cart struct {
-	ID       string         `json:"id"`
-	Products []*CartProduct `json:"products"`
+	ID     string     `json:"id"`
+	Status CartStatus `json:"status"`
+	// Version is bumped on every change to the cart lines
+	Version   uint64         `json:"version"`
+	Products  []*CartProduct `json:"products"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Line returns the cart line of the product, or nil if it is not in the cart.
+func (c *Cart) Line(productId string) *CartProduct {
+	for _, cp := range c.Products {
+		if cp.ProductID == productId {
+			return cp
+		}
+	}
+	return nil
 }
 
 type Product struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description *string `json:"description"`
-	Price       float64 `json:"price"`
-	ImageURL    *string `json:"image_url"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description *string     `json:"description"`
+	Price       money.Money `json:"price"`
+	ImageURL    *string     `json:"image_url"`
+	// TaxClass picks the tax rules that apply to the product
+	TaxClass string `json:"tax_class"`
+	// Fiscal and Recognition are only loaded by the product repository, not along with carts and orders
+	Fiscal      *ProductFiscal      `json:"fiscal,omitempty"`
+	Recognition *ProductRecognition `json:"recognition,omitempty"`
+}
+
+// ProductFiscal classifies a product for the fiscal documents of its sales.
+type ProductFiscal struct {
+	// NCM is the 8 digit Mercosur code of the product
+	NCM string `json:"ncm"`
+	// CEST is only required for products under tax substitution
+	CEST string `json:"cest"`
+	CFOP string `json:"cfop"`
+	// Origin is the ICMS origin of the goods, 0 being national
+	Origin uint `json:"origin"`
+	// CSOSN is the ICMS situation of stores in the Simples Nacional
+	CSOSN string `json:"csosn"`
+	Unit  string `json:"unit"`
+}
+
+// DefaultProductFiscal is a national product sold to consumers in the state, without ICMS credit.
+func DefaultProductFiscal() *ProductFiscal {
+	return &ProductFiscal{CFOP: "5102", CSOSN: "102", Unit: "UN"}
+}
+
+// DefaultWeightTolerance is the tolerance of products that don't set their own, in percent.
+const DefaultWeightTolerance = 50
+
+// ProductRecognition is how the cart tells the product apart, by what its camera sees and its scale weighs.
+type ProductRecognition struct {
+	// Labels are the classes the detector of the cart recognizes the product as, each one
+	// belonging to a single product
+	Labels []string `json:"labels"`
+	// WeightGrams is the expected weight of one unit, the product can't be weighed without it
+	WeightGrams *uint `json:"weight_grams"`
+	// WeightTolerance is how far a reading may be from the expected weight, in percent
+	WeightTolerance uint `json:"weight_tolerance"`
 }
 
 type CartProduct struct {
@@ -19,3 +112,524 @@ type CartProduct struct {
 	Quantity  uint    `json:"quantity"`
 	Product   Product `json:"product"`
 }
+
+// CartTotals are the amounts of a cart, as computed by the pricing package.
+type CartTotals struct {
+	Lines     []*LineTotals `json:"lines"`
+	ItemCount uint          `json:"item_count"`
+	Subtotal  money.Money   `json:"subtotal"`
+	// Discount adds up the promotions on the lines and the coupons on the whole cart
+	Discount money.Money      `json:"discount"`
+	Coupons  []*AppliedCoupon `json:"coupons"`
+	// Tax adds up every tax of the lines, TaxAdded being the part of it charged on top of the prices
+	Tax      money.Money  `json:"tax"`
+	TaxAdded money.Money  `json:"tax_added"`
+	Taxes    []*TaxAmount `json:"taxes"`
+	Total    money.Money  `json:"total"`
+}
+
+type LineTotals struct {
+	ProductID  string              `json:"product_id"`
+	Name       string              `json:"name"`
+	UnitPrice  money.Money         `json:"unit_price"`
+	Quantity   uint                `json:"quantity"`
+	Subtotal   money.Money         `json:"subtotal"`
+	Discount   money.Money         `json:"discount"`
+	Total      money.Money         `json:"total"`
+	Promotions []*AppliedPromotion `json:"promotions"`
+	Tax        money.Money         `json:"tax"`
+	Taxes      []*TaxAmount        `json:"taxes"`
+}
+
+// AppliedPromotion is a promotion that took money off a cart line.
+type AppliedPromotion struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	Discount money.Money `json:"discount"`
+}
+
+type PromotionKind string
+
+const (
+	// PromotionPercentage takes Percent off every unit.
+	PromotionPercentage PromotionKind = "percentage"
+	// PromotionFixed takes Amount off every unit.
+	PromotionFixed PromotionKind = "fixed"
+	// PromotionBuyXGetY gives GetQuantity units for free for every BuyQuantity units bought.
+	PromotionBuyXGetY PromotionKind = "buy_x_get_y"
+	// PromotionMultiBuy sells every BuyQuantity units for Amount, as in "3 Chamyto for 25.00".
+	PromotionMultiBuy PromotionKind = "multi_buy"
+)
+
+func (k PromotionKind) Valid() bool {
+	switch k {
+	case PromotionPercentage, PromotionFixed, PromotionBuyXGetY, PromotionMultiBuy:
+		return true
+	}
+	return false
+}
+
+// Promotion is a discount rule on a product, optionally limited to a time window.
+type Promotion struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Kind        PromotionKind `json:"kind"`
+	ProductID   string        `json:"product_id"`
+	Percent     uint          `json:"percent"`
+	Amount      money.Money   `json:"amount"`
+	BuyQuantity uint          `json:"buy_quantity"`
+	GetQuantity uint          `json:"get_quantity"`
+	StartsAt    *time.Time    `json:"starts_at"`
+	EndsAt      *time.Time    `json:"ends_at"`
+}
+
+// ActiveAt reports whether t falls in the window of the promotion, which includes its start but not its end.
+func (p *Promotion) ActiveAt(t time.Time) bool {
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+type CouponKind string
+
+const (
+	// CouponPercentage takes Percent off the cart total.
+	CouponPercentage CouponKind = "percentage"
+	// CouponFixed takes Amount off the cart total.
+	CouponFixed CouponKind = "fixed"
+)
+
+func (k CouponKind) Valid() bool {
+	return k == CouponPercentage || k == CouponFixed
+}
+
+type Coupon struct {
+	Code        string      `json:"code"`
+	Description string      `json:"description"`
+	Kind        CouponKind  `json:"kind"`
+	Percent     uint        `json:"percent"`
+	Amount      money.Money `json:"amount"`
+	// MinimumBasket is checked against the cart total after promotions
+	MinimumBasket money.Money `json:"minimum_basket"`
+	// MaxUses of 0 means the coupon can be redeemed any number of times
+	MaxUses              uint       `json:"max_uses"`
+	Uses                 uint       `json:"uses"`
+	SingleUsePerCustomer bool       `json:"single_use_per_customer"`
+	ExpiresAt            *time.Time `json:"expires_at"`
+}
+
+// CartCoupon is a coupon applied to a cart, only redeemed when the cart is checked out.
+type CartCoupon struct {
+	*Coupon
+	CustomerID *string `json:"customer_id"`
+}
+
+// CouponsOf returns the coupons behind the cart coupons, as priced by the calculator.
+func CouponsOf(cartCoupons []*CartCoupon) []*Coupon {
+	coupons := make([]*Coupon, 0, len(cartCoupons))
+	for _, cc := range cartCoupons {
+		coupons = append(coupons, cc.Coupon)
+	}
+	return coupons
+}
+
+// AppliedCoupon is a coupon that took money off a cart or order.
+type AppliedCoupon struct {
+	Code     string      `json:"code"`
+	Discount money.Money `json:"discount"`
+}
+
+// DefaultTaxClass is the tax class of products not given one.
+const DefaultTaxClass = "standard"
+
+type TaxKind string
+
+const (
+	TaxICMS   TaxKind = "icms"
+	TaxPIS    TaxKind = "pis"
+	TaxCOFINS TaxKind = "cofins"
+	TaxIPI    TaxKind = "ipi"
+)
+
+func (k TaxKind) Valid() bool {
+	switch k {
+	case TaxICMS, TaxPIS, TaxCOFINS, TaxIPI:
+		return true
+	}
+	return false
+}
+
+// TaxRule charges a tax on the products of a tax class. Inclusive taxes are part of the
+// price, as ICMS, PIS and COFINS are, and exclusive ones are charged on top of it, as IPI is.
+// Either way the tax is Rate of what is paid for the line, after discounts.
+type TaxRule struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	TaxClass string  `json:"tax_class"`
+	Tax      TaxKind `json:"tax"`
+	// Rate is in basis points, 1800 being 18%
+	Rate      uint `json:"rate"`
+	Inclusive bool `json:"inclusive"`
+}
+
+// TaxAmount is a tax charged on a line, or the sum of the ones with the same tax and rate on a cart or order.
+type TaxAmount struct {
+	Tax       TaxKind     `json:"tax"`
+	Rate      uint        `json:"rate"`
+	Inclusive bool        `json:"inclusive"`
+	Base      money.Money `json:"base"`
+	Amount    money.Money `json:"amount"`
+}
+
+// SumTaxes adds up the taxes of the lines with the same tax, rate and inclusiveness, in the order they first show up.
+func SumTaxes(lines [][]*TaxAmount) []*TaxAmount {
+	sums := make([]*TaxAmount, 0)
+
+	for _, taxes := range lines {
+	next:
+		for _, tax := range taxes {
+			for _, sum := range sums {
+				if sum.Tax == tax.Tax && sum.Rate == tax.Rate && sum.Inclusive == tax.Inclusive {
+					sum.Base = sum.Base.Add(tax.Base)
+					sum.Amount = sum.Amount.Add(tax.Amount)
+					continue next
+				}
+			}
+			sum := *tax
+			sums = append(sums, &sum)
+		}
+	}
+
+	return sums
+}
+
+type OrderStatus string
+
+const (
+	// OrderPending is placed but waiting for its payment to be captured
+	OrderPending   OrderStatus = "pending"
+	OrderCompleted OrderStatus = "completed"
+	// OrderCancelled could not be paid for, its units and coupons were given back to the cart
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+type Order struct {
+	ID        string           `json:"id"`
+	CartID    string           `json:"cart_id"`
+	Status    OrderStatus      `json:"status"`
+	Lines     []*OrderLine     `json:"lines"`
+	ItemCount uint             `json:"item_count"`
+	Subtotal  money.Money      `json:"subtotal"`
+	Discount  money.Money      `json:"discount"`
+	Coupons   []*AppliedCoupon `json:"coupons"`
+	Tax       money.Money      `json:"tax"`
+	TaxAdded  money.Money      `json:"tax_added"`
+	Taxes     []*TaxAmount     `json:"taxes"`
+	Total     money.Money      `json:"total"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// OrderLine is a snapshot of a cart line, frozen with the price at checkout time.
+type OrderLine struct {
+	OrderID   string       `json:"order_id"`
+	ProductID string       `json:"product_id"`
+	Name      string       `json:"name"`
+	UnitPrice money.Money  `json:"unit_price"`
+	Quantity  uint         `json:"quantity"`
+	Discount  money.Money  `json:"discount"`
+	Total     money.Money  `json:"total"`
+	Tax       money.Money  `json:"tax"`
+	Taxes     []*TaxAmount `json:"taxes"`
+}
+
+// NewOrder snapshots the priced cart lines into a completed order.
+func NewOrder(id string, cartId string, totals *CartTotals) *Order {
+	order := &Order{
+		ID:        id,
+		CartID:    cartId,
+		Status:    OrderCompleted,
+		Lines:     make([]*OrderLine, 0, len(totals.Lines)),
+		ItemCount: totals.ItemCount,
+		Subtotal:  totals.Subtotal,
+		Discount:  totals.Discount,
+		Coupons:   totals.Coupons,
+		Tax:       totals.Tax,
+		TaxAdded:  totals.TaxAdded,
+		Taxes:     totals.Taxes,
+		Total:     totals.Total,
+	}
+
+	for _, lt := range totals.Lines {
+		order.Lines = append(order.Lines, &OrderLine{
+			OrderID:   id,
+			ProductID: lt.ProductID,
+			Name:      lt.Name,
+			UnitPrice: lt.UnitPrice,
+			Quantity:  lt.Quantity,
+			Discount:  lt.Discount,
+			Total:     lt.Total,
+			Tax:       lt.Tax,
+			Taxes:     lt.Taxes,
+		})
+	}
+
+	return order
+}
+
+// CartEvent is a persisted notification about a cart. Sequence grows
+// monotonically per cart, so clients can resume from the last one they saw.
+type CartEvent struct {
+	CartID    string    `json:"cart_id"`
+	Sequence  uint64    `json:"sequence"`
+	Event     string    `json:"event"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PaymentStatus string
+
+const (
+	// PaymentPending is a payment whose authorization was not confirmed yet
+	PaymentPending    PaymentStatus = "pending"
+	PaymentAuthorized PaymentStatus = "authorized"
+	// PaymentCaptured is a payment whose order was placed
+	PaymentCaptured PaymentStatus = "captured"
+	// PaymentFailed is a payment declined by the gateway
+	PaymentFailed PaymentStatus = "failed"
+	// PaymentVoided is a payment released because the order could not be placed
+	PaymentVoided   PaymentStatus = "voided"
+	PaymentRefunded PaymentStatus = "refunded"
+)
+
+// PaymentIntent tracks one attempt at paying for a cart. Its order id is chosen
+// upfront, the order only existing once the payment is captured.
+type PaymentIntent struct {
+	ID      string `json:"id"`
+	OrderID string `json:"order_id"`
+	CartID  string `json:"cart_id"`
+	// IdempotencyKey is sent by the client, retrying a checkout with it resumes this payment
+	IdempotencyKey string        `json:"-"`
+	Amount         money.Money   `json:"amount"`
+	Status         PaymentStatus `json:"status"`
+	Method         string        `json:"method"`
+	Gateway        string        `json:"gateway"`
+	TransactionID  *string       `json:"transaction_id"`
+	FailureReason  *string       `json:"failure_reason"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// Store is the shop the orders are placed at, as printed on receipts.
+type Store struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// TaxID is the CNPJ of the store
+	TaxID string `json:"tax_id"`
+}
+
+type FiscalStatus string
+
+const (
+	// FiscalSigned is a document not authorized by the SEFAZ yet, to be sent again
+	FiscalSigned     FiscalStatus = "signed"
+	FiscalAuthorized FiscalStatus = "authorized"
+	FiscalRejected   FiscalStatus = "rejected"
+)
+
+// FiscalDocument is the NFC-e of an order. Its XML is the signed document and,
+// once authorized, the document along with the authorization protocol.
+type FiscalDocument struct {
+	OrderID   string       `json:"order_id"`
+	AccessKey string       `json:"access_key"`
+	Series    uint         `json:"series"`
+	Number    uint         `json:"number"`
+	Status    FiscalStatus `json:"status"`
+	XML       []byte       `json:"-"`
+	Protocol  *string      `json:"protocol"`
+	// StatusMessage is the last answer of the SEFAZ
+	StatusMessage *string   `json:"status_message"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// StockLevel is the stock of a product. Products without one are not tracked.
+type StockLevel struct {
+	ProductID string `json:"product_id"`
+	// OnHand counts the units in the store, including the ones sitting in carts. It
+	// may go below zero when more units are sold than were counted.
+	OnHand int `json:"on_hand"`
+	// Reserved counts the units in open carts whose reservation has not expired
+	Reserved uint `json:"reserved"`
+	// Available is what should still be on the shelves
+	Available         int  `json:"available"`
+	LowStockThreshold uint `json:"low_stock_threshold"`
+	// Low is set once the units on hand are down to the low stock threshold
+	Low       bool      `json:"low"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type StockReason string
+
+const (
+	// StockReceived adds the units of a delivery.
+	StockReceived StockReason = "received"
+	// StockReturned adds the units brought back by customers.
+	StockReturned StockReason = "returned"
+	// StockShrinkage removes units lost to theft or misplacement.
+	StockShrinkage StockReason = "shrinkage"
+	// StockDamaged removes units that can no longer be sold.
+	StockDamaged StockReason = "damaged"
+	// StockCount corrects the units on hand after counting them, either way.
+	StockCount StockReason = "count"
+	// StockSale removes the units of a placed order. It is only recorded by the orders.
+	StockSale StockReason = "sale"
+)
+
+func (r StockReason) Valid() bool {
+	switch r {
+	case StockReceived, StockReturned, StockShrinkage, StockDamaged, StockCount, StockSale:
+		return true
+	}
+	return false
+}
+
+// StockAdjustment is a change to the units on hand of a product, OnHand being the units left after it.
+type StockAdjustment struct {
+	ID        int64       `json:"id"`
+	ProductID string      `json:"product_id"`
+	Delta     int         `json:"delta"`
+	Reason    StockReason `json:"reason"`
+	Note      *string     `json:"note"`
+	// OrderID is the order of a sale
+	OrderID   *string   `json:"order_id"`
+	OnHand    int       `json:"on_hand"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StockAlert is raised when the units on hand of a product drop to its low stock
+// threshold, and resolved once they are brought back above it.
+type StockAlert struct {
+	ID         int64      `json:"id"`
+	ProductID  string     `json:"product_id"`
+	OnHand     int        `json:"on_hand"`
+	Threshold  uint       `json:"threshold"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+// PendingReason is why a change seen by a cart device was not applied right away.
+type PendingReason string
+
+const (
+	// PendingLowConfidence is given when the detector is not sure enough about the product
+	PendingLowConfidence PendingReason = "low_confidence"
+	// PendingWeightMismatch is given when the scale does not agree with the detector
+	PendingWeightMismatch PendingReason = "weight_mismatch"
+	// PendingNoWeight is given when the product has no weight to check the change against
+	PendingNoWeight PendingReason = "no_weight"
+)
+
+type PendingStatus string
+
+const (
+	PendingAwaiting  PendingStatus = "pending"
+	PendingConfirmed PendingStatus = "confirmed"
+	PendingRejected  PendingStatus = "rejected"
+	// PendingExpired is given to the items the shopper did not answer in time, which leave the cart untouched
+	PendingExpired PendingStatus = "expired"
+)
+
+// PendingItem is a change to a cart line held until the shopper confirms or rejects it.
+type PendingItem struct {
+	ID        string `json:"id"`
+	CartID    string `json:"cart_id"`
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	// Quantity is the change to the cart line, negative when units were taken out
+	Quantity   int           `json:"quantity"`
+	Reason     PendingReason `json:"reason"`
+	Label      string        `json:"label"`
+	Confidence float64       `json:"confidence"`
+	Status     PendingStatus `json:"status"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+	ResolvedAt *time.Time    `json:"resolved_at"`
+}
+
+// CartWeight is the last reading of the scale of a cart along with what the cart should weigh.
+type CartWeight struct {
+	CartID        string  `json:"cart_id"`
+	MeasuredGrams float64 `json:"measured_grams"`
+	// ExpectedGrams is what the lines of the cart weigh according to the catalog
+	ExpectedGrams float64 `json:"expected_grams"`
+	// Reconciled is unset when the reading could not be checked against the lines, as when
+	// a product in the cart has no weight or a change is waiting for the shopper
+	Reconciled bool `json:"reconciled"`
+	// MismatchSince is when the readings started to be off by more than the tolerance
+	MismatchSince *time.Time `json:"mismatch_since"`
+	MeasuredAt    time.Time  `json:"measured_at"`
+}
+
+type AnomalyKind string
+
+const (
+	// AnomalyWeightMismatch is raised when the scale of a cart keeps disagreeing with its lines
+	AnomalyWeightMismatch AnomalyKind = "weight_mismatch"
+)
+
+// CartAnomaly is something off with a cart for the staff to look at. It stays open until resolved.
+type CartAnomaly struct {
+	ID            int64       `json:"id"`
+	CartID        string      `json:"cart_id"`
+	Kind          AnomalyKind `json:"kind"`
+	ExpectedGrams float64     `json:"expected_grams"`
+	MeasuredGrams float64     `json:"measured_grams"`
+	// Since is when the discrepancy started, the anomaly being raised once it lasted long enough
+	Since      time.Time  `json:"since"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+// Device is a physical cart, whose recognizer and display authenticate with the same secret
+// and operate on the cart of the shopping session the device is bound to.
+type Device struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// SecretHash is kept in place of the secret, which is only handed out when the device is registered
+	SecretHash string `json:"-"`
+	// CartID is unset while the device waits for the next shopper
+	CartID  *string      `json:"cart_id"`
+	BoundAt *time.Time   `json:"bound_at"`
+	Health  DeviceHealth `json:"health"`
+	// HealthSince is when the device last changed health, unset if it never sent a heartbeat
+	HealthSince *time.Time `json:"health_since"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type DeviceHealth string
+
+const (
+	DeviceOnline DeviceHealth = "online"
+	// DeviceDegraded is a device that keeps sending heartbeats but with a component not working as it should
+	DeviceDegraded DeviceHealth = "degraded"
+	// DeviceOffline is a device that stopped sending heartbeats
+	DeviceOffline DeviceHealth = "offline"
+)
+
+// Heartbeat is the status of the components of a device, sent periodically. A component with
+// no status is one the device could not read.
+type Heartbeat struct {
+	ID                int64    `json:"id"`
+	DeviceID          string   `json:"device_id"`
+	CameraFPS         *float64 `json:"camera_fps"`
+	DetectorLatencyMs *float64 `json:"detector_latency_ms"`
+	// ScaleGrams is the reading of the HX711 of the scale
+	ScaleGrams *float64 `json:"scale_grams"`
+	// BatteryPercent is also unset for devices with no battery
+	BatteryPercent *float64  `json:"battery_percent"`
+	ReceivedAt     time.Time `json:"received_at"`
+}
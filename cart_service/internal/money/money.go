@@ -0,0 +1,231 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Currency string
+
+const (
+	BRL Currency = "BRL"
+	USD Currency = "USD"
+
+	DefaultCurrency = BRL
+)
+
+// Supported tells whether amounts may be in the currency. Only the default one is,
+// as every cart and order is priced in it.
+func (c Currency) Supported() bool {
+	return c == DefaultCurrency
+}
+
+// Every supported currency has cents as its minor unit
+const (
+	minorDigits = 2
+	minorUnits  = 100
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Money is an exact amount stored in minor units, so sums never drift
+// the way float64 prices did. The zero value is zero in no particular
+// currency and takes the currency of whatever it is combined with.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func New(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Cents returns an amount in the default currency.
+func Cents(amount int64) Money {
+	return New(amount, DefaultCurrency)
+}
+
+// Parse reads a decimal amount such as "5.99" or "-3" without going through float64.
+func Parse(value string, currency Currency) (Money, error) {
+	invalid := fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+
+	digits := strings.TrimSpace(value)
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if !isDigits(whole) || len(fraction) > minorDigits || (fraction != "" && !isDigits(fraction)) {
+		return Money{}, invalid
+	}
+	fraction += strings.Repeat("0", minorDigits-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, invalid
+	}
+	if negative {
+		amount = -amount
+	}
+
+	return New(amount, currency), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Add(other Money) Money {
+	return New(m.Amount+other.Amount, m.currencyWith(other))
+}
+
+func (m Money) Sub(other Money) Money {
+	return New(m.Amount-other.Amount, m.currencyWith(other))
+}
+
+// Mul multiplies the amount by a quantity, as in a unit price times the units bought.
+func (m Money) Mul(quantity int64) Money {
+	return New(m.Amount*quantity, m.Currency)
+}
+
+// Percent returns percent% of the amount, rounded half away from zero to the minor unit.
+func (m Money) Percent(percent uint) Money {
+	return m.BasisPoints(percent * 100)
+}
+
+// BasisPoints returns bp hundredths of a percent of the amount, as in a tax rate of 1.65%
+// being 165 basis points. It is rounded half away from zero to the minor unit.
+func (m Money) BasisPoints(bp uint) Money {
+	scaled := m.Amount * int64(bp)
+	half := int64(5000)
+	if scaled < 0 {
+		half = -half
+	}
+	return New((scaled+half)/10000, m.Currency)
+}
+
+// Allocate splits the amount into shares proportional to the weights, the minor units left
+// over by rounding going to the largest weight. The shares always add up to the amount.
+func (m Money) Allocate(weights []Money) []Money {
+	shares := make([]Money, len(weights))
+
+	var total int64
+	largest := 0
+	for i, weight := range weights {
+		shares[i] = New(0, m.Currency)
+		total += weight.Amount
+		if weight.Amount > weights[largest].Amount {
+			largest = i
+		}
+	}
+
+	if len(weights) == 0 || total == 0 {
+		return shares
+	}
+
+	left := m.Amount
+	for i, weight := range weights {
+		shares[i].Amount = m.Amount * weight.Amount / total
+		left -= shares[i].Amount
+	}
+	shares[largest].Amount += left
+
+	return shares
+}
+
+// Min returns the smaller of the two amounts.
+func Min(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) int {
+	m.currencyWith(other)
+	switch {
+	case m.Amount < other.Amount:
+		return -1
+	case m.Amount > other.Amount:
+		return 1
+	}
+	return 0
+}
+
+// CheckCurrency returns ErrCurrencyMismatch when the amounts can't be combined, for callers
+// that should fail instead of having Add, Sub or Cmp panic.
+func (m Money) CheckCurrency(other Money) error {
+	if m.Currency == "" || other.Currency == "" || m.Currency == other.Currency {
+		return nil
+	}
+	return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// currencyWith panics when combining amounts in different currencies, as there is
+// no sensible result and it means a cart or order was built from mixed prices.
+func (m Money) currencyWith(other Money) Currency {
+	if err := m.CheckCurrency(other); err != nil {
+		panic(err)
+	}
+	if m.Currency == "" {
+		return other.Currency
+	}
+	return m.Currency
+}
+
+// String formats the amount as a plain decimal, such as "5.99".
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/minorUnits, minorDigits, amount%minorUnits)
+}
+
+// UnmarshalJSON accepts either {"amount": 599, "currency": "BRL"} or, for
+// older clients, a decimal number or string in the default currency such as 5.99.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		type plain Money
+		var value plain
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		if value.Currency == "" {
+			value.Currency = DefaultCurrency
+		}
+		*m = Money(value)
+		return nil
+	}
+
+	parsed, err := Parse(strings.Trim(string(data), `"`), DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
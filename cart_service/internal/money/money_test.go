@@ -0,0 +1,111 @@
+package money_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		cases := map[string]int64{
+			"5.99":  599,
+			"5.9":   590,
+			"5":     500,
+			"0.01":  1,
+			"-3.50": -350,
+			"10.00": 1000,
+		}
+		for value, expected := range cases {
+			m, err := money.Parse(value, money.BRL)
+			require.NoError(t, err, value)
+			assert.Equal(t, money.New(expected, money.BRL), m, value)
+		}
+
+		for _, value := range []string{"", "abc", "1.999", ".5", "1.-5", "+1", "1.+5", "--5"} {
+			_, err := money.Parse(value, money.BRL)
+			assert.ErrorIs(t, err, money.ErrInvalidAmount, value)
+		}
+	})
+
+	t.Run("Sums are exact", func(t *testing.T) {
+		total := money.Money{}
+		for i := 0; i < 3; i++ {
+			total = total.Add(money.Cents(10))
+		}
+		assert.Equal(t, money.Cents(30), total)
+		assert.Equal(t, money.Cents(1797), money.Cents(599).Mul(3))
+		assert.Equal(t, money.Cents(-1), money.Cents(1).Sub(money.Cents(2)))
+	})
+
+	t.Run("Percent", func(t *testing.T) {
+		assert.Equal(t, money.Cents(180), money.Cents(1797).Percent(10))
+		assert.Equal(t, money.Cents(1), money.Cents(5).Percent(10))
+		assert.Equal(t, money.Cents(-1), money.Cents(-5).Percent(10))
+		assert.Equal(t, money.Cents(599), money.Cents(599).Percent(100))
+	})
+
+	t.Run("BasisPoints", func(t *testing.T) {
+		assert.Equal(t, money.Cents(165), money.Cents(10000).BasisPoints(165))
+		assert.Equal(t, money.Cents(30), money.Cents(1797).BasisPoints(165))
+		assert.Equal(t, money.Cents(-30), money.Cents(-1797).BasisPoints(165))
+		assert.Equal(t, money.Cents(0), money.Cents(0).BasisPoints(1800))
+	})
+
+	t.Run("Allocate", func(t *testing.T) {
+		shares := money.Cents(430).Allocate([]money.Money{money.Cents(2500), money.Cents(1797)})
+		assert.Equal(t, []money.Money{money.Cents(251), money.Cents(179)}, shares)
+
+		shares = money.Cents(100).Allocate([]money.Money{money.Cents(1), money.Cents(1), money.Cents(1)})
+		assert.Equal(t, []money.Money{money.Cents(34), money.Cents(33), money.Cents(33)}, shares)
+
+		assert.Equal(t, []money.Money{money.Cents(0)}, money.Cents(100).Allocate([]money.Money{money.Cents(0)}))
+	})
+
+	t.Run("Min", func(t *testing.T) {
+		assert.Equal(t, money.Cents(1), money.Min(money.Cents(1), money.Cents(2)))
+		assert.Equal(t, money.Cents(1), money.Min(money.Cents(2), money.Cents(1)))
+	})
+
+	t.Run("Mixing currencies panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			money.New(1, money.BRL).Add(money.New(1, money.USD))
+		})
+	})
+
+	t.Run("CheckCurrency", func(t *testing.T) {
+		assert.NoError(t, money.Cents(1).CheckCurrency(money.Cents(2)))
+		assert.NoError(t, money.Cents(1).CheckCurrency(money.Money{Amount: 2}))
+		assert.ErrorIs(t, money.New(1, money.BRL).CheckCurrency(money.New(1, money.USD)), money.ErrCurrencyMismatch)
+	})
+
+	t.Run("Supported", func(t *testing.T) {
+		assert.True(t, money.BRL.Supported())
+		assert.False(t, money.USD.Supported())
+		assert.False(t, money.Currency("XYZ").Supported())
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "5.99", money.Cents(599).String())
+		assert.Equal(t, "0.05", money.Cents(5).String())
+		assert.Equal(t, "-1.50", money.Cents(-150).String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(money.Cents(599))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"amount":599,"currency":"BRL"}`, string(data))
+
+		for _, input := range []string{`{"amount":599,"currency":"BRL"}`, `{"amount":599}`, `5.99`, `"5.99"`} {
+			var m money.Money
+			require.NoError(t, json.Unmarshal([]byte(input), &m), input)
+			assert.Equal(t, money.Cents(599), m, input)
+		}
+
+		var m money.Money
+		assert.Error(t, json.Unmarshal([]byte(`5.999`), &m))
+	})
+}
@@ -0,0 +1,155 @@
+package payments
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+// Payment methods with special meaning to the FakeGateway, any other one is authorized.
+const (
+	FakeDeclinedMethod    = "fake_declined"
+	FakeUnavailableMethod = "fake_unavailable"
+)
+
+type fakeStatus string
+
+const (
+	fakeAuthorized fakeStatus = "authorized"
+	fakeCaptured   fakeStatus = "captured"
+	fakeVoided     fakeStatus = "voided"
+)
+
+type fakeTransaction struct {
+	status     fakeStatus
+	authorized money.Money
+	captured   money.Money
+	refunded   money.Money
+}
+
+type fakeResult struct {
+	authorization *Authorization
+	err           error
+}
+
+// FakeGateway keeps transactions in memory, for tests and demos.
+type FakeGateway struct {
+	mu           sync.Mutex
+	transactions map[string]*fakeTransaction
+	results      map[string]fakeResult
+	next         int
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		transactions: make(map[string]*fakeTransaction),
+		results:      make(map[string]fakeResult),
+	}
+}
+
+func (f *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (f *FakeGateway) Authorize(request AuthorizeRequest) (*Authorization, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if request.IdempotencyKey != "" {
+		if result, ok := f.results[request.IdempotencyKey]; ok {
+			return result.authorization, result.err
+		}
+	}
+
+	var result fakeResult
+	switch {
+	case request.Method == FakeUnavailableMethod:
+		// Not remembered, as a real outage would not be
+		return nil, ErrUnavailable
+	case request.Method == FakeDeclinedMethod:
+		result.err = fmt.Errorf("%w: insufficient funds", ErrDeclined)
+	case request.Amount.IsNegative():
+		result.err = fmt.Errorf("%w: invalid amount", ErrDeclined)
+	default:
+		f.next++
+		id := fmt.Sprintf("fake_%d", f.next)
+		f.transactions[id] = &fakeTransaction{
+			status:     fakeAuthorized,
+			authorized: request.Amount,
+		}
+		result.authorization = &Authorization{TransactionID: id, Amount: request.Amount}
+	}
+
+	if request.IdempotencyKey != "" {
+		f.results[request.IdempotencyKey] = result
+	}
+
+	return result.authorization, result.err
+}
+
+func (f *FakeGateway) Capture(transactionId string, amount money.Money) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.transactions[transactionId]
+	if !ok {
+		return ErrUnknownTransaction
+	}
+
+	switch t.status {
+	case fakeCaptured:
+		if t.captured.Cmp(amount) == 0 {
+			return nil
+		}
+		return ErrInvalidState
+	case fakeVoided:
+		return ErrInvalidState
+	}
+
+	if amount.IsNegative() || amount.Cmp(t.authorized) > 0 {
+		return ErrAmountExceeded
+	}
+
+	t.status = fakeCaptured
+	t.captured = amount
+	return nil
+}
+
+func (f *FakeGateway) Void(transactionId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.transactions[transactionId]
+	if !ok {
+		return ErrUnknownTransaction
+	}
+
+	if t.status == fakeCaptured {
+		return ErrInvalidState
+	}
+
+	t.status = fakeVoided
+	return nil
+}
+
+func (f *FakeGateway) Refund(transactionId string, amount money.Money) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.transactions[transactionId]
+	if !ok {
+		return ErrUnknownTransaction
+	}
+
+	if t.status != fakeCaptured {
+		return ErrInvalidState
+	}
+
+	if amount.IsNegative() || t.refunded.Add(amount).Cmp(t.captured) > 0 {
+		return ErrAmountExceeded
+	}
+
+	t.refunded = t.refunded.Add(amount)
+	return nil
+}
@@ -0,0 +1,82 @@
+package payments_test
+
+import (
+	"testing"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeGateway(t *testing.T) {
+	authorize := func(t *testing.T, gateway *payments.FakeGateway, amount int64) *payments.Authorization {
+		authorization, err := gateway.Authorize(payments.AuthorizeRequest{Amount: money.Cents(amount), Method: "card"})
+		require.NoError(t, err)
+		return authorization
+	}
+
+	t.Run("Authorize and capture", func(t *testing.T) {
+		gateway := payments.NewFakeGateway()
+		authorization := authorize(t, gateway, 2896)
+
+		assert.Equal(t, money.Cents(2896), authorization.Amount)
+		assert.ErrorIs(t, gateway.Capture(authorization.TransactionID, money.Cents(2897)), payments.ErrAmountExceeded)
+		assert.NoError(t, gateway.Capture(authorization.TransactionID, money.Cents(2896)))
+		assert.NoError(t, gateway.Capture(authorization.TransactionID, money.Cents(2896)), "repeating a capture is a no-op")
+		assert.ErrorIs(t, gateway.Void(authorization.TransactionID), payments.ErrInvalidState)
+	})
+
+	t.Run("Idempotent authorizations", func(t *testing.T) {
+		gateway := payments.NewFakeGateway()
+		request := payments.AuthorizeRequest{Amount: money.Cents(100), Method: "card", IdempotencyKey: "k1"}
+
+		first, err := gateway.Authorize(request)
+		require.NoError(t, err)
+		second, err := gateway.Authorize(request)
+		require.NoError(t, err)
+		assert.Equal(t, first.TransactionID, second.TransactionID)
+
+		request.IdempotencyKey = "k2"
+		third, err := gateway.Authorize(request)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.TransactionID, third.TransactionID)
+	})
+
+	t.Run("Declined and unavailable methods", func(t *testing.T) {
+		gateway := payments.NewFakeGateway()
+
+		request := payments.AuthorizeRequest{Amount: money.Cents(100), Method: payments.FakeDeclinedMethod, IdempotencyKey: "k1"}
+		_, err := gateway.Authorize(request)
+		assert.ErrorIs(t, err, payments.ErrDeclined)
+
+		request.Method = "card"
+		_, err = gateway.Authorize(request)
+		assert.ErrorIs(t, err, payments.ErrDeclined, "the first result is kept for the key")
+
+		_, err = gateway.Authorize(payments.AuthorizeRequest{Amount: money.Cents(100), Method: payments.FakeUnavailableMethod})
+		assert.ErrorIs(t, err, payments.ErrUnavailable)
+	})
+
+	t.Run("Void", func(t *testing.T) {
+		gateway := payments.NewFakeGateway()
+		authorization := authorize(t, gateway, 500)
+
+		assert.NoError(t, gateway.Void(authorization.TransactionID))
+		assert.NoError(t, gateway.Void(authorization.TransactionID))
+		assert.ErrorIs(t, gateway.Capture(authorization.TransactionID, money.Cents(500)), payments.ErrInvalidState)
+		assert.ErrorIs(t, gateway.Void("nope"), payments.ErrUnknownTransaction)
+	})
+
+	t.Run("Refund", func(t *testing.T) {
+		gateway := payments.NewFakeGateway()
+		authorization := authorize(t, gateway, 500)
+
+		assert.ErrorIs(t, gateway.Refund(authorization.TransactionID, money.Cents(100)), payments.ErrInvalidState, "nothing was captured yet")
+		require.NoError(t, gateway.Capture(authorization.TransactionID, money.Cents(400)))
+
+		assert.NoError(t, gateway.Refund(authorization.TransactionID, money.Cents(300)))
+		assert.ErrorIs(t, gateway.Refund(authorization.TransactionID, money.Cents(200)), payments.ErrAmountExceeded)
+		assert.NoError(t, gateway.Refund(authorization.TransactionID, money.Cents(100)))
+	})
+}
@@ -0,0 +1,48 @@
+// Package payments defines how the cart service takes money for an order,
+// independently of the payment provider behind it.
+package payments
+
+import (
+	"errors"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+var (
+	// ErrDeclined means the payment method was refused, retrying it will not help.
+	ErrDeclined = errors.New("payment declined")
+	// ErrUnavailable means the gateway could not be reached or failed, the request may be retried.
+	ErrUnavailable        = errors.New("payment gateway unavailable")
+	ErrUnknownTransaction = errors.New("unknown payment transaction")
+	ErrInvalidState       = errors.New("payment transaction is not in a valid state for the operation")
+	ErrAmountExceeded     = errors.New("amount exceeds what is available on the transaction")
+)
+
+type AuthorizeRequest struct {
+	Amount money.Money
+	// Method identifies how the customer pays, its format depending on the gateway
+	Method string
+	// IdempotencyKey makes repeated authorizations with the same key return the first result
+	IdempotencyKey string
+	Description    string
+}
+
+// Authorization holds funds that are only taken once captured.
+type Authorization struct {
+	TransactionID string
+	Amount        money.Money
+}
+
+// PaymentGateway is implemented by each payment provider.
+// Capture and Void succeed when repeated, so a checkout interrupted halfway can be resumed.
+type PaymentGateway interface {
+	// Name is stored with the payments, telling which gateway the transactions belong to
+	Name() string
+	Authorize(request AuthorizeRequest) (*Authorization, error)
+	// Capture takes up to the authorized amount
+	Capture(transactionId string, amount money.Money) error
+	// Void releases an authorization that was not captured
+	Void(transactionId string) error
+	// Refund gives back up to the captured amount, possibly in several parts
+	Refund(transactionId string, amount money.Money) error
+}
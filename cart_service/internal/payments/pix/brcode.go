@@ -0,0 +1,198 @@
+// Package pix takes payments through PIX, the Brazilian instant payment system,
+// where the customer pays by scanning a BR Code with their bank app.
+package pix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	gui = "br.gov.bcb.pix"
+	// brlCode is the ISO 4217 numeric code of the real
+	brlCode = "986"
+
+	maxNameLength = 25
+	maxCityLength = 15
+	// Static codes carry txids of up to 25 characters, dynamic ones point to a charge
+	maxStaticTxIDLength = 25
+)
+
+// EMV-MPM field ids used by the BR Code
+const (
+	fieldPayloadFormat  = "00"
+	fieldInitiation     = "01"
+	fieldMerchantAcct   = "26"
+	fieldCategory       = "52"
+	fieldCurrency       = "53"
+	fieldAmount         = "54"
+	fieldCountry        = "58"
+	fieldMerchantName   = "59"
+	fieldMerchantCity   = "60"
+	fieldAdditionalData = "62"
+	fieldCRC            = "63"
+
+	accountGUI      = "00"
+	accountKey      = "01"
+	accountInfo     = "02"
+	accountLocation = "25"
+
+	additionalTxID = "05"
+)
+
+var ErrInvalidBRCode = errors.New("invalid BR Code")
+
+// BRCode describes a PIX charge. It is static when it carries the key of
+// the receiver and dynamic when it points to a charge registered with a PSP.
+type BRCode struct {
+	Key string
+	// Location is the URL of the charge at the PSP, without the scheme
+	Location     string
+	Description  string
+	MerchantName string
+	MerchantCity string
+	// Amount is left for the payer to fill in when zero
+	Amount money.Money
+	// TxID identifies the payment when it is confirmed
+	TxID string
+}
+
+func (b BRCode) Dynamic() bool {
+	return b.Location != ""
+}
+
+// Payload builds the EMV-MPM string of the code, the one behind the
+// QR code that customers may also copy and paste into their bank app.
+func (b BRCode) Payload() (string, error) {
+	if err := b.validate(); err != nil {
+		return "", err
+	}
+
+	var account strings.Builder
+	account.WriteString(field(accountGUI, gui))
+	if b.Dynamic() {
+		account.WriteString(field(accountLocation, b.Location))
+	} else {
+		account.WriteString(field(accountKey, b.Key))
+		if b.Description != "" {
+			account.WriteString(field(accountInfo, b.Description))
+		}
+	}
+
+	txid := b.TxID
+	if b.Dynamic() || txid == "" {
+		txid = "***"
+	}
+
+	var payload strings.Builder
+	payload.WriteString(field(fieldPayloadFormat, "01"))
+	if b.Dynamic() {
+		// Dynamic codes can only be paid once
+		payload.WriteString(field(fieldInitiation, "12"))
+	}
+	payload.WriteString(field(fieldMerchantAcct, account.String()))
+	payload.WriteString(field(fieldCategory, "0000"))
+	payload.WriteString(field(fieldCurrency, brlCode))
+	if !b.Amount.IsZero() {
+		payload.WriteString(field(fieldAmount, b.Amount.String()))
+	}
+	payload.WriteString(field(fieldCountry, "BR"))
+	payload.WriteString(field(fieldMerchantName, truncate(normalize(b.MerchantName), maxNameLength)))
+	payload.WriteString(field(fieldMerchantCity, truncate(normalize(b.MerchantCity), maxCityLength)))
+	payload.WriteString(field(fieldAdditionalData, field(additionalTxID, txid)))
+
+	// The checksum covers its own id and length
+	payload.WriteString(fieldCRC + "04")
+	return fmt.Sprintf("%s%04X", payload.String(), CRC16([]byte(payload.String()))), nil
+}
+
+// QRCode renders the payload as a PNG image with the given width in pixels.
+func (b BRCode) QRCode(size int) ([]byte, error) {
+	payload, err := b.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	return qrcode.Encode(payload, qrcode.Medium, size)
+}
+
+func (b BRCode) validate() error {
+	if !b.Dynamic() && b.Key == "" {
+		return fmt.Errorf("%w: missing key or location", ErrInvalidBRCode)
+	}
+	if normalize(b.MerchantName) == "" || normalize(b.MerchantCity) == "" {
+		return fmt.Errorf("%w: missing merchant name or city", ErrInvalidBRCode)
+	}
+	if b.Amount.IsNegative() {
+		return fmt.Errorf("%w: negative amount", ErrInvalidBRCode)
+	}
+	if !b.Amount.IsZero() && b.Amount.Currency != money.BRL {
+		return fmt.Errorf("%w: PIX only takes %s", ErrInvalidBRCode, money.BRL)
+	}
+	if len(b.TxID) > maxStaticTxIDLength && !b.Dynamic() {
+		return fmt.Errorf("%w: txid longer than %d characters", ErrInvalidBRCode, maxStaticTxIDLength)
+	}
+	for _, r := range b.TxID {
+		if !isAlphanumeric(r) {
+			return fmt.Errorf("%w: txid must be alphanumeric", ErrInvalidBRCode)
+		}
+	}
+	return nil
+}
+
+// field encodes an EMV data object as its id, the two digit length and the value.
+func field(id string, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// CRC16 is the CRC-16/CCITT-FALSE checksum closing every BR Code.
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "ê", "e", "è", "e", "í", "i", "ì", "i",
+	"ó", "o", "ô", "o", "õ", "o", "ö", "o", "ú", "u", "ü", "u", "ç", "c",
+	"Á", "A", "À", "A", "Â", "A", "Ã", "A", "Ä", "A",
+	"É", "E", "Ê", "E", "È", "E", "Í", "I", "Ì", "I",
+	"Ó", "O", "Ô", "O", "Õ", "O", "Ö", "O", "Ú", "U", "Ü", "U", "Ç", "C",
+)
+
+// normalize keeps names within the ASCII characters banks accept.
+func normalize(s string) string {
+	s = accents.Replace(strings.TrimSpace(s))
+	return strings.Map(func(r rune) rune {
+		if r > utf8.RuneSelf || r < ' ' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return strings.TrimSpace(s[:max])
+	}
+	return s
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
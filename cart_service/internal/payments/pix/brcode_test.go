@@ -0,0 +1,94 @@
+package pix_test
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"testing"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments/pix"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRC16(t *testing.T) {
+	assert.Equal(t, uint16(0x29B1), pix.CRC16([]byte("123456789")))
+}
+
+func TestBRCode(t *testing.T) {
+	t.Run("Static code from the BCB manual", func(t *testing.T) {
+		payload, err := pix.BRCode{
+			Key:          "123e4567-e12b-12d1-a456-426655440000",
+			MerchantName: "Fulano de Tal",
+			MerchantCity: "BRASILIA",
+		}.Payload()
+		require.NoError(t, err)
+
+		assert.Equal(t, "00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-4266554400005204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***63041D3D", payload)
+	})
+
+	t.Run("Static code with amount and txid", func(t *testing.T) {
+		payload, err := pix.BRCode{
+			Key:          "pix@zcart.com.br",
+			MerchantName: "Mercado São João da Boa Vista Ltda",
+			MerchantCity: "São Paulo",
+			Amount:       money.Cents(12375),
+			TxID:         "ORDER42",
+		}.Payload()
+		require.NoError(t, err)
+
+		assert.Contains(t, payload, "0116pix@zcart.com.br")
+		assert.Contains(t, payload, "5406123.75")
+		assert.Contains(t, payload, "5925Mercado Sao Joao da Boa V")
+		assert.Contains(t, payload, "6009Sao Paulo")
+		assert.Contains(t, payload, "62110507ORDER42")
+		assert.NotContains(t, payload, "010212")
+	})
+
+	t.Run("Dynamic code", func(t *testing.T) {
+		payload, err := pix.BRCode{
+			Location:     "pix.example.com/qr/v2/cob/abc",
+			MerchantName: "ZCART",
+			MerchantCity: "SAO PAULO",
+			Amount:       money.Cents(100),
+			TxID:         "abcdefghijklmnopqrstuvwxyz012345",
+		}.Payload()
+		require.NoError(t, err)
+
+		assert.Contains(t, payload, "010212")
+		assert.Contains(t, payload, "2529pix.example.com/qr/v2/cob/abc")
+		assert.Contains(t, payload, "62070503***")
+	})
+
+	t.Run("The checksum matches the payload", func(t *testing.T) {
+		payload, err := pix.BRCode{Key: "k", MerchantName: "ZCART", MerchantCity: "SAO PAULO"}.Payload()
+		require.NoError(t, err)
+
+		body, crc := payload[:len(payload)-4], payload[len(payload)-4:]
+		assert.Equal(t, crc, fmt.Sprintf("%04X", pix.CRC16([]byte(body))))
+	})
+
+	t.Run("Invalid codes", func(t *testing.T) {
+		invalid := []pix.BRCode{
+			{MerchantName: "ZCART", MerchantCity: "SAO PAULO"},
+			{Key: "k", MerchantCity: "SAO PAULO"},
+			{Key: "k", MerchantName: "ZCART", MerchantCity: "SAO PAULO", Amount: money.New(100, money.USD)},
+			{Key: "k", MerchantName: "ZCART", MerchantCity: "SAO PAULO", TxID: "not-alphanumeric"},
+			{Key: "k", MerchantName: "ZCART", MerchantCity: "SAO PAULO", TxID: "abcdefghijklmnopqrstuvwxyz"},
+		}
+		for _, code := range invalid {
+			_, err := code.Payload()
+			assert.ErrorIs(t, err, pix.ErrInvalidBRCode, "%+v", code)
+		}
+	})
+
+	t.Run("QR code", func(t *testing.T) {
+		image, err := pix.BRCode{Key: "k", MerchantName: "ZCART", MerchantCity: "SAO PAULO"}.QRCode(256)
+		require.NoError(t, err)
+
+		decoded, err := png.Decode(bytes.NewReader(image))
+		require.NoError(t, err)
+		assert.Equal(t, 256, decoded.Bounds().Dx())
+	})
+}
@@ -0,0 +1,180 @@
+package pix
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments"
+)
+
+// Method is the payment method customers choose to pay with PIX.
+const Method = "pix"
+
+const qrCodeSize = 256
+
+// DefaultChargeExpiry is how long customers have to pay a charge.
+const DefaultChargeExpiry = 30 * time.Minute
+
+// PSP is the payment service provider holding the PIX key of the store.
+type PSP interface {
+	// CreateCharge registers a charge, returning the location its dynamic BR Code points to.
+	// Creating it again with the same txid replaces it.
+	CreateCharge(txid string, amount money.Money) (location string, err error)
+	// Refund gives back a received payment to the payer, all of it when amount is nil
+	Refund(txid string, amount *money.Money) error
+}
+
+type Config struct {
+	// Key is the PIX key of the store, paid by static codes
+	Key          string
+	MerchantName string
+	MerchantCity string
+	// WebhookSecret must be sent by the PSP when confirming payments, every call being refused when empty
+	WebhookSecret string
+	// ChargeExpiry is how long a charge may be paid, its payment being given up after
+	ChargeExpiry time.Duration
+}
+
+// Charge is what the customer needs to pay for an order.
+type Charge struct {
+	TxID string
+	// BRCode is the copy and paste version of the QR code
+	BRCode string
+	// QRCode is a PNG image
+	QRCode []byte
+}
+
+// Gateway issues dynamic BR Codes through the PSP, or static ones for the
+// key of the store when there is none. Payments are not authorized through the
+// gateway but confirmed by the PSP webhook, once they were already received.
+type Gateway struct {
+	config Config
+	psp    PSP
+}
+
+func NewGateway(config Config, psp PSP) *Gateway {
+	return &Gateway{config: config, psp: psp}
+}
+
+func (g *Gateway) Name() string {
+	return Method
+}
+
+// Charge issues the BR Code paying the amount, identified by the given payment id.
+func (g *Gateway) Charge(paymentId string, amount money.Money) (*Charge, error) {
+	code := BRCode{
+		Key:          g.config.Key,
+		MerchantName: g.config.MerchantName,
+		MerchantCity: g.config.MerchantCity,
+		Amount:       amount,
+		TxID:         paymentId,
+	}
+
+	if g.psp == nil {
+		if len(code.TxID) > maxStaticTxIDLength {
+			code.TxID = code.TxID[:maxStaticTxIDLength]
+		}
+	} else {
+		location, err := g.psp.CreateCharge(code.TxID, amount)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", payments.ErrUnavailable, err)
+		}
+		code.Location = location
+	}
+
+	payload, err := code.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := code.QRCode(qrCodeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Charge{TxID: code.TxID, BRCode: payload, QRCode: image}, nil
+}
+
+// ExpiredBefore is when the charges issued before have expired by now.
+func (g *Gateway) ExpiredBefore(now time.Time) time.Time {
+	return now.Add(-g.config.ChargeExpiry)
+}
+
+// Authenticate tells whether a webhook call carries the configured secret.
+func (g *Gateway) Authenticate(secret string) bool {
+	if g.config.WebhookSecret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(g.config.WebhookSecret)) == 1
+}
+
+func (g *Gateway) Authorize(request payments.AuthorizeRequest) (*payments.Authorization, error) {
+	return nil, fmt.Errorf("%w: PIX payments are confirmed by the PSP", payments.ErrInvalidState)
+}
+
+// Capture does nothing, the money was received when the payment was confirmed.
+func (g *Gateway) Capture(transactionId string, amount money.Money) error {
+	return nil
+}
+
+// Void gives the money back, as a received PIX can't be cancelled.
+func (g *Gateway) Void(transactionId string) error {
+	if g.psp == nil {
+		return fmt.Errorf("%w: refunds need a PSP", payments.ErrUnavailable)
+	}
+	return g.psp.Refund(transactionId, nil)
+}
+
+func (g *Gateway) Refund(transactionId string, amount money.Money) error {
+	if g.psp == nil {
+		return fmt.Errorf("%w: refunds need a PSP", payments.ErrUnavailable)
+	}
+	return g.psp.Refund(transactionId, &amount)
+}
+
+// StubPSP stands in for a PSP during development. Payments to its charges
+// are confirmed by calling the webhook by hand.
+type StubPSP struct {
+	mu       sync.Mutex
+	charges  map[string]money.Money
+	refunded map[string]money.Money
+}
+
+func NewStubPSP() *StubPSP {
+	return &StubPSP{
+		charges:  make(map[string]money.Money),
+		refunded: make(map[string]money.Money),
+	}
+}
+
+func (s *StubPSP) CreateCharge(txid string, amount money.Money) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.charges[txid] = amount
+	return "pix.zcart.local/cob/" + txid, nil
+}
+
+func (s *StubPSP) Refund(txid string, amount *money.Money) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	charged, ok := s.charges[txid]
+	if !ok {
+		return payments.ErrUnknownTransaction
+	}
+
+	refund := charged.Sub(s.refunded[txid])
+	if amount != nil {
+		refund = *amount
+	}
+	if s.refunded[txid].Add(refund).Cmp(charged) > 0 {
+		return payments.ErrAmountExceeded
+	}
+
+	s.refunded[txid] = s.refunded[txid].Add(refund)
+	return nil
+}
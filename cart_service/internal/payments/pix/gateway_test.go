@@ -0,0 +1,61 @@
+package pix_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments"
+	"github.com/fsmiamoto/zcart/cart_service/internal/payments/pix"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway(t *testing.T) {
+	config := pix.Config{Key: "pix@zcart.com.br", MerchantName: "ZCART", MerchantCity: "SAO PAULO", WebhookSecret: "s3cr3t"}
+	paymentId := "6276503b875188d852ba7fd0a9908c18"
+
+	t.Run("Static charge", func(t *testing.T) {
+		gateway := pix.NewGateway(config, nil)
+
+		charge, err := gateway.Charge(paymentId, money.Cents(12375))
+		require.NoError(t, err)
+
+		assert.Equal(t, paymentId[:25], charge.TxID)
+		assert.Contains(t, charge.BRCode, "0116pix@zcart.com.br")
+		assert.NotEmpty(t, charge.QRCode)
+
+		assert.ErrorIs(t, gateway.Void(charge.TxID), payments.ErrUnavailable)
+	})
+
+	t.Run("Dynamic charge", func(t *testing.T) {
+		psp := pix.NewStubPSP()
+		gateway := pix.NewGateway(config, psp)
+
+		charge, err := gateway.Charge(paymentId, money.Cents(12375))
+		require.NoError(t, err)
+
+		assert.Equal(t, paymentId, charge.TxID)
+		assert.True(t, strings.HasPrefix(charge.BRCode, "000201010212"))
+		assert.Contains(t, charge.BRCode, "pix.zcart.local/cob/"+paymentId)
+
+		assert.NoError(t, gateway.Capture(charge.TxID, money.Cents(12375)))
+		assert.NoError(t, gateway.Refund(charge.TxID, money.Cents(375)))
+		assert.NoError(t, gateway.Void(charge.TxID), "gives back what is left")
+		assert.ErrorIs(t, gateway.Refund(charge.TxID, money.Cents(1)), payments.ErrAmountExceeded)
+	})
+
+	t.Run("Payments are not authorized through the gateway", func(t *testing.T) {
+		_, err := pix.NewGateway(config, nil).Authorize(payments.AuthorizeRequest{Amount: money.Cents(100), Method: pix.Method})
+		assert.ErrorIs(t, err, payments.ErrInvalidState)
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		gateway := pix.NewGateway(config, nil)
+		assert.True(t, gateway.Authenticate("s3cr3t"))
+		assert.False(t, gateway.Authenticate("guess"))
+		assert.False(t, gateway.Authenticate(""))
+
+		assert.False(t, pix.NewGateway(pix.Config{}, nil).Authenticate(""), "without a secret")
+	})
+}
@@ -0,0 +1,44 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+var (
+	ErrCouponExpired       = errors.New("coupon has expired")
+	ErrCouponExhausted     = errors.New("coupon has reached its usage limit")
+	ErrCouponMinimumBasket = errors.New("cart is below the coupon minimum")
+	ErrCouponAlreadyUsed   = errors.New("coupon was already used by the customer")
+	ErrCouponNeedsCustomer = errors.New("coupon requires a customer id")
+)
+
+// CheckCoupon tells whether the coupon can be used at the given time on a cart
+// whose total after promotions is basket. Per customer usage is checked by the caller.
+func CheckCoupon(coupon *models.Coupon, at time.Time, basket money.Money) error {
+	if coupon.ExpiresAt != nil && !at.Before(*coupon.ExpiresAt) {
+		return ErrCouponExpired
+	}
+	if coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses {
+		return ErrCouponExhausted
+	}
+	if basket.Cmp(coupon.MinimumBasket) < 0 {
+		return fmt.Errorf("%w of %s", ErrCouponMinimumBasket, coupon.MinimumBasket)
+	}
+	return nil
+}
+
+// couponDiscount returns how much the coupon takes off the remaining cart total.
+func couponDiscount(coupon *models.Coupon, remaining money.Money) money.Money {
+	switch coupon.Kind {
+	case models.CouponPercentage:
+		return remaining.Percent(coupon.Percent)
+	case models.CouponFixed:
+		return money.Min(coupon.Amount, remaining)
+	}
+	return money.New(0, remaining.Currency)
+}
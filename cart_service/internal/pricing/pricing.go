@@ -0,0 +1,189 @@
+package pricing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+// PromotionSource provides every registered promotion, running or not.
+type PromotionSource interface {
+	ListPromotions() ([]*models.Promotion, error)
+}
+
+// Calculator is the single place where cart amounts are computed, so what the
+// shopper sees on the cart, in realtime events and at checkout always agrees.
+type Calculator struct {
+	promotions PromotionSource
+	taxes      TaxRuleSource
+	// Now is used to decide which promotions are running
+	Now func() time.Time
+}
+
+// NewCalculator returns a calculator applying the running promotions and the tax rules of the sources, if any.
+func NewCalculator(promotions PromotionSource, taxes TaxRuleSource) *Calculator {
+	return &Calculator{
+		promotions: promotions,
+		taxes:      taxes,
+		Now:        time.Now,
+	}
+}
+
+// Price computes the line totals, item count, subtotal, discounts and grand total of the cart lines.
+// Promotions are applied to each line first and then the coupons, in order, to what is left of the total.
+// Coupons that can't be used on the cart at the moment are left out.
+// Taxes are charged last on what is paid for each line, its share of the coupons included,
+// the exclusive ones being added to the grand total.
+func (c *Calculator) Price(cartProducts []*models.CartProduct, coupons []*models.Coupon) (*models.CartTotals, error) {
+	promotions, err := c.runningPromotions()
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := c.taxRules()
+	if err != nil {
+		return nil, err
+	}
+
+	zero := money.New(0, money.DefaultCurrency)
+	if err := checkCurrencies(zero, cartProducts, promotions, coupons); err != nil {
+		return nil, err
+	}
+
+	totals := &models.CartTotals{
+		Lines:    make([]*models.LineTotals, 0, len(cartProducts)),
+		Subtotal: zero,
+		Discount: zero,
+		Coupons:  make([]*models.AppliedCoupon, 0),
+		Tax:      zero,
+		TaxAdded: zero,
+	}
+
+	for _, cp := range cartProducts {
+		line := &models.LineTotals{
+			ProductID:  cp.ProductID,
+			Name:       cp.Product.Name,
+			UnitPrice:  cp.Product.Price,
+			Quantity:   cp.Quantity,
+			Subtotal:   cp.Product.Price.Mul(int64(cp.Quantity)),
+			Discount:   zero,
+			Promotions: make([]*models.AppliedPromotion, 0),
+		}
+		if applied := bestPromotion(promotions, line); applied != nil {
+			line.Promotions = append(line.Promotions, applied)
+			line.Discount = line.Discount.Add(applied.Discount)
+		}
+		line.Total = line.Subtotal.Sub(line.Discount)
+
+		totals.Lines = append(totals.Lines, line)
+		totals.ItemCount += line.Quantity
+		totals.Subtotal = totals.Subtotal.Add(line.Subtotal)
+		totals.Discount = totals.Discount.Add(line.Discount)
+	}
+
+	now := c.Now()
+	remaining := totals.Subtotal.Sub(totals.Discount)
+	basket := remaining
+
+	for _, coupon := range coupons {
+		if CheckCoupon(coupon, now, basket) != nil {
+			continue
+		}
+		discount := couponDiscount(coupon, remaining)
+		remaining = remaining.Sub(discount)
+		totals.Discount = totals.Discount.Add(discount)
+		totals.Coupons = append(totals.Coupons, &models.AppliedCoupon{Code: coupon.Code, Discount: discount})
+	}
+
+	chargeTaxes(totals, rules, cartProducts)
+	totals.Total = totals.Subtotal.Sub(totals.Discount).Add(totals.TaxAdded)
+
+	return totals, nil
+}
+
+// checkCurrencies makes sure the prices of the products and the amounts of the promotions and
+// coupons that may apply to them are in the currency of zero, failing instead of mixing currencies.
+func checkCurrencies(zero money.Money, cartProducts []*models.CartProduct, promotions []*models.Promotion, coupons []*models.Coupon) error {
+	for _, cp := range cartProducts {
+		if err := zero.CheckCurrency(cp.Product.Price); err != nil {
+			return fmt.Errorf("price of product %s: %w", cp.ProductID, err)
+		}
+		for _, promotion := range promotions {
+			if promotion.ProductID != cp.ProductID {
+				continue
+			}
+			if err := zero.CheckCurrency(promotion.Amount); err != nil {
+				return fmt.Errorf("promotion %s: %w", promotion.ID, err)
+			}
+		}
+	}
+
+	for _, coupon := range coupons {
+		for _, amount := range []money.Money{coupon.Amount, coupon.MinimumBasket} {
+			if err := zero.CheckCurrency(amount); err != nil {
+				return fmt.Errorf("coupon %s: %w", coupon.Code, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// chargeTaxes charges the rules on the lines, sharing the coupons among them by their totals.
+func chargeTaxes(totals *models.CartTotals, rules []*models.TaxRule, cartProducts []*models.CartProduct) {
+	weights := make([]money.Money, len(totals.Lines))
+	var coupons money.Money
+	for i, line := range totals.Lines {
+		weights[i] = line.Total
+	}
+	for _, coupon := range totals.Coupons {
+		coupons = coupons.Add(coupon.Discount)
+	}
+	shares := coupons.Allocate(weights)
+
+	perLine := make([][]*models.TaxAmount, 0, len(totals.Lines))
+	for i, line := range totals.Lines {
+		line.Taxes = lineTaxes(rules, cartProducts[i].Product.TaxClass, line.Total.Sub(shares[i]))
+		line.Tax = money.New(0, line.Total.Currency)
+		for _, tax := range line.Taxes {
+			line.Tax = line.Tax.Add(tax.Amount)
+			if !tax.Inclusive {
+				totals.TaxAdded = totals.TaxAdded.Add(tax.Amount)
+			}
+		}
+		totals.Tax = totals.Tax.Add(line.Tax)
+		perLine = append(perLine, line.Taxes)
+	}
+
+	totals.Taxes = models.SumTaxes(perLine)
+}
+
+func (c *Calculator) taxRules() ([]*models.TaxRule, error) {
+	if c.taxes == nil {
+		return nil, nil
+	}
+	return c.taxes.ListTaxRules()
+}
+
+func (c *Calculator) runningPromotions() ([]*models.Promotion, error) {
+	if c.promotions == nil {
+		return nil, nil
+	}
+
+	all, err := c.promotions.ListPromotions()
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.Now()
+	running := make([]*models.Promotion, 0, len(all))
+	for _, promotion := range all {
+		if promotion.ActiveAt(now) {
+			running = append(running, promotion)
+		}
+	}
+
+	return running, nil
+}
@@ -0,0 +1,372 @@
+package pricing_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type promotions []*models.Promotion
+
+func (p promotions) ListPromotions() ([]*models.Promotion, error) {
+	return p, nil
+}
+
+type failingPromotions struct{ err error }
+
+func (f failingPromotions) ListPromotions() ([]*models.Promotion, error) {
+	return nil, f.err
+}
+
+type taxRules []*models.TaxRule
+
+func (r taxRules) ListTaxRules() ([]*models.TaxRule, error) {
+	return r, nil
+}
+
+type failingTaxRules struct{ err error }
+
+func (f failingTaxRules) ListTaxRules() ([]*models.TaxRule, error) {
+	return nil, f.err
+}
+
+func cartProduct(id string, name string, price int64, quantity uint) *models.CartProduct {
+	return &models.CartProduct{
+		CartID:    "2",
+		ProductID: id,
+		Quantity:  quantity,
+		Product:   models.Product{ID: id, Name: name, Price: money.Cents(price)},
+	}
+}
+
+func taxedProduct(id string, name string, price int64, quantity uint, taxClass string) *models.CartProduct {
+	cp := cartProduct(id, name, price, quantity)
+	cp.Product.TaxClass = taxClass
+	return cp
+}
+
+func timeRef(t time.Time) *time.Time {
+	return &t
+}
+
+func TestCalculator(t *testing.T) {
+	calculator := pricing.NewCalculator(nil, nil)
+
+	t.Run("Empty cart", func(t *testing.T) {
+		totals, err := calculator.Price(nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, &models.CartTotals{
+			Lines:    []*models.LineTotals{},
+			Subtotal: money.Cents(0),
+			Discount: money.Cents(0),
+			Coupons:  []*models.AppliedCoupon{},
+			Tax:      money.Cents(0),
+			TaxAdded: money.Cents(0),
+			Taxes:    []*models.TaxAmount{},
+			Total:    money.Cents(0),
+		}, totals)
+	})
+
+	t.Run("Line totals and subtotal", func(t *testing.T) {
+		totals, err := calculator.Price([]*models.CartProduct{
+			cartProduct("1", "Coca Cola", 599, 3),
+			cartProduct("5", "Chamyto", 1099, 1),
+		}, nil)
+		require.NoError(t, err)
+
+		zero := money.Cents(0)
+		none := []*models.AppliedPromotion{}
+		untaxed := []*models.TaxAmount{}
+		assert.Equal(t, &models.CartTotals{
+			Lines: []*models.LineTotals{
+				{ProductID: "1", Name: "Coca Cola", UnitPrice: money.Cents(599), Quantity: 3, Subtotal: money.Cents(1797), Discount: zero, Total: money.Cents(1797), Promotions: none, Tax: zero, Taxes: untaxed},
+				{ProductID: "5", Name: "Chamyto", UnitPrice: money.Cents(1099), Quantity: 1, Subtotal: money.Cents(1099), Discount: zero, Total: money.Cents(1099), Promotions: none, Tax: zero, Taxes: untaxed},
+			},
+			ItemCount: 4,
+			Subtotal:  money.Cents(2896),
+			Discount:  zero,
+			Coupons:   []*models.AppliedCoupon{},
+			Tax:       zero,
+			TaxAdded:  zero,
+			Taxes:     untaxed,
+			Total:     money.Cents(2896),
+		}, totals)
+	})
+
+	t.Run("Sums are exact", func(t *testing.T) {
+		totals, err := calculator.Price([]*models.CartProduct{
+			cartProduct("1", "Bala", 10, 3),
+			cartProduct("2", "Chiclete", 20, 1),
+		}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, money.Cents(30), totals.Lines[0].Total)
+		assert.Equal(t, money.Cents(50), totals.Total)
+	})
+
+	t.Run("Error with prices in another currency", func(t *testing.T) {
+		dollars := cartProduct("9", "Root Beer", 199, 1)
+		dollars.Product.Price = money.New(199, money.USD)
+
+		_, err := calculator.Price([]*models.CartProduct{cartProduct("1", "Coca Cola", 599, 1), dollars}, nil)
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	})
+
+	t.Run("Error with promotions or coupons in another currency", func(t *testing.T) {
+		cart := []*models.CartProduct{cartProduct("5", "Chamyto", 1099, 3)}
+
+		calculator := pricing.NewCalculator(promotions{
+			{ID: "p", Kind: models.PromotionFixed, ProductID: "5", Amount: money.New(100, money.USD)},
+		}, nil)
+		_, err := calculator.Price(cart, nil)
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+
+		_, err = pricing.NewCalculator(nil, nil).Price(cart, []*models.Coupon{
+			{Code: "TEN", Kind: models.CouponFixed, Amount: money.New(1000, money.USD)},
+		})
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	})
+}
+
+func TestPromotions(t *testing.T) {
+	now := time.Date(2022, 11, 2, 15, 0, 0, 0, time.UTC)
+
+	price := func(t *testing.T, promotion *models.Promotion, quantity uint) *models.LineTotals {
+		calculator := pricing.NewCalculator(promotions{promotion}, nil)
+		calculator.Now = func() time.Time { return now }
+
+		totals, err := calculator.Price([]*models.CartProduct{cartProduct("5", "Chamyto", 1099, quantity)}, nil)
+		require.NoError(t, err)
+		return totals.Lines[0]
+	}
+
+	t.Run("Percentage", func(t *testing.T) {
+		line := price(t, &models.Promotion{ID: "p", Name: "10% off", Kind: models.PromotionPercentage, ProductID: "5", Percent: 10}, 3)
+
+		assert.Equal(t, money.Cents(330), line.Discount)
+		assert.Equal(t, money.Cents(2967), line.Total)
+		assert.Equal(t, []*models.AppliedPromotion{{ID: "p", Name: "10% off", Discount: money.Cents(330)}}, line.Promotions)
+	})
+
+	t.Run("Fixed amount per unit", func(t *testing.T) {
+		line := price(t, &models.Promotion{Kind: models.PromotionFixed, ProductID: "5", Amount: money.Cents(100)}, 2)
+		assert.Equal(t, money.Cents(200), line.Discount)
+
+		line = price(t, &models.Promotion{Kind: models.PromotionFixed, ProductID: "5", Amount: money.Cents(5000)}, 2)
+		assert.Equal(t, money.Cents(0), line.Total, "a unit never costs less than nothing")
+	})
+
+	t.Run("Buy X get Y", func(t *testing.T) {
+		promotion := &models.Promotion{Kind: models.PromotionBuyXGetY, ProductID: "5", BuyQuantity: 2, GetQuantity: 1}
+
+		assert.Equal(t, money.Cents(0), price(t, promotion, 2).Discount)
+		assert.Equal(t, money.Cents(1099), price(t, promotion, 3).Discount)
+		assert.Equal(t, money.Cents(1099), price(t, promotion, 5).Discount)
+		assert.Equal(t, money.Cents(2198), price(t, promotion, 6).Discount)
+	})
+
+	t.Run("Multi-buy", func(t *testing.T) {
+		promotion := &models.Promotion{Kind: models.PromotionMultiBuy, ProductID: "5", BuyQuantity: 3, Amount: money.Cents(2500)}
+
+		assert.Equal(t, money.Cents(0), price(t, promotion, 2).Discount)
+		assert.Equal(t, money.Cents(2500), price(t, promotion, 3).Total)
+		assert.Equal(t, money.Cents(2500+1099), price(t, promotion, 4).Total)
+	})
+
+	t.Run("Outside the time window", func(t *testing.T) {
+		promotion := &models.Promotion{Kind: models.PromotionPercentage, ProductID: "5", Percent: 50}
+
+		promotion.StartsAt = timeRef(now.Add(time.Hour))
+		assert.Empty(t, price(t, promotion, 1).Promotions)
+
+		promotion.StartsAt = timeRef(now.Add(-time.Hour))
+		promotion.EndsAt = timeRef(now)
+		assert.Empty(t, price(t, promotion, 1).Promotions)
+
+		promotion.EndsAt = timeRef(now.Add(time.Minute))
+		assert.Len(t, price(t, promotion, 1).Promotions, 1)
+	})
+
+	t.Run("Other products are not affected", func(t *testing.T) {
+		line := price(t, &models.Promotion{Kind: models.PromotionPercentage, ProductID: "1", Percent: 50}, 1)
+		assert.Empty(t, line.Promotions)
+	})
+
+	t.Run("Only the best promotion applies", func(t *testing.T) {
+		calculator := pricing.NewCalculator(promotions{
+			{ID: "small", Kind: models.PromotionPercentage, ProductID: "5", Percent: 10},
+			{ID: "big", Kind: models.PromotionMultiBuy, ProductID: "5", BuyQuantity: 3, Amount: money.Cents(2500)},
+		}, nil)
+
+		totals, err := calculator.Price([]*models.CartProduct{cartProduct("5", "Chamyto", 1099, 3)}, nil)
+		require.NoError(t, err)
+
+		require.Len(t, totals.Lines[0].Promotions, 1)
+		assert.Equal(t, "big", totals.Lines[0].Promotions[0].ID)
+		assert.Equal(t, money.Cents(797), totals.Discount)
+		assert.Equal(t, money.Cents(2500), totals.Total)
+	})
+
+	t.Run("Error loading promotions", func(t *testing.T) {
+		expectedError := errors.New("database is locked")
+		calculator := pricing.NewCalculator(failingPromotions{expectedError}, nil)
+
+		_, err := calculator.Price([]*models.CartProduct{cartProduct("5", "Chamyto", 1099, 3)}, nil)
+		assert.ErrorIs(t, err, expectedError)
+	})
+}
+
+func TestCoupons(t *testing.T) {
+	now := time.Date(2022, 11, 2, 15, 0, 0, 0, time.UTC)
+
+	calculator := pricing.NewCalculator(promotions{
+		{ID: "chamyto", Kind: models.PromotionMultiBuy, ProductID: "5", BuyQuantity: 3, Amount: money.Cents(2500)},
+	}, nil)
+	calculator.Now = func() time.Time { return now }
+
+	// 25.00 for the Chamyto after the promotion plus 17.97 of Coca Cola
+	cart := []*models.CartProduct{
+		cartProduct("5", "Chamyto", 1099, 3),
+		cartProduct("1", "Coca Cola", 599, 3),
+	}
+
+	t.Run("Percentage of the total after promotions", func(t *testing.T) {
+		totals, err := calculator.Price(cart, []*models.Coupon{
+			{Code: "TEN", Kind: models.CouponPercentage, Percent: 10},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []*models.AppliedCoupon{{Code: "TEN", Discount: money.Cents(430)}}, totals.Coupons)
+		assert.Equal(t, money.Cents(797+430), totals.Discount)
+		assert.Equal(t, money.Cents(4297-430), totals.Total)
+	})
+
+	t.Run("Fixed amount never goes below zero", func(t *testing.T) {
+		totals, err := calculator.Price(cart, []*models.Coupon{
+			{Code: "TEN", Kind: models.CouponFixed, Amount: money.Cents(1000)},
+			{Code: "ALL", Kind: models.CouponFixed, Amount: money.Cents(100000)},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, totals.Coupons, 2)
+		assert.Equal(t, money.Cents(3297), totals.Coupons[1].Discount)
+		assert.Equal(t, money.Cents(0), totals.Total)
+	})
+
+	t.Run("Coupons that can't be used are left out", func(t *testing.T) {
+		totals, err := calculator.Price(cart, []*models.Coupon{
+			{Code: "OLD", Kind: models.CouponFixed, Amount: money.Cents(100), ExpiresAt: timeRef(now)},
+			{Code: "USED", Kind: models.CouponFixed, Amount: money.Cents(100), MaxUses: 1, Uses: 1},
+			{Code: "BIG", Kind: models.CouponFixed, Amount: money.Cents(100), MinimumBasket: money.Cents(5000)},
+		})
+		require.NoError(t, err)
+
+		assert.Empty(t, totals.Coupons)
+		assert.Equal(t, money.Cents(4297), totals.Total)
+	})
+
+	t.Run("CheckCoupon", func(t *testing.T) {
+		coupon := &models.Coupon{Code: "BIG", MinimumBasket: money.Cents(5000), ExpiresAt: timeRef(now.Add(time.Hour))}
+
+		assert.NoError(t, pricing.CheckCoupon(coupon, now, money.Cents(5000)))
+		assert.ErrorIs(t, pricing.CheckCoupon(coupon, now, money.Cents(4999)), pricing.ErrCouponMinimumBasket)
+		assert.ErrorIs(t, pricing.CheckCoupon(coupon, now.Add(time.Hour), money.Cents(5000)), pricing.ErrCouponExpired)
+	})
+}
+
+func TestTaxes(t *testing.T) {
+	rules := taxRules{
+		{ID: "icms", TaxClass: "standard", Tax: models.TaxICMS, Rate: 1800, Inclusive: true},
+		{ID: "pis", TaxClass: "standard", Tax: models.TaxPIS, Rate: 165, Inclusive: true},
+		{ID: "cofins", TaxClass: "standard", Tax: models.TaxCOFINS, Rate: 760, Inclusive: true},
+		{ID: "icms-food", TaxClass: "food", Tax: models.TaxICMS, Rate: 700, Inclusive: true},
+		{ID: "ipi", TaxClass: "appliances", Tax: models.TaxIPI, Rate: 1000},
+	}
+	calculator := pricing.NewCalculator(nil, rules)
+
+	t.Run("Inclusive taxes are part of the price", func(t *testing.T) {
+		// 18% of ICMS, 1.65% of PIS and 7.6% of COFINS on a sale of 100.00
+		totals, err := calculator.Price([]*models.CartProduct{taxedProduct("1", "Cafeteira", 10000, 1, "standard")}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []*models.TaxAmount{
+			{Tax: models.TaxICMS, Rate: 1800, Inclusive: true, Base: money.Cents(10000), Amount: money.Cents(1800)},
+			{Tax: models.TaxPIS, Rate: 165, Inclusive: true, Base: money.Cents(10000), Amount: money.Cents(165)},
+			{Tax: models.TaxCOFINS, Rate: 760, Inclusive: true, Base: money.Cents(10000), Amount: money.Cents(760)},
+		}, totals.Lines[0].Taxes)
+		assert.Equal(t, money.Cents(2725), totals.Lines[0].Tax)
+		assert.Equal(t, money.Cents(2725), totals.Tax)
+		assert.Equal(t, money.Cents(0), totals.TaxAdded)
+		assert.Equal(t, money.Cents(10000), totals.Total)
+	})
+
+	t.Run("Exclusive taxes are added to the total", func(t *testing.T) {
+		// 10% of IPI on a sale of 2 x 150.00
+		totals, err := calculator.Price([]*models.CartProduct{taxedProduct("12", "Liquidificador", 15000, 2, "appliances")}, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, money.Cents(3000), totals.Tax)
+		assert.Equal(t, money.Cents(3000), totals.TaxAdded)
+		assert.Equal(t, money.Cents(33000), totals.Total)
+	})
+
+	t.Run("Discounts are taken off the base", func(t *testing.T) {
+		calculator := pricing.NewCalculator(promotions{
+			{ID: "chamyto", Kind: models.PromotionMultiBuy, ProductID: "5", BuyQuantity: 3, Amount: money.Cents(2500)},
+		}, rules)
+
+		// 25.00 of Chamyto and 17.97 of Coca Cola, the 4.30 of the coupon split as 2.51 and 1.79
+		totals, err := calculator.Price([]*models.CartProduct{
+			taxedProduct("5", "Chamyto", 1099, 3, "food"),
+			taxedProduct("1", "Coca Cola", 599, 3, "standard"),
+		}, []*models.Coupon{{Code: "TEN", Kind: models.CouponPercentage, Percent: 10}})
+		require.NoError(t, err)
+
+		chamyto, coke := totals.Lines[0], totals.Lines[1]
+		assert.Equal(t, money.Cents(2249), chamyto.Taxes[0].Base)
+		// 7% of 22.49 is 1.5743
+		assert.Equal(t, money.Cents(157), chamyto.Tax)
+		assert.Equal(t, money.Cents(1618), coke.Taxes[0].Base)
+		// 2.9124 of ICMS, 0.26697 of PIS and 1.22968 of COFINS
+		assert.Equal(t, money.Cents(291+27+123), coke.Tax)
+
+		assert.Equal(t, []*models.TaxAmount{
+			{Tax: models.TaxICMS, Rate: 700, Inclusive: true, Base: money.Cents(2249), Amount: money.Cents(157)},
+			{Tax: models.TaxICMS, Rate: 1800, Inclusive: true, Base: money.Cents(1618), Amount: money.Cents(291)},
+			{Tax: models.TaxPIS, Rate: 165, Inclusive: true, Base: money.Cents(1618), Amount: money.Cents(27)},
+			{Tax: models.TaxCOFINS, Rate: 760, Inclusive: true, Base: money.Cents(1618), Amount: money.Cents(123)},
+		}, totals.Taxes)
+		assert.Equal(t, money.Cents(598), totals.Tax)
+		assert.Equal(t, money.Cents(3867), totals.Total)
+	})
+
+	t.Run("Same tax and rate are added up", func(t *testing.T) {
+		totals, err := calculator.Price([]*models.CartProduct{
+			taxedProduct("1", "Coca Cola", 599, 3, "standard"),
+			taxedProduct("4", "Café", 1250, 1, "standard"),
+			taxedProduct("9", "Caneta", 150, 1, ""),
+		}, nil)
+		require.NoError(t, err)
+
+		assert.Empty(t, totals.Lines[2].Taxes)
+		require.Len(t, totals.Taxes, 3)
+		assert.Equal(t, money.Cents(3047), totals.Taxes[0].Base)
+		// 3.2346 and 2.25 of ICMS, rounded per line
+		assert.Equal(t, money.Cents(323+225), totals.Taxes[0].Amount)
+	})
+
+	t.Run("Error loading tax rules", func(t *testing.T) {
+		expectedError := errors.New("database is locked")
+		calculator := pricing.NewCalculator(nil, failingTaxRules{expectedError})
+
+		_, err := calculator.Price([]*models.CartProduct{cartProduct("5", "Chamyto", 1099, 3)}, nil)
+		assert.ErrorIs(t, err, expectedError)
+	})
+}
@@ -0,0 +1,65 @@
+package pricing
+
+import (
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+// promotionDiscount returns how much the promotion takes off the line, zero when it does not apply.
+func promotionDiscount(promotion *models.Promotion, line *models.LineTotals) money.Money {
+	zero := money.New(0, line.UnitPrice.Currency)
+
+	if promotion.ProductID != line.ProductID {
+		return zero
+	}
+
+	quantity := int64(line.Quantity)
+
+	switch promotion.Kind {
+	case models.PromotionPercentage:
+		return line.Subtotal.Percent(promotion.Percent)
+	case models.PromotionFixed:
+		// Never makes a unit cost less than nothing
+		return money.Min(promotion.Amount, line.UnitPrice).Mul(quantity)
+	case models.PromotionBuyXGetY:
+		if promotion.BuyQuantity == 0 || promotion.GetQuantity == 0 {
+			return zero
+		}
+		group := int64(promotion.BuyQuantity + promotion.GetQuantity)
+		return line.UnitPrice.Mul(quantity / group * int64(promotion.GetQuantity))
+	case models.PromotionMultiBuy:
+		if promotion.BuyQuantity == 0 {
+			return zero
+		}
+		bundle := line.UnitPrice.Mul(int64(promotion.BuyQuantity))
+		saving := bundle.Sub(promotion.Amount)
+		if saving.IsNegative() {
+			return zero
+		}
+		return saving.Mul(quantity / int64(promotion.BuyQuantity))
+	}
+
+	return zero
+}
+
+// bestPromotion picks the promotion giving the largest discount on the line,
+// as promotions on the same product don't stack. It returns nil if none applies.
+func bestPromotion(promotions []*models.Promotion, line *models.LineTotals) *models.AppliedPromotion {
+	var best *models.AppliedPromotion
+
+	for _, promotion := range promotions {
+		discount := promotionDiscount(promotion, line)
+		if discount.Amount <= 0 {
+			continue
+		}
+		if best == nil || discount.Cmp(best.Discount) > 0 {
+			best = &models.AppliedPromotion{
+				ID:       promotion.ID,
+				Name:     promotion.Name,
+				Discount: discount,
+			}
+		}
+	}
+
+	return best
+}
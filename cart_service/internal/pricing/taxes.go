@@ -0,0 +1,31 @@
+package pricing
+
+import (
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+// TaxRuleSource provides the tax rules of every tax class.
+type TaxRuleSource interface {
+	ListTaxRules() ([]*models.TaxRule, error)
+}
+
+// lineTaxes charges the rules of the tax class on base, what is paid for the line after every discount.
+func lineTaxes(rules []*models.TaxRule, taxClass string, base money.Money) []*models.TaxAmount {
+	taxes := make([]*models.TaxAmount, 0)
+
+	for _, rule := range rules {
+		if rule.TaxClass != taxClass {
+			continue
+		}
+		taxes = append(taxes, &models.TaxAmount{
+			Tax:       rule.Tax,
+			Rate:      rule.Rate,
+			Inclusive: rule.Inclusive,
+			Base:      base,
+			Amount:    base.BasisPoints(rule.Rate),
+		})
+	}
+
+	return taxes
+}
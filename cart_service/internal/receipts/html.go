@@ -0,0 +1,42 @@
+package receipts
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+)
+
+//go:embed receipt.html.tmpl
+var htmlSource string
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(htmlSource))
+
+var rowClasses = map[rowStyle]string{
+	plain:     "plain",
+	title:     "title",
+	centered:  "centered",
+	separator: "separator",
+	indented:  "indented",
+	bold:      "bold",
+}
+
+type htmlRow struct {
+	Left  string
+	Right string
+	Class string
+}
+
+func (r *Receipt) HTML() ([]byte, error) {
+	var rows []htmlRow
+	for _, row := range r.rows() {
+		rows = append(rows, htmlRow{Left: row.left, Right: row.right, Class: rowClasses[row.style]})
+	}
+
+	var b bytes.Buffer
+	err := htmlTemplate.Execute(&b, struct {
+		OrderID string
+		Rows    []htmlRow
+	}{r.Order.ID, rows})
+
+	return b.Bytes(), err
+}
@@ -0,0 +1,74 @@
+package receipts
+
+import (
+	"bytes"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// The PDF looks like the printed slip, on 80mm wide paper as long as the receipt
+const (
+	pdfWidth     = 80.0
+	pdfMargin    = 5.0
+	pdfRowHeight = 4.5
+)
+
+func (r *Receipt) PDF() ([]byte, error) {
+	rows := r.rows()
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: pdfWidth, Ht: 2*pdfMargin + float64(len(rows)+1)*pdfRowHeight},
+	})
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetTitle("Receipt "+r.Order.ID, true)
+	pdf.AddPage()
+
+	// Core fonts only know cp1252, which covers Portuguese
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	width := pdfWidth - 2*pdfMargin
+
+	for _, row := range rows {
+		switch row.style {
+		case title:
+			pdf.SetFont("Helvetica", "B", 12)
+			pdf.CellFormat(width, pdfRowHeight+1, tr(row.left), "", 1, "C", false, 0, "")
+		case centered:
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.CellFormat(width, pdfRowHeight, tr(row.left), "", 1, "C", false, 0, "")
+		case separator:
+			y := pdf.GetY() + pdfRowHeight/2
+			pdf.SetDashPattern([]float64{0.8, 0.8}, 0)
+			pdf.Line(pdfMargin, y, pdfWidth-pdfMargin, y)
+			pdf.Ln(pdfRowHeight)
+		default:
+			style, indent := "", 0.0
+			switch row.style {
+			case bold:
+				style = "B"
+			case indented:
+				indent = 3
+			}
+			pdf.SetFont("Helvetica", style, 8)
+
+			rightWidth := pdf.GetStringWidth(tr(row.right)) + 1
+			pdf.SetX(pdfMargin + indent)
+			pdf.CellFormat(width-indent-rightWidth, pdfRowHeight, tr(fit(pdf, row.left, width-indent-rightWidth)), "", 0, "L", false, 0, "")
+			pdf.CellFormat(rightWidth, pdfRowHeight, tr(row.right), "", 1, "R", false, 0, "")
+		}
+	}
+
+	var b bytes.Buffer
+	err := pdf.Output(&b)
+	return b.Bytes(), err
+}
+
+// fit cuts the text so it does not run into the amount next to it.
+func fit(pdf *gofpdf.Fpdf, s string, width float64) string {
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
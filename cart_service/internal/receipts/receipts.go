@@ -0,0 +1,179 @@
+// Package receipts renders what customers take home after checkout.
+package receipts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+type Format string
+
+const (
+	HTML Format = "html"
+	PDF  Format = "pdf"
+	// Text fits thermal printers, which print 40 columns
+	Text Format = "text"
+)
+
+func (f Format) Valid() bool {
+	return f == HTML || f == PDF || f == Text
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case HTML:
+		return "text/html; charset=utf-8"
+	case PDF:
+		return "application/pdf"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// Receipt of an order, paid with the given payment when there is one.
+type Receipt struct {
+	Store    models.Store
+	Order    *models.Order
+	Payment  *models.PaymentIntent
+	IssuedAt time.Time
+}
+
+func New(store models.Store, order *models.Order, payment *models.PaymentIntent) *Receipt {
+	return &Receipt{Store: store, Order: order, Payment: payment, IssuedAt: time.Now()}
+}
+
+func (r *Receipt) Render(format Format) ([]byte, error) {
+	switch format {
+	case HTML:
+		return r.HTML()
+	case PDF:
+		return r.PDF()
+	case Text:
+		return []byte(r.Text()), nil
+	default:
+		return nil, fmt.Errorf("unknown receipt format %q", format)
+	}
+}
+
+type rowStyle int
+
+const (
+	plain rowStyle = iota
+	// title is the centered store name
+	title
+	centered
+	separator
+	// indented rows detail the row above them
+	indented
+	bold
+)
+
+// row is a line of the receipt, with text on the left and an amount on the right.
+// All formats lay out the same rows, so they always agree.
+type row struct {
+	left  string
+	right string
+	style rowStyle
+}
+
+func (r *Receipt) rows() []row {
+	order := r.Order
+	rows := []row{{left: r.Store.Name, style: title}}
+	if r.Store.Address != "" {
+		rows = append(rows, row{left: r.Store.Address, style: centered})
+	}
+	if r.Store.TaxID != "" {
+		rows = append(rows, row{left: "CNPJ " + r.Store.TaxID, style: centered})
+	}
+
+	rows = append(rows,
+		row{style: separator},
+		row{left: "Order " + order.ID},
+		row{left: order.CreatedAt.Local().Format("2006-01-02 15:04")},
+		row{style: separator},
+	)
+
+	for _, line := range order.Lines {
+		rows = append(rows,
+			row{left: line.Name},
+			row{
+				left:  fmt.Sprintf("%d x %s", line.Quantity, line.UnitPrice),
+				right: line.UnitPrice.Mul(int64(line.Quantity)).String(),
+				style: indented,
+			},
+		)
+		if !line.Discount.IsZero() {
+			rows = append(rows, row{left: "Discount", right: negative(line.Discount), style: indented})
+		}
+	}
+
+	rows = append(rows,
+		row{style: separator},
+		row{left: "Items", right: fmt.Sprint(order.ItemCount)},
+		row{left: "Subtotal", right: order.Subtotal.String()},
+	)
+	if !order.Discount.IsZero() {
+		rows = append(rows, row{left: "Discount", right: negative(order.Discount)})
+		for _, coupon := range order.Coupons {
+			rows = append(rows, row{left: "Coupon " + coupon.Code, right: negative(coupon.Discount), style: indented})
+		}
+	}
+	for _, tax := range order.Taxes {
+		if !tax.Inclusive {
+			rows = append(rows, row{left: taxName(tax), right: tax.Amount.String()})
+		}
+	}
+	rows = append(rows, row{
+		left:  "TOTAL",
+		right: fmt.Sprintf("%s %s", order.Total.Currency, order.Total),
+		style: bold,
+	})
+	// The taxes in the prices, which consumers must be told about
+	if included := order.Tax.Sub(order.TaxAdded); !included.IsZero() {
+		rows = append(rows, row{left: "Taxes included", right: included.String()})
+		for _, tax := range order.Taxes {
+			if tax.Inclusive {
+				rows = append(rows, row{left: taxName(tax), right: tax.Amount.String(), style: indented})
+			}
+		}
+	}
+
+	if r.Payment != nil {
+		rows = append(rows,
+			row{style: separator},
+			row{left: "Paid with " + r.Payment.Method, right: r.Payment.Amount.String()},
+		)
+	}
+
+	return append(rows,
+		row{style: separator},
+		row{left: "Thank you!", style: centered},
+	)
+}
+
+// taxName tells the tax along with its rate, as in "PIS 1.65%".
+func taxName(tax *models.TaxAmount) string {
+	rate := strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%d.%02d", tax.Rate/100, tax.Rate%100), "0"), ".")
+	return fmt.Sprintf("%s %s%%", strings.ToUpper(string(tax.Tax)), rate)
+}
+
+func negative(m money.Money) string {
+	return "-" + m.String()
+}
+
+// truncate cuts s to at most width runes.
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s
+}
+
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", width-len([]rune(s)))
+}
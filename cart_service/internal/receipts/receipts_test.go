@@ -0,0 +1,126 @@
+package receipts_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/receipts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReceipt() *receipts.Receipt {
+	order := &models.Order{
+		ID:        "2ee9e93cfe57ed7d0f2fa819b3908b92",
+		CartID:    "1",
+		Status:    models.OrderCompleted,
+		ItemCount: 4,
+		Lines: []*models.OrderLine{
+			{ProductID: "5", Name: "Chamyto", UnitPrice: money.Cents(1099), Quantity: 3, Discount: money.Cents(797), Total: money.Cents(2500)},
+			{ProductID: "7", Name: "Biscoito de polvilho <crocante> tamanho família", UnitPrice: money.Cents(599), Quantity: 1, Discount: money.Cents(0), Total: money.Cents(599)},
+		},
+		Subtotal: money.Cents(3896),
+		Discount: money.Cents(1107),
+		Coupons:  []*models.AppliedCoupon{{Code: "WELCOME10", Discount: money.Cents(310)}},
+		Tax:      money.Cents(305),
+		TaxAdded: money.Cents(0),
+		Taxes: []*models.TaxAmount{
+			{Tax: models.TaxICMS, Rate: 700, Inclusive: true, Base: money.Cents(2250), Amount: money.Cents(158)},
+			{Tax: models.TaxICMS, Rate: 1800, Inclusive: true, Base: money.Cents(539), Amount: money.Cents(97)},
+			{Tax: models.TaxPIS, Rate: 165, Inclusive: true, Base: money.Cents(539), Amount: money.Cents(9)},
+			{Tax: models.TaxCOFINS, Rate: 760, Inclusive: true, Base: money.Cents(539), Amount: money.Cents(41)},
+		},
+		Total:     money.Cents(2789),
+		CreatedAt: time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC),
+	}
+	payment := &models.PaymentIntent{Method: "pix", Amount: money.Cents(2789), Status: models.PaymentCaptured}
+	store := models.Store{Name: "ZCART", Address: "Av. Paulista, 1000 - São Paulo", TaxID: "12.345.678/0001-90"}
+
+	return receipts.New(store, order, payment)
+}
+
+func TestText(t *testing.T) {
+	text := testReceipt().Text()
+
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		assert.LessOrEqual(t, utf8.RuneCountInString(line), 40, "%q", line)
+	}
+
+	assert.Contains(t, text, "                 ZCART\n")
+	assert.Contains(t, text, "Order 2ee9e93cfe57ed7d0f2fa819b3908b92\n")
+	assert.Contains(t, text, "Chamyto\n  3 x 10.99                        32.97\n  Discount                         -7.97\n")
+	assert.Contains(t, text, "Biscoito de polvilho <crocante> tamanho\n")
+	assert.Contains(t, text, "  Coupon WELCOME10                 -3.10\n")
+	assert.Contains(t, text, "TOTAL                          BRL 27.89\nTaxes included                      3.05\n  ICMS 7%                           1.58\n")
+	assert.Contains(t, text, "  PIS 1.65%                         0.09\n  COFINS 7.6%                       0.41\n")
+	assert.Contains(t, text, "Paid with pix                      27.89\n")
+}
+
+func TestTextWithTaxesAdded(t *testing.T) {
+	receipt := testReceipt()
+	receipt.Order.Taxes = append(receipt.Order.Taxes, &models.TaxAmount{Tax: models.TaxIPI, Rate: 1000, Base: money.Cents(539), Amount: money.Cents(54)})
+	receipt.Order.Tax = money.Cents(305 + 54)
+	receipt.Order.TaxAdded = money.Cents(54)
+	receipt.Order.Total = money.Cents(2789 + 54)
+
+	text := receipt.Text()
+
+	assert.Contains(t, text, "IPI 10%                             0.54\nTOTAL                          BRL 28.43\nTaxes included                      3.05\n")
+	assert.NotContains(t, text, "  IPI")
+}
+
+func TestTextWithLongValues(t *testing.T) {
+	cases := map[string]func(receipt *receipts.Receipt){
+		"Payment method": func(receipt *receipts.Receipt) {
+			receipt.Payment.Method = strings.Repeat("m", 50)
+		},
+		"Amount": func(receipt *receipts.Receipt) {
+			receipt.Order.Total.Currency = money.Currency(strings.Repeat("C", 40))
+		},
+	}
+
+	for name, change := range cases {
+		t.Run(name, func(t *testing.T) {
+			receipt := testReceipt()
+			change(receipt)
+
+			for _, line := range strings.Split(strings.TrimSuffix(receipt.Text(), "\n"), "\n") {
+				assert.LessOrEqual(t, utf8.RuneCountInString(line), 40, "%q", line)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	html, err := testReceipt().HTML()
+	require.NoError(t, err)
+
+	assert.Contains(t, string(html), `<tr class="title"><td colspan="2">ZCART</td></tr>`)
+	assert.Contains(t, string(html), "Biscoito de polvilho &lt;crocante&gt; tamanho família")
+	assert.Contains(t, string(html), `<tr class="bold"><td>TOTAL</td><td class="amount">BRL 27.89</td></tr>`)
+	assert.Less(t, strings.Index(string(html), "TOTAL"), strings.Index(string(html), "Thank you!"))
+}
+
+func TestPDF(t *testing.T) {
+	pdf, err := testReceipt().PDF()
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-")))
+}
+
+func TestRender(t *testing.T) {
+	for _, format := range []receipts.Format{receipts.HTML, receipts.PDF, receipts.Text} {
+		body, err := testReceipt().Render(format)
+		require.NoError(t, err)
+		assert.NotEmpty(t, body)
+	}
+
+	_, err := testReceipt().Render("docx")
+	assert.Error(t, err)
+	assert.False(t, receipts.Format("docx").Valid())
+}
@@ -0,0 +1,51 @@
+package receipts
+
+import (
+	"strings"
+)
+
+const textWidth = 40
+
+// Text lays the receipt out in 40 columns, the width of thermal printer paper.
+func (r *Receipt) Text() string {
+	var b strings.Builder
+
+	for _, row := range r.rows() {
+		switch row.style {
+		case separator:
+			b.WriteString(strings.Repeat("-", textWidth))
+		case title, centered:
+			b.WriteString(center(row.left))
+		default:
+			b.WriteString(columns(row))
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+func center(s string) string {
+	s = truncate(s, textWidth)
+	return strings.TrimRight(strings.Repeat(" ", (textWidth-len([]rune(s)))/2)+s, " ")
+}
+
+// columns aligns the amount to the right edge, cutting the text on the left when both don't fit.
+func columns(row row) string {
+	left := row.left
+	if row.style == indented {
+		left = "  " + left
+	}
+
+	if row.right == "" {
+		return strings.TrimRight(truncate(left, textWidth), " ")
+	}
+
+	// The amount keeps at least the column separating it from the text
+	right := truncate(row.right, textWidth-1)
+	width := textWidth - len([]rune(right)) - 1
+	if width < 0 {
+		width = 0
+	}
+	return pad(truncate(left, width), width) + " " + right
+}
@@ -0,0 +1,184 @@
+package recognition
+
+import (
+	"math"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/ids"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+)
+
+const (
+	// DefaultMinConfidence is the score below which detections are not trusted.
+	DefaultMinConfidence = 0.7
+	// DefaultPendingItemTTL is how long the shopper has to answer for a detection that was not trusted.
+	DefaultPendingItemTTL = 2 * time.Minute
+	// DefaultWeightMismatchTolerance is how many grams the scale of a cart may be off by from its lines.
+	DefaultWeightMismatchTolerance = 100
+	// DefaultWeightMismatchPeriod is how long the scale must be off before an anomaly is raised.
+	DefaultWeightMismatchPeriod = 30 * time.Second
+)
+
+// Detection is a change the detector saw in the cart for a label.
+type Detection struct {
+	Label string `json:"label"`
+	// Count is how many units showed up, negative when they were taken out
+	Count      int     `json:"count"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Weighing holds the readings of the scale before and after the change, in grams.
+type Weighing struct {
+	Before float64 `json:"weight_before"`
+	After  float64 `json:"weight_after"`
+}
+
+// Change is how much the weight changed, negative when it dropped.
+func (w Weighing) Change() float64 {
+	return w.After - w.Before
+}
+
+type Verdict string
+
+const (
+	Accepted Verdict = "accepted"
+	// UnknownLabel is given to labels no product has in the catalog
+	UnknownLabel Verdict = "unknown_label"
+	// LowConfidence is given to detections the detector is not sure enough about
+	LowConfidence Verdict = "low_confidence"
+	// NoWeight is given to products the catalog has no weight for, so the change can't be checked
+	NoWeight Verdict = "no_weight"
+	// WeightMismatch is given when the scale does not agree with what was detected
+	WeightMismatch Verdict = "weight_mismatch"
+)
+
+// Decision tells whether a detection can be applied to the cart.
+type Decision struct {
+	Detection
+	// ProductID is empty for unknown labels
+	ProductID string  `json:"product_id,omitempty"`
+	Verdict   Verdict `json:"verdict"`
+}
+
+func (d *Decision) Accepted() bool {
+	return d.Verdict == Accepted
+}
+
+// Result holds a decision for each detection, in the order they were given.
+type Result struct {
+	Decisions []*Decision `json:"decisions"`
+	// ExpectedGrams is how much the weight should have changed with the detections that were weighed
+	ExpectedGrams float64 `json:"expected_grams"`
+	MeasuredGrams float64 `json:"measured_grams"`
+}
+
+type Config struct {
+	MinConfidence  float64
+	PendingItemTTL time.Duration
+	// WeightMismatchTolerance is in grams
+	WeightMismatchTolerance float64
+	WeightMismatchPeriod    time.Duration
+}
+
+// Verifier decides which detections of a cart device are real, so every device applies the same rules.
+type Verifier struct {
+	config Config
+}
+
+func NewVerifier(config Config) *Verifier {
+	return &Verifier{config}
+}
+
+// Verify checks the detections against the catalog, given as the products by label, and the scale.
+// The detections that can be weighed are checked together against the weight change, each unit
+// being allowed to be off by the tolerance of its product, so they are either all accepted or all
+// rejected. The change must also go in the same direction as the expected one.
+func (v *Verifier) Verify(detections []Detection, weighing Weighing, catalog map[string]*models.Product) *Result {
+	result := &Result{
+		Decisions:     make([]*Decision, 0, len(detections)),
+		MeasuredGrams: weighing.Change(),
+	}
+
+	weighed := make([]*Decision, 0, len(detections))
+	var tolerance float64
+
+	for _, detection := range detections {
+		decision := &Decision{Detection: detection}
+		result.Decisions = append(result.Decisions, decision)
+
+		product, ok := catalog[detection.Label]
+		if !ok || product == nil {
+			decision.Verdict = UnknownLabel
+			continue
+		}
+		decision.ProductID = product.ID
+
+		if detection.Confidence < v.config.MinConfidence {
+			decision.Verdict = LowConfidence
+			continue
+		}
+
+		recognition := product.Recognition
+		if recognition == nil || recognition.WeightGrams == nil {
+			decision.Verdict = NoWeight
+			continue
+		}
+
+		expected := float64(detection.Count) * float64(*recognition.WeightGrams)
+		result.ExpectedGrams += expected
+		tolerance += math.Abs(expected) * float64(recognition.WeightTolerance) / 100
+		weighed = append(weighed, decision)
+	}
+
+	verdict := Accepted
+	if !withinTolerance(result.ExpectedGrams, result.MeasuredGrams, tolerance) {
+		verdict = WeightMismatch
+	}
+	for _, decision := range weighed {
+		decision.Verdict = verdict
+	}
+
+	return result
+}
+
+// pendingReasons tells which verdicts the shopper is asked about, and why.
+var pendingReasons = map[Verdict]models.PendingReason{
+	LowConfidence:  models.PendingLowConfidence,
+	WeightMismatch: models.PendingWeightMismatch,
+	NoWeight:       models.PendingNoWeight,
+}
+
+// PendingItems holds the detections of known products that were not accepted until the shopper
+// of the cart confirms or rejects them, so they are not lost. Unknown labels are left out.
+func (v *Verifier) PendingItems(cartId string, result *Result, now time.Time) []*models.PendingItem {
+	items := make([]*models.PendingItem, 0)
+
+	for _, decision := range result.Decisions {
+		reason, ok := pendingReasons[decision.Verdict]
+		if !ok {
+			continue
+		}
+
+		items = append(items, &models.PendingItem{
+			ID:         ids.New(),
+			CartID:     cartId,
+			ProductID:  decision.ProductID,
+			Quantity:   decision.Count,
+			Reason:     reason,
+			Label:      decision.Label,
+			Confidence: decision.Confidence,
+			Status:     models.PendingAwaiting,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(v.config.PendingItemTTL),
+		})
+	}
+
+	return items
+}
+
+func withinTolerance(expected float64, measured float64, tolerance float64) bool {
+	if (expected > 0 && measured <= 0) || (expected < 0 && measured >= 0) {
+		return false
+	}
+	return math.Abs(measured-expected) <= tolerance
+}
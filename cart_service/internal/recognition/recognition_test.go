@@ -0,0 +1,147 @@
+package recognition_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func weighedProduct(id string, grams uint, tolerance uint) *models.Product {
+	return &models.Product{
+		ID:          id,
+		Recognition: &models.ProductRecognition{WeightGrams: &grams, WeightTolerance: tolerance},
+	}
+}
+
+func verdicts(result *recognition.Result) []recognition.Verdict {
+	verdicts := make([]recognition.Verdict, 0, len(result.Decisions))
+	for _, decision := range result.Decisions {
+		verdicts = append(verdicts, decision.Verdict)
+	}
+	return verdicts
+}
+
+func TestVerifier(t *testing.T) {
+	verifier := recognition.NewVerifier(recognition.Config{
+		MinConfidence:  recognition.DefaultMinConfidence,
+		PendingItemTTL: recognition.DefaultPendingItemTTL,
+	})
+
+	catalog := map[string]*models.Product{
+		"coke_soda":    weighedProduct("7", 387, 10),
+		"guarana_soda": weighedProduct("8", 361, 10),
+		"post_it":      weighedProduct("10", 43, 50),
+		"card_deck":    {ID: "11", Recognition: &models.ProductRecognition{WeightTolerance: 50}},
+	}
+
+	t.Run("Accepts a unit added within the tolerance", func(t *testing.T) {
+		result := verifier.Verify(
+			[]recognition.Detection{{Label: "coke_soda", Count: 1, Confidence: 0.91}},
+			recognition.Weighing{Before: 12, After: 412},
+			catalog,
+		)
+
+		assert.Equal(t, []recognition.Verdict{recognition.Accepted}, verdicts(result))
+		assert.Equal(t, "7", result.Decisions[0].ProductID)
+		assert.Equal(t, 387.0, result.ExpectedGrams)
+		assert.Equal(t, 400.0, result.MeasuredGrams)
+	})
+
+	t.Run("Accepts units taken out", func(t *testing.T) {
+		result := verifier.Verify(
+			[]recognition.Detection{{Label: "post_it", Count: -2, Confidence: 0.8}},
+			recognition.Weighing{Before: 500, After: 410},
+			catalog,
+		)
+
+		assert.Equal(t, []recognition.Verdict{recognition.Accepted}, verdicts(result))
+	})
+
+	t.Run("Rejects a change out of the tolerance", func(t *testing.T) {
+		result := verifier.Verify(
+			[]recognition.Detection{{Label: "coke_soda", Count: 1, Confidence: 0.91}},
+			recognition.Weighing{Before: 0, After: 300},
+			catalog,
+		)
+
+		assert.Equal(t, []recognition.Verdict{recognition.WeightMismatch}, verdicts(result))
+	})
+
+	t.Run("Rejects a change in the wrong direction", func(t *testing.T) {
+		result := verifier.Verify(
+			[]recognition.Detection{{Label: "coke_soda", Count: -1, Confidence: 0.91}},
+			recognition.Weighing{Before: 0, After: 387},
+			catalog,
+		)
+
+		assert.Equal(t, []recognition.Verdict{recognition.WeightMismatch}, verdicts(result))
+	})
+
+	t.Run("Weighs the detections together", func(t *testing.T) {
+		detections := []recognition.Detection{
+			{Label: "coke_soda", Count: 1, Confidence: 0.9},
+			{Label: "guarana_soda", Count: 1, Confidence: 0.85},
+		}
+
+		result := verifier.Verify(detections, recognition.Weighing{Before: 0, After: 750}, catalog)
+		assert.Equal(t, []recognition.Verdict{recognition.Accepted, recognition.Accepted}, verdicts(result))
+
+		result = verifier.Verify(detections, recognition.Weighing{Before: 0, After: 390}, catalog)
+		assert.Equal(t, []recognition.Verdict{recognition.WeightMismatch, recognition.WeightMismatch}, verdicts(result))
+	})
+
+	t.Run("Leaves out what can't be weighed", func(t *testing.T) {
+		result := verifier.Verify(
+			[]recognition.Detection{
+				{Label: "coke_soda", Count: 1, Confidence: 0.9},
+				{Label: "banana", Count: 1, Confidence: 0.99},
+				{Label: "guarana_soda", Count: 1, Confidence: 0.4},
+				{Label: "card_deck", Count: 1, Confidence: 0.9},
+			},
+			recognition.Weighing{Before: 0, After: 390},
+			catalog,
+		)
+
+		assert.Equal(t, []recognition.Verdict{
+			recognition.Accepted,
+			recognition.UnknownLabel,
+			recognition.LowConfidence,
+			recognition.NoWeight,
+		}, verdicts(result))
+		assert.Empty(t, result.Decisions[1].ProductID)
+		assert.Equal(t, 387.0, result.ExpectedGrams)
+	})
+
+	t.Run("Holds what was not accepted for the shopper", func(t *testing.T) {
+		now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+		result := verifier.Verify(
+			[]recognition.Detection{
+				{Label: "banana", Count: 1, Confidence: 0.99},
+				{Label: "guarana_soda", Count: -1, Confidence: 0.4},
+				{Label: "card_deck", Count: 2, Confidence: 0.9},
+			},
+			recognition.Weighing{Before: 400, After: 100},
+			catalog,
+		)
+
+		items := verifier.PendingItems("2", result, now)
+		require.Len(t, items, 2)
+
+		assert.NotEmpty(t, items[0].ID)
+		assert.Equal(t, "2", items[0].CartID)
+		assert.Equal(t, "8", items[0].ProductID)
+		assert.Equal(t, -1, items[0].Quantity)
+		assert.Equal(t, models.PendingLowConfidence, items[0].Reason)
+		assert.Equal(t, models.PendingAwaiting, items[0].Status)
+		assert.Equal(t, now.Add(2*time.Minute), items[0].ExpiresAt)
+
+		assert.Equal(t, "11", items[1].ProductID)
+		assert.Equal(t, 2, items[1].Quantity)
+		assert.Equal(t, models.PendingNoWeight, items[1].Reason)
+	})
+}
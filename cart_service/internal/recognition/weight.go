@@ -0,0 +1,46 @@
+package recognition
+
+import (
+	"math"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+)
+
+// AnomalyAction is what should happen to the weight mismatch anomaly of a cart after a reading.
+type AnomalyAction int
+
+const (
+	KeepAnomaly AnomalyAction = iota
+	RaiseAnomaly
+	ResolveAnomaly
+)
+
+// ReconcileWeight checks the current reading of a cart against its lines, carrying on the mismatch of the
+// previous reading, if any. Given whether the cart has an open anomaly, it tells what should happen to it:
+// it is raised once the readings were off by more than the tolerance for the mismatch period, and resolved
+// as soon as a reading is back within it. Readings that could not be reconciled leave the anomaly as it is.
+func (v *Verifier) ReconcileWeight(previous *models.CartWeight, current *models.CartWeight, open bool) AnomalyAction {
+	current.MismatchSince = nil
+
+	if !current.Reconciled {
+		return KeepAnomaly
+	}
+
+	if math.Abs(current.MeasuredGrams-current.ExpectedGrams) <= v.config.WeightMismatchTolerance {
+		if open {
+			return ResolveAnomaly
+		}
+		return KeepAnomaly
+	}
+
+	since := current.MeasuredAt
+	if previous != nil && previous.MismatchSince != nil {
+		since = *previous.MismatchSince
+	}
+	current.MismatchSince = &since
+
+	if !open && current.MeasuredAt.Sub(since) >= v.config.WeightMismatchPeriod {
+		return RaiseAnomaly
+	}
+	return KeepAnomaly
+}
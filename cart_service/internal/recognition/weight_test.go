@@ -0,0 +1,87 @@
+package recognition_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/recognition"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileWeight(t *testing.T) {
+	verifier := recognition.NewVerifier(recognition.Config{
+		WeightMismatchTolerance: 100,
+		WeightMismatchPeriod:    30 * time.Second,
+	})
+
+	start := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	reading := func(measured float64, expected float64, at time.Duration) *models.CartWeight {
+		return &models.CartWeight{
+			CartID:        "2",
+			MeasuredGrams: measured,
+			ExpectedGrams: expected,
+			Reconciled:    true,
+			MeasuredAt:    start.Add(at),
+		}
+	}
+
+	t.Run("Within the tolerance", func(t *testing.T) {
+		current := reading(820, 774, 0)
+
+		assert.Equal(t, recognition.KeepAnomaly, verifier.ReconcileWeight(nil, current, false))
+		assert.Nil(t, current.MismatchSince)
+	})
+
+	t.Run("Raises once the mismatch lasts for the period", func(t *testing.T) {
+		first := reading(1161, 774, 0)
+		assert.Equal(t, recognition.KeepAnomaly, verifier.ReconcileWeight(nil, first, false))
+		require.NotNil(t, first.MismatchSince)
+		assert.Equal(t, start, *first.MismatchSince)
+
+		second := reading(1160, 774, 20*time.Second)
+		assert.Equal(t, recognition.KeepAnomaly, verifier.ReconcileWeight(first, second, false))
+		assert.Equal(t, start, *second.MismatchSince)
+
+		third := reading(1162, 774, 30*time.Second)
+		assert.Equal(t, recognition.RaiseAnomaly, verifier.ReconcileWeight(second, third, false))
+
+		// Already raised
+		fourth := reading(1162, 774, 40*time.Second)
+		assert.Equal(t, recognition.KeepAnomaly, verifier.ReconcileWeight(third, fourth, true))
+		assert.Equal(t, start, *fourth.MismatchSince)
+	})
+
+	t.Run("A reading within the tolerance starts over", func(t *testing.T) {
+		first := reading(1161, 774, 0)
+		verifier.ReconcileWeight(nil, first, false)
+
+		second := reading(780, 774, 20*time.Second)
+		assert.Equal(t, recognition.KeepAnomaly, verifier.ReconcileWeight(first, second, false))
+		assert.Nil(t, second.MismatchSince)
+
+		third := reading(1161, 774, 40*time.Second)
+		assert.Equal(t, recognition.KeepAnomaly, verifier.ReconcileWeight(second, third, false))
+		assert.Equal(t, start.Add(40*time.Second), *third.MismatchSince)
+	})
+
+	t.Run("Resolves once back within the tolerance", func(t *testing.T) {
+		previous := reading(1161, 774, 0)
+		verifier.ReconcileWeight(nil, previous, true)
+
+		assert.Equal(t, recognition.ResolveAnomaly, verifier.ReconcileWeight(previous, reading(774, 774, time.Minute), true))
+	})
+
+	t.Run("Leaves the anomaly of readings that can't be reconciled", func(t *testing.T) {
+		previous := reading(1161, 774, 0)
+		verifier.ReconcileWeight(nil, previous, false)
+
+		current := reading(5000, 774, time.Minute)
+		current.Reconciled = false
+
+		assert.Equal(t, recognition.KeepAnomaly, verifier.ReconcileWeight(previous, current, false))
+		assert.Nil(t, current.MismatchSince)
+	})
+}
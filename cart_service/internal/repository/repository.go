@@ -1,10 +1,65 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+)
+
+var (
+	ErrCartNotFound          = errors.New("cart not found")
+	ErrCartAlreadyExists     = errors.New("cart already exists")
+	ErrCartNotOpen           = errors.New("cart is not open")
+	ErrCartNotCheckingOut    = errors.New("cart is not checking out")
+	ErrInvalidCartTransition = errors.New("invalid cart status transition")
+	ErrCartEmpty             = errors.New("cart is empty")
+
+	ErrProductNotFound      = errors.New("product not found")
+	ErrProductAlreadyExists = errors.New("product already exists")
+	ErrLabelTaken           = errors.New("label is already used by another product")
+
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrOrderNotPending = errors.New("order is not pending")
+
+	ErrPromotionNotFound      = errors.New("promotion not found")
+	ErrPromotionAlreadyExists = errors.New("promotion already exists")
+
+	ErrCouponNotFound       = errors.New("coupon not found")
+	ErrCouponAlreadyExists  = errors.New("coupon already exists")
+	ErrCouponAlreadyApplied = errors.New("coupon already applied to the cart")
+	ErrCouponNotApplied     = errors.New("coupon is not applied to the cart")
+
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrPaymentAlreadyExists = errors.New("payment already exists")
+
+	ErrFiscalDocumentNotFound      = errors.New("fiscal document not found")
+	ErrFiscalDocumentAlreadyExists = errors.New("order already has a fiscal document")
+
+	ErrTaxRuleNotFound      = errors.New("tax rule not found")
+	ErrTaxRuleAlreadyExists = errors.New("tax rule already exists")
+
+	ErrStockNotFound     = errors.New("product stock is not tracked")
+	ErrInsufficientStock = errors.New("not enough stock on hand")
+
+	ErrPendingItemNotFound = errors.New("pending item not found")
+	ErrPendingItemResolved = errors.New("pending item was already resolved")
+
+	ErrCartWeightNotFound = errors.New("cart has no weight reading")
+	ErrAnomalyNotFound    = errors.New("anomaly not found")
+
+	ErrDeviceNotFound      = errors.New("device not found")
+	ErrDeviceAlreadyExists = errors.New("device already exists")
+	ErrDeviceNotBound      = errors.New("device is not bound to a cart")
+	ErrDeviceHealthChanged = errors.New("device health was changed by someone else")
+	ErrCartAlreadyBound    = errors.New("cart is bound to another device")
 )
 
 type CartRepository interface {
+	CreateCart(cartId string) (*models.Cart, error)
+	// UpdateCartStatus moves the cart to status to, as long as it is still in status from.
+	UpdateCartStatus(cartId string, from models.CartStatus, to models.CartStatus) error
 	GetCart(cartId string) (*models.Cart, error)
 	GetCartProduct(cartId string, productId string) (*models.CartProduct, error)
 	UpdateProductQuantity(cartId string, productId string, delta int) error
@@ -14,4 +69,165 @@ type CartRepository interface {
 
 type ProductRepository interface {
 	GetProduct(productId string) (models.Product, error)
+	ListProducts(filter ProductFilter) ([]models.Product, error)
+	CountProducts(filter ProductFilter) (int, error)
+	CreateProduct(product models.Product) error
+	UpdateProduct(product models.Product) error
+	DeleteProduct(productId string) error
+}
+
+type OrderRepository interface {
+	// CreateFromCart atomically snapshots the lines of a cart checking out into a pending order, redeems
+	// the coupons applied to the cart, takes the units sold out of stock and empties it. When given,
+	// check is called with the order before anything is saved, an error from it leaving the cart untouched.
+	CreateFromCart(orderId string, cartId string, check func(*models.Order) error) (*models.Order, error)
+	// CompleteOrder completes a pending order once it is paid for, marking its cart paid.
+	CompleteOrder(orderId string) error
+	// CancelOrder gives up on a pending order that could not be paid for, putting its units back
+	// in stock and its lines and coupons back in the cart, still checking out.
+	CancelOrder(orderId string) error
+	GetOrder(orderId string) (*models.Order, error)
+	ListCartOrders(cartId string) ([]*models.Order, error)
+}
+
+type PromotionRepository interface {
+	CreatePromotion(promotion *models.Promotion) error
+	GetPromotion(promotionId string) (*models.Promotion, error)
+	// ListPromotions returns every promotion, including the ones not running at the moment.
+	ListPromotions() ([]*models.Promotion, error)
+	DeletePromotion(promotionId string) error
+}
+
+type TaxRuleRepository interface {
+	// CreateTaxRule fails if the tax class already has a rule for the same tax.
+	CreateTaxRule(rule *models.TaxRule) error
+	GetTaxRule(ruleId string) (*models.TaxRule, error)
+	ListTaxRules() ([]*models.TaxRule, error)
+	DeleteTaxRule(ruleId string) error
+}
+
+type StockRepository interface {
+	// TrackStock starts tracking the stock of the product, or changes its low stock threshold if it already is.
+	TrackStock(productId string, lowStockThreshold uint) (*models.StockLevel, error)
+	GetStock(productId string) (*models.StockLevel, error)
+	// ListStock returns the stock of every tracked product, only the low ones when lowOnly is set.
+	ListStock(lowOnly bool) ([]*models.StockLevel, error)
+	// AdjustStock changes the units on hand of the product, failing if it would take them below zero.
+	AdjustStock(adjustment *models.StockAdjustment) (*models.StockLevel, error)
+	// ListAdjustments returns the adjustments of the product, newest first.
+	ListAdjustments(productId string) ([]*models.StockAdjustment, error)
+	// ListAlerts returns the low stock alerts not resolved by a restock yet.
+	ListAlerts() ([]*models.StockAlert, error)
+}
+
+type PendingItemRepository interface {
+	CreatePendingItem(item *models.PendingItem) error
+	GetPendingItem(cartId string, itemId string) (*models.PendingItem, error)
+	// ListPendingItems returns the items of the cart still waiting for the shopper, oldest first.
+	ListPendingItems(cartId string) ([]*models.PendingItem, error)
+	// ConfirmPendingItem applies the change of the item to the open cart, unless it was
+	// already resolved or has expired.
+	ConfirmPendingItem(cartId string, itemId string) (*models.PendingItem, error)
+	// RejectPendingItem drops the item, leaving the cart untouched.
+	RejectPendingItem(cartId string, itemId string) (*models.PendingItem, error)
+	// ExpirePendingItems resolves the items that were not answered until now as expired and returns them.
+	ExpirePendingItems(now time.Time) ([]*models.PendingItem, error)
+}
+
+type CartWeightRepository interface {
+	// ExpectedWeight computes what the lines of the cart weigh according to the catalog. The weight
+	// is not known when a product in the cart has no weight or a change to the cart is pending.
+	ExpectedWeight(cartId string) (grams float64, known bool, err error)
+	GetCartWeight(cartId string) (*models.CartWeight, error)
+	// SaveCartWeight replaces the last reading of the cart.
+	SaveCartWeight(weight *models.CartWeight) error
+	GetOpenAnomaly(cartId string, kind models.AnomalyKind) (*models.CartAnomaly, error)
+	CreateAnomaly(anomaly *models.CartAnomaly) error
+	// ResolveAnomaly sets when the anomaly was resolved.
+	ResolveAnomaly(anomaly *models.CartAnomaly) error
+	// ListAnomalies returns the anomalies of every cart, newest first, only the open ones when openOnly is set.
+	ListAnomalies(openOnly bool) ([]*models.CartAnomaly, error)
+}
+
+type DeviceRepository interface {
+	CreateDevice(device *models.Device) error
+	GetDevice(deviceId string) (*models.Device, error)
+	ListDevices() ([]*models.Device, error)
+	// BindCart makes the device operate on the cart, which must be open and not bound to another device.
+	BindCart(deviceId string, cartId string, at time.Time) (*models.Device, error)
+	// UnbindCart leaves the device waiting for the next shopper.
+	UnbindCart(deviceId string) (*models.Device, error)
+	// ReleaseCart unbinds the device bound to the cart, if any, returning whether there was one.
+	ReleaseCart(cartId string) (bool, error)
+	// UpdateHealth moves the device to health to along with when it changed, as long as it is still in health from.
+	UpdateHealth(deviceId string, from models.DeviceHealth, to models.DeviceHealth, since time.Time) error
+}
+
+type HeartbeatRepository interface {
+	CreateHeartbeat(heartbeat *models.Heartbeat) error
+	// LastHeartbeats returns the last heartbeat of every device that sent one, by device.
+	LastHeartbeats() (map[string]*models.Heartbeat, error)
+	// ListHeartbeats returns up to limit heartbeats of the device, newest first.
+	ListHeartbeats(deviceId string, limit int) ([]*models.Heartbeat, error)
+	// PruneHeartbeats deletes the heartbeats received before the given time, returning how many there were.
+	PruneHeartbeats(before time.Time) (int64, error)
+}
+
+type CouponRepository interface {
+	CreateCoupon(coupon *models.Coupon) error
+	GetCoupon(code string) (*models.Coupon, error)
+	ListCoupons() ([]*models.Coupon, error)
+	// ApplyToCart attaches the coupon to an open cart. It is only redeemed when the cart is checked out.
+	ApplyToCart(cartId string, code string, customerId *string) error
+	RemoveFromCart(cartId string, code string) error
+	// ListCartCoupons returns the coupons applied to the cart, but the single use ones their customer
+	// redeemed since, the order of the cart being priced without them as well.
+	ListCartCoupons(cartId string) ([]*models.CartCoupon, error)
+	// UsedByCustomer reports whether the customer has already redeemed the coupon.
+	UsedByCustomer(code string, customerId string) (bool, error)
+}
+
+type PaymentRepository interface {
+	CreatePayment(payment *models.PaymentIntent) error
+	GetPaymentByKey(idempotencyKey string) (*models.PaymentIntent, error)
+	GetPaymentByTransaction(gateway string, transactionId string) (*models.PaymentIntent, error)
+	// UpdatePayment saves the status, transaction and failure reason of the payment
+	UpdatePayment(payment *models.PaymentIntent) error
+	// ExpirePendingPayments voids the payments through the gateway still pending since before the given
+	// time, for reason, and returns them.
+	ExpirePendingPayments(gateway string, before time.Time, reason string) ([]*models.PaymentIntent, error)
+	ListOrderPayments(orderId string) ([]*models.PaymentIntent, error)
+}
+
+type FiscalRepository interface {
+	// CreateDocument numbers the next document of the series and saves the one built for that number,
+	// so no number is skipped or taken twice. It fails if the order already has a document.
+	CreateDocument(orderId string, series uint, build func(number uint) (*models.FiscalDocument, error)) (*models.FiscalDocument, error)
+	GetDocument(orderId string) (*models.FiscalDocument, error)
+	// UpdateDocument saves the status, XML, protocol and status message of the document
+	UpdateDocument(document *models.FiscalDocument) error
+}
+
+type CartEventRepository interface {
+	// Append stores the event with the next sequence number of the cart and returns it.
+	Append(cartId string, event string, payload []byte) (uint64, error)
+	// ListSince returns up to limit events of the cart with a sequence greater than since, oldest first.
+	ListSince(cartId string, since uint64, limit int) ([]models.CartEvent, error)
+	// PruneEvents deletes the events of the carts paid, closed or abandoned longer than the retention
+	// before now, returning how many there were.
+	PruneEvents(now time.Time) (int64, error)
+}
+
+// ProductFilter narrows down and paginates a product listing.
+// Zero values mean "no restriction".
+type ProductFilter struct {
+	Name     string
+	MinPrice *money.Money
+	MaxPrice *money.Money
+	// Label only keeps the product the detector recognizes by it
+	Label string
+	// Labelled only keeps the products the detector recognizes
+	Labelled bool
+	Limit    int
+	Offset   int
 }
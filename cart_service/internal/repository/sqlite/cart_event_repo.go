@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+)
+
+type cartEventRepository struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+// NewCartEventRepository keeps the events of a cart for retention after the cart was paid, closed or abandoned.
+func NewCartEventRepository(db *sql.DB, retention time.Duration) repository.CartEventRepository {
+	return &cartEventRepository{db, retention}
+}
+
+func (c *cartEventRepository) Append(cartId string, event string, payload []byte) (uint64, error) {
+	// A single statement, so concurrent appends can't pick the same sequence
+	const query = `
+        INSERT INTO
+          cart_events (cart_id, sequence, event, payload)
+        SELECT
+          ?, COALESCE(MAX(sequence), 0) + 1, ?, ?
+        FROM
+          cart_events
+        WHERE
+          cart_id = ?
+        RETURNING sequence
+    `
+
+	var sequence uint64
+	if err := c.db.QueryRow(query, cartId, event, string(payload), cartId).Scan(&sequence); err != nil {
+		return 0, err
+	}
+
+	return sequence, nil
+}
+
+func (c *cartEventRepository) ListSince(cartId string, since uint64, limit int) ([]models.CartEvent, error) {
+	const query = `
+        SELECT
+          cart_id, sequence, event, payload, created_at
+        FROM
+          cart_events
+        WHERE
+          cart_id = ? AND sequence > ?
+        ORDER BY
+          sequence
+        LIMIT ?
+    `
+
+	rows, err := c.db.Query(query, cartId, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.CartEvent, 0)
+	for rows.Next() {
+		var (
+			event   models.CartEvent
+			payload string
+		)
+		if err := rows.Scan(&event.CartID, &event.Sequence, &event.Event, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (c *cartEventRepository) PruneEvents(now time.Time) (int64, error) {
+	// Finishing a cart is its last change, so it finished when it was last updated
+	const query = `
+        DELETE FROM
+          cart_events
+        WHERE
+          cart_id IN (SELECT id FROM carts WHERE status IN (?, ?, ?) AND updated_at < ?)
+    `
+
+	result, err := c.db.Exec(query, models.CartPaid, models.CartClosed, models.CartAbandoned, now.Add(-c.retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
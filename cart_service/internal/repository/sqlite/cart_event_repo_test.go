@@ -0,0 +1,97 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func createCartEventSetup() (repository.CartEventRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewCartEventRepository(db, 24*time.Hour), db, mock
+}
+
+func TestCartEventRepo(t *testing.T) {
+	t.Run("Append", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCartEventSetup()
+
+			payload := []byte(`{"event":"product_added"}`)
+
+			mock.ExpectQuery(`INSERT INTO cart_events .* SELECT .*MAX\(sequence\).* RETURNING sequence`).
+				WithArgs("2", "product_added", string(payload), "2").
+				WillReturnRows(sqlmock.NewRows([]string{"sequence"}).AddRow(8))
+
+			sequence, err := repo.Append("2", "product_added", payload)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(8), sequence)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error", func(t *testing.T) {
+			repo, _, mock := createCartEventSetup()
+
+			expectedError := errors.New("database is locked")
+			mock.ExpectQuery(`INSERT INTO cart_events`).WillReturnError(expectedError)
+
+			_, err := repo.Append("2", "product_added", []byte(`{}`))
+			assert.ErrorIs(t, err, expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListSince", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCartEventSetup()
+
+			createdAt := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+			mock.ExpectQuery(`SELECT .* FROM cart_events WHERE cart_id = \? AND sequence > \? ORDER BY sequence LIMIT \?`).
+				WithArgs("2", 3, 100).
+				WillReturnRows(sqlmock.NewRows([]string{"cart_id", "sequence", "event", "payload", "created_at"}).
+					AddRow("2", 4, "product_added", `{"a":1}`, createdAt).
+					AddRow("2", 5, "product_removed", `{"b":2}`, createdAt))
+
+			events, err := repo.ListSince("2", 3, 100)
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			assert.Equal(t, []models.CartEvent{
+				{CartID: "2", Sequence: 4, Event: "product_added", Payload: []byte(`{"a":1}`), CreatedAt: createdAt},
+				{CartID: "2", Sequence: 5, Event: "product_removed", Payload: []byte(`{"b":2}`), CreatedAt: createdAt},
+			}, events)
+		})
+
+		t.Run("Error", func(t *testing.T) {
+			repo, _, mock := createCartEventSetup()
+
+			expectedError := errors.New("boom")
+			mock.ExpectQuery(`SELECT .* FROM cart_events`).WillReturnError(expectedError)
+
+			_, err := repo.ListSince("2", 0, 100)
+			assert.ErrorIs(t, err, expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+	t.Run("PruneEvents", func(t *testing.T) {
+		repo, _, mock := createCartEventSetup()
+
+		now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+		mock.ExpectExec(`DELETE FROM cart_events WHERE cart_id IN \(SELECT id FROM carts WHERE status IN \(\?, \?, \?\) AND updated_at < \?\)`).
+			WithArgs(models.CartPaid, models.CartClosed, models.CartAbandoned, now.Add(-24*time.Hour)).
+			WillReturnResult(sqlmock.NewResult(0, 12))
+
+		pruned, err := repo.PruneEvents(now)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), pruned)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -3,33 +3,102 @@ package sqlite
 import (
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/fsmiamoto/zcart/cart_service/internal/models"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
 )
 
 var (
-	ErrCartNotFound = errors.New("cart not found")
+	ErrCartNotFound       = repository.ErrCartNotFound
+	ErrCartAlreadyExists  = repository.ErrCartAlreadyExists
+	ErrCartNotOpen        = repository.ErrCartNotOpen
+	ErrCartNotCheckingOut = repository.ErrCartNotCheckingOut
 )
 
 type sqlCartRepository struct {
-	db *sql.DB
+	db             *sql.DB
+	reservationTTL time.Duration
 }
 
-func NewCartRepository(db *sql.DB) repository.CartRepository {
-	return &sqlCartRepository{db}
+// NewCartRepository reserves the units of tracked products in a cart for reservationTTL
+// after every change to its lines.
+func NewCartRepository(db *sql.DB, reservationTTL time.Duration) repository.CartRepository {
+	return &sqlCartRepository{db, reservationTTL}
+}
+
+func (c *sqlCartRepository) CreateCart(cartId string) (*models.Cart, error) {
+	const query = `INSERT INTO carts (id, status) VALUES (?, ?)`
+
+	_, err := c.db.Exec(query, cartId, models.CartOpen)
+	if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+		return nil, ErrCartAlreadyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	return &models.Cart{
+		ID:        cartId,
+		Status:    models.CartOpen,
+		Products:  make([]*models.CartProduct, 0),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func (c *sqlCartRepository) UpdateCartStatus(cartId string, from models.CartStatus, to models.CartStatus) error {
+	const query = `UPDATE carts SET status = ?, updated_at = current_timestamp WHERE id = ? AND status = ?`
+
+	result, err := c.db.Exec(query, to, cartId, from)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// Either the cart does not exist or someone else changed its status first
+	if _, err := getCartStatus(c.db, cartId); err != nil {
+		return err
+	}
+
+	return repository.ErrInvalidCartTransition
 }
 
 func (c *sqlCartRepository) EmptyCart(cartId string) error {
-	return c.emptyCart(cartId)
+	return inOpenCart(c.db, cartId, func(tx *sql.Tx) error {
+		if err := emptyCart(tx, cartId); err != nil {
+			return err
+		}
+		return c.reserveStock(tx, cartId)
+	})
 }
 
 func (c *sqlCartRepository) RemoveProduct(cartId string, productId string) error {
-	return c.removeProduct(cartId, productId)
+	return inOpenCart(c.db, cartId, func(tx *sql.Tx) error {
+		if err := removeProduct(tx, cartId, productId); err != nil {
+			return err
+		}
+		return c.reserveStock(tx, cartId)
+	})
 }
 
 func (c *sqlCartRepository) UpdateProductQuantity(cartId string, productId string, delta int) error {
-	return c.updateQuantity(cartId, productId, delta)
+	return inOpenCart(c.db, cartId, func(tx *sql.Tx) error {
+		if err := updateQuantity(tx, cartId, productId, delta); err != nil {
+			return err
+		}
+		return c.reserveStock(tx, cartId)
+	})
 }
 
 func (c *sqlCartRepository) GetCartProduct(cartId string, productId string) (*models.CartProduct, error) {
@@ -46,6 +115,83 @@ func (c *sqlCartRepository) GetCartProduct(cartId string, productId string) (*mo
 }
 
 func (c *sqlCartRepository) GetCart(cartId string) (*models.Cart, error) {
+	const cartQuery = `SELECT id, status, version, created_at, updated_at FROM carts WHERE id = ?`
+
+	cart := &models.Cart{}
+	if err := c.db.QueryRow(cartQuery, cartId).Scan(&cart.ID, &cart.Status, &cart.Version, &cart.CreatedAt, &cart.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCartNotFound
+		}
+		return nil, err
+	}
+
+	products, err := getCartProducts(c.db, cartId)
+	if err != nil {
+		return nil, err
+	}
+	cart.Products = products
+
+	return cart, nil
+}
+
+func (c *sqlCartRepository) reserveStock(tx *sql.Tx, cartId string) error {
+	return reserveCartStock(tx, cartId, time.Now().UTC().Add(c.reservationTTL))
+}
+
+// inOpenCart runs fn in a transaction, after making sure the cart still accepts changes,
+// and bumps the cart version once fn succeeds.
+func inOpenCart(db *sql.DB, cartId string, fn func(tx *sql.Tx) error) error {
+	return inCart(db, cartId, models.CartOpen, ErrCartNotOpen, fn)
+}
+
+// inCart runs fn in a transaction, after making sure the cart is still in status, failing with
+// wrongStatus otherwise, and bumps the cart version once fn succeeds.
+func inCart(db *sql.DB, cartId string, status models.CartStatus, wrongStatus error, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	current, err := getCartStatus(tx, cartId)
+	if err == nil && current != status {
+		err = wrongStatus
+	}
+	if err == nil {
+		err = fn(tx)
+	}
+	if err == nil {
+		err = bumpCartVersion(tx, cartId)
+	}
+
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getCartStatus(db querier, cartId string) (models.CartStatus, error) {
+	const query = `SELECT status FROM carts WHERE id = ?`
+
+	var status models.CartStatus
+	if err := db.QueryRow(query, cartId).Scan(&status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrCartNotFound
+		}
+		return "", err
+	}
+
+	return status, nil
+}
+
+func getCartProducts(db querier, cartId string) ([]*models.CartProduct, error) {
 	const query = `
         SELECT
           cp.cart_id,
@@ -53,9 +199,11 @@ func (c *sqlCartRepository) GetCart(cartId string) (*models.Cart, error) {
           cp.quantity,
           p.name,
           p.price,
+          p.currency,
           p.id,
           p.description,
-          p.image_url
+          p.image_url,
+          p.tax_class
         FROM
           cart_products cp
           JOIN products p ON cp.product_id = p.id
@@ -67,41 +215,52 @@ func (c *sqlCartRepository) GetCart(cartId string) (*models.Cart, error) {
 
 	var cartProducts []*models.CartProduct
 
-	rows, err := c.db.Query(query, cartId)
+	rows, err := db.Query(query, cartId)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		cp := &models.CartProduct{}
 		if err := rows.Scan(
 			&cp.CartID, &cp.ProductID, &cp.Quantity, &cp.Product.Name,
-			&cp.Product.Price, &cp.Product.ID, &cp.Product.Description, &cp.Product.ImageURL,
+			&cp.Product.Price.Amount, &cp.Product.Price.Currency, &cp.Product.ID,
+			&cp.Product.Description, &cp.Product.ImageURL, &cp.Product.TaxClass,
 		); err != nil {
 			return nil, err
 		}
 		cartProducts = append(cartProducts, cp)
 	}
 
-	return &models.Cart{
-		ID:       cartId,
-		Products: cartProducts,
-	}, nil
+	return cartProducts, rows.Err()
+}
+
+func bumpCartVersion(tx *sql.Tx, cartId string) error {
+	const query = `UPDATE carts SET version = version + 1, updated_at = current_timestamp WHERE id = ?`
+	_, err := tx.Exec(query, cartId)
+	return err
+}
+
+func setCartStatus(tx *sql.Tx, cartId string, status models.CartStatus) error {
+	const query = `UPDATE carts SET status = ? WHERE id = ?`
+	_, err := tx.Exec(query, status, cartId)
+	return err
 }
 
-func (c *sqlCartRepository) emptyCart(cartId string) error {
+func emptyCart(tx *sql.Tx, cartId string) error {
 	const query = `DELETE FROM cart_products WHERE cart_id = ?`
-	_, err := c.db.Exec(query, cartId)
+	_, err := tx.Exec(query, cartId)
 	return err
 }
 
-func (c *sqlCartRepository) removeProduct(cartId string, productId string) error {
+func removeProduct(tx *sql.Tx, cartId string, productId string) error {
 	const query = `DELETE FROM cart_products WHERE cart_id = ? AND product_id = ?`
-	_, err := c.db.Exec(query, cartId, productId)
+	_, err := tx.Exec(query, cartId, productId)
 	return err
 }
 
-func (c *sqlCartRepository) updateQuantity(cartId string, productId string, delta int) error {
+func updateQuantity(tx *sql.Tx, cartId string, productId string, delta int) error {
 	// Docs: https://sqlite.org/lang_upsert.html
 	const query = `
         INSERT INTO
@@ -117,7 +276,7 @@ func (c *sqlCartRepository) updateQuantity(cartId string, productId string, delt
         WHERE
             cart_id = ? AND product_id = ? AND quantity <= 0;
     `
-	_, err := c.db.Exec(query, cartId, productId, delta, cartId, productId)
+	_, err := tx.Exec(query, cartId, productId, delta, cartId, productId)
 
 	return err
 }
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,33 +26,117 @@ func NewMock() (*sql.DB, sqlmock.Sqlmock) {
 
 func createCartSetup() (repository.CartRepository, *sql.DB, sqlmock.Sqlmock) {
 	db, mock := NewMock()
-	return sqlite.NewCartRepository(db), db, mock
+	return sqlite.NewCartRepository(db, 15*time.Minute), db, mock
+}
+
+func expectCartStatus(mock sqlmock.Sqlmock, cartId string, status models.CartStatus) {
+	mock.ExpectQuery(`SELECT status FROM carts WHERE id = ?`).
+		WithArgs(cartId).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+}
+
+func expectStockReserved(mock sqlmock.Sqlmock, cartId string) {
+	mock.ExpectExec(`DELETE FROM stock_reservations WHERE cart_id = \?;\s+INSERT INTO stock_reservations .* JOIN stock s`).
+		WithArgs(cartId, sqlmock.AnyArg(), cartId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectVersionBump(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`UPDATE carts SET version = version \+ 1`).WillReturnResult(sqlmock.NewResult(0, 1))
 }
 
 func TestCartRepo(t *testing.T) {
+	t.Run("CreateCart", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
+
+			mock.ExpectExec(`INSERT INTO carts`).
+				WithArgs("3", models.CartOpen).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			cart, err := repo.CreateCart("3")
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			assert.Equal(t, "3", cart.ID)
+			assert.Equal(t, models.CartOpen, cart.Status)
+			assert.Empty(t, cart.Products)
+		})
+
+		t.Run("Error with duplicated id", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
+
+			mock.ExpectExec(`INSERT INTO carts`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey})
+
+			_, err := repo.CreateCart("3")
+			assert.ErrorIs(t, err, sqlite.ErrCartAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("UpdateCartStatus", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
+
+			mock.ExpectExec(`UPDATE carts SET status = \?, updated_at = current_timestamp WHERE id = \? AND status = \?`).
+				WithArgs(models.CartCheckingOut, "1", models.CartOpen).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			assert.NoError(t, repo.UpdateCartStatus("1", models.CartOpen, models.CartCheckingOut))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with status changed concurrently", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
+
+			mock.ExpectExec(`UPDATE carts`).WillReturnResult(sqlmock.NewResult(0, 0))
+			expectCartStatus(mock, "1", models.CartAbandoned)
+
+			err := repo.UpdateCartStatus("1", models.CartOpen, models.CartCheckingOut)
+			assert.ErrorIs(t, err, repository.ErrInvalidCartTransition)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown cart", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
+
+			mock.ExpectExec(`UPDATE carts`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`SELECT status FROM carts`).WillReturnError(sql.ErrNoRows)
+
+			err := repo.UpdateCartStatus("404", models.CartOpen, models.CartCheckingOut)
+			assert.ErrorIs(t, err, sqlite.ErrCartNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
 	t.Run("GetCart", func(t *testing.T) {
 		t.Run("Success", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
 			cartId := "2"
-			rows := sqlmock.NewRows([]string{
-				"cp.cart_id", "cp.product_id", "cp.quantity", "p.name",
-				"p.price", "p.id", "p.description", "p.image_url",
-			})
+			createdAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+			mock.ExpectQuery(`SELECT id, status, version, created_at, updated_at FROM carts WHERE id = ?`).
+				WithArgs(cartId).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "status", "version", "created_at", "updated_at"}).
+					AddRow(cartId, models.CartOpen, 7, createdAt, createdAt))
+
+			rows := sqlmock.NewRows(cartProductColumns)
 
 			expectedCartProducts := []*models.CartProduct{
 				{
 					ProductID: "1",
 					Quantity:  3,
 					Product: models.Product{
-						ID: "1", Name: "Calzone", Price: 5.99, Description: optional("PedRão"),
+						ID: "1", Name: "Calzone", Price: money.Cents(599), Description: optional("PedRão"), TaxClass: "standard",
 					},
 				},
 				{
 					ProductID: "2",
 					Quantity:  1,
 					Product: models.Product{
-						ID: "2", Name: "Pão de Batata", Price: 2.99, ImageURL: optional("https://example.com"),
+						ID: "2", Name: "Pão de Batata", Price: money.Cents(299), ImageURL: optional("https://example.com"),
 					},
 				},
 			}
@@ -57,8 +144,8 @@ func TestCartRepo(t *testing.T) {
 			for _, cp := range expectedCartProducts {
 				rows.AddRow(
 					cp.CartID, cp.ProductID, cp.Quantity, cp.Product.Name,
-					cp.Product.Price, cp.Product.ID, cp.Product.Description,
-					cp.Product.ImageURL,
+					cp.Product.Price.Amount, cp.Product.Price.Currency, cp.Product.ID, cp.Product.Description,
+					cp.Product.ImageURL, cp.Product.TaxClass,
 				)
 			}
 
@@ -70,14 +157,34 @@ func TestCartRepo(t *testing.T) {
 			assert.NoError(t, err)
 			assert.NoError(t, mock.ExpectationsWereMet())
 
+			assert.Equal(t, models.CartOpen, cart.Status)
+			assert.Equal(t, uint64(7), cart.Version)
+			assert.Equal(t, createdAt, cart.CreatedAt)
 			assert.EqualValues(t, expectedCartProducts, cart.Products)
 		})
 
+		t.Run("Error with unknown cart", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
+
+			mock.ExpectQuery(`SELECT id, status, version, created_at, updated_at FROM carts`).
+				WithArgs("404").
+				WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.GetCart("404")
+			assert.ErrorIs(t, err, sqlite.ErrCartNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
 		t.Run("Error", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
 			cartId := "2"
 
+			mock.ExpectQuery(`SELECT id, status, version, created_at, updated_at FROM carts`).
+				WithArgs(cartId).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "status", "version", "created_at", "updated_at"}).
+					AddRow(cartId, models.CartOpen, 0, time.Now(), time.Now()))
+
 			expectedError := errors.New("ooops")
 			mock.ExpectQuery(`SELECT .* FROM cart_products cp JOIN products p`).
 				WithArgs(cartId).
@@ -90,45 +197,46 @@ func TestCartRepo(t *testing.T) {
 
 	})
 
-	t.Run("AddProduct", func(t *testing.T) {
-		t.Run("Success with product already added to cart", func(t *testing.T) {
+	t.Run("UpdateProductQuantity", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
 			cartId := "1"
 			productId := "42"
-			quantity := int(25)
-			quantityAlreadyAdded := int(15)
-
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}).AddRow(cartId, productId, quantityAlreadyAdded),
-			)
+			delta := 25
 
+			mock.ExpectBegin()
+			expectCartStatus(mock, cartId, models.CartOpen)
 			mock.ExpectExec("INSERT INTO cart_products").
-				WithArgs(cartId, productId, quantity+quantityAlreadyAdded).
+				WithArgs(cartId, productId, delta, cartId, productId).
 				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectStockReserved(mock, cartId)
+			expectVersionBump(mock)
+			mock.ExpectCommit()
 
-			err := repo.UpdateProductQuantity(cartId, productId, quantity)
+			err := repo.UpdateProductQuantity(cartId, productId, delta)
 
 			assert.NoError(t, err)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 
-		t.Run("Success with product not added to cart", func(t *testing.T) {
+		t.Run("Success with negative delta", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
 			cartId := "1"
 			productId := "42"
-			quantity := int(25)
-
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}),
-			)
+			delta := -5
 
-			mock.ExpectExec("INSERT INTO cart_products").
-				WithArgs(cartId, productId, quantity).
+			mock.ExpectBegin()
+			expectCartStatus(mock, cartId, models.CartOpen)
+			mock.ExpectExec("INSERT INTO cart_products.*DELETE FROM cart_products").
+				WithArgs(cartId, productId, delta, cartId, productId).
 				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectStockReserved(mock, cartId)
+			expectVersionBump(mock)
+			mock.ExpectCommit()
 
-			err := repo.UpdateProductQuantity(cartId, productId, quantity)
+			err := repo.UpdateProductQuantity(cartId, productId, delta)
 
 			assert.NoError(t, err)
 			assert.NoError(t, mock.ExpectationsWereMet())
@@ -139,80 +247,65 @@ func TestCartRepo(t *testing.T) {
 
 			cartId := "1"
 			productId := "42"
-			quantity := int(25)
-			quantityAlreadyAdded := int(15)
-
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}).AddRow(cartId, productId, quantityAlreadyAdded),
-			)
+			delta := 25
 
 			expectedError := errors.New("nope")
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, cartId, models.CartOpen)
 			mock.ExpectExec("INSERT INTO cart_products").
-				WithArgs(cartId, productId, quantity+quantityAlreadyAdded).
+				WithArgs(cartId, productId, delta, cartId, productId).
 				WillReturnError(expectedError)
+			mock.ExpectRollback()
 
-			err := repo.UpdateProductQuantity(cartId, productId, quantity)
+			err := repo.UpdateProductQuantity(cartId, productId, delta)
 
 			assert.ErrorIs(t, err, expectedError)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
-	})
 
-	t.Run("RemoveProduct", func(t *testing.T) {
-		t.Run("Success with product added to cart", func(t *testing.T) {
+		t.Run("Error with unknown cart", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
-			cartId := "1"
-			productId := "42"
-			quantity := int(5)
-			quantityAlreadyAdded := int(15)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT status FROM carts`).WillReturnError(sql.ErrNoRows)
+			mock.ExpectRollback()
 
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}).AddRow(cartId, productId, quantityAlreadyAdded),
-			)
+			err := repo.UpdateProductQuantity("404", "42", 1)
 
-			mock.ExpectExec("INSERT INTO cart_products").
-				WithArgs(cartId, productId, quantityAlreadyAdded-quantity).
-				WillReturnResult(sqlmock.NewResult(1, 1))
-
-			err := repo.RemoveProduct(cartId, productId)
-			assert.NoError(t, err)
+			assert.ErrorIs(t, err, sqlite.ErrCartNotFound)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 
-		t.Run("Success removing more quantity than what is added to the cart", func(t *testing.T) {
+		t.Run("Error with closed cart", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
-			cartId := "1"
-			productId := "42"
-			quantityAlreadyAdded := uint(15)
-
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}).AddRow(cartId, productId, quantityAlreadyAdded),
-			)
+			mock.ExpectBegin()
+			expectCartStatus(mock, "1", models.CartClosed)
+			mock.ExpectRollback()
 
-			mock.ExpectExec("DELETE FROM cart_products").
-				WithArgs(cartId, productId).
-				WillReturnResult(sqlmock.NewResult(1, 1))
+			err := repo.UpdateProductQuantity("1", "42", 1)
 
-			err := repo.RemoveProduct(cartId, productId)
-			assert.NoError(t, err)
+			assert.ErrorIs(t, err, sqlite.ErrCartNotOpen)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
+	})
 
-		t.Run("Success removing with product not added to cart", func(t *testing.T) {
+	t.Run("RemoveProduct", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
 			cartId := "1"
 			productId := "42"
 
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}),
-			)
-
+			mock.ExpectBegin()
+			expectCartStatus(mock, cartId, models.CartOpen)
 			mock.ExpectExec("DELETE FROM cart_products").
 				WithArgs(cartId, productId).
 				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectStockReserved(mock, cartId)
+			expectVersionBump(mock)
+			mock.ExpectCommit()
 
 			err := repo.RemoveProduct(cartId, productId)
 			assert.NoError(t, err)
@@ -226,39 +319,56 @@ func TestCartRepo(t *testing.T) {
 			cartId := "1"
 			productId := "42"
 
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}),
-			)
-
+			mock.ExpectBegin()
+			expectCartStatus(mock, cartId, models.CartOpen)
 			mock.ExpectExec("DELETE FROM cart_products").
 				WithArgs(cartId, productId).
 				WillReturnError(expectedError)
+			mock.ExpectRollback()
 
 			err := repo.RemoveProduct(cartId, productId)
 			assert.ErrorIs(t, err, expectedError)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 
-		t.Run("Error when updating", func(t *testing.T) {
+		t.Run("Error with abandoned cart", func(t *testing.T) {
 			repo, _, mock := createCartSetup()
 
-			expectedError := errors.New("you have no power here")
-			cartId := "1"
-			productId := "42"
-			quantity := uint(25)
-			quantityAlreadyAdded := uint(50)
+			mock.ExpectBegin()
+			expectCartStatus(mock, "1", models.CartAbandoned)
+			mock.ExpectRollback()
 
-			mock.ExpectQuery("SELECT cart_id,product_id,quantity FROM cart_products").WillReturnRows(
-				sqlmock.NewRows([]string{"cart_id", "product_id", "quantity"}).
-					AddRow(cartId, productId, quantityAlreadyAdded),
-			)
+			err := repo.RemoveProduct("1", "42")
+			assert.ErrorIs(t, err, sqlite.ErrCartNotOpen)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
 
-			mock.ExpectExec("INSERT INTO cart_products").
-				WithArgs(cartId, productId, quantityAlreadyAdded-quantity).
-				WillReturnError(expectedError)
+	t.Run("EmptyCart", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
 
-			err := repo.RemoveProduct(cartId, productId)
-			assert.ErrorIs(t, err, expectedError)
+			mock.ExpectBegin()
+			expectCartStatus(mock, "1", models.CartOpen)
+			mock.ExpectExec("DELETE FROM cart_products WHERE cart_id = ?").
+				WithArgs("1").
+				WillReturnResult(sqlmock.NewResult(0, 4))
+			expectStockReserved(mock, "1")
+			expectVersionBump(mock)
+			mock.ExpectCommit()
+
+			assert.NoError(t, repo.EmptyCart("1"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with paid cart", func(t *testing.T) {
+			repo, _, mock := createCartSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "1", models.CartPaid)
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.EmptyCart("1"), sqlite.ErrCartNotOpen)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	})
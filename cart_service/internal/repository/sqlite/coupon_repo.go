@@ -0,0 +1,248 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrCouponNotFound       = repository.ErrCouponNotFound
+	ErrCouponAlreadyExists  = repository.ErrCouponAlreadyExists
+	ErrCouponAlreadyApplied = repository.ErrCouponAlreadyApplied
+	ErrCouponNotApplied     = repository.ErrCouponNotApplied
+)
+
+const couponColumns = `code, description, kind, percent, amount, minimum_basket, currency, max_uses, uses, single_use_per_customer, expires_at`
+
+type couponRepository struct {
+	db *sql.DB
+}
+
+func NewCouponRepository(db *sql.DB) repository.CouponRepository {
+	return &couponRepository{db}
+}
+
+func (c *couponRepository) CreateCoupon(coupon *models.Coupon) error {
+	const query = `INSERT INTO coupons (` + couponColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := c.db.Exec(
+		query, coupon.Code, coupon.Description, coupon.Kind, coupon.Percent,
+		coupon.Amount.Amount, coupon.MinimumBasket.Amount, coupon.Amount.Currency,
+		coupon.MaxUses, coupon.Uses, coupon.SingleUsePerCustomer, nullTime(coupon.ExpiresAt),
+	)
+	if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+		return ErrCouponAlreadyExists
+	}
+
+	return err
+}
+
+func (c *couponRepository) GetCoupon(code string) (*models.Coupon, error) {
+	const query = `SELECT ` + couponColumns + ` FROM coupons WHERE code = ?`
+
+	coupon, err := scanCoupon(c.db.QueryRow(query, code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+func (c *couponRepository) ListCoupons() ([]*models.Coupon, error) {
+	const query = `SELECT ` + couponColumns + ` FROM coupons ORDER BY created_at, code`
+
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := make([]*models.Coupon, 0)
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, rows.Err()
+}
+
+func (c *couponRepository) ApplyToCart(cartId string, code string, customerId *string) error {
+	const query = `INSERT INTO cart_coupons (cart_id, code, customer_id) VALUES (?, ?, ?)`
+
+	return inOpenCart(c.db, cartId, func(tx *sql.Tx) error {
+		_, err := tx.Exec(query, cartId, code, customerId)
+		if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+			return ErrCouponAlreadyApplied
+		}
+		return err
+	})
+}
+
+func (c *couponRepository) RemoveFromCart(cartId string, code string) error {
+	const query = `DELETE FROM cart_coupons WHERE cart_id = ? AND code = ?`
+
+	return inOpenCart(c.db, cartId, func(tx *sql.Tx) error {
+		result, err := tx.Exec(query, cartId, code)
+		if err != nil {
+			return err
+		}
+		return expectAffected(result, ErrCouponNotApplied)
+	})
+}
+
+func (c *couponRepository) ListCartCoupons(cartId string) ([]*models.CartCoupon, error) {
+	return getCartCoupons(c.db, cartId)
+}
+
+func (c *couponRepository) UsedByCustomer(code string, customerId string) (bool, error) {
+	return usedByCustomer(c.db, code, customerId)
+}
+
+// getCartCoupons returns the coupons applied to the cart, leaving out the single use ones
+// their customer redeemed since, so the cart and its order are priced without them.
+func getCartCoupons(db querier, cartId string) ([]*models.CartCoupon, error) {
+	const query = `
+        SELECT
+          c.code, c.description, c.kind, c.percent, c.amount, c.minimum_basket, c.currency,
+          c.max_uses, c.uses, c.single_use_per_customer, c.expires_at, cc.customer_id
+        FROM
+          cart_coupons cc
+          JOIN coupons c ON cc.code = c.code
+        WHERE
+          cc.cart_id = ?
+          AND NOT (
+            c.single_use_per_customer
+            AND EXISTS (SELECT 1 FROM coupon_redemptions r WHERE r.code = cc.code AND r.customer_id = cc.customer_id)
+          )
+        ORDER BY
+          cc.created_at, cc.code
+    `
+
+	rows, err := db.Query(query, cartId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cartCoupons := make([]*models.CartCoupon, 0)
+	for rows.Next() {
+		var customerId sql.NullString
+		coupon, err := scanCoupon(rows, &customerId)
+		if err != nil {
+			return nil, err
+		}
+
+		cartCoupon := &models.CartCoupon{Coupon: coupon}
+		if customerId.Valid {
+			cartCoupon.CustomerID = &customerId.String
+		}
+		cartCoupons = append(cartCoupons, cartCoupon)
+	}
+
+	return cartCoupons, rows.Err()
+}
+
+func usedByCustomer(db querier, code string, customerId string) (bool, error) {
+	const query = `SELECT COUNT(*) FROM coupon_redemptions WHERE code = ? AND customer_id = ?`
+
+	var count int
+	if err := db.QueryRow(query, code, customerId).Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// redeemCoupons records the coupons that took money off the order and counts their uses. The order
+// is priced in the same transaction without the coupons that ran out, so running out here fails the
+// checkout, before the payment is captured, only if another one got the last use first.
+func redeemCoupons(tx *sql.Tx, order *models.Order, cartCoupons []*models.CartCoupon) error {
+	const useQuery = `UPDATE coupons SET uses = uses + 1 WHERE code = ? AND (max_uses = 0 OR uses < max_uses)`
+	const redemptionQuery = `INSERT INTO coupon_redemptions (code, order_id, customer_id, discount) VALUES (?, ?, ?, ?)`
+
+	customers := make(map[string]*string, len(cartCoupons))
+	for _, cc := range cartCoupons {
+		customers[cc.Code] = cc.CustomerID
+	}
+
+	for _, applied := range order.Coupons {
+		customerId := customers[applied.Code]
+
+		result, err := tx.Exec(useQuery, applied.Code)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(result, pricing.ErrCouponExhausted); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(redemptionQuery, applied.Code, order.ID, customerId, applied.Discount.Amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unredeemCoupons applies the coupons redeemed by the order to the cart again, giving their uses back.
+func unredeemCoupons(tx *sql.Tx, cartId string, orderId string) error {
+	const applyQuery = `INSERT INTO cart_coupons (cart_id, code, customer_id) SELECT ?, code, customer_id FROM coupon_redemptions WHERE order_id = ?`
+	const useQuery = `UPDATE coupons SET uses = uses - 1 WHERE code IN (SELECT code FROM coupon_redemptions WHERE order_id = ?)`
+	const redemptionQuery = `DELETE FROM coupon_redemptions WHERE order_id = ?`
+
+	if _, err := tx.Exec(applyQuery, cartId, orderId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(useQuery, orderId); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(redemptionQuery, orderId)
+	return err
+}
+
+func removeCartCoupons(tx *sql.Tx, cartId string) error {
+	const query = `DELETE FROM cart_coupons WHERE cart_id = ?`
+
+	_, err := tx.Exec(query, cartId)
+	return err
+}
+
+// scanCoupon reads the coupon columns followed by any extra ones.
+func scanCoupon(row scanner, extra ...interface{}) (*models.Coupon, error) {
+	var (
+		coupon    models.Coupon
+		currency  string
+		expiresAt sql.NullTime
+	)
+
+	dest := []interface{}{
+		&coupon.Code, &coupon.Description, &coupon.Kind, &coupon.Percent,
+		&coupon.Amount.Amount, &coupon.MinimumBasket.Amount, &currency,
+		&coupon.MaxUses, &coupon.Uses, &coupon.SingleUsePerCustomer, &expiresAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	coupon.Amount.Currency = money.Currency(currency)
+	coupon.MinimumBasket.Currency = money.Currency(currency)
+	if expiresAt.Valid {
+		coupon.ExpiresAt = &expiresAt.Time
+	}
+
+	return &coupon, nil
+}
@@ -0,0 +1,186 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createCouponSetup() (repository.CouponRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewCouponRepository(db), db, mock
+}
+
+var couponColumns = []string{
+	"code", "description", "kind", "percent", "amount", "minimum_basket", "currency",
+	"max_uses", "uses", "single_use_per_customer", "expires_at",
+}
+
+func TestCouponRepo(t *testing.T) {
+	expiresAt := time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	coupon := &models.Coupon{
+		Code:                 "WELCOME10",
+		Description:          "10% off your first purchase",
+		Kind:                 models.CouponPercentage,
+		Percent:              10,
+		Amount:               money.Cents(0),
+		MinimumBasket:        money.Cents(2000),
+		SingleUsePerCustomer: true,
+		ExpiresAt:            &expiresAt,
+	}
+
+	customerId := "c1"
+
+	t.Run("CreateCoupon", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectExec(`INSERT INTO coupons`).
+				WithArgs(
+					coupon.Code, coupon.Description, coupon.Kind, 10, 0, 2000, money.BRL,
+					0, 0, true, sql.NullTime{Time: expiresAt, Valid: true},
+				).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			assert.NoError(t, repo.CreateCoupon(coupon))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with duplicated code", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectExec(`INSERT INTO coupons`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey})
+
+			assert.ErrorIs(t, repo.CreateCoupon(coupon), sqlite.ErrCouponAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetCoupon", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM coupons WHERE code = ?`).
+				WithArgs(coupon.Code).
+				WillReturnRows(sqlmock.NewRows(couponColumns).
+					AddRow(coupon.Code, coupon.Description, "percentage", 10, 0, 2000, "BRL", 0, 0, true, expiresAt))
+
+			got, err := repo.GetCoupon(coupon.Code)
+			require.NoError(t, err)
+			assert.Equal(t, coupon, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown code", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM coupons WHERE code = ?`).
+				WithArgs("NOPE").
+				WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.GetCoupon("NOPE")
+			assert.ErrorIs(t, err, sqlite.ErrCouponNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ApplyToCart", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartOpen)
+			mock.ExpectExec(`INSERT INTO cart_coupons`).
+				WithArgs("2", coupon.Code, &customerId).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectVersionBump(mock)
+			mock.ExpectCommit()
+
+			assert.NoError(t, repo.ApplyToCart("2", coupon.Code, &customerId))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error when already applied", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartOpen)
+			mock.ExpectExec(`INSERT INTO cart_coupons`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey})
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.ApplyToCart("2", coupon.Code, nil), sqlite.ErrCouponAlreadyApplied)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with closed cart", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartClosed)
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.ApplyToCart("2", coupon.Code, nil), sqlite.ErrCartNotOpen)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("RemoveFromCart", func(t *testing.T) {
+		t.Run("Error when not applied", func(t *testing.T) {
+			repo, _, mock := createCouponSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartOpen)
+			mock.ExpectExec(`DELETE FROM cart_coupons WHERE cart_id = \? AND code = \?`).
+				WithArgs("2", coupon.Code).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.RemoveFromCart("2", coupon.Code), sqlite.ErrCouponNotApplied)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListCartCoupons", func(t *testing.T) {
+		repo, _, mock := createCouponSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM cart_coupons cc JOIN coupons c`).
+			WithArgs("2").
+			WillReturnRows(sqlmock.NewRows(append(couponColumns, "cc.customer_id")).
+				AddRow(coupon.Code, coupon.Description, "percentage", 10, 0, 2000, "BRL", 0, 0, true, expiresAt, customerId).
+				AddRow("ZCART5", "", "fixed", 0, 500, 0, "BRL", 100, 3, false, nil, nil))
+
+		cartCoupons, err := repo.ListCartCoupons("2")
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		require.Len(t, cartCoupons, 2)
+		assert.Equal(t, &models.CartCoupon{Coupon: coupon, CustomerID: &customerId}, cartCoupons[0])
+		assert.Nil(t, cartCoupons[1].CustomerID)
+		assert.Equal(t, money.Cents(500), cartCoupons[1].Amount)
+	})
+
+	t.Run("UsedByCustomer", func(t *testing.T) {
+		repo, _, mock := createCouponSetup()
+
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM coupon_redemptions WHERE code = \? AND customer_id = \?`).
+			WithArgs(coupon.Code, customerId).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		used, err := repo.UsedByCustomer(coupon.Code, customerId)
+		require.NoError(t, err)
+		assert.True(t, used)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
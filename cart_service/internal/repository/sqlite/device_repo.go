@@ -0,0 +1,194 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrDeviceNotFound      = repository.ErrDeviceNotFound
+	ErrDeviceAlreadyExists = repository.ErrDeviceAlreadyExists
+	ErrCartAlreadyBound    = repository.ErrCartAlreadyBound
+	ErrDeviceHealthChanged = repository.ErrDeviceHealthChanged
+)
+
+const deviceColumns = `id, name, secret_hash, cart_id, bound_at, health, health_since, created_at`
+
+type deviceRepository struct {
+	db *sql.DB
+}
+
+func NewDeviceRepository(db *sql.DB) repository.DeviceRepository {
+	return &deviceRepository{db}
+}
+
+func (d *deviceRepository) CreateDevice(device *models.Device) error {
+	const query = `INSERT INTO devices (id, name, secret_hash, health, created_at) VALUES (?, ?, ?, ?, ?)`
+
+	_, err := d.db.Exec(query, device.ID, device.Name, device.SecretHash, device.Health, device.CreatedAt)
+	if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+		return ErrDeviceAlreadyExists
+	}
+
+	return err
+}
+
+func (d *deviceRepository) GetDevice(deviceId string) (*models.Device, error) {
+	return getDevice(d.db, deviceId)
+}
+
+func (d *deviceRepository) ListDevices() ([]*models.Device, error) {
+	const query = `SELECT ` + deviceColumns + ` FROM devices ORDER BY id`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := make([]*models.Device, 0)
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, rows.Err()
+}
+
+func (d *deviceRepository) BindCart(deviceId string, cartId string, at time.Time) (*models.Device, error) {
+	const query = `UPDATE devices SET cart_id = ?, bound_at = ? WHERE id = ?`
+
+	var device *models.Device
+
+	err := inTx(d.db, func(tx *sql.Tx) error {
+		status, err := getCartStatus(tx, cartId)
+		if err != nil {
+			return err
+		}
+		if status != models.CartOpen {
+			return ErrCartNotOpen
+		}
+
+		result, err := tx.Exec(query, cartId, at, deviceId)
+		if isConstraintError(err, sqlite3.ErrConstraintUnique) {
+			return ErrCartAlreadyBound
+		}
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(result, ErrDeviceNotFound); err != nil {
+			return err
+		}
+
+		device, err = getDevice(tx, deviceId)
+		return err
+	})
+
+	return device, err
+}
+
+func (d *deviceRepository) UnbindCart(deviceId string) (*models.Device, error) {
+	const query = `UPDATE devices SET cart_id = NULL, bound_at = NULL WHERE id = ?`
+
+	var device *models.Device
+
+	err := inTx(d.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(query, deviceId)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(result, ErrDeviceNotFound); err != nil {
+			return err
+		}
+
+		device, err = getDevice(tx, deviceId)
+		return err
+	})
+
+	return device, err
+}
+
+func (d *deviceRepository) ReleaseCart(cartId string) (bool, error) {
+	const query = `UPDATE devices SET cart_id = NULL, bound_at = NULL WHERE cart_id = ?`
+
+	result, err := d.db.Exec(query, cartId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (d *deviceRepository) UpdateHealth(deviceId string, from models.DeviceHealth, to models.DeviceHealth, since time.Time) error {
+	const query = `UPDATE devices SET health = ?, health_since = ? WHERE id = ? AND health = ?`
+
+	result, err := d.db.Exec(query, to, since, deviceId, from)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// Either the device does not exist or someone else changed its health first
+	if _, err := getDevice(d.db, deviceId); err != nil {
+		return err
+	}
+
+	return ErrDeviceHealthChanged
+}
+
+func getDevice(db querier, deviceId string) (*models.Device, error) {
+	const query = `SELECT ` + deviceColumns + ` FROM devices WHERE id = ?`
+
+	device, err := scanDevice(db.QueryRow(query, deviceId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+
+	return device, nil
+}
+
+func scanDevice(row scanner) (*models.Device, error) {
+	var (
+		device      models.Device
+		cartId      sql.NullString
+		boundAt     sql.NullTime
+		healthSince sql.NullTime
+	)
+
+	if err := row.Scan(
+		&device.ID, &device.Name, &device.SecretHash, &cartId, &boundAt,
+		&device.Health, &healthSince, &device.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if cartId.Valid {
+		device.CartID = &cartId.String
+	}
+	if boundAt.Valid {
+		device.BoundAt = &boundAt.Time
+	}
+	if healthSince.Valid {
+		device.HealthSince = &healthSince.Time
+	}
+
+	return &device, nil
+}
@@ -0,0 +1,236 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createDeviceSetup() (repository.DeviceRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewDeviceRepository(db), db, mock
+}
+
+var deviceColumns = []string{"id", "name", "secret_hash", "cart_id", "bound_at", "health", "health_since", "created_at"}
+
+func TestDeviceRepo(t *testing.T) {
+	now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	t.Run("CreateDevice", func(t *testing.T) {
+		device := &models.Device{ID: "cart-01", Name: "Cart 01", SecretHash: "hash", Health: models.DeviceOffline, CreatedAt: now}
+
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectExec(`INSERT INTO devices \(id, name, secret_hash, health, created_at\)`).
+				WithArgs("cart-01", "Cart 01", "hash", models.DeviceOffline, now).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			assert.NoError(t, repo.CreateDevice(device))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with a taken id", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectExec(`INSERT INTO devices`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey})
+
+			assert.ErrorIs(t, repo.CreateDevice(device), sqlite.ErrDeviceAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetDevice", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM devices WHERE id = ?`).
+				WithArgs("cart-01").
+				WillReturnRows(sqlmock.NewRows(deviceColumns).AddRow("cart-01", "Cart 01", "hash", "2", now, "online", now, now))
+
+			device, err := repo.GetDevice("cart-01")
+			require.NoError(t, err)
+
+			cartId := "2"
+			assert.Equal(t, &models.Device{
+				ID:          "cart-01",
+				Name:        "Cart 01",
+				SecretHash:  "hash",
+				CartID:      &cartId,
+				BoundAt:     &now,
+				Health:      models.DeviceOnline,
+				HealthSince: &now,
+				CreatedAt:   now,
+			}, device)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown device", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectQuery(`FROM devices`).WillReturnRows(sqlmock.NewRows(deviceColumns))
+
+			_, err := repo.GetDevice("404")
+			assert.ErrorIs(t, err, sqlite.ErrDeviceNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListDevices", func(t *testing.T) {
+		repo, _, mock := createDeviceSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM devices ORDER BY id`).
+			WillReturnRows(sqlmock.NewRows(deviceColumns).
+				AddRow("cart-01", "Cart 01", "hash", "2", now, "online", now, now).
+				AddRow("cart-02", "Cart 02", "hash", nil, nil, "offline", nil, now))
+
+		devices, err := repo.ListDevices()
+		require.NoError(t, err)
+		require.Len(t, devices, 2)
+		assert.Nil(t, devices[1].CartID)
+		assert.Nil(t, devices[1].BoundAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("BindCart", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "3", models.CartOpen)
+			mock.ExpectExec(`UPDATE devices SET cart_id = \?, bound_at = \? WHERE id = \?`).
+				WithArgs("3", now, "cart-01").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(`FROM devices WHERE id = ?`).
+				WithArgs("cart-01").
+				WillReturnRows(sqlmock.NewRows(deviceColumns).AddRow("cart-01", "Cart 01", "hash", "3", now, "online", now, now))
+			mock.ExpectCommit()
+
+			device, err := repo.BindCart("cart-01", "3", now)
+			require.NoError(t, err)
+			require.NotNil(t, device.CartID)
+			assert.Equal(t, "3", *device.CartID)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with a cart that is not open", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "3", models.CartPaid)
+			mock.ExpectRollback()
+
+			_, err := repo.BindCart("cart-01", "3", now)
+			assert.ErrorIs(t, err, sqlite.ErrCartNotOpen)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with a cart bound to another device", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "3", models.CartOpen)
+			mock.ExpectExec(`UPDATE devices`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique})
+			mock.ExpectRollback()
+
+			_, err := repo.BindCart("cart-02", "3", now)
+			assert.ErrorIs(t, err, sqlite.ErrCartAlreadyBound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown device", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "3", models.CartOpen)
+			mock.ExpectExec(`UPDATE devices`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			_, err := repo.BindCart("404", "3", now)
+			assert.ErrorIs(t, err, sqlite.ErrDeviceNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("UnbindCart", func(t *testing.T) {
+		repo, _, mock := createDeviceSetup()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE devices SET cart_id = NULL, bound_at = NULL WHERE id = \?`).
+			WithArgs("cart-01").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`FROM devices WHERE id = ?`).
+			WillReturnRows(sqlmock.NewRows(deviceColumns).AddRow("cart-01", "Cart 01", "hash", nil, nil, "offline", nil, now))
+		mock.ExpectCommit()
+
+		device, err := repo.UnbindCart("cart-01")
+		require.NoError(t, err)
+		assert.Nil(t, device.CartID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("UpdateHealth", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectExec(`UPDATE devices SET health = \?, health_since = \? WHERE id = \? AND health = \?`).
+				WithArgs(models.DeviceDegraded, now, "cart-01", models.DeviceOnline).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			assert.NoError(t, repo.UpdateHealth("cart-01", models.DeviceOnline, models.DeviceDegraded, now))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error when changed by someone else", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectExec(`UPDATE devices`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`FROM devices WHERE id = ?`).
+				WithArgs("cart-01").
+				WillReturnRows(sqlmock.NewRows(deviceColumns).AddRow("cart-01", "Cart 01", "hash", nil, nil, "offline", now, now))
+
+			assert.ErrorIs(t, repo.UpdateHealth("cart-01", models.DeviceOnline, models.DeviceOffline, now), sqlite.ErrDeviceHealthChanged)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown device", func(t *testing.T) {
+			repo, _, mock := createDeviceSetup()
+
+			mock.ExpectExec(`UPDATE devices`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`FROM devices WHERE id = ?`).WillReturnRows(sqlmock.NewRows(deviceColumns))
+
+			assert.ErrorIs(t, repo.UpdateHealth("404", models.DeviceOffline, models.DeviceOnline, now), sqlite.ErrDeviceNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ReleaseCart", func(t *testing.T) {
+		repo, _, mock := createDeviceSetup()
+
+		mock.ExpectExec(`UPDATE devices SET cart_id = NULL, bound_at = NULL WHERE cart_id = \?`).
+			WithArgs("2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE devices`).
+			WithArgs("3").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		released, err := repo.ReleaseCart("2")
+		require.NoError(t, err)
+		assert.True(t, released)
+
+		released, err = repo.ReleaseCart("3")
+		require.NoError(t, err)
+		assert.False(t, released)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
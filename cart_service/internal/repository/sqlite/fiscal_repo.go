@@ -0,0 +1,125 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrFiscalDocumentNotFound      = repository.ErrFiscalDocumentNotFound
+	ErrFiscalDocumentAlreadyExists = repository.ErrFiscalDocumentAlreadyExists
+)
+
+const fiscalColumns = `order_id, access_key, series, number, status, xml, protocol, status_message, created_at, updated_at`
+
+type fiscalRepository struct {
+	db *sql.DB
+}
+
+func NewFiscalRepository(db *sql.DB) repository.FiscalRepository {
+	return &fiscalRepository{db}
+}
+
+func (f *fiscalRepository) CreateDocument(orderId string, series uint, build func(number uint) (*models.FiscalDocument, error)) (*models.FiscalDocument, error) {
+	const (
+		exists = `SELECT COUNT(*) FROM fiscal_documents WHERE order_id = ?`
+		next   = `SELECT COALESCE(MAX(number), 0) + 1 FROM fiscal_documents WHERE series = ?`
+		insert = `INSERT INTO fiscal_documents (` + fiscalColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	)
+
+	var document *models.FiscalDocument
+
+	err := inTx(f.db, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(exists, orderId).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrFiscalDocumentAlreadyExists
+		}
+
+		var number uint
+		if err := tx.QueryRow(next, series).Scan(&number); err != nil {
+			return err
+		}
+
+		var err error
+		document, err = build(number)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		document.CreatedAt = now
+		document.UpdatedAt = now
+
+		_, err = tx.Exec(
+			insert, document.OrderID, document.AccessKey, document.Series, document.Number, document.Status,
+			string(document.XML), document.Protocol, document.StatusMessage, document.CreatedAt, document.UpdatedAt,
+		)
+		if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+			return ErrFiscalDocumentAlreadyExists
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+func (f *fiscalRepository) GetDocument(orderId string) (*models.FiscalDocument, error) {
+	const query = `SELECT ` + fiscalColumns + ` FROM fiscal_documents WHERE order_id = ?`
+
+	document, err := scanFiscalDocument(f.db.QueryRow(query, orderId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrFiscalDocumentNotFound
+		}
+		return nil, err
+	}
+
+	return document, nil
+}
+
+func (f *fiscalRepository) UpdateDocument(document *models.FiscalDocument) error {
+	const query = `UPDATE fiscal_documents SET status = ?, xml = ?, protocol = ?, status_message = ?, updated_at = ? WHERE order_id = ?`
+
+	document.UpdatedAt = time.Now().UTC()
+
+	result, err := f.db.Exec(query, document.Status, string(document.XML), document.Protocol, document.StatusMessage, document.UpdatedAt, document.OrderID)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrFiscalDocumentNotFound)
+}
+
+func scanFiscalDocument(row scanner) (*models.FiscalDocument, error) {
+	var (
+		document                models.FiscalDocument
+		protocol, statusMessage sql.NullString
+	)
+
+	if err := row.Scan(
+		&document.OrderID, &document.AccessKey, &document.Series, &document.Number, &document.Status,
+		&document.XML, &protocol, &statusMessage, &document.CreatedAt, &document.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if protocol.Valid {
+		document.Protocol = &protocol.String
+	}
+	if statusMessage.Valid {
+		document.StatusMessage = &statusMessage.String
+	}
+
+	return &document, nil
+}
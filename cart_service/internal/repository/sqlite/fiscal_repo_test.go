@@ -0,0 +1,138 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createFiscalSetup() (repository.FiscalRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewFiscalRepository(db), db, mock
+}
+
+var fiscalColumns = []string{
+	"order_id", "access_key", "series", "number", "status", "xml", "protocol", "status_message", "created_at", "updated_at",
+}
+
+func TestFiscalRepo(t *testing.T) {
+	const accessKey = "35221111222333000181650010000000421627855192"
+
+	build := func(number uint) (*models.FiscalDocument, error) {
+		return &models.FiscalDocument{
+			OrderID:   "o1",
+			AccessKey: accessKey,
+			Series:    1,
+			Number:    number,
+			Status:    models.FiscalSigned,
+			XML:       []byte("<NFe/>"),
+		}, nil
+	}
+
+	t.Run("CreateDocument", func(t *testing.T) {
+		t.Run("Success with the next number of the series", func(t *testing.T) {
+			repo, _, mock := createFiscalSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT COUNT\(\*\) FROM fiscal_documents WHERE order_id = ?`).
+				WithArgs("o1").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			mock.ExpectQuery(`SELECT COALESCE\(MAX\(number\), 0\) \+ 1 FROM fiscal_documents WHERE series = ?`).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow(42))
+			mock.ExpectExec(`INSERT INTO fiscal_documents`).
+				WithArgs("o1", accessKey, 1, 42, models.FiscalSigned, "<NFe/>", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+
+			document, err := repo.CreateDocument("o1", 1, build)
+			require.NoError(t, err)
+			assert.Equal(t, uint(42), document.Number)
+			assert.False(t, document.CreatedAt.IsZero())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error when the order already has a document", func(t *testing.T) {
+			repo, _, mock := createFiscalSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT COUNT\(\*\) FROM fiscal_documents`).
+				WithArgs("o1").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectRollback()
+
+			_, err := repo.CreateDocument("o1", 1, build)
+			assert.ErrorIs(t, err, sqlite.ErrFiscalDocumentAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error building the document", func(t *testing.T) {
+			repo, _, mock := createFiscalSetup()
+			expectedError := errors.New("product is missing fiscal data")
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT COUNT\(\*\) FROM fiscal_documents`).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			mock.ExpectQuery(`SELECT COALESCE`).
+				WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow(1))
+			mock.ExpectRollback()
+
+			_, err := repo.CreateDocument("o1", 1, func(uint) (*models.FiscalDocument, error) {
+				return nil, expectedError
+			})
+			assert.ErrorIs(t, err, expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetDocument", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createFiscalSetup()
+			now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+			mock.ExpectQuery(`SELECT .* FROM fiscal_documents WHERE order_id = ?`).
+				WithArgs("o1").
+				WillReturnRows(sqlmock.NewRows(fiscalColumns).
+					AddRow("o1", accessKey, 1, 42, "authorized", "<nfeProc/>", "135220000000001", "100 - Autorizado o uso da NF-e", now, now))
+
+			document, err := repo.GetDocument("o1")
+			require.NoError(t, err)
+			assert.Equal(t, models.FiscalAuthorized, document.Status)
+			assert.Equal(t, []byte("<nfeProc/>"), document.XML)
+			require.NotNil(t, document.Protocol)
+			assert.Equal(t, "135220000000001", *document.Protocol)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown order", func(t *testing.T) {
+			repo, _, mock := createFiscalSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM fiscal_documents`).WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.GetDocument("o2")
+			assert.ErrorIs(t, err, sqlite.ErrFiscalDocumentNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("UpdateDocument", func(t *testing.T) {
+		repo, _, mock := createFiscalSetup()
+		document, _ := build(42)
+		document.Status = models.FiscalRejected
+
+		mock.ExpectExec(`UPDATE fiscal_documents SET status = \?, xml = \?, protocol = \?, status_message = \?, updated_at = \? WHERE order_id = \?`).
+			WithArgs(models.FiscalRejected, "<NFe/>", nil, nil, sqlmock.AnyArg(), "o1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.UpdateDocument(document), sqlite.ErrFiscalDocumentNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
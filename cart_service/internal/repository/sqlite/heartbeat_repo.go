@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+)
+
+const heartbeatColumns = `id, device_id, camera_fps, detector_latency_ms, scale_grams, battery_percent, received_at`
+
+type heartbeatRepository struct {
+	db *sql.DB
+}
+
+func NewHeartbeatRepository(db *sql.DB) repository.HeartbeatRepository {
+	return &heartbeatRepository{db}
+}
+
+func (h *heartbeatRepository) CreateHeartbeat(heartbeat *models.Heartbeat) error {
+	const query = `
+        INSERT INTO
+          device_heartbeats (device_id, camera_fps, detector_latency_ms, scale_grams, battery_percent, received_at)
+        VALUES
+          (?, ?, ?, ?, ?, ?)
+    `
+
+	result, err := h.db.Exec(
+		query, heartbeat.DeviceID, heartbeat.CameraFPS, heartbeat.DetectorLatencyMs,
+		heartbeat.ScaleGrams, heartbeat.BatteryPercent, heartbeat.ReceivedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	heartbeat.ID, err = result.LastInsertId()
+	return err
+}
+
+func (h *heartbeatRepository) LastHeartbeats() (map[string]*models.Heartbeat, error) {
+	const query = `
+        SELECT ` + heartbeatColumns + `
+        FROM device_heartbeats
+        WHERE id IN (SELECT MAX(id) FROM device_heartbeats GROUP BY device_id)
+    `
+
+	heartbeats, err := queryHeartbeats(h.db, query)
+	if err != nil {
+		return nil, err
+	}
+
+	byDevice := make(map[string]*models.Heartbeat, len(heartbeats))
+	for _, heartbeat := range heartbeats {
+		byDevice[heartbeat.DeviceID] = heartbeat
+	}
+
+	return byDevice, nil
+}
+
+func (h *heartbeatRepository) ListHeartbeats(deviceId string, limit int) ([]*models.Heartbeat, error) {
+	const query = `SELECT ` + heartbeatColumns + ` FROM device_heartbeats WHERE device_id = ? ORDER BY id DESC LIMIT ?`
+
+	return queryHeartbeats(h.db, query, deviceId, limit)
+}
+
+func (h *heartbeatRepository) PruneHeartbeats(before time.Time) (int64, error) {
+	const query = `DELETE FROM device_heartbeats WHERE received_at < ?`
+
+	result, err := h.db.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func queryHeartbeats(db querier, query string, args ...interface{}) ([]*models.Heartbeat, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heartbeats := make([]*models.Heartbeat, 0)
+	for rows.Next() {
+		var heartbeat models.Heartbeat
+		if err := rows.Scan(
+			&heartbeat.ID, &heartbeat.DeviceID, &heartbeat.CameraFPS, &heartbeat.DetectorLatencyMs,
+			&heartbeat.ScaleGrams, &heartbeat.BatteryPercent, &heartbeat.ReceivedAt,
+		); err != nil {
+			return nil, err
+		}
+		heartbeats = append(heartbeats, &heartbeat)
+	}
+
+	return heartbeats, rows.Err()
+}
@@ -0,0 +1,102 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createHeartbeatSetup() (repository.HeartbeatRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewHeartbeatRepository(db), db, mock
+}
+
+var heartbeatColumns = []string{"id", "device_id", "camera_fps", "detector_latency_ms", "scale_grams", "battery_percent", "received_at"}
+
+func TestHeartbeatRepo(t *testing.T) {
+	now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	fps, latency, grams := 12.0, 80.0, 387.0
+
+	t.Run("CreateHeartbeat", func(t *testing.T) {
+		repo, _, mock := createHeartbeatSetup()
+
+		heartbeat := &models.Heartbeat{
+			DeviceID:          "cart-01",
+			CameraFPS:         &fps,
+			DetectorLatencyMs: &latency,
+			ScaleGrams:        &grams,
+			ReceivedAt:        now,
+		}
+
+		mock.ExpectExec(`INSERT INTO device_heartbeats`).
+			WithArgs("cart-01", 12.0, 80.0, 387.0, nil, now).
+			WillReturnResult(sqlmock.NewResult(5, 1))
+
+		require.NoError(t, repo.CreateHeartbeat(heartbeat))
+		assert.Equal(t, int64(5), heartbeat.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("LastHeartbeats", func(t *testing.T) {
+		repo, _, mock := createHeartbeatSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM device_heartbeats WHERE id IN \(SELECT MAX\(id\) FROM device_heartbeats GROUP BY device_id\)`).
+			WillReturnRows(sqlmock.NewRows(heartbeatColumns).
+				AddRow(5, "cart-01", 12.0, 80.0, 387.0, nil, now).
+				AddRow(7, "cart-02", nil, nil, nil, 15.0, now))
+
+		heartbeats, err := repo.LastHeartbeats()
+		require.NoError(t, err)
+		require.Len(t, heartbeats, 2)
+		assert.Equal(t, &models.Heartbeat{
+			ID:                5,
+			DeviceID:          "cart-01",
+			CameraFPS:         &fps,
+			DetectorLatencyMs: &latency,
+			ScaleGrams:        &grams,
+			ReceivedAt:        now,
+		}, heartbeats["cart-01"])
+		assert.Nil(t, heartbeats["cart-02"].CameraFPS)
+		assert.Equal(t, 15.0, *heartbeats["cart-02"].BatteryPercent)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ListHeartbeats", func(t *testing.T) {
+		repo, _, mock := createHeartbeatSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM device_heartbeats WHERE device_id = \? ORDER BY id DESC LIMIT \?`).
+			WithArgs("cart-01", 10).
+			WillReturnRows(sqlmock.NewRows(heartbeatColumns).
+				AddRow(5, "cart-01", 12.0, 80.0, 387.0, nil, now).
+				AddRow(4, "cart-01", 11.0, 85.0, 387.0, nil, now.Add(-10*time.Second)))
+
+		heartbeats, err := repo.ListHeartbeats("cart-01", 10)
+		require.NoError(t, err)
+		require.Len(t, heartbeats, 2)
+		assert.Equal(t, int64(4), heartbeats[1].ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("PruneHeartbeats", func(t *testing.T) {
+		repo, _, mock := createHeartbeatSetup()
+
+		before := now.Add(-24 * time.Hour)
+
+		mock.ExpectExec(`DELETE FROM device_heartbeats WHERE received_at < \?`).
+			WithArgs(before).
+			WillReturnResult(sqlmock.NewResult(0, 42))
+
+		pruned, err := repo.PruneHeartbeats(before)
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), pruned)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
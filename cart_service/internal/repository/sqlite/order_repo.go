@@ -0,0 +1,413 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+)
+
+var (
+	ErrOrderNotFound   = repository.ErrOrderNotFound
+	ErrOrderNotPending = repository.ErrOrderNotPending
+	ErrCartEmpty       = repository.ErrCartEmpty
+)
+
+type orderRepository struct {
+	db         *sql.DB
+	calculator *pricing.Calculator
+}
+
+// NewOrderRepository prices the orders it creates with calculator, the same one used for the carts.
+func NewOrderRepository(db *sql.DB, calculator *pricing.Calculator) repository.OrderRepository {
+	return &orderRepository{db, calculator}
+}
+
+func (o *orderRepository) CreateFromCart(orderId string, cartId string, check func(*models.Order) error) (*models.Order, error) {
+	var order *models.Order
+
+	err := inCart(o.db, cartId, models.CartCheckingOut, ErrCartNotCheckingOut, func(tx *sql.Tx) error {
+		cartProducts, err := getCartProducts(tx, cartId)
+		if err != nil {
+			return err
+		}
+
+		if len(cartProducts) == 0 {
+			return ErrCartEmpty
+		}
+
+		cartCoupons, err := getCartCoupons(tx, cartId)
+		if err != nil {
+			return err
+		}
+
+		totals, err := o.calculator.Price(cartProducts, models.CouponsOf(cartCoupons))
+		if err != nil {
+			return err
+		}
+
+		order = models.NewOrder(orderId, cartId, totals)
+		order.Status = models.OrderPending
+		order.CreatedAt = time.Now().UTC()
+
+		if check != nil {
+			if err := check(order); err != nil {
+				return err
+			}
+		}
+
+		if err := insertOrder(tx, order); err != nil {
+			return err
+		}
+
+		if err := redeemCoupons(tx, order, cartCoupons); err != nil {
+			return err
+		}
+
+		if err := sellStock(tx, order); err != nil {
+			return err
+		}
+
+		if err := removeCartCoupons(tx, cartId); err != nil {
+			return err
+		}
+
+		return emptyCart(tx, cartId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (o *orderRepository) CompleteOrder(orderId string) error {
+	return inTx(o.db, func(tx *sql.Tx) error {
+		cartId, err := setPendingOrderStatus(tx, orderId, models.OrderCompleted)
+		if err != nil {
+			return err
+		}
+
+		if err := setCartStatus(tx, cartId, models.CartPaid); err != nil {
+			return err
+		}
+
+		return bumpCartVersion(tx, cartId)
+	})
+}
+
+func (o *orderRepository) CancelOrder(orderId string) error {
+	const linesQuery = `SELECT product_id, quantity FROM order_lines WHERE order_id = ?`
+	const restoreLinesQuery = `INSERT INTO cart_products (cart_id, product_id, quantity) SELECT ?, product_id, quantity FROM order_lines WHERE order_id = ?`
+
+	return inTx(o.db, func(tx *sql.Tx) error {
+		cartId, err := setPendingOrderStatus(tx, orderId, models.OrderCancelled)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(linesQuery, orderId)
+		if err != nil {
+			return err
+		}
+		returned := make([]*models.StockAdjustment, 0)
+		for rows.Next() {
+			adjustment := &models.StockAdjustment{Reason: models.StockReturned, OrderID: &orderId}
+			if err := rows.Scan(&adjustment.ProductID, &adjustment.Delta); err != nil {
+				rows.Close()
+				return err
+			}
+			returned = append(returned, adjustment)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, adjustment := range returned {
+			if err := adjustStock(tx, adjustment, now); err != nil && !errors.Is(err, ErrStockNotFound) {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(restoreLinesQuery, cartId, orderId); err != nil {
+			return err
+		}
+
+		if err := unredeemCoupons(tx, cartId, orderId); err != nil {
+			return err
+		}
+
+		return bumpCartVersion(tx, cartId)
+	})
+}
+
+// setPendingOrderStatus moves a pending order to status, returning its cart.
+func setPendingOrderStatus(tx *sql.Tx, orderId string, status models.OrderStatus) (string, error) {
+	const query = `UPDATE orders SET status = ? WHERE id = ? AND status = ? RETURNING cart_id`
+
+	var cartId string
+	if err := tx.QueryRow(query, status, orderId, models.OrderPending).Scan(&cartId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrOrderNotPending
+		}
+		return "", err
+	}
+
+	return cartId, nil
+}
+
+func (o *orderRepository) GetOrder(orderId string) (*models.Order, error) {
+	const query = `SELECT id, cart_id, status, item_count, subtotal, discount, tax, tax_added, total, currency, created_at FROM orders WHERE id = ?`
+
+	order, err := scanOrder(o.db.QueryRow(query, orderId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	if err := loadOrderDetails(o.db, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (o *orderRepository) ListCartOrders(cartId string) ([]*models.Order, error) {
+	const query = `
+        SELECT
+          id, cart_id, status, item_count, subtotal, discount, tax, tax_added, total, currency, created_at
+        FROM
+          orders
+        WHERE
+          cart_id = ?
+        ORDER BY
+          created_at, id
+    `
+
+	rows, err := o.db.Query(query, cartId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]*models.Order, 0)
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, order := range orders {
+		if err := loadOrderDetails(o.db, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return orders, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOrder(row scanner) (*models.Order, error) {
+	order := &models.Order{}
+	var currency money.Currency
+	err := row.Scan(
+		&order.ID, &order.CartID, &order.Status, &order.ItemCount,
+		&order.Subtotal.Amount, &order.Discount.Amount, &order.Tax.Amount, &order.TaxAdded.Amount,
+		&order.Total.Amount, &currency, &order.CreatedAt,
+	)
+	order.Subtotal.Currency = currency
+	order.Discount.Currency = currency
+	order.Tax.Currency = currency
+	order.TaxAdded.Currency = currency
+	order.Total.Currency = currency
+	return order, err
+}
+
+func insertOrder(tx *sql.Tx, order *models.Order) error {
+	const orderQuery = `
+        INSERT INTO
+          orders (id, cart_id, status, item_count, subtotal, discount, tax, tax_added, total, currency, created_at)
+        VALUES
+          (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+	const lineQuery = `
+        INSERT INTO
+          order_lines (order_id, product_id, name, unit_price, quantity, discount, total, tax)
+        VALUES
+          (?, ?, ?, ?, ?, ?, ?, ?)
+    `
+	const taxQuery = `
+        INSERT INTO
+          order_line_taxes (order_id, product_id, tax, rate, inclusive, base, amount)
+        VALUES
+          (?, ?, ?, ?, ?, ?, ?)
+    `
+
+	if _, err := tx.Exec(
+		orderQuery, order.ID, order.CartID, order.Status, order.ItemCount,
+		order.Subtotal.Amount, order.Discount.Amount, order.Tax.Amount, order.TaxAdded.Amount,
+		order.Total.Amount, order.Total.Currency, order.CreatedAt,
+	); err != nil {
+		return err
+	}
+
+	for _, line := range order.Lines {
+		if _, err := tx.Exec(
+			lineQuery, line.OrderID, line.ProductID, line.Name,
+			line.UnitPrice.Amount, line.Quantity, line.Discount.Amount, line.Total.Amount, line.Tax.Amount,
+		); err != nil {
+			return err
+		}
+
+		for _, tax := range line.Taxes {
+			if _, err := tx.Exec(
+				taxQuery, line.OrderID, line.ProductID, tax.Tax, tax.Rate, tax.Inclusive, tax.Base.Amount, tax.Amount.Amount,
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadOrderDetails fills in the lines along with their taxes and the coupons redeemed by the order.
+func loadOrderDetails(db querier, order *models.Order) error {
+	var err error
+
+	if order.Lines, err = getOrderLines(db, order.ID, order.Total.Currency); err != nil {
+		return err
+	}
+
+	if err := loadOrderTaxes(db, order); err != nil {
+		return err
+	}
+
+	order.Coupons, err = getOrderCoupons(db, order.ID, order.Total.Currency)
+	return err
+}
+
+// getOrderLines loads the lines of the order, whose amounts are all in the currency of the order.
+func getOrderLines(db querier, orderId string, currency money.Currency) ([]*models.OrderLine, error) {
+	const query = `
+        SELECT
+          order_id, product_id, name, unit_price, quantity, discount, total, tax
+        FROM
+          order_lines
+        WHERE
+          order_id = ?
+        ORDER BY
+          rowid
+    `
+
+	rows, err := db.Query(query, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]*models.OrderLine, 0)
+	for rows.Next() {
+		line := &models.OrderLine{}
+		if err := rows.Scan(
+			&line.OrderID, &line.ProductID, &line.Name,
+			&line.UnitPrice.Amount, &line.Quantity, &line.Discount.Amount, &line.Total.Amount, &line.Tax.Amount,
+		); err != nil {
+			return nil, err
+		}
+		line.UnitPrice.Currency = currency
+		line.Discount.Currency = currency
+		line.Total.Currency = currency
+		line.Tax.Currency = currency
+		line.Taxes = make([]*models.TaxAmount, 0)
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+// loadOrderTaxes fills in the taxes of the lines of the order and adds them up for the whole order.
+func loadOrderTaxes(db querier, order *models.Order) error {
+	const query = `
+        SELECT
+          product_id, tax, rate, inclusive, base, amount
+        FROM
+          order_line_taxes
+        WHERE
+          order_id = ?
+        ORDER BY
+          rowid
+    `
+
+	rows, err := db.Query(query, order.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	lines := make(map[string]*models.OrderLine, len(order.Lines))
+	for _, line := range order.Lines {
+		lines[line.ProductID] = line
+	}
+
+	for rows.Next() {
+		var productId string
+		tax := &models.TaxAmount{}
+		if err := rows.Scan(&productId, &tax.Tax, &tax.Rate, &tax.Inclusive, &tax.Base.Amount, &tax.Amount.Amount); err != nil {
+			return err
+		}
+		tax.Base.Currency = order.Total.Currency
+		tax.Amount.Currency = order.Total.Currency
+		if line := lines[productId]; line != nil {
+			line.Taxes = append(line.Taxes, tax)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	perLine := make([][]*models.TaxAmount, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		perLine = append(perLine, line.Taxes)
+	}
+	order.Taxes = models.SumTaxes(perLine)
+
+	return nil
+}
+
+func getOrderCoupons(db querier, orderId string, currency money.Currency) ([]*models.AppliedCoupon, error) {
+	const query = `SELECT code, discount FROM coupon_redemptions WHERE order_id = ? ORDER BY rowid`
+
+	rows, err := db.Query(query, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := make([]*models.AppliedCoupon, 0)
+	for rows.Next() {
+		coupon := &models.AppliedCoupon{}
+		if err := rows.Scan(&coupon.Code, &coupon.Discount.Amount); err != nil {
+			return nil, err
+		}
+		coupon.Discount.Currency = currency
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, rows.Err()
+}
@@ -0,0 +1,447 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/pricing"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticTaxRules []*models.TaxRule
+
+func (s staticTaxRules) ListTaxRules() ([]*models.TaxRule, error) {
+	return s, nil
+}
+
+// createOrderSetup charges 18% of ICMS on the products of the standard tax class
+func createOrderSetup() (repository.OrderRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	calculator := pricing.NewCalculator(nil, staticTaxRules{
+		{ID: "icms", TaxClass: "standard", Tax: models.TaxICMS, Rate: 1800, Inclusive: true},
+	})
+	return sqlite.NewOrderRepository(db, calculator), db, mock
+}
+
+var orderColumns = []string{"id", "cart_id", "status", "item_count", "subtotal", "discount", "tax", "tax_added", "total", "currency", "created_at"}
+var orderLineColumns = []string{"order_id", "product_id", "name", "unit_price", "quantity", "discount", "total", "tax"}
+var orderLineTaxColumns = []string{"product_id", "tax", "rate", "inclusive", "base", "amount"}
+
+var cartProductColumns = []string{
+	"cp.cart_id", "cp.product_id", "cp.quantity", "p.name",
+	"p.price", "p.currency", "p.id", "p.description", "p.image_url", "p.tax_class",
+}
+
+// cartProductRows has 3 Coca Cola of the standard tax class and 1 untaxed Chamyto
+func cartProductRows(cartId string) *sqlmock.Rows {
+	return sqlmock.NewRows(cartProductColumns).
+		AddRow(cartId, "1", 3, "Coca Cola", 599, "BRL", "1", nil, nil, "standard").
+		AddRow(cartId, "5", 1, "Chamyto", 1099, "BRL", "5", nil, nil, "")
+}
+
+// expectUntrackedSale sells units of a product whose stock is not tracked
+func expectUntrackedSale(mock sqlmock.Sqlmock, productId string, quantity int) {
+	mock.ExpectQuery(`UPDATE stock SET on_hand = on_hand \+ \?`).
+		WithArgs(-quantity, sqlmock.AnyArg(), productId).
+		WillReturnRows(sqlmock.NewRows([]string{"on_hand", "low_stock_threshold"}))
+}
+
+func expectReservationsReleased(mock sqlmock.Sqlmock, cartId string) {
+	mock.ExpectExec(`DELETE FROM stock_reservations WHERE cart_id = ?`).
+		WithArgs(cartId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectOrderLineTaxes(mock sqlmock.Sqlmock, orderId string, rows *sqlmock.Rows) {
+	if rows == nil {
+		rows = sqlmock.NewRows(orderLineTaxColumns)
+	}
+	mock.ExpectQuery(`SELECT .* FROM order_line_taxes WHERE order_id = ?`).
+		WithArgs(orderId).
+		WillReturnRows(rows)
+}
+
+var cartCouponColumns = append(append([]string{}, couponColumns...), "cc.customer_id")
+
+func expectCartCoupons(mock sqlmock.Sqlmock, cartId string, rows *sqlmock.Rows) {
+	if rows == nil {
+		rows = sqlmock.NewRows(cartCouponColumns)
+	}
+	mock.ExpectQuery(`SELECT .* FROM cart_coupons cc JOIN coupons c`).
+		WithArgs(cartId).
+		WillReturnRows(rows)
+}
+
+func expectOrderCoupons(mock sqlmock.Sqlmock, orderId string) {
+	mock.ExpectQuery(`SELECT code, discount FROM coupon_redemptions WHERE order_id = ?`).
+		WithArgs(orderId).
+		WillReturnRows(sqlmock.NewRows([]string{"code", "discount"}))
+}
+
+func expectCartPaid(mock sqlmock.Sqlmock, cartId string) {
+	mock.ExpectExec(`UPDATE carts SET status = \? WHERE id = \?`).
+		WithArgs(models.CartPaid, cartId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectPendingOrder(mock sqlmock.Sqlmock, orderId string, status models.OrderStatus, cartId string) {
+	mock.ExpectQuery(`UPDATE orders SET status = \? WHERE id = \? AND status = \? RETURNING cart_id`).
+		WithArgs(status, orderId, models.OrderPending).
+		WillReturnRows(sqlmock.NewRows([]string{"cart_id"}).AddRow(cartId))
+}
+
+func TestOrderRepo(t *testing.T) {
+	t.Run("CreateFromCart", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartCheckingOut)
+			mock.ExpectQuery(`SELECT .* FROM cart_products cp JOIN products p`).
+				WithArgs("2").
+				WillReturnRows(cartProductRows("2"))
+			expectCartCoupons(mock, "2", nil)
+			mock.ExpectExec(`INSERT INTO orders`).
+				WithArgs("o1", "2", models.OrderPending, 4, 2896, 0, 323, 0, 2896, money.BRL, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_lines`).
+				WithArgs("o1", "1", "Coca Cola", 599, 3, 0, 1797, 323).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_line_taxes`).
+				WithArgs("o1", "1", models.TaxICMS, 1800, true, 1797, 323).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_lines`).
+				WithArgs("o1", "5", "Chamyto", 1099, 1, 0, 1099, 0).
+				WillReturnResult(sqlmock.NewResult(2, 1))
+			// The Coca Cola drops from 10 to 7 units, below its threshold of 8
+			mock.ExpectQuery(`UPDATE stock SET on_hand = on_hand \+ \?, updated_at = \? WHERE product_id = \? RETURNING on_hand, low_stock_threshold`).
+				WithArgs(-3, sqlmock.AnyArg(), "1").
+				WillReturnRows(sqlmock.NewRows([]string{"on_hand", "low_stock_threshold"}).AddRow(7, 8))
+			mock.ExpectExec(`INSERT INTO stock_adjustments`).
+				WithArgs("1", -3, models.StockSale, nil, "o1", 7, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO stock_alerts`).
+				WithArgs("1", 7, 8, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectUntrackedSale(mock, "5", 1)
+			expectReservationsReleased(mock, "2")
+			mock.ExpectExec(`DELETE FROM cart_coupons WHERE cart_id = ?`).
+				WithArgs("2").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`DELETE FROM cart_products WHERE cart_id = ?`).
+				WithArgs("2").
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			expectVersionBump(mock)
+			mock.ExpectCommit()
+
+			order, err := repo.CreateFromCart("o1", "2", nil)
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			assert.Equal(t, "o1", order.ID)
+			assert.Equal(t, uint(4), order.ItemCount)
+			assert.Equal(t, money.Cents(2896), order.Total)
+			assert.Equal(t, money.Cents(323), order.Tax)
+			assert.Len(t, order.Lines, 2)
+		})
+
+		t.Run("Success with coupon", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartCheckingOut)
+			mock.ExpectQuery(`SELECT .* FROM cart_products cp JOIN products p`).
+				WithArgs("2").
+				WillReturnRows(cartProductRows("2"))
+			expectCartCoupons(mock, "2", sqlmock.NewRows(cartCouponColumns).
+				AddRow("WELCOME10", "", "percentage", 10, 0, 2000, "BRL", 0, 0, true, nil, "c1"))
+			mock.ExpectExec(`INSERT INTO orders`).
+				WithArgs("o1", "2", models.OrderPending, 4, 2896, 290, 291, 0, 2606, money.BRL, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_lines`).WillReturnResult(sqlmock.NewResult(1, 1))
+			// The Coca Cola takes 1.80 of the coupon, 18% of 16.17 being 2.9106
+			mock.ExpectExec(`INSERT INTO order_line_taxes`).
+				WithArgs("o1", "1", models.TaxICMS, 1800, true, 1617, 291).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_lines`).WillReturnResult(sqlmock.NewResult(2, 1))
+			mock.ExpectExec(`UPDATE coupons SET uses = uses \+ 1`).
+				WithArgs("WELCOME10").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`INSERT INTO coupon_redemptions`).
+				WithArgs("WELCOME10", "o1", "c1", 290).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectUntrackedSale(mock, "1", 3)
+			expectUntrackedSale(mock, "5", 1)
+			expectReservationsReleased(mock, "2")
+			mock.ExpectExec(`DELETE FROM cart_coupons WHERE cart_id = ?`).
+				WithArgs("2").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`DELETE FROM cart_products WHERE cart_id = ?`).
+				WithArgs("2").
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			expectVersionBump(mock)
+			mock.ExpectCommit()
+
+			order, err := repo.CreateFromCart("o1", "2", nil)
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			assert.Equal(t, []*models.AppliedCoupon{{Code: "WELCOME10", Discount: money.Cents(290)}}, order.Coupons)
+			assert.Equal(t, money.Cents(2606), order.Total)
+		})
+
+		t.Run("Error with coupon used up in the meantime", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartCheckingOut)
+			mock.ExpectQuery(`SELECT .* FROM cart_products cp JOIN products p`).
+				WithArgs("2").
+				WillReturnRows(cartProductRows("2"))
+			expectCartCoupons(mock, "2", sqlmock.NewRows(cartCouponColumns).
+				AddRow("ZCART5", "", "fixed", 0, 500, 0, "BRL", 100, 99, false, nil, nil))
+			mock.ExpectExec(`INSERT INTO orders`).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_lines`).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_line_taxes`).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_lines`).WillReturnResult(sqlmock.NewResult(2, 1))
+			mock.ExpectExec(`UPDATE coupons SET uses = uses \+ 1`).
+				WithArgs("ZCART5").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			_, err := repo.CreateFromCart("o1", "2", nil)
+			assert.ErrorIs(t, err, pricing.ErrCouponExhausted)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error when the check fails", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			expectedError := errors.New("cart changed")
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartCheckingOut)
+			mock.ExpectQuery(`SELECT .* FROM cart_products cp JOIN products p`).
+				WithArgs("2").
+				WillReturnRows(cartProductRows("2"))
+			expectCartCoupons(mock, "2", nil)
+			mock.ExpectRollback()
+
+			var checked *models.Order
+			_, err := repo.CreateFromCart("o1", "2", func(order *models.Order) error {
+				checked = order
+				return expectedError
+			})
+			assert.ErrorIs(t, err, expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			require.NotNil(t, checked)
+			assert.Equal(t, money.Cents(2896), checked.Total)
+		})
+
+		t.Run("Error with empty cart", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartCheckingOut)
+			mock.ExpectQuery(`SELECT .* FROM cart_products cp JOIN products p`).
+				WithArgs("2").
+				WillReturnRows(sqlmock.NewRows(cartProductColumns))
+			mock.ExpectRollback()
+
+			_, err := repo.CreateFromCart("o1", "2", nil)
+			assert.ErrorIs(t, err, sqlite.ErrCartEmpty)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with cart not checking out", func(t *testing.T) {
+			for _, status := range []models.CartStatus{models.CartOpen, models.CartPaid, models.CartClosed} {
+				repo, _, mock := createOrderSetup()
+
+				mock.ExpectBegin()
+				expectCartStatus(mock, "2", status)
+				mock.ExpectRollback()
+
+				_, err := repo.CreateFromCart("o1", "2", nil)
+				assert.ErrorIs(t, err, sqlite.ErrCartNotCheckingOut, status)
+				assert.NoError(t, mock.ExpectationsWereMet())
+			}
+		})
+
+		t.Run("Error when inserting lines", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			expectedError := errors.New("disk full")
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartCheckingOut)
+			mock.ExpectQuery(`SELECT .* FROM cart_products cp JOIN products p`).
+				WithArgs("2").
+				WillReturnRows(cartProductRows("2"))
+			expectCartCoupons(mock, "2", nil)
+			mock.ExpectExec(`INSERT INTO orders`).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO order_lines`).WillReturnError(expectedError)
+			mock.ExpectRollback()
+
+			_, err := repo.CreateFromCart("o1", "2", nil)
+			assert.ErrorIs(t, err, expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("CompleteOrder", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			mock.ExpectBegin()
+			expectPendingOrder(mock, "o1", models.OrderCompleted, "2")
+			expectCartPaid(mock, "2")
+			expectVersionBump(mock)
+			mock.ExpectCommit()
+
+			assert.NoError(t, repo.CompleteOrder("o1"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with order not pending", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`UPDATE orders SET status`).WillReturnRows(sqlmock.NewRows([]string{"cart_id"}))
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.CompleteOrder("o1"), sqlite.ErrOrderNotPending)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("CancelOrder", func(t *testing.T) {
+		repo, _, mock := createOrderSetup()
+
+		mock.ExpectBegin()
+		expectPendingOrder(mock, "o1", models.OrderCancelled, "2")
+		mock.ExpectQuery(`SELECT product_id, quantity FROM order_lines WHERE order_id = ?`).
+			WithArgs("o1").
+			WillReturnRows(sqlmock.NewRows([]string{"product_id", "quantity"}).AddRow("1", 3).AddRow("5", 1))
+		mock.ExpectQuery(`UPDATE stock SET on_hand = on_hand \+ \?`).
+			WithArgs(3, sqlmock.AnyArg(), "1").
+			WillReturnRows(sqlmock.NewRows([]string{"on_hand", "low_stock_threshold"}).AddRow(10, 8))
+		mock.ExpectExec(`INSERT INTO stock_adjustments`).
+			WithArgs("1", 3, models.StockReturned, nil, "o1", 10, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`UPDATE stock_alerts SET resolved_at`).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectUntrackedSale(mock, "5", -1)
+		mock.ExpectExec(`INSERT INTO cart_products \(cart_id, product_id, quantity\) SELECT \?, product_id, quantity FROM order_lines`).
+			WithArgs("2", "o1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`INSERT INTO cart_coupons \(cart_id, code, customer_id\) SELECT \?, code, customer_id FROM coupon_redemptions`).
+			WithArgs("2", "o1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE coupons SET uses = uses - 1`).
+			WithArgs("o1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM coupon_redemptions WHERE order_id = ?`).
+			WithArgs("o1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectVersionBump(mock)
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.CancelOrder("o1"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetOrder", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			createdAt := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+			mock.ExpectQuery(`SELECT .* FROM orders WHERE id = ?`).
+				WithArgs("o1").
+				WillReturnRows(sqlmock.NewRows(orderColumns).
+					AddRow("o1", "2", models.OrderCompleted, 3, 1797, 0, 323, 0, 1797, "BRL", createdAt))
+			mock.ExpectQuery(`SELECT .* FROM order_lines WHERE order_id = ?`).
+				WithArgs("o1").
+				WillReturnRows(sqlmock.NewRows(orderLineColumns).
+					AddRow("o1", "1", "Coca Cola", 599, 3, 0, 1797, 323))
+			expectOrderLineTaxes(mock, "o1", sqlmock.NewRows(orderLineTaxColumns).
+				AddRow("1", "icms", 1800, true, 1797, 323))
+			expectOrderCoupons(mock, "o1")
+
+			order, err := repo.GetOrder("o1")
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			icms := &models.TaxAmount{Tax: models.TaxICMS, Rate: 1800, Inclusive: true, Base: money.Cents(1797), Amount: money.Cents(323)}
+
+			assert.Equal(t, &models.Order{
+				ID:        "o1",
+				CartID:    "2",
+				Status:    models.OrderCompleted,
+				ItemCount: 3,
+				Subtotal:  money.Cents(1797),
+				Discount:  money.Cents(0),
+				Coupons:   []*models.AppliedCoupon{},
+				Tax:       money.Cents(323),
+				TaxAdded:  money.Cents(0),
+				Taxes:     []*models.TaxAmount{icms},
+				Total:     money.Cents(1797),
+				CreatedAt: createdAt,
+				Lines: []*models.OrderLine{
+					{
+						OrderID: "o1", ProductID: "1", Name: "Coca Cola", UnitPrice: money.Cents(599), Quantity: 3, Discount: money.Cents(0), Total: money.Cents(1797),
+						Tax: money.Cents(323), Taxes: []*models.TaxAmount{icms},
+					},
+				},
+			}, order)
+		})
+
+		t.Run("Error with unknown order", func(t *testing.T) {
+			repo, _, mock := createOrderSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM orders WHERE id = ?`).
+				WithArgs("nope").
+				WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.GetOrder("nope")
+			assert.ErrorIs(t, err, sqlite.ErrOrderNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListCartOrders", func(t *testing.T) {
+		repo, _, mock := createOrderSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM orders WHERE cart_id = ?`).
+			WithArgs("2").
+			WillReturnRows(sqlmock.NewRows(orderColumns).
+				AddRow("o1", "2", models.OrderCompleted, 3, 1797, 0, 0, 0, 1797, "BRL", time.Now()).
+				AddRow("o2", "2", models.OrderCompleted, 1, 1099, 0, 0, 0, 1099, "BRL", time.Now()))
+		mock.ExpectQuery(`SELECT .* FROM order_lines`).
+			WithArgs("o1").
+			WillReturnRows(sqlmock.NewRows(orderLineColumns).AddRow("o1", "1", "Coca Cola", 599, 3, 0, 1797, 0))
+		expectOrderLineTaxes(mock, "o1", nil)
+		expectOrderCoupons(mock, "o1")
+		mock.ExpectQuery(`SELECT .* FROM order_lines`).
+			WithArgs("o2").
+			WillReturnRows(sqlmock.NewRows(orderLineColumns).AddRow("o2", "5", "Chamyto", 1099, 1, 0, 1099, 0))
+		expectOrderLineTaxes(mock, "o2", nil)
+		expectOrderCoupons(mock, "o2")
+
+		orders, err := repo.ListCartOrders("2")
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		require.Len(t, orders, 2)
+		assert.Equal(t, "o2", orders[1].ID)
+		assert.Equal(t, "Chamyto", orders[1].Lines[0].Name)
+	})
+}
@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrPaymentNotFound      = repository.ErrPaymentNotFound
+	ErrPaymentAlreadyExists = repository.ErrPaymentAlreadyExists
+)
+
+const paymentColumns = `id, order_id, cart_id, idempotency_key, amount, currency, status, method, gateway, transaction_id, failure_reason, created_at, updated_at`
+
+type paymentRepository struct {
+	db *sql.DB
+}
+
+func NewPaymentRepository(db *sql.DB) repository.PaymentRepository {
+	return &paymentRepository{db}
+}
+
+func (p *paymentRepository) CreatePayment(payment *models.PaymentIntent) error {
+	const query = `INSERT INTO payment_intents (` + paymentColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now().UTC()
+	payment.CreatedAt = now
+	payment.UpdatedAt = now
+
+	_, err := p.db.Exec(
+		query, payment.ID, payment.OrderID, payment.CartID, payment.IdempotencyKey,
+		payment.Amount.Amount, payment.Amount.Currency, payment.Status, payment.Method, payment.Gateway,
+		payment.TransactionID, payment.FailureReason, payment.CreatedAt, payment.UpdatedAt,
+	)
+	if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+		return ErrPaymentAlreadyExists
+	}
+
+	return err
+}
+
+func (p *paymentRepository) GetPaymentByKey(idempotencyKey string) (*models.PaymentIntent, error) {
+	const query = `SELECT ` + paymentColumns + ` FROM payment_intents WHERE idempotency_key = ?`
+
+	payment, err := scanPayment(p.db.QueryRow(query, idempotencyKey))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (p *paymentRepository) GetPaymentByTransaction(gateway string, transactionId string) (*models.PaymentIntent, error) {
+	const query = `SELECT ` + paymentColumns + ` FROM payment_intents WHERE gateway = ? AND transaction_id = ?`
+
+	payment, err := scanPayment(p.db.QueryRow(query, gateway, transactionId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (p *paymentRepository) UpdatePayment(payment *models.PaymentIntent) error {
+	const query = `UPDATE payment_intents SET status = ?, transaction_id = ?, failure_reason = ?, updated_at = ? WHERE id = ?`
+
+	payment.UpdatedAt = time.Now().UTC()
+
+	result, err := p.db.Exec(query, payment.Status, payment.TransactionID, payment.FailureReason, payment.UpdatedAt, payment.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrPaymentNotFound)
+}
+
+func (p *paymentRepository) ExpirePendingPayments(gateway string, before time.Time, reason string) ([]*models.PaymentIntent, error) {
+	const query = `
+        UPDATE
+          payment_intents
+        SET
+          status = ?, failure_reason = ?, updated_at = ?
+        WHERE
+          gateway = ? AND status = ? AND created_at < ?
+        RETURNING
+          ` + paymentColumns
+
+	rows, err := p.db.Query(query, models.PaymentVoided, reason, time.Now().UTC(), gateway, models.PaymentPending, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]*models.PaymentIntent, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+func (p *paymentRepository) ListOrderPayments(orderId string) ([]*models.PaymentIntent, error) {
+	const query = `SELECT ` + paymentColumns + ` FROM payment_intents WHERE order_id = ? ORDER BY created_at, id`
+
+	rows, err := p.db.Query(query, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]*models.PaymentIntent, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+func scanPayment(row scanner) (*models.PaymentIntent, error) {
+	var (
+		payment                      models.PaymentIntent
+		transactionId, failureReason sql.NullString
+	)
+
+	if err := row.Scan(
+		&payment.ID, &payment.OrderID, &payment.CartID, &payment.IdempotencyKey,
+		&payment.Amount.Amount, &payment.Amount.Currency, &payment.Status, &payment.Method, &payment.Gateway,
+		&transactionId, &failureReason, &payment.CreatedAt, &payment.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if transactionId.Valid {
+		payment.TransactionID = &transactionId.String
+	}
+	if failureReason.Valid {
+		payment.FailureReason = &failureReason.String
+	}
+
+	return &payment, nil
+}
@@ -0,0 +1,152 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createPaymentSetup() (repository.PaymentRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewPaymentRepository(db), db, mock
+}
+
+var paymentColumns = []string{
+	"id", "order_id", "cart_id", "idempotency_key", "amount", "currency", "status",
+	"method", "gateway", "transaction_id", "failure_reason", "created_at", "updated_at",
+}
+
+func TestPaymentRepo(t *testing.T) {
+	newPayment := func() *models.PaymentIntent {
+		return &models.PaymentIntent{
+			ID:             "p1",
+			OrderID:        "o1",
+			CartID:         "2",
+			IdempotencyKey: "k1",
+			Amount:         money.Cents(2896),
+			Status:         models.PaymentPending,
+			Method:         "card",
+			Gateway:        "fake",
+		}
+	}
+
+	t.Run("CreatePayment", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createPaymentSetup()
+			payment := newPayment()
+
+			mock.ExpectExec(`INSERT INTO payment_intents`).
+				WithArgs(
+					"p1", "o1", "2", "k1", 2896, money.BRL, models.PaymentPending, "card", "fake",
+					nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg(),
+				).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			require.NoError(t, repo.CreatePayment(payment))
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.False(t, payment.CreatedAt.IsZero())
+		})
+
+		t.Run("Error with reused idempotency key", func(t *testing.T) {
+			repo, _, mock := createPaymentSetup()
+
+			mock.ExpectExec(`INSERT INTO payment_intents`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique})
+
+			assert.ErrorIs(t, repo.CreatePayment(newPayment()), sqlite.ErrPaymentAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetPaymentByKey", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createPaymentSetup()
+			now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+			mock.ExpectQuery(`SELECT .* FROM payment_intents WHERE idempotency_key = ?`).
+				WithArgs("k1").
+				WillReturnRows(sqlmock.NewRows(paymentColumns).
+					AddRow("p1", "o1", "2", "k1", 2896, "BRL", "authorized", "card", "fake", "fake_1", nil, now, now))
+
+			payment, err := repo.GetPaymentByKey("k1")
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			expected := newPayment()
+			expected.Status = models.PaymentAuthorized
+			transactionId := "fake_1"
+			expected.TransactionID = &transactionId
+			expected.CreatedAt = now
+			expected.UpdatedAt = now
+			assert.Equal(t, expected, payment)
+		})
+
+		t.Run("Error with unknown key", func(t *testing.T) {
+			repo, _, mock := createPaymentSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM payment_intents WHERE idempotency_key = ?`).
+				WithArgs("nope").
+				WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.GetPaymentByKey("nope")
+			assert.ErrorIs(t, err, sqlite.ErrPaymentNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetPaymentByTransaction", func(t *testing.T) {
+		repo, _, mock := createPaymentSetup()
+		now := time.Now()
+
+		mock.ExpectQuery(`SELECT .* FROM payment_intents WHERE gateway = \? AND transaction_id = \?`).
+			WithArgs("pix", "tx1").
+			WillReturnRows(sqlmock.NewRows(paymentColumns).
+				AddRow("p1", "o1", "2", "k1", 2896, "BRL", "pending", "pix", "pix", "tx1", nil, now, now))
+
+		payment, err := repo.GetPaymentByTransaction("pix", "tx1")
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, "p1", payment.ID)
+	})
+
+	t.Run("UpdatePayment", func(t *testing.T) {
+		repo, _, mock := createPaymentSetup()
+		payment := newPayment()
+		reason := "payment declined: insufficient funds"
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = &reason
+
+		mock.ExpectExec(`UPDATE payment_intents SET status = \?, transaction_id = \?, failure_reason = \?, updated_at = \? WHERE id = \?`).
+			WithArgs(models.PaymentFailed, nil, &reason, sqlmock.AnyArg(), "p1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.UpdatePayment(payment))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("ExpirePendingPayments", func(t *testing.T) {
+		repo, _, mock := createPaymentSetup()
+		now := time.Now()
+		before := now.Add(-15 * time.Minute)
+
+		mock.ExpectQuery(`UPDATE payment_intents SET status = \?, failure_reason = \?, updated_at = \? WHERE gateway = \? AND status = \? AND created_at < \? RETURNING`).
+			WithArgs(models.PaymentVoided, "charge expired", sqlmock.AnyArg(), "pix", models.PaymentPending, before).
+			WillReturnRows(sqlmock.NewRows(paymentColumns).
+				AddRow("p1", "o1", "2", "k1", 2896, "BRL", "voided", "pix", "pix", "tx1", "charge expired", before, now))
+
+		payments, err := repo.ExpirePendingPayments("pix", before, "charge expired")
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		require.Len(t, payments, 1)
+		assert.Equal(t, models.PaymentVoided, payments[0].Status)
+		assert.Equal(t, "2", payments[0].CartID)
+	})
+}
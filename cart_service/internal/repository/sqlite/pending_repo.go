@@ -0,0 +1,232 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+)
+
+var (
+	ErrPendingItemNotFound = repository.ErrPendingItemNotFound
+	ErrPendingItemResolved = repository.ErrPendingItemResolved
+)
+
+const selectPendingItem = `
+        SELECT
+          i.id, i.cart_id, i.product_id, p.name, i.quantity, i.reason, i.label, i.confidence,
+          i.status, i.created_at, i.expires_at, i.resolved_at
+        FROM
+          cart_pending_items i
+          JOIN products p ON i.product_id = p.id
+`
+
+type pendingItemRepository struct {
+	db             *sql.DB
+	reservationTTL time.Duration
+}
+
+// NewPendingItemRepository keeps the units of confirmed items reserved for reservationTTL, like the cart repository.
+func NewPendingItemRepository(db *sql.DB, reservationTTL time.Duration) repository.PendingItemRepository {
+	return &pendingItemRepository{db, reservationTTL}
+}
+
+func (p *pendingItemRepository) CreatePendingItem(item *models.PendingItem) error {
+	const query = `
+        INSERT INTO
+          cart_pending_items (id, cart_id, product_id, quantity, reason, label, confidence, status, created_at, expires_at)
+        VALUES
+          (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+
+	_, err := p.db.Exec(
+		query, item.ID, item.CartID, item.ProductID, item.Quantity, item.Reason,
+		item.Label, item.Confidence, item.Status, item.CreatedAt, item.ExpiresAt,
+	)
+
+	return err
+}
+
+func (p *pendingItemRepository) GetPendingItem(cartId string, itemId string) (*models.PendingItem, error) {
+	return getPendingItem(p.db, cartId, itemId)
+}
+
+func (p *pendingItemRepository) ListPendingItems(cartId string) ([]*models.PendingItem, error) {
+	const query = selectPendingItem + ` WHERE i.cart_id = ? AND i.status = ? ORDER BY i.created_at, i.id`
+
+	rows, err := p.db.Query(query, cartId, models.PendingAwaiting)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPendingItems(rows)
+}
+
+func (p *pendingItemRepository) ConfirmPendingItem(cartId string, itemId string) (*models.PendingItem, error) {
+	var item *models.PendingItem
+
+	err := inOpenCart(p.db, cartId, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+
+		var err error
+		if item, err = resolvePendingItem(tx, cartId, itemId, models.PendingConfirmed, now); err != nil {
+			return err
+		}
+		if err := updateQuantity(tx, cartId, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+
+		return reserveCartStock(tx, cartId, now.Add(p.reservationTTL))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (p *pendingItemRepository) RejectPendingItem(cartId string, itemId string) (*models.PendingItem, error) {
+	var item *models.PendingItem
+
+	err := inTx(p.db, func(tx *sql.Tx) error {
+		var err error
+		item, err = resolvePendingItem(tx, cartId, itemId, models.PendingRejected, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (p *pendingItemRepository) ExpirePendingItems(now time.Time) ([]*models.PendingItem, error) {
+	const query = `
+        UPDATE
+          cart_pending_items
+        SET
+          status = ?, resolved_at = ?
+        WHERE
+          status = ? AND expires_at <= ?
+        RETURNING
+          id
+    `
+
+	var items []*models.PendingItem
+
+	err := inTx(p.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query(query, models.PendingExpired, now, models.PendingAwaiting, now)
+		if err != nil {
+			return err
+		}
+
+		expired := make([]string, 0)
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			expired = append(expired, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		items = make([]*models.PendingItem, 0, len(expired))
+		for _, id := range expired {
+			item, err := scanPendingItem(tx.QueryRow(selectPendingItem+` WHERE i.id = ?`, id))
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func getPendingItem(db querier, cartId string, itemId string) (*models.PendingItem, error) {
+	const query = selectPendingItem + ` WHERE i.cart_id = ? AND i.id = ?`
+
+	item, err := scanPendingItem(db.QueryRow(query, cartId, itemId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPendingItemNotFound
+		}
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// resolvePendingItem moves an item still waiting for the shopper to status. An item past
+// its expiry is no longer waiting, even if it was not marked as expired yet.
+func resolvePendingItem(tx *sql.Tx, cartId string, itemId string, status models.PendingStatus, now time.Time) (*models.PendingItem, error) {
+	const query = `
+        UPDATE
+          cart_pending_items
+        SET
+          status = ?, resolved_at = ?
+        WHERE
+          cart_id = ? AND id = ? AND status = ? AND expires_at > ?
+    `
+
+	result, err := tx.Exec(query, status, now, cartId, itemId, models.PendingAwaiting, now)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := getPendingItem(tx, cartId, itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expectAffected(result, ErrPendingItemResolved); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func scanPendingItems(rows *sql.Rows) ([]*models.PendingItem, error) {
+	defer rows.Close()
+
+	items := make([]*models.PendingItem, 0)
+	for rows.Next() {
+		item, err := scanPendingItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func scanPendingItem(row scanner) (*models.PendingItem, error) {
+	var (
+		item       models.PendingItem
+		resolvedAt sql.NullTime
+	)
+
+	if err := row.Scan(
+		&item.ID, &item.CartID, &item.ProductID, &item.Name, &item.Quantity, &item.Reason, &item.Label,
+		&item.Confidence, &item.Status, &item.CreatedAt, &item.ExpiresAt, &resolvedAt,
+	); err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		item.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &item, nil
+}
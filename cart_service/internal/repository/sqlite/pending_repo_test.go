@@ -0,0 +1,187 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createPendingItemSetup() (repository.PendingItemRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewPendingItemRepository(db, 15*time.Minute), db, mock
+}
+
+var pendingItemColumns = []string{
+	"id", "cart_id", "product_id", "name", "quantity", "reason", "label", "confidence",
+	"status", "created_at", "expires_at", "resolved_at",
+}
+
+func TestPendingItemRepo(t *testing.T) {
+	now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	pendingItemRow := func(status models.PendingStatus, resolvedAt interface{}) *sqlmock.Rows {
+		return sqlmock.NewRows(pendingItemColumns).AddRow(
+			"p1", "2", "7", "Coca Cola Soda 350ml", 1, "weight_mismatch", "coke_soda", 0.91,
+			status, now, now.Add(2*time.Minute), resolvedAt,
+		)
+	}
+
+	t.Run("CreatePendingItem", func(t *testing.T) {
+		repo, _, mock := createPendingItemSetup()
+
+		item := &models.PendingItem{
+			ID:         "p1",
+			CartID:     "2",
+			ProductID:  "7",
+			Quantity:   1,
+			Reason:     models.PendingWeightMismatch,
+			Label:      "coke_soda",
+			Confidence: 0.91,
+			Status:     models.PendingAwaiting,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(2 * time.Minute),
+		}
+
+		mock.ExpectExec(`INSERT INTO cart_pending_items`).
+			WithArgs("p1", "2", "7", 1, models.PendingWeightMismatch, "coke_soda", 0.91, models.PendingAwaiting, now, item.ExpiresAt).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		assert.NoError(t, repo.CreatePendingItem(item))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ListPendingItems", func(t *testing.T) {
+		repo, _, mock := createPendingItemSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM cart_pending_items i JOIN products p .* WHERE i.cart_id = \? AND i.status = \? ORDER BY`).
+			WithArgs("2", models.PendingAwaiting).
+			WillReturnRows(pendingItemRow(models.PendingAwaiting, nil))
+
+		items, err := repo.ListPendingItems("2")
+		require.NoError(t, err)
+		assert.Equal(t, []*models.PendingItem{{
+			ID:         "p1",
+			CartID:     "2",
+			ProductID:  "7",
+			Name:       "Coca Cola Soda 350ml",
+			Quantity:   1,
+			Reason:     models.PendingWeightMismatch,
+			Label:      "coke_soda",
+			Confidence: 0.91,
+			Status:     models.PendingAwaiting,
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(2 * time.Minute),
+		}}, items)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ConfirmPendingItem", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createPendingItemSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartOpen)
+			mock.ExpectExec(`UPDATE cart_pending_items SET status = \?, resolved_at = \? WHERE cart_id = \? AND id = \? AND status = \? AND expires_at > \?`).
+				WithArgs(models.PendingConfirmed, sqlmock.AnyArg(), "2", "p1", models.PendingAwaiting, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(`FROM cart_pending_items i .* WHERE i.cart_id = \? AND i.id = \?`).
+				WithArgs("2", "p1").
+				WillReturnRows(pendingItemRow(models.PendingConfirmed, now))
+			mock.ExpectExec("INSERT INTO cart_products").
+				WithArgs("2", "7", 1, "2", "7").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectStockReserved(mock, "2")
+			expectVersionBump(mock)
+			mock.ExpectCommit()
+
+			item, err := repo.ConfirmPendingItem("2", "p1")
+			require.NoError(t, err)
+			assert.Equal(t, models.PendingConfirmed, item.Status)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with an expired item", func(t *testing.T) {
+			repo, _, mock := createPendingItemSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartOpen)
+			mock.ExpectExec(`UPDATE cart_pending_items`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`FROM cart_pending_items`).WillReturnRows(pendingItemRow(models.PendingAwaiting, nil))
+			mock.ExpectRollback()
+
+			_, err := repo.ConfirmPendingItem("2", "p1")
+			assert.ErrorIs(t, err, sqlite.ErrPendingItemResolved)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with a cart that is not open", func(t *testing.T) {
+			repo, _, mock := createPendingItemSetup()
+
+			mock.ExpectBegin()
+			expectCartStatus(mock, "2", models.CartPaid)
+			mock.ExpectRollback()
+
+			_, err := repo.ConfirmPendingItem("2", "p1")
+			assert.ErrorIs(t, err, sqlite.ErrCartNotOpen)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("RejectPendingItem", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createPendingItemSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE cart_pending_items`).
+				WithArgs(models.PendingRejected, sqlmock.AnyArg(), "2", "p1", models.PendingAwaiting, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(`FROM cart_pending_items`).WillReturnRows(pendingItemRow(models.PendingRejected, now))
+			mock.ExpectCommit()
+
+			item, err := repo.RejectPendingItem("2", "p1")
+			require.NoError(t, err)
+			assert.Equal(t, models.PendingRejected, item.Status)
+			require.NotNil(t, item.ResolvedAt)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown item", func(t *testing.T) {
+			repo, _, mock := createPendingItemSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE cart_pending_items`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`FROM cart_pending_items`).WillReturnRows(sqlmock.NewRows(pendingItemColumns))
+			mock.ExpectRollback()
+
+			_, err := repo.RejectPendingItem("2", "404")
+			assert.ErrorIs(t, err, sqlite.ErrPendingItemNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ExpirePendingItems", func(t *testing.T) {
+		repo, _, mock := createPendingItemSetup()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE cart_pending_items SET status = \?, resolved_at = \? WHERE status = \? AND expires_at <= \? RETURNING id`).
+			WithArgs(models.PendingExpired, now, models.PendingAwaiting, now).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p1"))
+		mock.ExpectQuery(`FROM cart_pending_items i .* WHERE i.id = \?`).
+			WithArgs("p1").
+			WillReturnRows(pendingItemRow(models.PendingExpired, now))
+		mock.ExpectCommit()
+
+		items, err := repo.ExpirePendingItems(now)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, models.PendingExpired, items[0].Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -3,15 +3,26 @@ package sqlite
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/fsmiamoto/zcart/cart_service/internal/models"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
 )
 
 var (
-	ErrProductNotFound = errors.New("product not found")
+	ErrProductNotFound      = repository.ErrProductNotFound
+	ErrProductAlreadyExists = repository.ErrProductAlreadyExists
+	ErrLabelTaken           = repository.ErrLabelTaken
 )
 
+const productColumns = `id, name, price, currency, description, image_url, tax_class, ncm, cest, cfop, origin, csosn, unit, weight_grams, weight_tolerance`
+
+// selectProduct reads the product columns followed by its labels, separated by spaces
+const selectProduct = `SELECT ` + productColumns + `, (SELECT GROUP_CONCAT(label, ' ') FROM product_labels WHERE product_id = products.id) FROM products`
+
 type productRepository struct {
 	db *sql.DB
 }
@@ -21,12 +32,10 @@ func NewProductRepository(db *sql.DB) repository.ProductRepository {
 }
 
 func (c *productRepository) GetProduct(productId string) (models.Product, error) {
-	const query = `SELECT id, name, price, description, image_url FROM products WHERE id = ?`
-	var product models.Product
-
-	row := c.db.QueryRow(query, productId)
+	const query = selectProduct + ` WHERE id = ?`
 
-	if err := row.Scan(&product.ID, &product.Name, &product.Price, &product.Description, &product.ImageURL); err != nil {
+	product, err := scanProduct(c.db.QueryRow(query, productId))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return product, ErrProductNotFound
 		}
@@ -35,3 +44,235 @@ func (c *productRepository) GetProduct(productId string) (models.Product, error)
 
 	return product, nil
 }
+
+func (c *productRepository) ListProducts(filter repository.ProductFilter) ([]models.Product, error) {
+	where, args := productFilterClause(filter)
+
+	query := selectProduct + where + ` ORDER BY name, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]models.Product, 0)
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+func (c *productRepository) CountProducts(filter repository.ProductFilter) (int, error) {
+	where, args := productFilterClause(filter)
+
+	var total int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM products`+where, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (c *productRepository) CreateProduct(product models.Product) error {
+	const query = `INSERT INTO products (` + productColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	fiscal := fiscalOrDefault(product)
+	recognition := recognitionOrDefault(product)
+
+	return inTx(c.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			query, product.ID, product.Name, product.Price.Amount, product.Price.Currency,
+			product.Description, product.ImageURL, product.TaxClass,
+			fiscal.NCM, fiscal.CEST, fiscal.CFOP, fiscal.Origin, fiscal.CSOSN, fiscal.Unit,
+			recognition.WeightGrams, recognition.WeightTolerance,
+		)
+		if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+			return ErrProductAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+
+		return insertLabels(tx, product.ID, recognition.Labels)
+	})
+}
+
+func (c *productRepository) UpdateProduct(product models.Product) error {
+	const query = `
+        UPDATE
+          products
+        SET
+          name = ?,
+          price = ?,
+          currency = ?,
+          description = ?,
+          image_url = ?,
+          tax_class = ?,
+          ncm = ?,
+          cest = ?,
+          cfop = ?,
+          origin = ?,
+          csosn = ?,
+          unit = ?,
+          weight_grams = ?,
+          weight_tolerance = ?,
+          updated_at = current_timestamp
+        WHERE
+          id = ?
+    `
+
+	fiscal := fiscalOrDefault(product)
+	recognition := recognitionOrDefault(product)
+
+	return inTx(c.db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			query, product.Name, product.Price.Amount, product.Price.Currency,
+			product.Description, product.ImageURL, product.TaxClass,
+			fiscal.NCM, fiscal.CEST, fiscal.CFOP, fiscal.Origin, fiscal.CSOSN, fiscal.Unit,
+			recognition.WeightGrams, recognition.WeightTolerance, product.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(result, ErrProductNotFound); err != nil {
+			return err
+		}
+
+		if err := deleteLabels(tx, product.ID); err != nil {
+			return err
+		}
+
+		return insertLabels(tx, product.ID, recognition.Labels)
+	})
+}
+
+func (c *productRepository) DeleteProduct(productId string) error {
+	const query = `DELETE FROM products WHERE id = ?`
+
+	return inTx(c.db, func(tx *sql.Tx) error {
+		// Frees the labels for other products
+		if err := deleteLabels(tx, productId); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(query, productId)
+		if err != nil {
+			return err
+		}
+
+		return expectAffected(result, ErrProductNotFound)
+	})
+}
+
+func insertLabels(tx *sql.Tx, productId string, labels []string) error {
+	const query = `INSERT INTO product_labels (label, product_id) VALUES (?, ?)`
+
+	for _, label := range labels {
+		_, err := tx.Exec(query, label, productId)
+		if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+			return fmt.Errorf("%w: %s", ErrLabelTaken, label)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteLabels(tx *sql.Tx, productId string) error {
+	const query = `DELETE FROM product_labels WHERE product_id = ?`
+
+	_, err := tx.Exec(query, productId)
+	return err
+}
+
+func scanProduct(row scanner) (models.Product, error) {
+	var (
+		product     models.Product
+		fiscal      models.ProductFiscal
+		recognition models.ProductRecognition
+		weightGrams sql.NullInt64
+		labels      sql.NullString
+	)
+
+	err := row.Scan(
+		&product.ID, &product.Name, &product.Price.Amount, &product.Price.Currency, &product.Description, &product.ImageURL,
+		&product.TaxClass, &fiscal.NCM, &fiscal.CEST, &fiscal.CFOP, &fiscal.Origin, &fiscal.CSOSN, &fiscal.Unit,
+		&weightGrams, &recognition.WeightTolerance, &labels,
+	)
+	if err != nil {
+		return product, err
+	}
+
+	if weightGrams.Valid {
+		grams := uint(weightGrams.Int64)
+		recognition.WeightGrams = &grams
+	}
+	recognition.Labels = strings.Fields(labels.String)
+	sort.Strings(recognition.Labels)
+
+	product.Fiscal = &fiscal
+	product.Recognition = &recognition
+	return product, nil
+}
+
+func fiscalOrDefault(product models.Product) *models.ProductFiscal {
+	if product.Fiscal == nil {
+		return models.DefaultProductFiscal()
+	}
+	return product.Fiscal
+}
+
+func recognitionOrDefault(product models.Product) *models.ProductRecognition {
+	if product.Recognition == nil {
+		return &models.ProductRecognition{Labels: make([]string, 0), WeightTolerance: models.DefaultWeightTolerance}
+	}
+	return product.Recognition
+}
+
+// likeEscaper makes the wildcards of LIKE match themselves, so searching for "100%" only finds names with a percent sign.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func productFilterClause(filter repository.ProductFilter) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if filter.Name != "" {
+		conditions = append(conditions, `name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(filter.Name)+"%")
+	}
+	if filter.MinPrice != nil {
+		conditions = append(conditions, `price >= ?`)
+		args = append(args, filter.MinPrice.Amount)
+	}
+	if filter.MaxPrice != nil {
+		conditions = append(conditions, `price <= ?`)
+		args = append(args, filter.MaxPrice.Amount)
+	}
+	if filter.Label != "" {
+		conditions = append(conditions, `id IN (SELECT product_id FROM product_labels WHERE label = ?)`)
+		args = append(args, filter.Label)
+	}
+	if filter.Labelled {
+		conditions = append(conditions, `id IN (SELECT product_id FROM product_labels)`)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+
+	return ` WHERE ` + strings.Join(conditions, ` AND `), args
+}
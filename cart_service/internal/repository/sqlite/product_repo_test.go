@@ -7,8 +7,10 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
 	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,6 +23,15 @@ func createProductSetup() (repository.ProductRepository, *sql.DB, sqlmock.Sqlmoc
 	return sqlite.NewProductRepository(db), db, mock
 }
 
+var productColumns = []string{
+	"id", "name", "price", "currency", "description", "image_url", "tax_class",
+	"ncm", "cest", "cfop", "origin", "csosn", "unit", "weight_grams", "weight_tolerance", "labels",
+}
+
+func grams(g uint) *uint {
+	return &g
+}
+
 func TestProductRepo(t *testing.T) {
 	t.Run("GetProduct", func(t *testing.T) {
 		t.Run("Success", func(t *testing.T) {
@@ -31,13 +42,20 @@ func TestProductRepo(t *testing.T) {
 			expectedProduct := models.Product{
 				ID:          "1",
 				Name:        "Pureisteixo 5",
-				Price:       8999.99,
+				Price:       money.Cents(899999),
 				Description: optional("asdf"),
 				ImageURL:    optional("https://someurl.com/pureisteixo5"),
+				TaxClass:    "standard",
+				Fiscal:      &models.ProductFiscal{NCM: "95045000", CFOP: "5102", Origin: 2, CSOSN: "102", Unit: "UN"},
+				Recognition: &models.ProductRecognition{Labels: []string{"ps5", "ps5_box"}, WeightGrams: grams(4500), WeightTolerance: 10},
 			}
 
-			rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "image_url"}).
-				AddRow(expectedProduct.ID, expectedProduct.Name, expectedProduct.Price, expectedProduct.Description, expectedProduct.ImageURL)
+			rows := sqlmock.NewRows(productColumns).
+				AddRow(
+					expectedProduct.ID, expectedProduct.Name, expectedProduct.Price.Amount, expectedProduct.Price.Currency,
+					expectedProduct.Description, expectedProduct.ImageURL, "standard", "95045000", "", "5102", 2, "102", "UN",
+					4500, 10, "ps5_box ps5",
+				)
 
 			mock.ExpectQuery(`SELECT .* FROM products WHERE id = ?`).WithArgs(productId).WillReturnRows(rows)
 
@@ -74,4 +92,230 @@ func TestProductRepo(t *testing.T) {
 		})
 
 	})
+	t.Run("ListProducts", func(t *testing.T) {
+		t.Run("Success with filters", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			minPrice, maxPrice := money.Cents(100), money.Cents(1000)
+			filter := repository.ProductFilter{
+				Name:     "Coca",
+				MinPrice: &minPrice,
+				MaxPrice: &maxPrice,
+				Limit:    20,
+				Offset:   40,
+			}
+
+			rows := sqlmock.NewRows(productColumns).
+				AddRow("1", "Coca Cola", 599, "BRL", nil, nil, "standard", "22021000", "0300700", "5405", 0, "500", "UN", nil, 50, nil).
+				AddRow("7", "Coca Cola Soda 350ml", 399, "BRL", nil, nil, "standard", "22021000", "0300700", "5405", 0, "500", "UN", 387, 50, "coke_soda")
+
+			mock.ExpectQuery(`SELECT .* FROM products WHERE name LIKE \? ESCAPE '\\' AND price >= \? AND price <= \? ORDER BY .* LIMIT \? OFFSET \?`).
+				WithArgs("%Coca%", 100, 1000, 20, 40).
+				WillReturnRows(rows)
+
+			products, err := repo.ListProducts(filter)
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			fiscal := &models.ProductFiscal{NCM: "22021000", CEST: "0300700", CFOP: "5405", CSOSN: "500", Unit: "UN"}
+			assert.EqualValues(t, []models.Product{
+				{
+					ID: "1", Name: "Coca Cola", Price: money.Cents(599), TaxClass: "standard", Fiscal: fiscal,
+					Recognition: &models.ProductRecognition{Labels: []string{}, WeightTolerance: 50},
+				},
+				{
+					ID: "7", Name: "Coca Cola Soda 350ml", Price: money.Cents(399), TaxClass: "standard", Fiscal: fiscal,
+					Recognition: &models.ProductRecognition{Labels: []string{"coke_soda"}, WeightGrams: grams(387), WeightTolerance: 50},
+				},
+			}, products)
+		})
+
+		t.Run("Success with wildcards in the name", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM products WHERE name LIKE \? ESCAPE`).
+				WithArgs(`%100\% suco\_uva\\%`, 20, 0).
+				WillReturnRows(sqlmock.NewRows(productColumns))
+
+			_, err := repo.ListProducts(repository.ProductFilter{Name: `100% suco_uva\`, Limit: 20})
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Success with label", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM products WHERE id IN \(SELECT product_id FROM product_labels WHERE label = \?\) ORDER BY name, id LIMIT \? OFFSET \?`).
+				WithArgs("coke_soda", 20, 0).
+				WillReturnRows(sqlmock.NewRows(productColumns))
+
+			_, err := repo.ListProducts(repository.ProductFilter{Label: "coke_soda", Limit: 20})
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Success without filters", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM products ORDER BY name, id$`).
+				WithArgs().
+				WillReturnRows(sqlmock.NewRows(productColumns))
+
+			products, err := repo.ListProducts(repository.ProductFilter{})
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.Empty(t, products)
+			assert.NotNil(t, products)
+		})
+
+		t.Run("Error", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			expectedError := errors.New("boom")
+			mock.ExpectQuery(`SELECT .* FROM products`).WillReturnError(expectedError)
+
+			_, err := repo.ListProducts(repository.ProductFilter{})
+			assert.ErrorIs(t, err, expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("CountProducts", func(t *testing.T) {
+		repo, _, mock := createProductSetup()
+
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products WHERE name LIKE \?`).
+			WithArgs("%Leite%").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		total, err := repo.CountProducts(repository.ProductFilter{Name: "Leite", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("CreateProduct", func(t *testing.T) {
+		product := models.Product{
+			ID:       "12",
+			Name:     "Guaraná",
+			Price:    money.Cents(449),
+			ImageURL: optional("https://example.com/guarana.png"),
+			TaxClass: "standard",
+			Fiscal:   &models.ProductFiscal{NCM: "22021000", CEST: "0300700", CFOP: "5405", CSOSN: "500", Unit: "UN"},
+			Recognition: &models.ProductRecognition{
+				Labels:          []string{"guarana_soda"},
+				WeightGrams:     grams(361),
+				WeightTolerance: 30,
+			},
+		}
+
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO products`).
+				WithArgs(
+					product.ID, product.Name, product.Price.Amount, product.Price.Currency, product.Description, product.ImageURL, "standard",
+					"22021000", "0300700", "5405", uint(0), "500", "UN", 361, 30,
+				).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO product_labels \(label, product_id\) VALUES \(\?, \?\)`).
+				WithArgs("guarana_soda", product.ID).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+
+			assert.NoError(t, repo.CreateProduct(product))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with duplicated id", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO products`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey})
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.CreateProduct(product), sqlite.ErrProductAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with label of another product", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO products`).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec(`INSERT INTO product_labels`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey})
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.CreateProduct(product), sqlite.ErrLabelTaken)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("UpdateProduct", func(t *testing.T) {
+		product := models.Product{ID: "1", Name: "Coca Cola 2L", Price: money.Cents(999)}
+
+		t.Run("Success with the default fiscal and recognition data", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE products SET .* updated_at = current_timestamp WHERE id = ?`).
+				WithArgs(
+					product.Name, product.Price.Amount, product.Price.Currency, product.Description, product.ImageURL, "",
+					"", "", "5102", uint(0), "102", "UN", nil, 50, product.ID,
+				).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`DELETE FROM product_labels WHERE product_id = ?`).
+				WithArgs(product.ID).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			assert.NoError(t, repo.UpdateProduct(product))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown product", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE products`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.UpdateProduct(product), sqlite.ErrProductNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("DeleteProduct", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM product_labels WHERE product_id = ?`).
+				WithArgs("3").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`DELETE FROM products WHERE id = ?`).
+				WithArgs("3").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			assert.NoError(t, repo.DeleteProduct("3"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown product", func(t *testing.T) {
+			repo, _, mock := createProductSetup()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM product_labels`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`DELETE FROM products`).
+				WithArgs("404").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			assert.ErrorIs(t, repo.DeleteProduct("404"), sqlite.ErrProductNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
 }
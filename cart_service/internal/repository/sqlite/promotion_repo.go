@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrPromotionNotFound      = repository.ErrPromotionNotFound
+	ErrPromotionAlreadyExists = repository.ErrPromotionAlreadyExists
+)
+
+const promotionColumns = `id, name, kind, product_id, percent, amount, currency, buy_quantity, get_quantity, starts_at, ends_at`
+
+type promotionRepository struct {
+	db *sql.DB
+}
+
+func NewPromotionRepository(db *sql.DB) repository.PromotionRepository {
+	return &promotionRepository{db}
+}
+
+func (p *promotionRepository) CreatePromotion(promotion *models.Promotion) error {
+	const query = `INSERT INTO promotions (` + promotionColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := p.db.Exec(
+		query, promotion.ID, promotion.Name, promotion.Kind, promotion.ProductID, promotion.Percent,
+		promotion.Amount.Amount, promotion.Amount.Currency, promotion.BuyQuantity, promotion.GetQuantity,
+		nullTime(promotion.StartsAt), nullTime(promotion.EndsAt),
+	)
+	if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+		return ErrPromotionAlreadyExists
+	}
+
+	return err
+}
+
+func (p *promotionRepository) GetPromotion(promotionId string) (*models.Promotion, error) {
+	const query = `SELECT ` + promotionColumns + ` FROM promotions WHERE id = ?`
+
+	promotion, err := scanPromotion(p.db.QueryRow(query, promotionId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+func (p *promotionRepository) ListPromotions() ([]*models.Promotion, error) {
+	const query = `SELECT ` + promotionColumns + ` FROM promotions ORDER BY created_at, id`
+
+	rows, err := p.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := make([]*models.Promotion, 0)
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, promotion)
+	}
+
+	return promotions, rows.Err()
+}
+
+func (p *promotionRepository) DeletePromotion(promotionId string) error {
+	const query = `DELETE FROM promotions WHERE id = ?`
+
+	result, err := p.db.Exec(query, promotionId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrPromotionNotFound)
+}
+
+func scanPromotion(row scanner) (*models.Promotion, error) {
+	var (
+		promotion        models.Promotion
+		startsAt, endsAt sql.NullTime
+	)
+
+	if err := row.Scan(
+		&promotion.ID, &promotion.Name, &promotion.Kind, &promotion.ProductID, &promotion.Percent,
+		&promotion.Amount.Amount, &promotion.Amount.Currency, &promotion.BuyQuantity, &promotion.GetQuantity,
+		&startsAt, &endsAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if startsAt.Valid {
+		promotion.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		promotion.EndsAt = &endsAt.Time
+	}
+
+	return &promotion, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
@@ -0,0 +1,145 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/money"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createPromotionSetup() (repository.PromotionRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewPromotionRepository(db), db, mock
+}
+
+var promotionColumns = []string{
+	"id", "name", "kind", "product_id", "percent", "amount", "currency",
+	"buy_quantity", "get_quantity", "starts_at", "ends_at",
+}
+
+func TestPromotionRepo(t *testing.T) {
+	endsAt := time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	promotion := &models.Promotion{
+		ID:          "chamyto-3-for-25",
+		Name:        "3 Chamyto for 25.00",
+		Kind:        models.PromotionMultiBuy,
+		ProductID:   "5",
+		Amount:      money.Cents(2500),
+		BuyQuantity: 3,
+		EndsAt:      &endsAt,
+	}
+
+	t.Run("CreatePromotion", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createPromotionSetup()
+
+			mock.ExpectExec(`INSERT INTO promotions`).
+				WithArgs(
+					promotion.ID, promotion.Name, promotion.Kind, promotion.ProductID, 0,
+					2500, money.BRL, 3, 0, sql.NullTime{}, sql.NullTime{Time: endsAt, Valid: true},
+				).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			assert.NoError(t, repo.CreatePromotion(promotion))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with duplicated id", func(t *testing.T) {
+			repo, _, mock := createPromotionSetup()
+
+			mock.ExpectExec(`INSERT INTO promotions`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey})
+
+			assert.ErrorIs(t, repo.CreatePromotion(promotion), sqlite.ErrPromotionAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetPromotion", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createPromotionSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM promotions WHERE id = ?`).
+				WithArgs(promotion.ID).
+				WillReturnRows(sqlmock.NewRows(promotionColumns).
+					AddRow(promotion.ID, promotion.Name, "multi_buy", "5", 0, 2500, "BRL", 3, 0, nil, endsAt))
+
+			got, err := repo.GetPromotion(promotion.ID)
+			require.NoError(t, err)
+			assert.Equal(t, promotion, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown promotion", func(t *testing.T) {
+			repo, _, mock := createPromotionSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM promotions WHERE id = ?`).
+				WithArgs("nope").
+				WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.GetPromotion("nope")
+			assert.ErrorIs(t, err, sqlite.ErrPromotionNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListPromotions", func(t *testing.T) {
+		repo, _, mock := createPromotionSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM promotions ORDER BY created_at, id`).
+			WillReturnRows(sqlmock.NewRows(promotionColumns).
+				AddRow("a", "10% off", "percentage", "1", 10, 0, "BRL", 0, 0, nil, nil).
+				AddRow("b", "Buy 2 get 1", "buy_x_get_y", "6", 0, 0, "BRL", 2, 1, nil, nil))
+
+		promotions, err := repo.ListPromotions()
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		require.Len(t, promotions, 2)
+		assert.Equal(t, uint(10), promotions[0].Percent)
+		assert.Equal(t, models.PromotionBuyXGetY, promotions[1].Kind)
+		assert.Nil(t, promotions[1].StartsAt)
+	})
+
+	t.Run("DeletePromotion", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createPromotionSetup()
+
+			mock.ExpectExec(`DELETE FROM promotions WHERE id = ?`).
+				WithArgs("a").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			assert.NoError(t, repo.DeletePromotion("a"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown promotion", func(t *testing.T) {
+			repo, _, mock := createPromotionSetup()
+
+			mock.ExpectExec(`DELETE FROM promotions`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			assert.ErrorIs(t, repo.DeletePromotion("a"), sqlite.ErrPromotionNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error", func(t *testing.T) {
+			repo, _, mock := createPromotionSetup()
+
+			expectedError := errors.New("boom")
+			mock.ExpectExec(`DELETE FROM promotions`).WillReturnError(expectedError)
+
+			assert.ErrorIs(t, repo.DeletePromotion("a"), expectedError)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+}
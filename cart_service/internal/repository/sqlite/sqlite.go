@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// expectAffected returns notFound when result reports that no rows were touched.
+func expectAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func isConstraintError(err error, codes ...sqlite3.ErrNoExtended) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	for _, code := range codes {
+		if sqliteErr.ExtendedCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// inTx runs fn in a transaction, committed only if fn succeeds.
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,326 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+)
+
+var (
+	ErrStockNotFound     = repository.ErrStockNotFound
+	ErrInsufficientStock = repository.ErrInsufficientStock
+)
+
+// stockQuery selects the stock levels along with the units held by unexpired reservations of open carts.
+// Its arguments are the current time and the statuses of the open carts, as given by reservationArgs.
+const stockQuery = `
+        SELECT
+          s.product_id, s.on_hand, s.low_stock_threshold, s.updated_at,
+          COALESCE((
+            SELECT SUM(r.quantity)
+            FROM stock_reservations r JOIN carts c ON r.cart_id = c.id
+            WHERE r.product_id = s.product_id AND r.expires_at > ? AND c.status IN (?, ?)
+          ), 0)
+        FROM
+          stock s
+`
+
+const stockAdjustmentColumns = `id, product_id, delta, reason, note, order_id, on_hand, created_at`
+
+const stockAlertColumns = `id, product_id, on_hand, threshold, created_at, resolved_at`
+
+type stockRepository struct {
+	db *sql.DB
+}
+
+func NewStockRepository(db *sql.DB) repository.StockRepository {
+	return &stockRepository{db}
+}
+
+func (s *stockRepository) TrackStock(productId string, lowStockThreshold uint) (*models.StockLevel, error) {
+	// Selecting from products keeps unknown products from being tracked
+	const query = `
+        INSERT INTO
+          stock (product_id, low_stock_threshold)
+        SELECT
+          id, ? FROM products WHERE id = ?
+        ON CONFLICT(product_id) DO
+        UPDATE
+        SET
+          low_stock_threshold = excluded.low_stock_threshold, updated_at = current_timestamp
+    `
+
+	result, err := s.db.Exec(query, lowStockThreshold, productId)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectAffected(result, ErrProductNotFound); err != nil {
+		return nil, err
+	}
+
+	return s.GetStock(productId)
+}
+
+func (s *stockRepository) GetStock(productId string) (*models.StockLevel, error) {
+	return getStock(s.db, productId, time.Now().UTC())
+}
+
+func (s *stockRepository) ListStock(lowOnly bool) ([]*models.StockLevel, error) {
+	query := stockQuery
+	if lowOnly {
+		query += ` WHERE s.on_hand <= s.low_stock_threshold`
+	}
+	query += ` ORDER BY s.product_id`
+
+	rows, err := s.db.Query(query, reservationArgs(time.Now().UTC())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := make([]*models.StockLevel, 0)
+	for rows.Next() {
+		level, err := scanStockLevel(rows)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, rows.Err()
+}
+
+func (s *stockRepository) AdjustStock(adjustment *models.StockAdjustment) (*models.StockLevel, error) {
+	var level *models.StockLevel
+
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+
+		current, err := getStock(tx, adjustment.ProductID, now)
+		if err != nil {
+			return err
+		}
+		if current.OnHand+adjustment.Delta < 0 {
+			return ErrInsufficientStock
+		}
+
+		if err := adjustStock(tx, adjustment, now); err != nil {
+			return err
+		}
+
+		level, err = getStock(tx, adjustment.ProductID, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return level, nil
+}
+
+func (s *stockRepository) ListAdjustments(productId string) ([]*models.StockAdjustment, error) {
+	const query = `SELECT ` + stockAdjustmentColumns + ` FROM stock_adjustments WHERE product_id = ? ORDER BY id DESC`
+
+	// Make untracked products a 404 instead of an empty list
+	if _, err := s.GetStock(productId); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, productId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := make([]*models.StockAdjustment, 0)
+	for rows.Next() {
+		adjustment, err := scanStockAdjustment(rows)
+		if err != nil {
+			return nil, err
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+
+	return adjustments, rows.Err()
+}
+
+func (s *stockRepository) ListAlerts() ([]*models.StockAlert, error) {
+	const query = `SELECT ` + stockAlertColumns + ` FROM stock_alerts WHERE resolved_at IS NULL ORDER BY id`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make([]*models.StockAlert, 0)
+	for rows.Next() {
+		alert, err := scanStockAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+func reservationArgs(now time.Time) []interface{} {
+	return []interface{}{now, models.CartOpen, models.CartCheckingOut}
+}
+
+func getStock(db querier, productId string, now time.Time) (*models.StockLevel, error) {
+	level, err := scanStockLevel(db.QueryRow(stockQuery+` WHERE s.product_id = ?`, append(reservationArgs(now), productId)...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStockNotFound
+		}
+		return nil, err
+	}
+
+	return level, nil
+}
+
+// adjustStock applies the adjustment to the units on hand of a tracked product and records it,
+// opening a low stock alert when it takes them down to the threshold and resolving the open
+// ones when it brings them back above it.
+func adjustStock(tx *sql.Tx, adjustment *models.StockAdjustment, now time.Time) error {
+	const updateQuery = `UPDATE stock SET on_hand = on_hand + ?, updated_at = ? WHERE product_id = ? RETURNING on_hand, low_stock_threshold`
+	const insertQuery = `
+        INSERT INTO
+          stock_adjustments (product_id, delta, reason, note, order_id, on_hand, created_at)
+        VALUES
+          (?, ?, ?, ?, ?, ?, ?)
+    `
+	const alertQuery = `INSERT INTO stock_alerts (product_id, on_hand, threshold, created_at) VALUES (?, ?, ?, ?)`
+	const resolveQuery = `UPDATE stock_alerts SET resolved_at = ? WHERE product_id = ? AND resolved_at IS NULL`
+
+	var threshold int
+	if err := tx.QueryRow(updateQuery, adjustment.Delta, now, adjustment.ProductID).Scan(&adjustment.OnHand, &threshold); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStockNotFound
+		}
+		return err
+	}
+	adjustment.CreatedAt = now
+
+	result, err := tx.Exec(
+		insertQuery, adjustment.ProductID, adjustment.Delta, adjustment.Reason,
+		adjustment.Note, adjustment.OrderID, adjustment.OnHand, now,
+	)
+	if err != nil {
+		return err
+	}
+	if adjustment.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	before := adjustment.OnHand - adjustment.Delta
+	switch {
+	case before > threshold && adjustment.OnHand <= threshold:
+		_, err = tx.Exec(alertQuery, adjustment.ProductID, adjustment.OnHand, threshold, now)
+	case before <= threshold && adjustment.OnHand > threshold:
+		_, err = tx.Exec(resolveQuery, now, adjustment.ProductID)
+	}
+
+	return err
+}
+
+// sellStock takes the units of the order out of stock and releases the reservations of its cart.
+// Untracked products are skipped, and tracked ones may go below zero since the units already left.
+func sellStock(tx *sql.Tx, order *models.Order) error {
+	const releaseQuery = `DELETE FROM stock_reservations WHERE cart_id = ?`
+
+	for _, line := range order.Lines {
+		err := adjustStock(tx, &models.StockAdjustment{
+			ProductID: line.ProductID,
+			Delta:     -int(line.Quantity),
+			Reason:    models.StockSale,
+			OrderID:   &order.ID,
+		}, order.CreatedAt)
+		if err != nil && !errors.Is(err, ErrStockNotFound) {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(releaseQuery, order.CartID)
+	return err
+}
+
+// reserveCartStock holds the units of tracked products in the cart until expiresAt,
+// replacing the previous reservations of the cart.
+func reserveCartStock(tx *sql.Tx, cartId string, expiresAt time.Time) error {
+	const query = `
+        DELETE FROM
+          stock_reservations
+        WHERE
+          cart_id = ?;
+
+        INSERT INTO
+          stock_reservations (cart_id, product_id, quantity, expires_at)
+        SELECT
+          cp.cart_id, cp.product_id, cp.quantity, ?
+        FROM
+          cart_products cp
+          JOIN stock s ON cp.product_id = s.product_id
+        WHERE
+          cp.cart_id = ?;
+    `
+
+	_, err := tx.Exec(query, cartId, expiresAt, cartId)
+	return err
+}
+
+func scanStockLevel(row scanner) (*models.StockLevel, error) {
+	var level models.StockLevel
+
+	if err := row.Scan(&level.ProductID, &level.OnHand, &level.LowStockThreshold, &level.UpdatedAt, &level.Reserved); err != nil {
+		return nil, err
+	}
+	level.Available = level.OnHand - int(level.Reserved)
+	level.Low = level.OnHand <= int(level.LowStockThreshold)
+
+	return &level, nil
+}
+
+func scanStockAdjustment(row scanner) (*models.StockAdjustment, error) {
+	var (
+		adjustment models.StockAdjustment
+		note       sql.NullString
+		orderId    sql.NullString
+	)
+
+	if err := row.Scan(
+		&adjustment.ID, &adjustment.ProductID, &adjustment.Delta, &adjustment.Reason,
+		&note, &orderId, &adjustment.OnHand, &adjustment.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if note.Valid {
+		adjustment.Note = &note.String
+	}
+	if orderId.Valid {
+		adjustment.OrderID = &orderId.String
+	}
+
+	return &adjustment, nil
+}
+
+func scanStockAlert(row scanner) (*models.StockAlert, error) {
+	var (
+		alert      models.StockAlert
+		resolvedAt sql.NullTime
+	)
+
+	if err := row.Scan(&alert.ID, &alert.ProductID, &alert.OnHand, &alert.Threshold, &alert.CreatedAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		alert.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &alert, nil
+}
@@ -0,0 +1,173 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createStockSetup() (repository.StockRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewStockRepository(db), db, mock
+}
+
+var stockColumns = []string{"product_id", "on_hand", "low_stock_threshold", "updated_at", "reserved"}
+var stockAdjustmentColumns = []string{"id", "product_id", "delta", "reason", "note", "order_id", "on_hand", "created_at"}
+var stockAlertColumns = []string{"id", "product_id", "on_hand", "threshold", "created_at", "resolved_at"}
+
+func expectStockLevel(mock sqlmock.Sqlmock, productId string, rows *sqlmock.Rows) {
+	mock.ExpectQuery(`SELECT .* FROM stock_reservations r JOIN carts c .* FROM stock s WHERE s.product_id = ?`).
+		WithArgs(sqlmock.AnyArg(), models.CartOpen, models.CartCheckingOut, productId).
+		WillReturnRows(rows)
+}
+
+func TestStockRepo(t *testing.T) {
+	now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	t.Run("TrackStock", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createStockSetup()
+
+			mock.ExpectExec(`INSERT INTO stock \(product_id, low_stock_threshold\) SELECT id, \? FROM products WHERE id = \? ON CONFLICT`).
+				WithArgs(12, "1").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			expectStockLevel(mock, "1", sqlmock.NewRows(stockColumns).AddRow("1", 0, 12, now, 0))
+
+			level, err := repo.TrackStock("1", 12)
+			require.NoError(t, err)
+			assert.Equal(t, &models.StockLevel{ProductID: "1", LowStockThreshold: 12, Low: true, UpdatedAt: now}, level)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown product", func(t *testing.T) {
+			repo, _, mock := createStockSetup()
+
+			mock.ExpectExec(`INSERT INTO stock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			_, err := repo.TrackStock("404", 1)
+			assert.ErrorIs(t, err, sqlite.ErrProductNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetStock", func(t *testing.T) {
+		t.Run("Success with units reserved by carts", func(t *testing.T) {
+			repo, _, mock := createStockSetup()
+
+			expectStockLevel(mock, "1", sqlmock.NewRows(stockColumns).AddRow("1", 48, 12, now, 40))
+
+			level, err := repo.GetStock("1")
+			require.NoError(t, err)
+			assert.Equal(t, 48, level.OnHand)
+			assert.Equal(t, uint(40), level.Reserved)
+			assert.Equal(t, 8, level.Available)
+			assert.False(t, level.Low)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with untracked product", func(t *testing.T) {
+			repo, _, mock := createStockSetup()
+
+			expectStockLevel(mock, "5", sqlmock.NewRows(stockColumns))
+
+			_, err := repo.GetStock("5")
+			assert.ErrorIs(t, err, sqlite.ErrStockNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListStock", func(t *testing.T) {
+		repo, _, mock := createStockSetup()
+
+		mock.ExpectQuery(`FROM stock s WHERE s.on_hand <= s.low_stock_threshold ORDER BY s.product_id`).
+			WithArgs(sqlmock.AnyArg(), models.CartOpen, models.CartCheckingOut).
+			WillReturnRows(sqlmock.NewRows(stockColumns).AddRow("4", 3, 5, now, 1))
+
+		levels, err := repo.ListStock(true)
+		require.NoError(t, err)
+		require.Len(t, levels, 1)
+		assert.Equal(t, 2, levels[0].Available)
+		assert.True(t, levels[0].Low)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("AdjustStock", func(t *testing.T) {
+		t.Run("Success resolving the alerts of a restocked product", func(t *testing.T) {
+			repo, _, mock := createStockSetup()
+			note := "Weekly delivery"
+
+			mock.ExpectBegin()
+			expectStockLevel(mock, "4", sqlmock.NewRows(stockColumns).AddRow("4", 3, 5, now, 0))
+			mock.ExpectQuery(`UPDATE stock SET on_hand = on_hand \+ \?`).
+				WithArgs(24, sqlmock.AnyArg(), "4").
+				WillReturnRows(sqlmock.NewRows([]string{"on_hand", "low_stock_threshold"}).AddRow(27, 5))
+			mock.ExpectExec(`INSERT INTO stock_adjustments`).
+				WithArgs("4", 24, models.StockReceived, note, nil, 27, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(7, 1))
+			mock.ExpectExec(`UPDATE stock_alerts SET resolved_at = \? WHERE product_id = \? AND resolved_at IS NULL`).
+				WithArgs(sqlmock.AnyArg(), "4").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectStockLevel(mock, "4", sqlmock.NewRows(stockColumns).AddRow("4", 27, 5, now, 0))
+			mock.ExpectCommit()
+
+			adjustment := &models.StockAdjustment{ProductID: "4", Delta: 24, Reason: models.StockReceived, Note: &note}
+			level, err := repo.AdjustStock(adjustment)
+			require.NoError(t, err)
+			assert.Equal(t, 27, level.OnHand)
+			assert.False(t, level.Low)
+			assert.Equal(t, int64(7), adjustment.ID)
+			assert.Equal(t, 27, adjustment.OnHand)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error taking more units than on hand", func(t *testing.T) {
+			repo, _, mock := createStockSetup()
+
+			mock.ExpectBegin()
+			expectStockLevel(mock, "4", sqlmock.NewRows(stockColumns).AddRow("4", 3, 5, now, 0))
+			mock.ExpectRollback()
+
+			_, err := repo.AdjustStock(&models.StockAdjustment{ProductID: "4", Delta: -4, Reason: models.StockShrinkage})
+			assert.ErrorIs(t, err, sqlite.ErrInsufficientStock)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListAdjustments", func(t *testing.T) {
+		repo, _, mock := createStockSetup()
+
+		expectStockLevel(mock, "1", sqlmock.NewRows(stockColumns).AddRow("1", 45, 12, now, 0))
+		mock.ExpectQuery(`SELECT .* FROM stock_adjustments WHERE product_id = \? ORDER BY id DESC`).
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows(stockAdjustmentColumns).
+				AddRow(2, "1", -3, "sale", nil, "o1", 45, now).
+				AddRow(1, "1", 48, "received", nil, nil, 48, now))
+
+		adjustments, err := repo.ListAdjustments("1")
+		require.NoError(t, err)
+		require.Len(t, adjustments, 2)
+		require.NotNil(t, adjustments[0].OrderID)
+		assert.Equal(t, "o1", *adjustments[0].OrderID)
+		assert.Nil(t, adjustments[1].OrderID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ListAlerts", func(t *testing.T) {
+		repo, _, mock := createStockSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM stock_alerts WHERE resolved_at IS NULL`).
+			WillReturnRows(sqlmock.NewRows(stockAlertColumns).AddRow(1, "4", 3, 5, now, nil))
+
+		alerts, err := repo.ListAlerts()
+		require.NoError(t, err)
+		assert.Equal(t, []*models.StockAlert{{ID: 1, ProductID: "4", OnHand: 3, Threshold: 5, CreatedAt: now}}, alerts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
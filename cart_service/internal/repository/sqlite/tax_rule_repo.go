@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrTaxRuleNotFound      = repository.ErrTaxRuleNotFound
+	ErrTaxRuleAlreadyExists = repository.ErrTaxRuleAlreadyExists
+)
+
+const taxRuleColumns = `id, name, tax_class, tax, rate, inclusive`
+
+type taxRuleRepository struct {
+	db *sql.DB
+}
+
+func NewTaxRuleRepository(db *sql.DB) repository.TaxRuleRepository {
+	return &taxRuleRepository{db}
+}
+
+func (t *taxRuleRepository) CreateTaxRule(rule *models.TaxRule) error {
+	const query = `INSERT INTO tax_rules (` + taxRuleColumns + `) VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err := t.db.Exec(query, rule.ID, rule.Name, rule.TaxClass, rule.Tax, rule.Rate, rule.Inclusive)
+	if isConstraintError(err, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintUnique) {
+		return ErrTaxRuleAlreadyExists
+	}
+
+	return err
+}
+
+func (t *taxRuleRepository) GetTaxRule(ruleId string) (*models.TaxRule, error) {
+	const query = `SELECT ` + taxRuleColumns + ` FROM tax_rules WHERE id = ?`
+
+	rule, err := scanTaxRule(t.db.QueryRow(query, ruleId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTaxRuleNotFound
+		}
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (t *taxRuleRepository) ListTaxRules() ([]*models.TaxRule, error) {
+	const query = `SELECT ` + taxRuleColumns + ` FROM tax_rules ORDER BY tax_class, created_at, id`
+
+	rows, err := t.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]*models.TaxRule, 0)
+	for rows.Next() {
+		rule, err := scanTaxRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (t *taxRuleRepository) DeleteTaxRule(ruleId string) error {
+	const query = `DELETE FROM tax_rules WHERE id = ?`
+
+	result, err := t.db.Exec(query, ruleId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrTaxRuleNotFound)
+}
+
+func scanTaxRule(row scanner) (*models.TaxRule, error) {
+	var rule models.TaxRule
+
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.TaxClass, &rule.Tax, &rule.Rate, &rule.Inclusive); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
@@ -0,0 +1,106 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTaxRuleSetup() (repository.TaxRuleRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewTaxRuleRepository(db), db, mock
+}
+
+var taxRuleColumns = []string{"id", "name", "tax_class", "tax", "rate", "inclusive"}
+
+func TestTaxRuleRepo(t *testing.T) {
+	rule := &models.TaxRule{
+		ID:        "icms-standard",
+		Name:      "ICMS 18%",
+		TaxClass:  "standard",
+		Tax:       models.TaxICMS,
+		Rate:      1800,
+		Inclusive: true,
+	}
+
+	t.Run("CreateTaxRule", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createTaxRuleSetup()
+
+			mock.ExpectExec(`INSERT INTO tax_rules`).
+				WithArgs(rule.ID, rule.Name, rule.TaxClass, rule.Tax, rule.Rate, rule.Inclusive).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			assert.NoError(t, repo.CreateTaxRule(rule))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with the tax already charged on the class", func(t *testing.T) {
+			repo, _, mock := createTaxRuleSetup()
+
+			mock.ExpectExec(`INSERT INTO tax_rules`).
+				WillReturnError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique})
+
+			assert.ErrorIs(t, repo.CreateTaxRule(rule), sqlite.ErrTaxRuleAlreadyExists)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetTaxRule", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createTaxRuleSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM tax_rules WHERE id = ?`).
+				WithArgs(rule.ID).
+				WillReturnRows(sqlmock.NewRows(taxRuleColumns).AddRow("icms-standard", "ICMS 18%", "standard", "icms", 1800, true))
+
+			got, err := repo.GetTaxRule(rule.ID)
+			require.NoError(t, err)
+			assert.Equal(t, rule, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error with unknown rule", func(t *testing.T) {
+			repo, _, mock := createTaxRuleSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM tax_rules`).WillReturnError(sql.ErrNoRows)
+
+			_, err := repo.GetTaxRule("nope")
+			assert.ErrorIs(t, err, sqlite.ErrTaxRuleNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("ListTaxRules", func(t *testing.T) {
+		repo, _, mock := createTaxRuleSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM tax_rules ORDER BY tax_class`).
+			WillReturnRows(sqlmock.NewRows(taxRuleColumns).
+				AddRow("icms-food", "ICMS 7%", "food", "icms", 700, true).
+				AddRow("icms-standard", "ICMS 18%", "standard", "icms", 1800, true))
+
+		rules, err := repo.ListTaxRules()
+		require.NoError(t, err)
+		require.Len(t, rules, 2)
+		assert.Equal(t, uint(700), rules[0].Rate)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("DeleteTaxRule", func(t *testing.T) {
+		repo, _, mock := createTaxRuleSetup()
+
+		mock.ExpectExec(`DELETE FROM tax_rules WHERE id = ?`).
+			WithArgs("nope").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.ErrorIs(t, repo.DeleteTaxRule("nope"), sqlite.ErrTaxRuleNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+)
+
+var (
+	ErrCartWeightNotFound = repository.ErrCartWeightNotFound
+	ErrAnomalyNotFound    = repository.ErrAnomalyNotFound
+)
+
+const cartWeightColumns = `cart_id, measured_grams, expected_grams, reconciled, mismatch_since, measured_at`
+
+const anomalyColumns = `id, cart_id, kind, expected_grams, measured_grams, since, created_at, resolved_at`
+
+type cartWeightRepository struct {
+	db *sql.DB
+}
+
+func NewCartWeightRepository(db *sql.DB) repository.CartWeightRepository {
+	return &cartWeightRepository{db}
+}
+
+func (w *cartWeightRepository) ExpectedWeight(cartId string) (float64, bool, error) {
+	const query = `
+        SELECT
+          COALESCE(SUM(cp.quantity * p.weight_grams), 0),
+          COUNT(*) FILTER (WHERE p.weight_grams IS NULL),
+          (SELECT COUNT(*) FROM cart_pending_items WHERE cart_id = ? AND status = ?)
+        FROM
+          cart_products cp
+          JOIN products p ON cp.product_id = p.id
+        WHERE
+          cp.cart_id = ?
+    `
+
+	var (
+		grams     float64
+		unweighed int
+		pending   int
+	)
+
+	if err := w.db.QueryRow(query, cartId, models.PendingAwaiting, cartId).Scan(&grams, &unweighed, &pending); err != nil {
+		return 0, false, err
+	}
+
+	return grams, unweighed == 0 && pending == 0, nil
+}
+
+func (w *cartWeightRepository) GetCartWeight(cartId string) (*models.CartWeight, error) {
+	const query = `SELECT ` + cartWeightColumns + ` FROM cart_weights WHERE cart_id = ?`
+
+	var (
+		weight        models.CartWeight
+		mismatchSince sql.NullTime
+	)
+
+	err := w.db.QueryRow(query, cartId).Scan(
+		&weight.CartID, &weight.MeasuredGrams, &weight.ExpectedGrams, &weight.Reconciled, &mismatchSince, &weight.MeasuredAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCartWeightNotFound
+		}
+		return nil, err
+	}
+	if mismatchSince.Valid {
+		weight.MismatchSince = &mismatchSince.Time
+	}
+
+	return &weight, nil
+}
+
+func (w *cartWeightRepository) SaveCartWeight(weight *models.CartWeight) error {
+	const query = `
+        INSERT INTO
+          cart_weights (` + cartWeightColumns + `)
+        VALUES
+          (?, ?, ?, ?, ?, ?)
+        ON CONFLICT(cart_id) DO
+        UPDATE
+        SET
+          measured_grams = excluded.measured_grams,
+          expected_grams = excluded.expected_grams,
+          reconciled = excluded.reconciled,
+          mismatch_since = excluded.mismatch_since,
+          measured_at = excluded.measured_at
+    `
+
+	_, err := w.db.Exec(
+		query, weight.CartID, weight.MeasuredGrams, weight.ExpectedGrams,
+		weight.Reconciled, nullTime(weight.MismatchSince), weight.MeasuredAt,
+	)
+
+	return err
+}
+
+func (w *cartWeightRepository) GetOpenAnomaly(cartId string, kind models.AnomalyKind) (*models.CartAnomaly, error) {
+	const query = `SELECT ` + anomalyColumns + ` FROM cart_anomalies WHERE cart_id = ? AND kind = ? AND resolved_at IS NULL`
+
+	anomaly, err := scanAnomaly(w.db.QueryRow(query, cartId, kind))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAnomalyNotFound
+		}
+		return nil, err
+	}
+
+	return anomaly, nil
+}
+
+func (w *cartWeightRepository) CreateAnomaly(anomaly *models.CartAnomaly) error {
+	const query = `
+        INSERT INTO
+          cart_anomalies (cart_id, kind, expected_grams, measured_grams, since, created_at)
+        VALUES
+          (?, ?, ?, ?, ?, ?)
+    `
+
+	result, err := w.db.Exec(
+		query, anomaly.CartID, anomaly.Kind, anomaly.ExpectedGrams,
+		anomaly.MeasuredGrams, anomaly.Since, anomaly.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	anomaly.ID, err = result.LastInsertId()
+	return err
+}
+
+func (w *cartWeightRepository) ResolveAnomaly(anomaly *models.CartAnomaly) error {
+	const query = `UPDATE cart_anomalies SET resolved_at = ? WHERE id = ?`
+
+	result, err := w.db.Exec(query, nullTime(anomaly.ResolvedAt), anomaly.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, ErrAnomalyNotFound)
+}
+
+func (w *cartWeightRepository) ListAnomalies(openOnly bool) ([]*models.CartAnomaly, error) {
+	query := `SELECT ` + anomalyColumns + ` FROM cart_anomalies`
+	if openOnly {
+		query += ` WHERE resolved_at IS NULL`
+	}
+	query += ` ORDER BY id DESC`
+
+	rows, err := w.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anomalies := make([]*models.CartAnomaly, 0)
+	for rows.Next() {
+		anomaly, err := scanAnomaly(rows)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	return anomalies, rows.Err()
+}
+
+func scanAnomaly(row scanner) (*models.CartAnomaly, error) {
+	var (
+		anomaly    models.CartAnomaly
+		resolvedAt sql.NullTime
+	)
+
+	if err := row.Scan(
+		&anomaly.ID, &anomaly.CartID, &anomaly.Kind, &anomaly.ExpectedGrams,
+		&anomaly.MeasuredGrams, &anomaly.Since, &anomaly.CreatedAt, &resolvedAt,
+	); err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		anomaly.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &anomaly, nil
+}
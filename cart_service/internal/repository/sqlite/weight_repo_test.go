@@ -0,0 +1,176 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fsmiamoto/zcart/cart_service/internal/models"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository"
+	"github.com/fsmiamoto/zcart/cart_service/internal/repository/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createCartWeightSetup() (repository.CartWeightRepository, *sql.DB, sqlmock.Sqlmock) {
+	db, mock := NewMock()
+	return sqlite.NewCartWeightRepository(db), db, mock
+}
+
+var cartWeightColumns = []string{"cart_id", "measured_grams", "expected_grams", "reconciled", "mismatch_since", "measured_at"}
+var anomalyColumns = []string{"id", "cart_id", "kind", "expected_grams", "measured_grams", "since", "created_at", "resolved_at"}
+
+func TestCartWeightRepo(t *testing.T) {
+	now := time.Date(2022, 11, 2, 15, 4, 5, 0, time.UTC)
+
+	t.Run("ExpectedWeight", func(t *testing.T) {
+		expectWeight := func(mock sqlmock.Sqlmock, grams float64, unweighed int, pending int) {
+			mock.ExpectQuery(`SELECT COALESCE\(SUM\(cp.quantity \* p.weight_grams\), 0\), .* FROM cart_products cp JOIN products p`).
+				WithArgs("2", models.PendingAwaiting, "2").
+				WillReturnRows(sqlmock.NewRows([]string{"grams", "unweighed", "pending"}).AddRow(grams, unweighed, pending))
+		}
+
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCartWeightSetup()
+
+			expectWeight(mock, 774, 0, 0)
+
+			grams, known, err := repo.ExpectedWeight("2")
+			require.NoError(t, err)
+			assert.Equal(t, 774.0, grams)
+			assert.True(t, known)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Unknown with products without weight", func(t *testing.T) {
+			repo, _, mock := createCartWeightSetup()
+
+			expectWeight(mock, 387, 1, 0)
+
+			_, known, err := repo.ExpectedWeight("2")
+			require.NoError(t, err)
+			assert.False(t, known)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Unknown with pending items", func(t *testing.T) {
+			repo, _, mock := createCartWeightSetup()
+
+			expectWeight(mock, 387, 0, 1)
+
+			_, known, err := repo.ExpectedWeight("2")
+			require.NoError(t, err)
+			assert.False(t, known)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("GetCartWeight", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			repo, _, mock := createCartWeightSetup()
+
+			mock.ExpectQuery(`SELECT .* FROM cart_weights WHERE cart_id = ?`).
+				WithArgs("2").
+				WillReturnRows(sqlmock.NewRows(cartWeightColumns).AddRow("2", 1161, 774, true, now, now))
+
+			weight, err := repo.GetCartWeight("2")
+			require.NoError(t, err)
+			assert.Equal(t, &models.CartWeight{
+				CartID:        "2",
+				MeasuredGrams: 1161,
+				ExpectedGrams: 774,
+				Reconciled:    true,
+				MismatchSince: &now,
+				MeasuredAt:    now,
+			}, weight)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("Error without readings", func(t *testing.T) {
+			repo, _, mock := createCartWeightSetup()
+
+			mock.ExpectQuery(`FROM cart_weights`).WillReturnRows(sqlmock.NewRows(cartWeightColumns))
+
+			_, err := repo.GetCartWeight("2")
+			assert.ErrorIs(t, err, sqlite.ErrCartWeightNotFound)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("SaveCartWeight", func(t *testing.T) {
+		repo, _, mock := createCartWeightSetup()
+
+		mock.ExpectExec(`INSERT INTO cart_weights .* ON CONFLICT\(cart_id\) DO UPDATE`).
+			WithArgs("2", 780.0, 774.0, true, sql.NullTime{}, now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := repo.SaveCartWeight(&models.CartWeight{CartID: "2", MeasuredGrams: 780, ExpectedGrams: 774, Reconciled: true, MeasuredAt: now})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetOpenAnomaly", func(t *testing.T) {
+		repo, _, mock := createCartWeightSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM cart_anomalies WHERE cart_id = \? AND kind = \? AND resolved_at IS NULL`).
+			WithArgs("2", models.AnomalyWeightMismatch).
+			WillReturnRows(sqlmock.NewRows(anomalyColumns))
+
+		_, err := repo.GetOpenAnomaly("2", models.AnomalyWeightMismatch)
+		assert.ErrorIs(t, err, sqlite.ErrAnomalyNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("CreateAnomaly", func(t *testing.T) {
+		repo, _, mock := createCartWeightSetup()
+
+		anomaly := &models.CartAnomaly{
+			CartID:        "2",
+			Kind:          models.AnomalyWeightMismatch,
+			ExpectedGrams: 774,
+			MeasuredGrams: 1161,
+			Since:         now.Add(-30 * time.Second),
+			CreatedAt:     now,
+		}
+
+		mock.ExpectExec(`INSERT INTO cart_anomalies`).
+			WithArgs("2", models.AnomalyWeightMismatch, 774.0, 1161.0, anomaly.Since, now).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+
+		require.NoError(t, repo.CreateAnomaly(anomaly))
+		assert.Equal(t, int64(3), anomaly.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ResolveAnomaly", func(t *testing.T) {
+		repo, _, mock := createCartWeightSetup()
+
+		mock.ExpectExec(`UPDATE cart_anomalies SET resolved_at = \? WHERE id = \?`).
+			WithArgs(sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.ResolveAnomaly(&models.CartAnomaly{ID: 3, ResolvedAt: &now}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ListAnomalies", func(t *testing.T) {
+		repo, _, mock := createCartWeightSetup()
+
+		mock.ExpectQuery(`SELECT .* FROM cart_anomalies WHERE resolved_at IS NULL ORDER BY id DESC`).
+			WillReturnRows(sqlmock.NewRows(anomalyColumns).AddRow(3, "2", "weight_mismatch", 774, 1161, now, now, nil))
+
+		anomalies, err := repo.ListAnomalies(true)
+		require.NoError(t, err)
+		assert.Equal(t, []*models.CartAnomaly{{
+			ID:            3,
+			CartID:        "2",
+			Kind:          models.AnomalyWeightMismatch,
+			ExpectedGrams: 774,
+			MeasuredGrams: 1161,
+			Since:         now,
+			CreatedAt:     now,
+		}}, anomalies)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,5 +1,6 @@
 import requests
 from enum import Enum
+from typing import List, Optional
 
 
 class UpdateCartRequestAction(Enum):
@@ -21,10 +22,98 @@ class UpdateCartRequest:
         }
 
 
+class Detection:
+    def __init__(self, label: str, count: int, confidence: float):
+        self.label = label
+        # Negative when units were taken out of the cart
+        self.count = count
+        self.confidence = confidence
+
+    def to_json(self):
+        return {
+            "label": self.label,
+            "count": self.count,
+            "confidence": self.confidence,
+        }
+
+
+class DetectionsRequest:
+    def __init__(
+        self, detections: List[Detection], weight_before: float, weight_after: float
+    ):
+        self.__detections = detections
+        self.__weight_before = weight_before
+        self.__weight_after = weight_after
+
+    def to_json(self):
+        return {
+            "detections": [detection.to_json() for detection in self.__detections],
+            "weight_before": self.__weight_before,
+            "weight_after": self.__weight_after,
+        }
+
+
+class Heartbeat:
+    """Status of the components of the device, None for the ones it could not read"""
+
+    def __init__(
+        self,
+        camera_fps: Optional[float],
+        detector_latency_ms: Optional[float],
+        scale_grams: Optional[float],
+        battery_percent: Optional[float] = None,
+    ):
+        self.__camera_fps = camera_fps
+        self.__detector_latency_ms = detector_latency_ms
+        self.__scale_grams = scale_grams
+        self.__battery_percent = battery_percent
+
+    def to_json(self):
+        return {
+            "camera_fps": self.__camera_fps,
+            "detector_latency_ms": self.__detector_latency_ms,
+            "scale_grams": self.__scale_grams,
+            "battery_percent": self.__battery_percent,
+        }
+
+
+class DeviceCredentials:
+    def __init__(self, device_id: str, secret: str):
+        self.device_id = device_id
+        self.secret = secret
+
+    def to_headers(self):
+        return {"X-Device-ID": self.device_id, "X-Device-Secret": self.secret}
+
+
 class CartServiceClient:
     def __init__(self, base_url="http://localhost:3333"):
         self.__base_url = base_url
 
+    def get_device_cart(self, credentials: DeviceCredentials) -> Optional[str]:
+        """Returns the id of the cart the device is bound to, None when the
+        device waits for the next shopper"""
+        url = f"{self.__base_url}/devices/me/cart"
+        response = requests.get(url, headers=credentials.to_headers())
+        if response.status_code == 404:
+            return None
+        response.raise_for_status()
+        return response.json()["id"]
+
     def execute(self, cart_id: str, request: UpdateCartRequest):
         url = f"{self.__base_url}/cart/{cart_id}/products"
         return requests.post(url, json=request.to_json())
+
+    def post_heartbeat(self, credentials: DeviceCredentials, heartbeat: Heartbeat):
+        url = f"{self.__base_url}/devices/me/heartbeat"
+        return requests.post(
+            url, json=heartbeat.to_json(), headers=credentials.to_headers()
+        )
+
+    def post_detections(self, cart_id: str, request: DetectionsRequest):
+        url = f"{self.__base_url}/cart/{cart_id}/detections"
+        return requests.post(url, json=request.to_json())
+
+    def post_weight(self, cart_id: str, grams: float):
+        url = f"{self.__base_url}/cart/{cart_id}/weight"
+        return requests.post(url, json={"grams": grams})
@@ -0,0 +1,43 @@
+import time
+from threading import Lock
+from typing import Optional, Tuple
+
+
+class FrameStats:
+    """Keeps the frame rate of the camera and how long the detector takes for a
+    frame, smoothed over the last frames, for the heartbeats of the device"""
+
+    def __init__(self, smoothing: float = 0.1, stale_after: float = 2.0):
+        self.__smoothing = smoothing
+        # Past this many seconds without frames the camera is not reporting
+        self.__stale_after = stale_after
+        self.__lock = Lock()
+        self.__last_frame: Optional[float] = None
+        self.__fps: Optional[float] = None
+        self.__latency_ms: Optional[float] = None
+
+    def frame(self, detector_seconds: float):
+        now = time.monotonic()
+        with self.__lock:
+            if self.__last_frame is not None and now > self.__last_frame:
+                self.__fps = self.__smooth(self.__fps, 1 / (now - self.__last_frame))
+            self.__latency_ms = self.__smooth(
+                self.__latency_ms, detector_seconds * 1000
+            )
+            self.__last_frame = now
+
+    def snapshot(self) -> Tuple[Optional[float], Optional[float]]:
+        """Returns the frame rate and the detector latency in milliseconds, None
+        when no frame was seen lately"""
+        with self.__lock:
+            if (
+                self.__last_frame is None
+                or time.monotonic() - self.__last_frame > self.__stale_after
+            ):
+                return None, None
+            return self.__fps, self.__latency_ms
+
+    def __smooth(self, current: Optional[float], value: float) -> float:
+        if current is None:
+            return value
+        return current + self.__smoothing * (value - current)
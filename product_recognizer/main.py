@@ -1,4 +1,5 @@
 #! /usr/bin/python3
+import os
 import sys
 import time
 import argparse
@@ -10,8 +11,9 @@ from efficientdet_tpu import (
     EfficientDetFramePreprocessor,
 )
 from frame_object_filter import FrameObjectFilter
+from frame_stats import FrameStats
 from weight_sensor import WeightSensor
-from cart_service import CartServiceClient
+from cart_service import CartServiceClient, DeviceCredentials
 from video_window import VideoWindow
 from product_recognizer import ProductRecognizer
 from queue import Queue
@@ -41,13 +43,18 @@ def run(args):
     log.info("done taring")
 
     frame_objects_queue: "Queue[List[FrameObject]]" = Queue()
+    frame_stats = FrameStats()
 
     product_recognizer = ProductRecognizer(
         queue=frame_objects_queue,
         weight_sensor=weight_sensor,
         logger=log,
         cart_service_client=cart_service_client,
-        cart_id=args.cart_id,
+        cart_id=None if args.device_id else args.cart_id,
+        device=DeviceCredentials(args.device_id, args.device_secret)
+        if args.device_id
+        else None,
+        frame_stats=frame_stats,
     )
 
     object_filter = FrameObjectFilter(confidence_thresold=args.confidence_threshold)
@@ -65,7 +72,9 @@ def run(args):
 
             input, _ = preprocessor.process(frame)
 
+            detector_start = time.monotonic()
             objects = detector.get_objects(input)
+            frame_stats.frame(time.monotonic() - detector_start)
 
             filtered_objects = object_filter.filter(objects)
 
@@ -86,7 +95,24 @@ def run(args):
 
 if __name__ == "__main__":
     parser = argparse.ArgumentParser("zCart Product Recognizer Application")
-    parser.add_argument("--cart_id", dest="cart_id", default="2", help="Cart ID")
+    parser.add_argument(
+        "--cart_id",
+        dest="cart_id",
+        default="2",
+        help="Cart ID, only used when not running as a registered device",
+    )
+    parser.add_argument(
+        "--device_id",
+        dest="device_id",
+        default=os.environ.get("ZCART_DEVICE_ID"),
+        help="Device ID, the cart being the one the device is bound to",
+    )
+    parser.add_argument(
+        "--device_secret",
+        dest="device_secret",
+        default=os.environ.get("ZCART_DEVICE_SECRET"),
+        help="Device secret, better given in ZCART_DEVICE_SECRET",
+    )
     parser.add_argument(
         "--model_file",
         dest="model_file",
@@ -1,4 +0,0 @@
-class Product:
-    def __init__(self, product_id: str, weight_in_grams: float):
-        self.product_id = product_id
-        self.weight_in_grams = weight_in_grams
@@ -1,37 +1,64 @@
+import time
 from queue import Queue, Empty
-from typing import Dict, List
+from typing import Dict, List, Optional
 from collections import defaultdict
 from threading import Thread
 from logger import Logger
 
-from cart_service import CartServiceClient, UpdateCartRequest, UpdateCartRequestAction
+from cart_service import (
+    CartServiceClient,
+    DeviceCredentials,
+    Detection,
+    DetectionsRequest,
+    Heartbeat,
+)
 from frame_object import FrameObject
+from frame_stats import FrameStats
 from weight_sensor import WeightSensor
-from product_catalog import ProductCatalog, StubProductCatalog
 
 
 class ProductRecognizer:
+    """Reports the objects that show up in or leave the cart to the cart service,
+    which decides along with the weight readings whether they change the cart"""
+
     def __init__(
         self,
         queue: "Queue[List[FrameObject]]",
         weight_sensor: WeightSensor,
         logger: Logger,
         cart_service_client: CartServiceClient,
-        cart_id: str,
-        catalog: ProductCatalog = StubProductCatalog(),
-        weight_tolerance: float = 0.5,
+        cart_id: Optional[str],
+        device: Optional[DeviceCredentials] = None,
+        frame_stats: Optional[FrameStats] = None,
+        weight_report_interval: float = 5.0,
+        cart_refresh_interval: float = 2.0,
+        heartbeat_interval: float = 10.0,
     ):
         self.queue = queue
         self.weight_sensor = weight_sensor
         self.cart_service_client = cart_service_client
         self.log = logger
-        self.catalog = catalog
 
-        self.weight_tolerance = weight_tolerance
+        # A device operates on the cart it is bound to, which changes between shoppers
         self.cart_id = cart_id
+        self.device = device
+        self.cart_refresh_interval = cart_refresh_interval
+        self.last_cart_refresh = 0.0
+
+        # Lets the cart service know the camera, detector and scale are working
+        self.frame_stats = frame_stats
+        self.heartbeat_interval = heartbeat_interval
+        self.last_heartbeat = 0.0
 
         self.last_frame_objects = {}
+        self.last_confidences = {}
         self.last_weight_reading = 0.0
+        # Labels of no product in the catalog, not worth reporting again
+        self.unknown_labels = set()
+
+        # The cart service compares the readings with what the cart should weigh
+        self.weight_report_interval = weight_report_interval
+        self.last_weight_report = 0.0
 
     def start(self):
         self.__stopped = False
@@ -55,6 +82,10 @@ class ProductRecognizer:
                     self.log.info("empty diff")
                     continue
 
+                if self.cart_id is None:
+                    self.log.info("not bound to a cart, ignoring diff")
+                    continue
+
                 self.log.debug(f"object diff in frame: {frame_diff}")
 
                 weight_reading = self.weight_sensor.get_reading(samples=5)
@@ -62,13 +93,19 @@ class ProductRecognizer:
                     f"weight: {weight_reading} - last weight: {self.last_weight_reading}"
                 )
 
-                for label, count in frame_diff.items():
-                    if not self.__valid_weight_difference(label, count, weight_reading):
-                        self.log.info("ignoring, not valid weight difference")
-                        continue
+                # Objects that left the frame keep their last confidence
+                self.last_confidences.update(self.__build_confidence_dict(objects))
+                detections = [
+                    Detection(label, count, self.last_confidences.get(label, 0.0))
+                    for label, count in frame_diff.items()
+                ]
 
-                    self.__call_cart_service(label, count)
-                    self.last_weight_reading = weight_reading
+                accepted = self.__post_detections(detections, weight_reading)
+                if not accepted:
+                    continue
+
+                self.last_weight_reading = weight_reading
+                for label in accepted:
                     self.last_frame_objects[label] = current_frame_objects[label]
                     if self.last_frame_objects[label] == 0:
                         del self.last_frame_objects[label]
@@ -76,69 +113,115 @@ class ProductRecognizer:
             except Empty:
                 if self.__stopped:
                     return
+                self.__refresh_cart()
+                self.__report_weight()
+                self.__send_heartbeat()
 
-    def __valid_weight_difference(self, label: str, count: int, reading: float) -> bool:
-        if reading > self.last_weight_reading and count < 0:
-            self.log.debug(
-                f"weight increased but count is negative ({count}), ignoring"
-            )
-            return False
+    def __send_heartbeat(self):
+        if self.device is None:
+            return
 
-        if reading < self.last_weight_reading and count > 0:
-            self.log.debug(
-                f"weight decreased but count is positive ({count}), ignoring"
-            )
-            return False
+        now = time.monotonic()
+        if now - self.last_heartbeat < self.heartbeat_interval:
+            return
+        self.last_heartbeat = now
 
-        product = self.catalog.get_product(label)
-        if not product:
-            # TODO: Review this
-            return False
+        camera_fps, detector_latency_ms = (
+            self.frame_stats.snapshot() if self.frame_stats else (None, None)
+        )
+        try:
+            scale_grams = self.weight_sensor.get_reading(samples=5)
+        except:
+            self.log.error("exception while reading the scale")
+            scale_grams = None
+
+        heartbeat = Heartbeat(camera_fps, detector_latency_ms, scale_grams)
+        try:
+            response = self.cart_service_client.post_heartbeat(self.device, heartbeat)
+            if response.status_code != 200:
+                self.log.error(f"heartbeat got status {response.status_code}")
+        except:
+            self.log.error("exception while sending heartbeat")
 
-        weight_difference = reading - self.last_weight_reading
-        expected_difference = count * product.weight_in_grams
+    def __refresh_cart(self):
+        """Asks the cart service which cart the device is bound to, starting over
+        whenever it is bound to another one"""
+        if self.device is None:
+            return
 
-        self.log.debug(
-            f"expected_difference: {expected_difference}, actual: {weight_difference}"
-        )
+        now = time.monotonic()
+        if now - self.last_cart_refresh < self.cart_refresh_interval:
+            return
+        self.last_cart_refresh = now
 
-        return self.__is_weight_diff_withing_tolerance(
-            expected_difference, weight_difference
-        )
+        try:
+            cart_id = self.cart_service_client.get_device_cart(self.device)
+        except:
+            self.log.error("exception while asking for the cart of the device")
+            return
+
+        if cart_id == self.cart_id:
+            return
 
-    def __is_weight_diff_withing_tolerance(
-        self, expected: float, actual: float
-    ) -> bool:
-        # Use absolute value since with negative values the comparisons would invert
-        expected = abs(expected)
-        actual = abs(actual)
-        return (1 - self.weight_tolerance) * expected <= actual and actual <= (
-            1 + self.weight_tolerance
-        ) * expected
-
-    def __call_cart_service(self, label: str, count: int):
+        self.log.info(f"now operating on cart {cart_id}")
+        self.cart_id = cart_id
+        self.last_frame_objects = {}
+        self.last_confidences = {}
+        self.last_weight_reading = self.weight_sensor.get_reading(samples=5)
+        self.unknown_labels = set()
+
+    def __post_detections(
+        self, detections: List[Detection], weight_reading: float
+    ) -> Optional[List[str]]:
+        """Returns the labels of the detections the cart service accepted or asked
+        the shopper about, which are not reported again"""
         self.log.info("will call cart service")
 
-        request = self.__build_cart_service_request(label, count)
+        request = DetectionsRequest(
+            detections, self.last_weight_reading, weight_reading
+        )
 
         try:
-            response = self.cart_service_client.execute(self.cart_id, request)
+            response = self.cart_service_client.post_detections(self.cart_id, request)
             self.log.info(f"got status {response.status_code}")
+            if response.status_code != 200:
+                return None
+
+            accepted = []
+            for decision in response.json()["decisions"]:
+                self.log.debug(f"{decision['label']}: {decision['verdict']}")
+                if decision["verdict"] == "accepted":
+                    accepted.append(decision["label"])
+                elif decision["verdict"] == "unknown_label":
+                    self.unknown_labels.add(decision["label"])
+            for item in response.json()["pending"]:
+                self.log.info(f"{item['label']} is waiting for the shopper")
+                accepted.append(item["label"])
+            return accepted
         except:
             self.log.error("exception while calling cart service")
+            return None
 
-    def __build_cart_service_request(self, label: str, count: int):
-        product = self.catalog.get_product(label)
-        if not product:
-            raise Exception(f"product not found for label {label}")
-
-        return UpdateCartRequest(
-            product.product_id,
-            abs(count),
-            UpdateCartRequestAction.ADD
-            if count > 0
-            else UpdateCartRequestAction.REMOVE,
-        )
+    def __report_weight(self):
+        """Sends a reading of the scale every interval, read here so that the
+        sensor is never read by two threads at once"""
+        if self.cart_id is None:
+            return
+
+        now = time.monotonic()
+        if now - self.last_weight_report < self.weight_report_interval:
+            return
+        self.last_weight_report = now
+
+        weight_reading = self.weight_sensor.get_reading(samples=5)
+        try:
+            response = self.cart_service_client.post_weight(
+                self.cart_id, weight_reading
+            )
+            if response.status_code != 200:
+                self.log.error(f"weight report got status {response.status_code}")
+        except:
+            self.log.error("exception while reporting weight")
 
     def __build_object_dict(self, objects: List[FrameObject]) -> Dict[str, int]:
         result = defaultdict(int)
@@ -147,6 +230,14 @@ class ProductRecognizer:
             result[object.label] += 1
         return result
 
+    def __build_confidence_dict(self, objects: List[FrameObject]) -> Dict[str, float]:
+        # The least confident object of a label stands for all of them
+        result = {}
+        for object in objects:
+            score = float(object.score)
+            result[object.label] = min(score, result.get(object.label, score))
+        return result
+
     def __get_frame_diff(
         self, current_frame_objects: Dict[str, int], last_frame_objects: Dict[str, int]
     ) -> Dict[str, int]:
@@ -154,13 +245,11 @@ class ProductRecognizer:
 
         for label in current_frame_objects:
             self.log.debug(f"label: {label}")
-            if not self.catalog.get_product(label):
+            if label in self.unknown_labels:
                 continue
             result[label] = current_frame_objects[label]
 
         for label in last_frame_objects:
-            if not self.catalog.get_product(label):
-                continue
             if label in result:
                 # Might be negative
                 result[label] -= last_frame_objects[label]
@@ -8,7 +8,7 @@ import {
     Result,
 } from "antd";
 import { DollarCircleFilled, DownCircleFilled, ShoppingCartOutlined, UpCircleFilled } from "@ant-design/icons";
-import { CartProvider, CartItem } from "src/service/cart_provider";
+import { CartProvider, CartItem, CartTotals, PendingItem } from "src/service/cart_provider";
 import { LoadingSpinner } from "src/components/loading_spinner";
 import "./App.css";
 import { CartItemList } from "./components/cart_item_list";
@@ -19,21 +19,59 @@ export interface Props {
 
 const { Header, Content, Footer } = Layout;
 
+const pendingReasons: Record<PendingItem["reason"], string> = {
+    low_confidence: "we could not tell it apart clearly",
+    weight_mismatch: "the weight did not match",
+    no_weight: "we could not weigh it",
+};
+
 function App(props: Props) {
     const [cartItems, setCartItems] = useState<CartItem[]>([]);
     const [loading, setLoading] = useState(true);
-    const [subtotal, setSubtotal] = useState(0.0);
+    const [totals, setTotals] = useState<CartTotals>();
     const [modalVisible, setModalVisible] = useState(false);
     const [checkedout, setCheckedout] = useState(false);
+    // Oldest first, the shopper answering for one at a time
+    const [pendingItems, setPendingItems] = useState<PendingItem[]>([]);
 
     useEffect(() => {
         if (!loading) return;
-        props.cartProvider.ListCartItems().then((items) => {
-            setCartItems(items);
+        props.cartProvider.GetCart().then((cart) => {
+            setCartItems(cart.items);
+            setTotals(cart.totals);
             setLoading(false);
         });
     }, [props.cartProvider, loading]);
 
+    useEffect(() => {
+        props.cartProvider.GetPendingItems().then(setPendingItems);
+
+        props.cartProvider.OnPendingItem((item) => {
+            setPendingItems((items) => [...items.filter((pending) => pending.id !== item.id), item]);
+        });
+
+        props.cartProvider.OnPendingItemResolved((item) => {
+            setPendingItems((items) => items.filter((pending) => pending.id !== item.id));
+        });
+
+        // The thank you screen stays up until the next shopper taps "Buy Again"
+        props.cartProvider.OnCartChanged(() => {
+            setModalVisible(false);
+            setLoading(true);
+            props.cartProvider.GetPendingItems().then(setPendingItems);
+        });
+    }, [props.cartProvider]);
+
+    const handlePendingItem = useCallback((item: PendingItem, confirmed: boolean) => {
+        setPendingItems((items) => items.filter((pending) => pending.id !== item.id));
+        const answer = confirmed
+            ? props.cartProvider.ConfirmPendingItem(item.id)
+            : props.cartProvider.RejectPendingItem(item.id);
+        answer.catch(() => message.error("Sorry, it was too late to answer for this item"));
+    }, [props.cartProvider]);
+
+    const pendingItem = pendingItems[0];
+
     useEffect(() => {
         props.cartProvider.OnAddProduct((item) => {
             message.info({
@@ -54,21 +92,13 @@ function App(props: Props) {
         });
     }, [props.cartProvider]);
 
-    useEffect(() => {
-        setSubtotal(
-            cartItems.reduce(
-                (total, item) => total + item.price * item.quantity,
-                0.0
-            )
-        );
-    }, [cartItems]);
-
     useEffect(() => {
         if (checkedout) {
             props.cartProvider.Checkout()
             return
         };
         setCartItems([])
+        setTotals(undefined)
         setLoading(true)
     }, [checkedout, props.cartProvider])
 
@@ -118,9 +148,9 @@ function App(props: Props) {
                         Checkout
                     </Button>
                     <span>
-                        Subtotal:{" "}
+                        Total:{" "}
                         <Statistic
-                            value={subtotal}
+                            value={totals?.total ?? 0}
                             prefix="R$"
                             precision={2}
                             decimalSeparator=","
@@ -143,6 +173,24 @@ function App(props: Props) {
             >
                 Do you want to finish your purchase?
             </Modal>
+            <Modal
+                visible={!!pendingItem && !modalVisible}
+                onOk={() => pendingItem && handlePendingItem(pendingItem, true)}
+                onCancel={() => pendingItem && handlePendingItem(pendingItem, false)}
+                okText={"Yes"}
+                cancelText={"No"}
+                bodyStyle={{ fontSize: "1.25rem" }}
+                okButtonProps={{ style: { fontSize: "1.25rem", paddingBottom: "35px" } }}
+                cancelButtonProps={{ style: { fontSize: "1.25rem", paddingBottom: "35px" } }}
+                closable={false}
+            >
+                {pendingItem && (
+                    <span>
+                        Did you {pendingItem.quantity > 0 ? "add" : "remove"} {Math.abs(pendingItem.quantity)}x{" "}
+                        <b>{pendingItem.title}</b>? We are asking because {pendingReasons[pendingItem.reason]}.
+                    </span>
+                )}
+            </Modal>
         </Layout>
     );
 }
@@ -10,8 +10,19 @@ const root = ReactDOM.createRoot(
   document.getElementById("root") as HTMLElement
 );
 
+// Registered carts show the cart they are bound to, others a fixed one
+const device = process.env.REACT_APP_DEVICE_ID
+  ? {
+      id: process.env.REACT_APP_DEVICE_ID,
+      secret: process.env.REACT_APP_DEVICE_SECRET ?? "",
+    }
+  : undefined;
+
 const provider = process.env.REACT_APP_CART_SERVICE_URL
-  ? new CartServiceCartProvider(process.env.REACT_APP_CART_SERVICE_URL)
+  ? new CartServiceCartProvider(
+      process.env.REACT_APP_CART_SERVICE_URL,
+      device ?? process.env.REACT_APP_CART_ID
+    )
   : new StubCartProvider();
 
 root.render(
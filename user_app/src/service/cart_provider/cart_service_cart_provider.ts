@@ -1,9 +1,28 @@
 import axios, { AxiosInstance } from "axios";
-import { CartProvider, CartItem, ItemHandler } from "src/service/cart_provider";
+import {
+  Cart,
+  CartChangedHandler,
+  CartProvider,
+  CartItem,
+  ItemHandler,
+  PendingItem,
+  PendingItemHandler,
+} from "src/service/cart_provider";
+
+// Amounts are integer minor units, so 599 is 5.99
+interface CartServiceMoney {
+  amount: number;
+  currency: string;
+}
 
 interface CartServiceResponse {
   id: string;
+  version: number;
   products: CartServiceCartProduct[];
+  item_count: number;
+  subtotal: CartServiceMoney;
+  discount: CartServiceMoney;
+  total: CartServiceMoney;
 }
 
 interface CartServiceCartProduct {
@@ -13,44 +32,122 @@ interface CartServiceCartProduct {
   product: {
     id: string;
     name: string;
-    price: number;
+    price: CartServiceMoney;
     image_url?: string;
     description?: string;
   };
 }
 
+interface CartServicePendingItem {
+  id: string;
+  name: string;
+  quantity: number;
+  reason: PendingItem["reason"];
+  status: "pending" | "confirmed" | "rejected" | "expired";
+  expires_at: string;
+}
+
 enum CartEvent {
   ProductAdded = "product_added",
   ProductRemoved = "product_removed",
+  PendingItemAdded = "pending_item_added",
+  PendingItemResolved = "pending_item_resolved",
+  CartSnapshot = "cart_snapshot",
 }
 
 interface CartEventNotification {
   event: CartEvent;
-  cart_product: CartServiceCartProduct;
+  cart_product: CartServiceCartProduct | null;
+  sequence: number;
+  line_quantity: number;
+  cart: CartServiceResponse;
+  pending_item?: CartServicePendingItem;
 }
 
+// Credentials of the physical cart the app runs on, registered with the cart service
+export interface DeviceCredentials {
+  id: string;
+  secret: string;
+}
+
+// How often a device asks which cart it is bound to
+const deviceCartInterval = 2000;
+
 export class CartServiceCartProvider implements CartProvider {
   private readonly axios: AxiosInstance;
-  private readonly cartId: string;
+  // Unset while the device waits for the next shopper
+  private cartId?: string;
   private readonly baseUrl: string;
   private websocket?: WebSocket;
+  private lastSequence?: number;
+  // Latest cart received through the websocket
+  private cart?: CartServiceResponse;
   private addProductHandler?: ItemHandler;
   private removeProductHandler?: ItemHandler;
+  private pendingItemHandler?: PendingItemHandler;
+  private pendingItemResolvedHandler?: PendingItemHandler;
+  private cartChangedHandler?: CartChangedHandler;
 
-  constructor(url: string, cartId: string = "2") {
-    this.cartId = cartId;
+  // A device operates on the cart it is bound to instead of a fixed one
+  constructor(url: string, cart: string | DeviceCredentials = "2") {
     this.baseUrl = url;
     this.axios = axios.create({
       baseURL: url,
     });
-    this.setupWebsocket()
+    if (typeof cart === "string") {
+      this.cartId = cart;
+      this.setupWebsocket();
+    } else {
+      this.followDeviceCart(cart);
+    }
+  }
+
+  private followDeviceCart(device: DeviceCredentials) {
+    const refresh = async () => {
+      let cartId: string | undefined;
+      try {
+        const response = await this.axios.get("/devices/me/cart", {
+          headers: { "X-Device-ID": device.id, "X-Device-Secret": device.secret },
+        });
+        cartId = (response.data as CartServiceResponse).id;
+      } catch (error) {
+        if (!axios.isAxiosError(error) || error.response?.status !== 404) {
+          console.log("failed to get the cart of the device", error);
+          return;
+        }
+      }
+      if (cartId !== this.cartId) {
+        this.changeCart(cartId);
+      }
+    };
+    refresh();
+    setInterval(refresh, deviceCartInterval);
+  }
+
+  private changeCart(cartId?: string) {
+    console.log(`now showing cart ${cartId}`);
+    this.cartId = cartId;
+    this.cart = undefined;
+    this.lastSequence = undefined;
+    this.setupWebsocket();
+    this.cartChangedHandler && this.cartChangedHandler();
   }
 
   private setupWebsocket() {
     if (this.websocket) {
-      this.websocket.close()
+      // Closed on purpose, so it must not reconnect
+      this.websocket.onclose = null;
+      this.websocket.close();
+      this.websocket = undefined;
+    }
+    if (!this.cartId) {
+      return;
+    }
+    let webSocketUrl = `${this.baseUrl}/cart/${this.cartId}/ws`.replace("http", "ws");
+    if (this.lastSequence !== undefined) {
+      // Ask for whatever was published while we were disconnected
+      webSocketUrl += `?since=${this.lastSequence}`;
     }
-    const webSocketUrl = `${this.baseUrl}/cart/${this.cartId}/ws`.replace("http", "ws");
     this.websocket = new WebSocket(webSocketUrl);
     this.websocket.onopen = (_event) => {
       console.log("opening websocket");
@@ -61,24 +158,80 @@ export class CartServiceCartProvider implements CartProvider {
     };
     this.websocket.onmessage = (event) => {
       const payload = JSON.parse(event.data) as CartEventNotification;
+      if (payload.sequence) {
+        this.lastSequence = payload.sequence;
+      }
+      if (payload.cart && (!this.cart || payload.cart.version >= this.cart.version)) {
+        this.cart = payload.cart;
+      }
+      if (payload.pending_item) {
+        const item = this.pendingItemAdapter(payload.pending_item);
+        if (payload.event === CartEvent.PendingItemAdded) {
+          this.pendingItemHandler && this.pendingItemHandler(item);
+        } else if (payload.event === CartEvent.PendingItemResolved) {
+          this.pendingItemResolvedHandler && this.pendingItemResolvedHandler(item);
+        }
+      }
+      if (!payload.cart_product) {
+        return;
+      }
       if (payload.event === CartEvent.ProductAdded) {
         this.addProductHandler &&
           this.addProductHandler(this.adapter(payload.cart_product));
-      } else {
+      } else if (payload.event === CartEvent.ProductRemoved) {
         this.removeProductHandler &&
           this.removeProductHandler(this.adapter(payload.cart_product));
       }
     };
   }
 
-  async ListCartItems(): Promise<CartItem[]> {
-    const items = (await this.axios.get(`/cart/${this.cartId}`))
-      .data as CartServiceResponse;
-    return items.products.map(this.adapter);
+  async GetCart(): Promise<Cart> {
+    if (!this.cartId) {
+      return {
+        items: [],
+        totals: { item_count: 0, subtotal: 0, discount: 0, total: 0 },
+      };
+    }
+    if (!this.cart) {
+      this.cart = (await this.axios.get(`/cart/${this.cartId}`))
+        .data as CartServiceResponse;
+    }
+    return {
+      items: this.cart.products.map(this.adapter),
+      totals: {
+        item_count: this.cart.item_count,
+        subtotal: toDecimal(this.cart.subtotal),
+        discount: toDecimal(this.cart.discount),
+        total: toDecimal(this.cart.total),
+      },
+    };
+  }
+
+  async GetPendingItems(): Promise<PendingItem[]> {
+    if (!this.cartId) {
+      return [];
+    }
+    const items = (await this.axios.get(`/cart/${this.cartId}/pending`))
+      .data as CartServicePendingItem[];
+    return items.map(this.pendingItemAdapter);
+  }
+
+  async ConfirmPendingItem(id: string) {
+    await this.axios.post(`/cart/${this.cartId}/pending/${id}/confirm`);
+  }
+
+  async RejectPendingItem(id: string) {
+    await this.axios.post(`/cart/${this.cartId}/pending/${id}/reject`);
   }
 
   async Checkout() {
-    await this.axios.post(`/cart/${this.cartId}/checkout`)
+    // The service only places the order once the payment goes through
+    await this.axios.post(
+      `/cart/${this.cartId}/checkout`,
+      { payment_method: "card" },
+      { headers: { "Idempotency-Key": crypto.randomUUID() } }
+    );
+    this.cart = undefined;
   }
 
   OnAddProduct(handler: ItemHandler) {
@@ -89,13 +242,39 @@ export class CartServiceCartProvider implements CartProvider {
     this.removeProductHandler = handler;
   }
 
+  OnPendingItem(handler: PendingItemHandler) {
+    this.pendingItemHandler = handler;
+  }
+
+  OnPendingItemResolved(handler: PendingItemHandler) {
+    this.pendingItemResolvedHandler = handler;
+  }
+
+  OnCartChanged(handler: CartChangedHandler) {
+    this.cartChangedHandler = handler;
+  }
+
   private adapter(cartProduct: CartServiceCartProduct): CartItem {
     return {
       quantity: cartProduct.quantity,
       title: cartProduct.product.name,
-      price: cartProduct.product.price,
+      price: toDecimal(cartProduct.product.price),
       image_url: cartProduct.product.image_url,
       description: cartProduct.product.description,
     };
   }
+
+  private pendingItemAdapter(item: CartServicePendingItem): PendingItem {
+    return {
+      id: item.id,
+      title: item.name,
+      quantity: item.quantity,
+      reason: item.reason,
+      expires_at: new Date(item.expires_at),
+    };
+  }
+}
+
+function toDecimal(money: CartServiceMoney): number {
+  return money.amount / 100;
 }
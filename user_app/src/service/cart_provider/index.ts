@@ -9,11 +9,44 @@ export interface CartItem extends Item {
   quantity: number;
 }
 
+// Amounts are computed by the cart service, never by the app
+export interface CartTotals {
+  item_count: number;
+  subtotal: number;
+  discount: number;
+  total: number;
+}
+
+export interface Cart {
+  items: CartItem[];
+  totals: CartTotals;
+}
+
+// A change the cart was not sure about, waiting for the shopper to confirm or reject it
+export interface PendingItem {
+  id: string;
+  title: string;
+  // Negative when units were taken out of the cart
+  quantity: number;
+  reason: "low_confidence" | "weight_mismatch" | "no_weight";
+  expires_at: Date;
+}
+
 export interface CartProvider {
-  ListCartItems(): Promise<CartItem[]>;
+  GetCart(): Promise<Cart>;
+  GetPendingItems(): Promise<PendingItem[]>;
   OnAddProduct(handler: ItemHandler): void;
   OnRemoveProduct(handler: ItemHandler): void;
+  OnPendingItem(handler: PendingItemHandler): void;
+  // Called once the item was confirmed, rejected or expired
+  OnPendingItemResolved(handler: PendingItemHandler): void;
+  // Called when the cart is replaced by another one, as when the device is bound to the next shopper
+  OnCartChanged(handler: CartChangedHandler): void;
+  ConfirmPendingItem(id: string): Promise<void>;
+  RejectPendingItem(id: string): Promise<void>;
   Checkout(): Promise<void>;
 }
 
 export type ItemHandler = (item: CartItem) => void;
+export type PendingItemHandler = (item: PendingItem) => void;
+export type CartChangedHandler = () => void;
@@ -1,8 +1,12 @@
 import {
+  Cart,
+  CartChangedHandler,
   CartProvider,
   Item,
   CartItem,
   ItemHandler,
+  PendingItem,
+  PendingItemHandler,
 } from "src/service/cart_provider";
 
 const IMAGE_BASE_URL = "https://zcart-test-images.s3.amazonaws.com";
@@ -95,13 +99,34 @@ export class StubCartProvider implements CartProvider {
     }, this.interval);
   }
 
-  async ListCartItems(): Promise<CartItem[]> {
+  async GetCart(): Promise<Cart> {
     await new Promise((resolve) => {
       setTimeout(resolve, this.delay);
     });
-    return this.cartItems;
+    const subtotal = this.cartItems.reduce(
+      (total, item) => total + item.price * item.quantity,
+      0.0
+    );
+    return {
+      items: this.cartItems,
+      totals: {
+        item_count: this.cartItems.reduce((count, item) => count + item.quantity, 0),
+        subtotal: subtotal,
+        discount: 0,
+        total: subtotal,
+      },
+    };
+  }
+
+  // The stub is always sure about what it adds to the cart
+  async GetPendingItems(): Promise<PendingItem[]> {
+    return [];
   }
 
+  async ConfirmPendingItem(_id: string) {}
+
+  async RejectPendingItem(_id: string) {}
+
   async Checkout() {
     this.cartItems = []
   }
@@ -114,6 +139,12 @@ export class StubCartProvider implements CartProvider {
     this.removeHandler = handler;
   }
 
+  OnPendingItem(_handler: PendingItemHandler) {}
+
+  OnPendingItemResolved(_handler: PendingItemHandler) {}
+
+  OnCartChanged(_handler: CartChangedHandler) {}
+
   AddItem() {
     const randomItem = this.ITEMS[this.randomIndex()];
 